@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"time"
+)
+
+// UserStatusClaim the claim holding the Cognito status of the user, added by the AdminGetUserEnricher
+const UserStatusClaim = "cognito:user_status"
+
+// User a user of the user pool, as returned by the AdminGetUser API
+type User struct {
+	Username   string
+	Status     string
+	Enabled    bool
+	Attributes map[string]string
+}
+
+// UserGetter fetches the users of the user pool. It is typically implemented with the AdminGetUser API of the
+// AWS SDK, which requires AWS credentials, returning ErrUserNotFound for the UserNotFoundException.
+type UserGetter interface {
+	AdminGetUser(ctx context.Context, userPoolID, username string) (*User, error)
+}
+
+// UserGetterFunc adapter to use an ordinary function as a UserGetter
+type UserGetterFunc func(ctx context.Context, userPoolID, username string) (*User, error)
+
+// AdminGetUser calls f(ctx, userPoolID, username)
+func (f UserGetterFunc) AdminGetUser(ctx context.Context, userPoolID, username string) (*User, error) {
+	return f(ctx, userPoolID, username)
+}
+
+// AdminGetUserEnricher an Enricher merging the attributes of the user, and its status under UserStatusClaim,
+// fetched with the AdminGetUser API from the user pool of the issuer of the token and cached per issuer and username
+// for the given time to live. The tokens of the disabled or deleted users are rejected. The machine to machine
+// tokens are not enriched.
+func (mw *AuthMiddleware) AdminGetUserEnricher(users UserGetter, ttl time.Duration) Enricher {
+	cache := &ttlCache{}
+	return EnricherFunc(func(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error) {
+		username := NewClaims(claims).Username
+		if username == "" {
+			return nil, nil
+		}
+		iss, _ := claims["iss"].(string)
+		pool := mw.issuerPool(iss)
+		if pool == nil {
+			return nil, tokenError(ErrBadIssuer, fmt.Errorf("the issuer %q is not one of the trusted user pools", iss))
+		}
+		// the usernames are unique within a user pool only
+		key := iss + "\x00" + username
+		cached, ok := cache.get(key)
+		if !ok {
+			user, err := users.AdminGetUser(ctx, pool.UserPoolID, username)
+			if errors.Is(err, ErrUserNotFound) {
+				return nil, tokenError(ErrUserNotFound, fmt.Errorf("user %s not found", username))
+			}
+			if err != nil {
+				return nil, err
+			}
+			cached = user
+			cache.set(key, user, mw.now().Add(ttl))
+		}
+
+		user := cached.(*User)
+		if !user.Enabled {
+			return nil, tokenError(ErrUserDisabled, fmt.Errorf("user %s is disabled", username))
+		}
+		additional := jwtgo.MapClaims{UserStatusClaim: user.Status}
+		for name, value := range user.Attributes {
+			additional[name] = value
+		}
+		return additional, nil
+	})
+}
@@ -0,0 +1,60 @@
+package jwt
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_AdminGetUserEnricher(t *testing.T) {
+	t.Logf("Given the users of the pool fetched with AdminGetUser")
+	{
+		calls := map[string]int{}
+		users := UserGetterFunc(func(ctx context.Context, userPoolID, username string) (*User, error) {
+			assert.Equal(t, TestUserPoolID, userPoolID)
+			calls[username]++
+			switch username {
+			case "jdoe":
+				return &User{Username: username, Status: "CONFIRMED", Enabled: true, Attributes: map[string]string{"custom:tenant": "acme"}}, nil
+			case "disabled":
+				return &User{Username: username, Status: "CONFIRMED", Enabled: false}, nil
+			}
+			return nil, ErrUserNotFound
+		})
+		mw := newTestMiddleware()
+		mw.Enrichers = []Enricher{mw.AdminGetUserEnricher(users, time.Minute)}
+		router := authzHandler(mw, mw.RequirePolicy(`claims["custom:tenant"] == "acme" && claims["cognito:user_status"] == "CONFIRMED"`))
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		assert.Equal(t, 1, calls["jdoe"])
+
+		disabled := testClaims()
+		disabled["username"] = "disabled"
+		w := performRequest(router, "GET", "/orders", signToken(disabled))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "user_disabled")
+
+		deleted := testClaims()
+		deleted["username"] = "deleted"
+		w = performRequest(router, "GET", "/orders", signToken(deleted))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "user_not_found")
+	}
+
+	t.Logf("Given users of the same username in two user pools")
+	{
+		users := UserGetterFunc(func(ctx context.Context, userPoolID, username string) (*User, error) {
+			return &User{Username: username, Status: "CONFIRMED", Enabled: true, Attributes: map[string]string{"custom:tenant": userPoolID}}, nil
+		})
+		mw := &AuthMiddleware{UserPools: []*AuthMiddleware{newTestMiddleware(), otherPool()}}
+		mw.Enrichers = []Enricher{mw.AdminGetUserEnricher(users, time.Minute)}
+		router := authzHandler(mw, mw.RequirePolicy(`claims["custom:tenant"] == "`+otherUserPoolID+`"`))
+
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", otherPoolToken(testClaims())).Code,
+			"the user is fetched from the pool of the issuer, not the one cached for the other pool")
+	}
+}
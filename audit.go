@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+const (
+
+	// AuditAuthenticated the outcome of a request presenting a valid token
+	AuditAuthenticated = "authenticated"
+
+	// AuditUnauthenticated the outcome of a request rejected with 401 Unauthorized
+	AuditUnauthenticated = "unauthenticated"
+
+	// AuditForbidden the outcome of an authenticated request rejected with 403 Forbidden
+	AuditForbidden = "forbidden"
+
+	// AuditLockedOut the outcome of the request whose failure locked out the principal of its token, see Lockout.
+	// The event carries the sub and client_id of the rejected token.
+	AuditLockedOut = "locked_out"
+
+	// AuditQuotaExceeded the outcome of an authenticated request rejected with 429 Too Many Requests, its principal
+	// having exceeded the Quota of its tenant
+	AuditQuotaExceeded = "quota_exceeded"
+)
+
+// AuditEvent a security audit record of the authentication of a request, meant to be shipped to SIEM pipelines
+type AuditEvent struct {
+
+	// Timestamp the time of the event
+	Timestamp time.Time
+
+	// Method the request method
+	Method string
+
+	// Route the gin route pattern
+	Route string
+
+	// Subject the sub of the caller, empty for unauthenticated requests
+	Subject string `json:",omitempty"`
+
+	// ClientID the app client of the token, empty for unauthenticated requests
+	ClientID string `json:",omitempty"`
+
+	// IP the client IP of the request
+	IP string
+
+	// RequestID the correlation ID of the request, see AuthMiddleware.RequestID
+	RequestID string `json:",omitempty"`
+
+	// ErrorID the ID of the failure of a rejected request, as found in the error response and the logs
+	ErrorID string `json:",omitempty"`
+
+	// Outcome one of AuditAuthenticated, AuditUnauthenticated, AuditForbidden, AuditLockedOut or AuditQuotaExceeded
+	Outcome string
+
+	// Reason why the request has been rejected, e.g. expired or the denial message
+	Reason string `json:",omitempty"`
+}
+
+// AuditSink receives the audit events of the middleware
+type AuditSink interface {
+	Audit(AuditEvent)
+}
+
+// AuditSinkFunc adapter to use an ordinary function as an AuditSink
+type AuditSinkFunc func(AuditEvent)
+
+// Audit calls f(e)
+func (f AuditSinkFunc) Audit(e AuditEvent) {
+	f(e)
+}
+
+// AuditChannel returns an AuditSink sending the events to ch. Events are dropped when the channel is full,
+// so that a slow consumer never holds up the requests.
+func AuditChannel(ch chan<- AuditEvent) AuditSink {
+	return AuditSinkFunc(func(e AuditEvent) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+}
+
+// audit hands over the audit event of the request to the sink, principal is nil for unauthenticated requests
+func (mw *AuthMiddleware) audit(c *gin.Context, principal Principal, outcome, reason string) {
+	if mw.AuditEvents == nil {
+		return
+	}
+	event := AuditEvent{
+		Timestamp: mw.now(),
+		Method:    c.Request.Method,
+		Route:     c.FullPath(),
+		IP:        c.ClientIP(),
+		RequestID: c.GetString(RequestIDKey),
+		Outcome:   outcome,
+		Reason:    redactTokens(reason),
+	}
+	if outcome != AuditAuthenticated {
+		event.ErrorID = mw.errorID(c)
+	}
+	if principal != nil {
+		event.Subject = principal.ID()
+		event.ClientID = NewClaims(principal.Claims()).ClientID
+	}
+	mw.hook(func() { mw.AuditEvents.Audit(event) })
+}
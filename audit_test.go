@@ -0,0 +1,48 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_AuditEvents(t *testing.T) {
+	t.Logf("Given a middleware streaming its audit events to a channel")
+	{
+		events := make(chan AuditEvent, 10)
+		now := time.Now().Add(time.Minute).UTC()
+		mw := newTestMiddleware()
+		mw.AuditEvents = AuditChannel(events)
+		mw.TimeFunc = func() time.Time { return now }
+		router := authzHandler(mw, mw.RequireGroups("admins"))
+
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", "not-a-token").Code)
+		event := <-events
+		assert.NotEmpty(t, event.ErrorID)
+		event.ErrorID = ""
+		assert.Equal(t, AuditEvent{Timestamp: now, Method: "GET", Route: "/orders", IP: "", Outcome: AuditUnauthenticated, Reason: "malformed"}, event)
+
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", tokenWithGroups("users")).Code)
+		event = <-events
+		assert.Equal(t, AuditAuthenticated, event.Outcome)
+		assert.Equal(t, "user-123", event.Subject)
+		assert.Equal(t, "test-client", event.ClientID)
+		event = <-events
+		assert.Equal(t, AuditForbidden, event.Outcome)
+		assert.Equal(t, "user-123", event.Subject)
+		assert.NotEmpty(t, event.Reason)
+	}
+}
+
+func Test_AuditChannelDropsEventsWhenFull(t *testing.T) {
+	t.Logf("Given an audit channel nobody consumes")
+	{
+		events := make(chan AuditEvent, 1)
+		sink := AuditChannel(events)
+		sink.Audit(AuditEvent{Outcome: AuditAuthenticated})
+		sink.Audit(AuditEvent{Outcome: AuditForbidden})
+		assert.Equal(t, AuditAuthenticated, (<-events).Outcome)
+		assert.Len(t, events, 0)
+	}
+}
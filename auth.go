@@ -1,18 +1,21 @@
 package jwt
 
 import (
+	"context"
 	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
-	jwtgo "github.com/golang-jwt/jwt"
 	"github.com/gin-gonic/gin"
-	"log"
+	jwtgo "github.com/golang-jwt/jwt"
+	"golang.org/x/sync/singleflight"
 	"math/big"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -38,22 +41,54 @@ const (
 	// HEADER used by the JWT middle ware
 	HEADER = "header"
 
+	// COOKIE used by the JWT middle ware to read the token from a cookie, e.g. "cookie:access_token"
+	COOKIE = "cookie"
+
 	// IssuerFieldName the issuer field name
 	IssuerFieldName = "iss"
 )
 
-// AuthMiddleware middleware
+// AuthMiddleware middleware. It is safe for concurrent use once configured: the handlers are created from a frozen
+// copy of the fields, see Freeze, and the json web keys are replaced by RefreshJWK under a lock. The caches, stores
+// and hooks set on the middleware must be safe for concurrent use as well.
 type AuthMiddleware struct {
 
 	// User can define own Unauthorized func.
 	Unauthorized func(*gin.Context, int, string)
 
+	// User can define own Forbidden func, invoked when an authenticated caller is not authorized.
+	Forbidden func(*gin.Context, int, string)
+
+	// ErrorMapper maps the failures to the status and JSON body of the response, taking precedence over the
+	// Unauthorized and Forbidden funcs. Errors mapped to a zero status get the default response. The
+	// errors match the Err* variables with errors.Is.
+	ErrorMapper func(err error) (status int, body interface{})
+
+	// ProblemJSON renders the failures as RFC 7807 application/problem+json documents unless custom
+	// Unauthorized and Forbidden funcs are set
+	ProblemJSON bool
+
+	// ErrorMode how much detail of the failures the responses disclose, VerboseErrors by default. The logs
+	// and audit events always carry the concrete failure.
+	ErrorMode ErrorMode
+
+	// CSRF the cross site request forgery protection of the requests authenticated by a cookie, CSRFDoubleSubmit
+	// by default. It only applies when the token is read from a cookie or the Sessions are on.
+	CSRF CSRFMode
+
+	// Timeout the deadline of the validation of a request, the network backed checks included: the refresh of
+	// the json web key set, the Enrichers and the Authorizer. The requests exceeding it are answered with a 503
+	// and the timeout failure reason. One hour by default.
 	Timeout time.Duration
 
 	// TokenLookup the header name of the token
 	TokenLookup string
 
-	// TimeFunc
+	// Extractor optional extraction of the token from the requests, taking precedence over the TokenLookup
+	Extractor TokenExtractor
+
+	// TimeFunc the clock of the exp, nbf, iat and auth_time checks of the tokens, the DPoP proofs, the automatic
+	// refresh and the audit events, time.Now by default. Tests and simulations set it to control the time.
 	TimeFunc func() time.Time
 
 	// Realm name to display to the user. Required.
@@ -71,8 +106,243 @@ type AuthMiddleware struct {
 	// The issuer
 	Iss string
 
+	// JWKURL the url of the json web key set, the one of the user pool by default
+	JWKURL string
+
 	// JWK public JSON Web Key (JWK) for your user pool
 	JWK map[string]JWKKey
+
+	// Keys optional provider of the public keys verifying the signatures, taking precedence over the JWK
+	Keys KeyProvider
+
+	// RefreshOnUnknownKey downloads the json web key set again when a token names an unknown kid, e.g. right after
+	// a key rotation, and validates the token with the new keys. See UnknownKeyRefreshInterval.
+	RefreshOnUnknownKey bool
+
+	// UnknownKeyRefreshInterval the minimum interval between two refreshes on unknown kids, so that tokens forged
+	// with random kids cannot hammer the user pool. One minute by default.
+	UnknownKeyRefreshInterval time.Duration
+
+	// MachineToMachine accepts only the machine to machine access tokens, issued to app clients with the client
+	// credentials grant. The client_id of these tokens identifies the caller and their scopes grant the access.
+	MachineToMachine bool
+
+	// StrictMode a single switch for the security sensitive deployments: the issuer and the json web key set url
+	// must be https, loopback included, the tokens must name their kid, be signed with a key naming its algorithm
+	// of at least 2048 bits, carry no crit header nor padding, be issued by the Iss and carry their exp and iat
+	StrictMode bool
+
+	// GroupsMatch whether RequireGroups needs any (default) or all of the listed groups
+	GroupsMatch MatchMode
+
+	// GroupHierarchy the groups included by each group, e.g. admin: [editor], editor: [viewer]. The members
+	// of a group are considered members of all the groups it transitively includes.
+	GroupHierarchy map[string][]string
+
+	// RBAC optional role based access control enforced on every route once the token is validated
+	RBAC *RBAC
+
+	// Routes optional per route requirements enforced once the token is validated
+	Routes RouteTable
+
+	// Required optional requirements enforced on every route, on top of the Routes
+	Required RouteRequirement
+
+	// Authorizer optional external authorization engine consulted once the token is validated
+	Authorizer Authorizer
+
+	// AuthorizerFailOpen allows the requests when the Authorizer fails, they are denied by default
+	AuthorizerFailOpen bool
+
+	// Decisions optional sink receiving every authorization decision
+	Decisions DecisionSink
+
+	// PublicRoutes the routes which do not require a token, either "/path" or "METHOD /path" using the
+	// gin route pattern. See DenyByDefault.
+	PublicRoutes []string
+
+	// Logger the logger of the middleware, logs are discarded when nil
+	Logger Logger
+
+	// LogLevel the minimum level of the logs, LogLevelInfo by default
+	LogLevel LogLevel
+
+	// RequestID extracts the correlation ID of the request, e.g. RequestIDFromHeader("X-Request-Id"), which is
+	// then included in the logs, the audit events and the error responses of the middleware
+	RequestID func(*gin.Context) string
+
+	// Debug turns the debug mode on: the LogLevel is lowered to LogLevelDebug and the decoded header and
+	// claims of the rejected tokens are logged, with the signature, the email addresses and the sensitive
+	// claims redacted
+	Debug bool
+
+	// SensitiveClaims the claims redacted from the debug logs on top of the DefaultSensitiveClaims
+	SensitiveClaims []string
+
+	// Metrics receives the outcome of the validations, metrics are disabled when nil
+	Metrics Metrics
+
+	// FailureLogSampling samples the logs of the rejected tokens, every failure is logged by default
+	FailureLogSampling FailureSampling
+
+	// ErrorReporter receives the unexpected internal errors, see the sentryjwt package for Sentry
+	ErrorReporter ErrorReporter
+
+	// AuditEvents receives an AuditEvent for every authenticated, unauthenticated and forbidden request
+	AuditEvents AuditSink
+
+	// Hooks optional dispatcher running the AuditEvents, Decisions, Metrics and ErrorReporter hooks in the
+	// background, see NewDispatcher. The hooks are run on the request path when nil.
+	Hooks *Dispatcher
+
+	// ClaimsMapper optional transformation of the validated claims, e.g. to enrich them with
+	// application roles or strip PII. The result is stored in the context under MappedClaimsKey.
+	ClaimsMapper func(jwtgo.MapClaims) (interface{}, error)
+
+	// ClaimsValidators optional checks of the claims of the tokens once their signature and standard claims are
+	// validated, the tokens they reject are answered with a 401
+	ClaimsValidators []ClaimsValidator
+
+	// Enrichers fetch additional claims of the callers once their token is validated, see UserInfoEnricher
+	Enrichers []Enricher
+
+	// ClientID the ID of the app client of the user pool, required by the RefreshHandler
+	ClientID string
+
+	// ClientSecret the secret of the app client, if any
+	ClientSecret string
+
+	// RefreshTokenCookie the cookie holding the refresh token, DefaultRefreshTokenCookie by default
+	RefreshTokenCookie string
+
+	// RefreshTokenValidity the validity of the refresh tokens of the app client, DefaultRefreshTokenValidity by
+	// default. The LogoutHandler keeps the revocations for as long, the refresh token of the revoked tokens issuing
+	// new ones until it expires.
+	RefreshTokenValidity time.Duration
+
+	// RefreshBefore turns the automatic refresh on in cookie mode: the tokens expiring within RefreshBefore are
+	// refreshed with the refresh token cookie while the request is handled, and the cookies set by the
+	// CallbackHandler are updated on the response
+	RefreshBefore time.Duration
+
+	// Domain the domain of the Cognito Hosted UI, e.g. "<prefix>.auth.<region>.amazoncognito.com"
+	Domain string
+
+	// RedirectURL the callback URL registered on the app client, served by the CallbackHandler
+	RedirectURL string
+
+	// LoginScopes the scopes requested to the Hosted UI, "openid" by default
+	LoginScopes []string
+
+	// BrowserLogin redirects the unauthenticated browser requests to the Hosted UI rather than answering with a
+	// 401. The token is then read from the AccessTokenCookie set by the CallbackHandler, unless TokenLookup is set.
+	BrowserLogin bool
+
+	// Revocations optional store of the revoked tokens, rejected by the middleware, see LogoutHandler
+	Revocations RevocationStore
+
+	// RevocationChecker optional check of the revoked tokens taking precedence over the Revocations, e.g. a read
+	// only view of the store of another service
+	RevocationChecker RevocationChecker
+
+	// Sessions turns the server side sessions on: once the token of a browser client is validated, the middleware
+	// issues a SessionCookie backed by the store, and the following requests carrying the cookie skip the
+	// validation of the token until it expires. The clients presenting their token in a header get no session.
+	Sessions SessionStore
+
+	// GlobalSignOut makes the LogoutHandler call the Cognito GlobalSignOut, invalidating the refresh tokens
+	// of the user on every device
+	GlobalSignOut bool
+
+	// TokenSink receives the tokens obtained by the CodeExchangeHandler, JSONTokenSink by default
+	TokenSink TokenSink
+
+	// TokenCache optional cache of the validated tokens, see NewTokenCache
+	TokenCache *TokenCache
+
+	// RejectionCache optional cache of the rejected tokens, see NewRejectionCache
+	RejectionCache *RejectionCache
+
+	// FailureLimiter optional throttling of the clients failing the validation of their tokens again and again,
+	// answered with a 429, see NewFailureLimiter
+	FailureLimiter *FailureLimiter
+
+	// DPoP optional validation of the DPoP proofs of possession binding the access tokens to a key of the client
+	DPoP *DPoP
+
+	// ClientCertificate returns the client certificate the access tokens bound to a certificate by their
+	// cnf.x5t#S256 claim are checked against, RFC 8705. The certificate of the TLS connection by default, see
+	// ClientCertificateFromHeader behind a TLS terminating proxy. The bound tokens are always checked.
+	ClientCertificate func(*http.Request) (*x509.Certificate, error)
+
+	// Fingerprint optional binding of the tokens to the fingerprint of the client they are first used from, see
+	// NewFingerprintBinding
+	Fingerprint *FingerprintBinding
+
+	// Lockout optional temporary lockout of the principals whose tokens are replayed once revoked again and again,
+	// whatever the client IP. Their tokens are rejected with ErrPrincipalLockedOut until the window ends and an
+	// AuditLockedOut event is emitted as they are locked out. Only the tokens whose signature verified are counted,
+	// so that forged tokens cannot lock out a user.
+	Lockout *FailureLimiter
+
+	// UserPools the middlewares of the user pools trusted by the middleware when it serves several of them, e.g.
+	// created by Bootstrap. The user pool of each token is resolved from its iss claim, the tokens of the other
+	// issuers being rejected with ErrBadIssuer, and its signature and claims are validated by the middleware of its
+	// pool: json web key set, issuer, StrictMode, MachineToMachine and ClaimsValidators, the ones of this middleware
+	// applying on top of them. The other options of this middleware, its Region and UserPoolID being then optional,
+	// apply to the tokens of all the pools.
+	UserPools []*AuthMiddleware
+
+	// TenantResolver optional routing of the requests to the user pool of their tenant, e.g. TenantFromSubdomain.
+	// The tokens of the requests naming a tenant must be issued by the user pool of the tenant in Tenants, the
+	// ones naming an unknown tenant are rejected with ErrUnknownTenant. The requests naming no tenant are served
+	// by the user pool of the issuer of their token, unless StrictTenancy is on.
+	TenantResolver TenantResolver
+
+	// StrictTenancy fails closed on the tenancy of the requests: the requests resolving to no tenant are rejected
+	// with ErrUnknownTenant rather than served by the user pool of their issuer, and the tenants sharing a user
+	// pool require a TenantClaim, so that the tokens of a tenant never authorize the requests of another
+	StrictTenancy bool
+
+	// TenantClaim optional claim naming the tenant of the tokens, e.g. custom:tenant_id, which must be the tenant
+	// of the request, or the tokens are rejected with ErrTenantMismatch. It tells apart the tenants sharing a pool.
+	TenantClaim string
+
+	// Policy the validation policy of the tokens of the user pool when this middleware is one of the UserPools or
+	// Tenants of another middleware, see TenantPolicy
+	Policy *TenantPolicy
+
+	// TenantPolicies the validation policies of the tenants by tenant ID, taking precedence over the Policy of
+	// their user pool
+	TenantPolicies map[string]*TenantPolicy
+
+	// Tenants the middlewares of the user pools of the tenants by tenant ID, the tenants sharing a user pool
+	// sharing its middleware. They are trusted along with the UserPools. Both are the initial set of the user
+	// pools, see AddUserPool and RemoveUserPool to update it at runtime.
+	Tenants map[string]*AuthMiddleware
+
+	// QuotaStore counts the requests of the principals against the Quota of the policy of their tenant, a
+	// MemoryQuotaStore for a single instance, see the redisjwt package to share them
+	QuotaStore QuotaStore
+
+	// Discovery the discovery of the user pools of the account by their tags, see DiscoverUserPools and
+	// PollUserPools
+	Discovery *PoolDiscovery
+
+	// root the middleware a frozen copy was made of, holding the keys and the statistics, see Freeze
+	root *AuthMiddleware
+
+	keysMu           sync.RWMutex
+	refreshes        singleflight.Group
+	tokenRefreshes   singleflight.Group
+	jwkLoadedAt      time.Time
+	lastRefreshErr   error
+	verificationKeys map[string]*verificationKey
+	unknownKeyAt     time.Time
+	unknownKeyCount  uint64
+	pools            userPools
+	stats            stats
+	sampler          failureSampler
 }
 
 // JWK is json data struct for JSON Web Key
@@ -92,13 +362,38 @@ type JWKKey struct {
 
 // AuthError auth error response
 type AuthError struct {
-	Message string `json:"message"`
-	Code    int    `json:code`
+	Message string      `json:"message"`
+	Code    int         `json:"code"`
+	Error   string      `json:"error,omitempty"`
+	Detail  interface{} `json:"detail,omitempty"`
+
+	// RequestID the correlation ID of the request, see AuthMiddleware.RequestID
+	RequestID string `json:"request_id,omitempty"`
+
+	// ErrorID the ID of the failure, also found in the logs and the audit event of the request
+	ErrorID string `json:"error_id,omitempty"`
+
+	// Cause the failure answered by the response, e.g. the AccessError of CheckRoute, matched by the ErrorMapper
+	Cause error `json:"-"`
+}
+
+// errorResponse renders the default AuthError JSON response, including the error code and
+// detail recorded in the context by the authorization helpers
+func errorResponse(c *gin.Context, code int, message string) {
+	authErr := AuthError{Code: code, Message: message, Error: c.GetString(ErrorCodeKey), RequestID: c.GetString(RequestIDKey), ErrorID: c.GetString(ErrorIDKey)}
+	if detail, ok := c.Get(ErrorDetailKey); ok {
+		authErr.Detail = detail
+	}
+	c.JSON(code, authErr)
 }
 
 // MiddlewareInit initialize jwt configs.
 func (mw *AuthMiddleware) MiddlewareInit() {
 
+	if mw.TokenLookup == "" && mw.BrowserLogin {
+		mw.TokenLookup = COOKIE + ":" + AccessTokenCookie
+	}
+
 	if mw.TokenLookup == "" {
 		mw.TokenLookup = "header:" + AuthorizationHeader
 	}
@@ -107,75 +402,470 @@ func (mw *AuthMiddleware) MiddlewareInit() {
 		mw.Timeout = time.Hour
 	}
 
+	if mw.UnknownKeyRefreshInterval == 0 {
+		mw.UnknownKeyRefreshInterval = time.Minute
+	}
+
 	if mw.TimeFunc == nil {
 		mw.TimeFunc = time.Now
 	}
 
 	if mw.Unauthorized == nil {
-		mw.Unauthorized = func(c *gin.Context, code int, message string) {
-			c.JSON(code, AuthError{Code: code, Message: message})
-		}
+		mw.Unauthorized = mw.defaultResponse()
+	}
+
+	if mw.Forbidden == nil {
+		mw.Forbidden = mw.defaultResponse()
 	}
 
 	if mw.Realm == "" {
 		mw.Realm = "gin jwt"
 	}
+
+	if mw.RefreshTokenCookie == "" {
+		mw.RefreshTokenCookie = DefaultRefreshTokenCookie
+	}
+
+	if mw.RefreshTokenValidity == 0 {
+		mw.RefreshTokenValidity = DefaultRefreshTokenValidity
+	}
+
+	if mw.TokenSink == nil {
+		mw.TokenSink = JSONTokenSink()
+	}
+
+	if len(mw.LoginScopes) == 0 {
+		mw.LoginScopes = []string{"openid"}
+	}
 }
 
 func (mw *AuthMiddleware) middlewareImpl(c *gin.Context) {
+	mw.correlate(c)
+
+	if mw.isPublic(c) {
+		c.Next()
+		return
+	}
+
+	// the handlers are called outside of authenticate, their panics are not the middleware's to recover
+	if mw.authenticate(c) {
+		refreshed := mw.autoRefresh(c)
+		c.Next()
+		refreshed()
+	}
+}
+
+// authenticate validates the token of the request and enforces the authorization requirements, aborting
+// the request when it is rejected. Panics are recovered and answered with a 500.
+func (mw *AuthMiddleware) authenticate(c *gin.Context) (ok bool) {
+	logger := mw.requestLog(c)
+	defer func() {
+		if r := recover(); r != nil {
+			err := mw.recovered(logger, "authentication", r)
+			c.Abort()
+			mw.respond(c, http.StatusInternalServerError, err, nil)
+			ok = false
+		}
+	}()
+
+	if mw.throttled(c, logger) || !mw.checkCSRF(c, logger) {
+		return false
+	}
+
+	ctx, cancel := mw.validationContext(c.Request.Context())
+	defer cancel()
 
-	// Parse the given token
-	var tokenStr string
+	// Resume the session of the caller, or parse the given token
+	token := mw.sessionToken(c, logger)
+	resumed := token != nil
 	var err error
+	if !resumed {
+		var tokenStr string
+		tokenStr, err = mw.requestToken(c.Request, logger)
+		if err == nil {
+			token, err = mw.validateTokenContext(ctx, tokenStr, logger)
+		}
+	}
+	// the bindings are checked on every request, the ones resuming a session included
+	if err == nil {
+		err = mw.checkBindings(c.Request, c.ClientIP(), token, logger)
+	}
+	if err != nil {
+		mw.recordFailure(c, logger, token, err)
+	}
+	var tenant *Tenant
+	var policy *TenantPolicy
+	if err == nil {
+		tenant, policy, err = mw.checkTenant(c.Request, token, logger)
+	}
+	if err == nil {
+		err = mw.checkLockout(logger, token)
+	}
+	if err == nil && !resumed {
+		mw.startSession(c, token, logger)
+	}
+
+	if err != nil {
+		mw.audit(c, nil, AuditUnauthenticated, failureReason(err))
+		if mw.BrowserLogin && acceptsHTML(c) {
+			mw.redirectToLogin(c, c.Request.URL.RequestURI())
+			return false
+		}
+		mw.unauthorized(c, err)
+		return false
+	}
+
+	claims := token.Claims.(jwtgo.MapClaims)
+	if err := mw.enrich(ctx, logger, token.Raw, claims); err != nil {
+		mw.audit(c, nil, AuditUnauthenticated, failureReason(err))
+		mw.unauthorized(c, err)
+		return false
+	}
+	c.Set(TokenKey, token)
+	c.Set(TokenStringKey, token.Raw)
+	if exp, ok := ExpiresAt(claims); ok {
+		c.Set(TokenExpiryKey, exp)
+	}
+	principal := NewPrincipal(claims)
+	c.Set(PrincipalKey, principal)
+	if tenant != nil {
+		c.Set(TenantKey, tenant)
+		c.Request = c.Request.WithContext(ContextWithTenant(c.Request.Context(), tenant))
+	}
+	if !mw.checkQuota(c, logger, principal, tenant, policy) {
+		return false
+	}
 
-	parts := strings.Split(mw.TokenLookup, ":")
-	switch parts[0] {
-	case HEADER:
-		tokenStr, err = mw.jwtFromHeader(c, parts[1])
+	// the token is valid, rejecting its claims is an authorization failure
+	if mw.ClaimsMapper != nil {
+		mapped, err := mw.ClaimsMapper(claims)
+		if err != nil {
+			logger.Warn("Failed to map the jwt token claims", "error", err)
+			mw.forbidden(c, accessError(ErrClaimsRejected, err.Error()))
+			return false
+		}
+		c.Set(MappedClaimsKey, mapped)
 	}
 
+	if policy != nil && !mw.authorizeRequirement(c, principal, policy.Required) {
+		return false
+	}
+	if !mw.authorizeRBAC(c, principal) || !mw.authorizeRoute(c, principal) || !mw.authorizeExternal(ctx, c, principal) {
+		return false
+	}
+	mw.issueCSRFCookie(c)
+	mw.audit(c, principal, AuditAuthenticated, "")
+	return true
+}
+
+// ExtractToken extracts the token from the request headers as per TokenLookup, header returning the value
+// of the given header. It lets the adapters of other web frameworks share the lookup configuration.
+func (mw *AuthMiddleware) ExtractToken(header func(key string) string) (string, error) {
+	return mw.extractToken(header, mw.log())
+}
+
+func (mw *AuthMiddleware) extractToken(header func(key string) string, logger Logger) (string, error) {
+	kind, name, ok := strings.Cut(mw.TokenLookup, ":")
+	if !ok || kind != HEADER {
+		mw.extractionFailed(logger, InvalidAuthHeaderError)
+		return "", InvalidAuthHeaderError
+	}
+	tokenStr := bearerToken(mw.dpopToken(header(name)))
+	if tokenStr == "" {
+		mw.extractionFailed(logger, AuthHeaderEmptyError)
+		return "", AuthHeaderEmptyError
+	}
+	return tokenStr, nil
+}
+
+// bearerToken strips the Bearer authorization scheme of RFC 6750 from the token, if any, the scheme being matched
+// case insensitively
+func bearerToken(tokenStr string) string {
+	if scheme, token, ok := strings.Cut(tokenStr, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return token
+	}
+	return tokenStr
+}
+
+// requestToken extracts the token from the request with the Extractor, or as per TokenLookup, either from a header
+// or a cookie
+func (mw *AuthMiddleware) requestToken(r *http.Request, logger Logger) (string, error) {
+	if mw.Extractor != nil {
+		return mw.extractWith(r, logger)
+	}
+	name := strings.TrimPrefix(mw.TokenLookup, COOKIE+":")
+	if name == mw.TokenLookup {
+		return mw.extractToken(r.Header.Get, logger)
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		err = tokenError(ErrMissingHeader, fmt.Errorf("cookie %s missing", name))
+		mw.extractionFailed(logger, err)
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+func (mw *AuthMiddleware) extractionFailed(logger Logger, err error) {
+	reason := failureReason(err)
+	if mw.sampleFailure(reason) {
+		logger.Warn("Failed to extract the jwt token", "error_class", reason, "error", err)
+	}
+	mw.observe(reason, 0)
+}
+
+// ValidateToken parses the given token and validates its signature and claims, then the policy of the user pool of
+// its issuer. It is the core validation used by the middleware, independent of gin. The checks needing the request,
+// the tenancy of the requests included, fail closed, see ValidateRequest.
+func (mw *AuthMiddleware) ValidateToken(tokenStr string) (*jwtgo.Token, error) {
+	return mw.ValidateTokenContext(context.Background(), tokenStr)
+}
+
+// ValidateTokenContext is ValidateToken failing with the error of ctx once it is done, an ErrValidationTimeout
+// once its deadline is exceeded
+func (mw *AuthMiddleware) ValidateTokenContext(ctx context.Context, tokenStr string) (*jwtgo.Token, error) {
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	logger := mw.log()
+	token, err := mw.validateTokenContext(ctx, tokenStr, logger)
 	if err != nil {
-		log.Printf("JWT token Parser error: %s", err.Error())
-		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
-		return
+		return token, err
 	}
+	if err := mw.checkRequestless(token, logger); err != nil {
+		return token, err
+	}
+	if _, err := mw.authorizeTenant(nil, token, logger); err != nil {
+		return token, err
+	}
+	return token, nil
+}
 
-	token, err := mw.parse(tokenStr)
+func (mw *AuthMiddleware) validateToken(tokenStr string, logger Logger) (*jwtgo.Token, error) {
+	return mw.validateTokenContext(context.Background(), tokenStr, logger)
+}
 
+// parseToken parses the token, once more after a refresh of the json web key set when its kid is unknown
+func (mw *AuthMiddleware) parseToken(ctx context.Context, tokenStr string, logger Logger) (*jwtgo.Token, error) {
+	start := time.Now()
+	token, err := mw.cachedParse(tokenStr, logger)
+	if errors.Is(err, ErrUnknownKeyID) && mw.refreshUnknownKey(ctx, tokenStr, token, logger) {
+		token, err = mw.cachedParse(tokenStr, logger)
+	}
+	latency := time.Since(start)
+	mw.logValidation(logger, token, err, latency)
+	reason := ""
 	if err != nil {
-		log.Printf("JWT token Parser error: %s", err.Error())
-		mw.unauthorized(c, http.StatusUnauthorized, err.Error())
-		return
+		reason = failureReason(err)
 	}
+	mw.observe(reason, latency)
+	return token, err
+}
 
-	c.Set("JWT_TOKEN", token)
-	c.Next()
+// cachedParse parses the token unless it is found in the TokenCache, whose hits are still checked against the
+// TimeFunc and for revocation, or in the RejectionCache. The obviously malformed tokens are rejected straight away.
+func (mw *AuthMiddleware) cachedParse(tokenStr string, logger Logger) (*jwtgo.Token, error) {
+	if err := precheck(tokenStr); err != nil {
+		return nil, err
+	}
+	if mw.TokenCache != nil {
+		if token, ok := mw.TokenCache.get(tokenStr); ok {
+			if err := mw.validateTimes(token.Claims.(jwtgo.MapClaims)); err != nil {
+				mw.TokenCache.Remove(tokenStr)
+				return token, err
+			}
+			if err := mw.checkTrusted(token.Claims.(jwtgo.MapClaims)); err != nil {
+				mw.TokenCache.Remove(tokenStr)
+				return token, err
+			}
+			if err := mw.checkRevoked(token.Claims.(jwtgo.MapClaims)); err != nil {
+				if errors.Is(err, ErrTokenRevoked) {
+					mw.TokenCache.Remove(tokenStr)
+				}
+				return token, err
+			}
+			return token, nil
+		}
+	}
+	if mw.RejectionCache != nil {
+		if err := mw.RejectionCache.get(tokenStr); err != nil {
+			return nil, err
+		}
+	}
+	token, err := mw.safeParse(tokenStr, logger)
+	if err == nil && mw.TokenCache != nil {
+		mw.TokenCache.add(token)
+	}
+	if err != nil && mw.RejectionCache != nil {
+		mw.RejectionCache.add(tokenStr, err)
+	}
+	return token, err
+}
+
+// logValidation logs the outcome of the validation of a token, with the kid, iss and sub of the token
+// when it could be decoded
+func (mw *AuthMiddleware) logValidation(logger Logger, token *jwtgo.Token, err error, latency time.Duration) {
+	if err == nil && !mw.logEnabled(LogLevelDebug) {
+		return
+	}
+	var reason string
+	if err != nil {
+		reason = failureReason(err)
+		if !mw.sampleFailure(reason) {
+			return
+		}
+	}
+	var fields []interface{}
+	if token != nil {
+		if kid, ok := token.Header["kid"].(string); ok {
+			fields = append(fields, "kid", kid)
+		}
+		if claims, ok := token.Claims.(jwtgo.MapClaims); ok {
+			if iss, ok := claims["iss"].(string); ok {
+				fields = append(fields, "iss", iss)
+			}
+			if sub, ok := claims["sub"].(string); ok {
+				fields = append(fields, "sub", sub)
+			}
+		}
+	}
+	fields = append(fields, "latency", latency)
+	if err != nil {
+		logger.Warn("Failed to validate the jwt token", append(fields, "error_class", reason, "error", err)...)
+		mw.logRejectedToken(logger, token)
+		return
+	}
+	logger.Debug("Validated the jwt token", fields...)
 }
 
-func (mw *AuthMiddleware) jwtFromHeader(c *gin.Context, key string) (string, error) {
-	authHeader := c.Request.Header.Get(key)
+func (mw *AuthMiddleware) unauthorized(c *gin.Context, err error) {
+	c.Abort()
+	if errors.Is(err, ErrValidationTimeout) {
+		mw.respond(c, http.StatusServiceUnavailable, err, mw.Unauthorized)
+		return
+	}
+	if errors.Is(err, ErrInvalidDPoPProof) {
+		c.Set(ErrorCodeKey, InvalidDPoPProof)
+	}
+	c.Header(AuthenticateHeader, mw.Challenge(err))
+	mw.respond(c, http.StatusUnauthorized, err, mw.Unauthorized)
+}
 
-	if authHeader == "" {
-		return "", AuthHeaderEmptyError
+// Challenge returns the RFC 6750 WWW-Authenticate challenge of a 401 response: the Bearer scheme and the realm alone
+// when the request carries no token, with the invalid_token error and the failure reason when the token is invalid,
+// the DPoP scheme when its DPoP proof is. Authorization failures are answered 403 without challenge, but for
+// insufficient scopes.
+func (mw *AuthMiddleware) Challenge(err error) string {
+	realm := quoteParam(mw.realm())
+	if err == nil || errors.Is(err, ErrMissingHeader) || errors.Is(err, ErrInvalidTokenLookup) {
+		return "Bearer realm=" + realm
+	}
+	if errors.Is(err, ErrInvalidDPoPProof) {
+		return fmt.Sprintf(`DPoP realm=%s, error="%s", algs="%s"`, realm, InvalidDPoPProof, strings.Join(dpopAlgs, " "))
 	}
-	return authHeader, nil
+	return fmt.Sprintf(`Bearer realm=%s, error="%s", error_description="%s"`, realm, InvalidToken, failureReason(err))
+}
+
+// ScopeChallenge returns the RFC 6750 WWW-Authenticate challenge of the 403 response to an access token lacking the
+// given scopes
+func (mw *AuthMiddleware) ScopeChallenge(scopes []string) string {
+	return fmt.Sprintf(`Bearer realm=%s, error="%s", error_description="%s", scope=%s`,
+		quoteParam(mw.realm()), InsufficientScope, "the access token lacks the required scopes", quoteParam(strings.Join(scopes, " ")))
 }
 
-func (mw *AuthMiddleware) unauthorized(c *gin.Context, code int, message string) {
+// quoteParam quotes the value of a parameter of a challenge, escaping its quotes and backslashes
+func quoteParam(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func (mw *AuthMiddleware) realm() string {
 	if mw.Realm == "" {
-		mw.Realm = "gin jwt"
+		return "gin jwt"
 	}
-	c.Header(AuthenticateHeader, "JWT realm="+mw.Realm)
+	return mw.Realm
+}
+
+func (mw *AuthMiddleware) forbidden(c *gin.Context, err error) {
+	principal, _ := GetPrincipal(c)
+	mw.audit(c, principal, AuditForbidden, err.Error())
 	c.Abort()
+	mw.respond(c, http.StatusForbidden, err, mw.Forbidden)
+}
+
+// respond renders the failure with the ErrorMapper when set, the given renderer otherwise
+func (mw *AuthMiddleware) respond(c *gin.Context, code int, err error, render func(*gin.Context, int, string)) {
+	c.Set(ErrorKey, err)
+	mw.errorID(c)
+	if !canWriteBody(c) {
+		c.Status(code)
+		return
+	}
+	if mw.ErrorMapper != nil {
+		if status, body := mw.ErrorMapper(err); status != 0 {
+			c.JSON(status, body)
+			return
+		}
+	}
+	if render == nil {
+		render = mw.defaultResponse()
+	}
+	if mw.ErrorMode == ProductionErrors {
+		c.Set(ErrorDetailKey, nil)
+	}
+	render(c, code, mw.errorMessage(code, err))
+}
+
+// ErrorBody returns the status and the body of the response to the failure described by authErr, as the middleware
+// renders it by default, so that the adapters of the other frameworks share its error contract: the ones of the
+// ErrorMapper when it maps the Cause, a Problem when ProblemJSON is set, the AuthError otherwise. The message is the
+// one of the Cause redacted of the tokens, the status text alone and no detail in the ProductionErrors mode.
+func (mw *AuthMiddleware) ErrorBody(r *http.Request, authErr AuthError) (int, interface{}) {
+	err := authErr.Cause
+	if err == nil {
+		err = errors.New(authErr.Message)
+	}
+	if mw.ErrorMapper != nil {
+		if status, body := mw.ErrorMapper(err); status != 0 {
+			return status, body
+		}
+	}
+	authErr.Message = mw.errorMessage(authErr.Code, err)
+	if authErr.ErrorID == "" {
+		authErr.ErrorID = newErrorID()
+	}
+	if mw.ErrorMode == ProductionErrors {
+		authErr.Detail = nil
+	}
+	if mw.ProblemJSON {
+		return authErr.Code, Problem{
+			Type:        "about:blank",
+			Title:       http.StatusText(authErr.Code),
+			Status:      authErr.Code,
+			Detail:      authErr.Message,
+			Instance:    r.URL.Path,
+			Error:       authErr.Error,
+			ErrorDetail: authErr.Detail,
+			RequestID:   authErr.RequestID,
+			ErrorID:     authErr.ErrorID,
+		}
+	}
+	return authErr.Code, authErr
+}
 
-	mw.Unauthorized(c, code, message)
-	return
+// errorMessage the message of the response to the failure, redacted of the tokens, the status text alone in the
+// ProductionErrors mode
+func (mw *AuthMiddleware) errorMessage(code int, err error) string {
+	if mw.ErrorMode == ProductionErrors {
+		return http.StatusText(code)
+	}
+	return redactTokens(err.Error())
 }
 
 // MiddlewareFunc implements the Middleware interface.
 func (mw *AuthMiddleware) MiddlewareFunc() gin.HandlerFunc {
 	// initialise
 	mw.MiddlewareInit()
+	mw = mw.Freeze()
 	return func(c *gin.Context) {
 		mw.middlewareImpl(c)
 		return
@@ -183,10 +873,12 @@ func (mw *AuthMiddleware) MiddlewareFunc() gin.HandlerFunc {
 }
 
 // AuthJWTMiddleware create an instance of the middle ware function
+//
+// Deprecated: use NewForUserPool, which derives the issuer and validates the configuration.
 func AuthJWTMiddleware(iss, userPoolID, region string) (*AuthMiddleware, error) {
 
 	// Download the public json web key for the given user pool ID at the start of the plugin
-	jwk, err := getJWK(fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v/.well-known/jwks.json", region, userPoolID))
+	jwk, err := getJWK(NopLogger{}, fmt.Sprintf(jwkURLFormat, region, userPoolID))
 	if err != nil {
 		return nil, err
 	}
@@ -194,63 +886,148 @@ func AuthJWTMiddleware(iss, userPoolID, region string) (*AuthMiddleware, error)
 	authMiddleware := &AuthMiddleware{
 		Timeout: time.Hour,
 
-		Unauthorized: func(c *gin.Context, code int, message string) {
-			c.JSON(code, AuthError{Code: code, Message: message})
-		},
+		Unauthorized: errorResponse,
 
 		// Token header
 		TokenLookup: "header:" + AuthorizationHeader,
 		TimeFunc:    time.Now,
-		JWK:         jwk,
 		Iss:         iss,
 		Region:      region,
 		UserPoolID:  userPoolID,
 	}
+	authMiddleware.setJWK(jwk)
 	return authMiddleware, nil
 }
 
+// NewForUserPool creates the middleware of the user pool, deriving the issuer and the url of the json web key set
+// from the region and the user pool ID, and downloads the keys unless a KeyProvider is given. The overrides, see the
+// With* options, are applied before the download, e.g. to set the JWKURL of a proxy.
+func NewForUserPool(region, userPoolID string, overrides ...Option) (*AuthMiddleware, error) {
+	return NewWithContext(context.Background(), region, userPoolID, overrides...)
+}
+
+// NewWithContext is NewForUserPool downloading the keys within the deadline of ctx, e.g. the one of the startup of
+// the application, and giving up once ctx is cancelled
+func NewWithContext(ctx context.Context, region, userPoolID string, overrides ...Option) (*AuthMiddleware, error) {
+	mw := &AuthMiddleware{
+		Region:       region,
+		UserPoolID:   userPoolID,
+		Iss:          UserPool{Region: region, UserPoolID: userPoolID}.Iss(),
+		Unauthorized: errorResponse,
+	}
+	for _, override := range overrides {
+		override(mw)
+	}
+	if err := mw.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid middleware configuration: %w", err)
+	}
+	if mw.Keys == nil {
+		jwk, err := getJWKContext(ctx, mw.log(), mw.jwkURL())
+		if err != nil {
+			return nil, err
+		}
+		mw.setJWK(jwk)
+	}
+	mw.MiddlewareInit()
+	return mw, nil
+}
+
+// parse validates the token with the middleware of its user pool, then checks it is not revoked
 func (mw *AuthMiddleware) parse(tokenStr string) (*jwtgo.Token, error) {
+	pool, err := mw.resolvePool(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	token, err := pool.verify(tokenStr, mw)
+	if err != nil {
+		return token, err
+	}
+	if err := mw.checkRevoked(token.Claims.(jwtgo.MapClaims)); err != nil {
+		return token, err
+	}
+	return token, nil
+}
+
+// verify verifies the signature of the token with the keys of the user pool and validates its claims. The StrictMode,
+// MachineToMachine and ClaimsValidators of the middleware serving the pool apply on top of the ones of the pool.
+func (mw *AuthMiddleware) verify(tokenStr string, server *AuthMiddleware) (*jwtgo.Token, error) {
+	strict := mw.StrictMode || server.StrictMode
 
 	// 1. Decode the token string into JWT format.
-	token, err := jwtgo.Parse(tokenStr, func(token *jwtgo.Token) (interface{}, error) {
+	// the exp, nbf and iat are checked against the TimeFunc below rather than the clock of the jwt library
+	parser := &jwtgo.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenStr, func(token *jwtgo.Token) (interface{}, error) {
 
 		// cognito user pool : RS256
 		if _, ok := token.Method.(*jwtgo.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			return nil, fmt.Errorf("%w: %v", ErrUnexpectedSigningMethod, token.Header["alg"])
 		}
 
 		// 5. Get the kid from the JWT token header and retrieve the corresponding JSON Web Key that was stored
-		if kid, ok := token.Header["kid"]; ok {
-			if kidStr, ok := kid.(string); ok {
-				key := mw.JWK[kidStr]
-				// 6. Verify the signature of the decoded JWT token.
-				rsaPublicKey := convertKey(key.E, key.N)
-				return rsaPublicKey, nil
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("%w: the token has no kid", ErrUnknownKeyID)
+		}
+		if strict {
+			if err := strictHeader(token); err != nil {
+				return nil, err
 			}
 		}
-
-		// rsa public key
-		return "", nil
+		publicKey, alg, err := mw.keyProvider().PublicKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if strict {
+			if err := strictKey(token, publicKey, alg); err != nil {
+				return nil, err
+			}
+		}
+		if alg != "" && alg != token.Method.Alg() {
+			return nil, fmt.Errorf("%w: %v", ErrUnexpectedSigningMethod, token.Header["alg"])
+		}
+		// 6. Verify the signature of the decoded JWT token.
+		return publicKey, nil
 	})
 
 	if err != nil {
-		return token, err
+		return token, mw.classify(token, err)
 	}
 
 	claims := token.Claims.(jwtgo.MapClaims)
+	if err := mw.validateTimes(claims); err != nil {
+		return token, err
+	}
 
 	iss, ok := claims["iss"]
 	if !ok {
-		return token, fmt.Errorf("token does not contain issuer")
+		return token, ErrMissingIssuer
+	}
+	if strict {
+		if err := mw.strictClaims(claims); err != nil {
+			return token, err
+		}
 	}
 	issStr := iss.(string)
 	if strings.Contains(issStr, "cognito-idp") {
-		err = validateAWSJwtClaims(claims, mw.Region, mw.UserPoolID)
+		err = validateAWSJwtClaims(claims, mw.Region, mw.UserPoolID, mw.now())
 		if err != nil {
 			return token, err
 		}
 	}
 
+	if (mw.MachineToMachine || server.MachineToMachine) && !IsMachine(claims) {
+		return token, tokenError(ErrWrongTokenUse, errors.New("expecting a client credentials access token"))
+	}
+
+	if err := mw.validateClaims(claims); err != nil {
+		return token, err
+	}
+	if server != mw {
+		if err := server.validateClaims(claims); err != nil {
+			return token, err
+		}
+	}
+
 	if token.Valid {
 		return token, nil
 	}
@@ -258,14 +1035,13 @@ func (mw *AuthMiddleware) parse(tokenStr string) (*jwtgo.Token, error) {
 }
 
 // validateAWSJwtClaims validates AWS Cognito User Pool JWT
-func validateAWSJwtClaims(claims jwtgo.MapClaims, region, userPoolID string) error {
+func validateAWSJwtClaims(claims jwtgo.MapClaims, region, userPoolID string, now time.Time) error {
 	var err error
 	// 3. Check the iss claim. It should match your user pool.
 	issShoudBe := fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v", region, userPoolID)
 	err = validateClaimItem("iss", []string{issShoudBe}, claims)
 	if err != nil {
-		Error.Printf("Failed to validate the jwt token claims %v", err)
-		return err
+		return tokenError(ErrBadIssuer, err)
 	}
 
 	// 4. Check the token_use claim.
@@ -277,7 +1053,7 @@ func validateAWSJwtClaims(claims jwtgo.MapClaims, region, userPoolID string) err
 				}
 			}
 		}
-		return errors.New("token_use should be id or access")
+		return ErrWrongTokenUse
 	}
 
 	err = validateTokenUse()
@@ -286,7 +1062,7 @@ func validateAWSJwtClaims(claims jwtgo.MapClaims, region, userPoolID string) err
 	}
 
 	// 7. Check the exp claim and make sure the token is not expired.
-	err = validateExpired(claims)
+	err = validateExpired(claims, now)
 	if err != nil {
 		return err
 	}
@@ -307,25 +1083,51 @@ func validateClaimItem(key string, keyShouldBe []string, claims jwtgo.MapClaims)
 	return fmt.Errorf("%v does not match any of valid values: %v", key, keyShouldBe)
 }
 
-func validateExpired(claims jwtgo.MapClaims) error {
+// now the current time as per the TimeFunc, the clock of all the time based checks of the tokens
+func (mw *AuthMiddleware) now() time.Time {
+	if mw.TimeFunc == nil {
+		return time.Now()
+	}
+	return mw.TimeFunc()
+}
+
+// validateTimes checks the exp, nbf, iat and auth_time of the token against the TimeFunc, the ones which are
+// missing passing. The failures are *jwtgo.ValidationError as the jwt library would have returned them.
+func (mw *AuthMiddleware) validateTimes(claims jwtgo.MapClaims) error {
+	now := mw.now().Unix()
+	switch {
+	case !claims.VerifyExpiresAt(now, false):
+		return mw.classify(nil, jwtgo.NewValidationError("Token is expired", jwtgo.ValidationErrorExpired))
+	case !claims.VerifyIssuedAt(now, false):
+		return mw.classify(nil, jwtgo.NewValidationError("Token used before issued", jwtgo.ValidationErrorIssuedAt))
+	case !claims.VerifyNotBefore(now, false):
+		return mw.classify(nil, jwtgo.NewValidationError("Token is not valid yet", jwtgo.ValidationErrorNotValidYet))
+	}
+	if authTime, ok := claims["auth_time"].(float64); ok && int64(authTime) > now {
+		return mw.classify(nil, jwtgo.NewValidationError("Token authenticated in the future", jwtgo.ValidationErrorNotValidYet))
+	}
+	return nil
+}
+
+func validateExpired(claims jwtgo.MapClaims, now time.Time) error {
 	if tokenExp, ok := claims["exp"]; ok {
 		if exp, ok := tokenExp.(float64); ok {
-			now := time.Now().Unix()
-			fmt.Printf("current unixtime : %v\n", now)
-			fmt.Printf("expire unixtime  : %v\n", int64(exp))
-			if int64(exp) > now {
+			if int64(exp) > now.Unix() {
 				return nil
 			}
 		}
-		return errors.New("cannot parse token exp")
+		return tokenError(ErrTokenExpired, errors.New("cannot parse token exp"))
 	}
-	return errors.New("token is expired")
+	return ErrTokenExpired
 }
 
-func convertKey(rawE, rawN string) *rsa.PublicKey {
+func convertKey(rawE, rawN string) (*rsa.PublicKey, error) {
 	decodedE, err := base64.RawURLEncoding.DecodeString(rawE)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("decoding the exponent: %w", err)
+	}
+	if len(decodedE) == 0 || len(decodedE) > 4 {
+		return nil, fmt.Errorf("the exponent is %d bytes long, expecting 1 to 4", len(decodedE))
 	}
 	if len(decodedE) < 4 {
 		ndata := make([]byte, 4)
@@ -336,27 +1138,44 @@ func convertKey(rawE, rawN string) *rsa.PublicKey {
 		N: &big.Int{},
 		E: int(binary.BigEndian.Uint32(decodedE[:])),
 	}
+	if pubKey.E < 3 || pubKey.E%2 == 0 {
+		return nil, fmt.Errorf("the exponent %d is not an odd number greater than 2", pubKey.E)
+	}
 	decodedN, err := base64.RawURLEncoding.DecodeString(rawN)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("decoding the modulus: %w", err)
 	}
 	pubKey.N.SetBytes(decodedN)
-	return pubKey
+	if pubKey.N.Sign() == 0 {
+		return nil, errors.New("the modulus is empty")
+	}
+	return pubKey, nil
 }
 
 // Download the json web public key for the given user pool id
-func getJWK(jwkURL string) (map[string]JWKKey, error) {
-	Info.Printf("Downloading the jwk from the given url %s", jwkURL)
+func getJWK(logger Logger, jwkURL string) (map[string]JWKKey, error) {
+	return getJWKContext(context.Background(), logger, jwkURL)
+}
+
+// getJWKContext downloads the json web key set within the deadline of ctx
+func getJWKContext(ctx context.Context, logger Logger, jwkURL string) (map[string]JWKKey, error) {
+	logger.Info("Downloading the jwk", "url", jwkURL)
 	jwk := &JWK{}
 
-	var myClient = &http.Client{Timeout: 10 * time.Second}
-	r, err := myClient.Get(jwkURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwkURL, nil)
 	if err != nil {
-		return nil, err
+		return nil, &JWKSError{URL: jwkURL, Err: err}
+	}
+	r, err := cognitoHTTPClient.Do(req)
+	if err != nil {
+		return nil, &JWKSError{URL: jwkURL, Err: err}
+	}
+	defer closeBody(r)
+	if r.StatusCode != http.StatusOK {
+		return nil, &JWKSError{URL: jwkURL, StatusCode: r.StatusCode, Err: fmt.Errorf("unexpected status %s", r.Status)}
 	}
-	defer r.Body.Close()
 	if err := json.NewDecoder(r.Body).Decode(jwk); err != nil {
-		return nil, err
+		return nil, &JWKSError{URL: jwkURL, StatusCode: r.StatusCode, Err: fmt.Errorf("decoding the jwk: %w", err)}
 	}
 
 	jwkMap := make(map[string]JWKKey, 0)
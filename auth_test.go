@@ -1,27 +1,91 @@
 package jwt
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/assert"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 const (
 	ExpiredCognitoToken = "eyJraWQiOiJsY2ZiTlVjNm9CYVlrMTlpRGhsVnI2OUk2ZTZcL3hCQTAzakk5SkhiM2lmST0iLCJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJkZDAzODg3OS0xMTA2LTRkZjMtOTFhZS0wM2UwYjc5Zjc4NDMiLCJldmVudF9pZCI6ImY4ZTQ4NGI0LWVmMzEtNDIxNy05NjRmLTNhZWZkM2NlNWZjNSIsInRva2VuX3VzZSI6ImFjY2VzcyIsInNjb3BlIjoiYXdzLmNvZ25pdG8uc2lnbmluLnVzZXIuYWRtaW4iLCJhdXRoX3RpbWUiOjE1NjM4NzEwMjQsImlzcyI6Imh0dHBzOlwvXC9jb2duaXRvLWlkcC5ldS13ZXN0LTIuYW1hem9uYXdzLmNvbVwvZXUtd2VzdC0yX25VV05zeWx6VCIsImV4cCI6MTU2Mzg3NDYyNCwiaWF0IjoxNTYzODcxMDI0LCJqdGkiOiJhOGYwMmJjMC0xZTM0LTQxMmItYjE3Yi1hMTAyYWM3YTIxNjkiLCJjbGllbnRfaWQiOiI0MjNhNWNjNnRqNWkzYW1kbWgwYXIycmszIiwidXNlcm5hbWUiOiIzYmI3MWUxNS00NjQ5LTQ0MjktYjE3MS1iMjEwNTlhYmQwZjAifQ.KnRZ6gEVwZNRPmULRk9VA7HlhAViOnwMPezakuBHXwNHFieThlJR6y8uMhcVS4bm0Du55PkIjVWkFgl9G1aiRgtd2k6vVtJHw_PPoe6VbvKDuus3ZSyu9NCD4DBF10_dEsEw3CibfrAxislw0-AEGZT_DegZgHWV5rzMBFZYeOJ7ptxpyykQOhkL7NtN1kB7BwBIUKMGw7mUAOGkPXC5RuKNPbUj4FFt-OmQX4-mDYNeQY6zkLrLt9eizf4N1CKR1WjMdeBHUrIgfrXuY1ZGrD9ZQGgEqzT2wZ9ZO3lNtBm1t65sQvvJTfTDwQb1z-dV1yXCravMd28g9fC8Jda9XQ"
 	CheckMark           = "\u2713"
 	BallotX             = "\u2717"
+
+	TestRegion     = "eu-west-2"
+	TestUserPoolID = "eu-west-2_testpool"
+	TestKid        = "test-kid"
 )
 
+// testKey the RSA key used to sign the tokens issued in the tests
+var testKey = mustGenerateKey()
+
+func mustGenerateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// testJWK the public json web key matching testKey
+func testJWK() map[string]JWKKey {
+	return map[string]JWKKey{
+		TestKid: {
+			Alg: "RS256",
+			Kid: TestKid,
+			Kty: "RSA",
+			Use: "sig",
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(testKey.E)).Bytes()),
+			N:   base64.RawURLEncoding.EncodeToString(testKey.N.Bytes()),
+		},
+	}
+}
+
+// newTestMiddleware creates a middleware trusting the tokens issued by signToken
+func newTestMiddleware() *AuthMiddleware {
+	return &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID, JWK: testJWK()}
+}
+
+// testClaims valid access token claims for the test user pool
+func testClaims() jwtgo.MapClaims {
+	return jwtgo.MapClaims{
+		"sub":       "user-123",
+		"iss":       fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v", TestRegion, TestUserPoolID),
+		"token_use": "access",
+		"client_id": "test-client",
+		"username":  "jdoe",
+		"iat":       time.Now().Unix(),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// signToken signs the given claims with the test key
+func signToken(claims jwtgo.MapClaims) string {
+	token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims)
+	token.Header["kid"] = TestKid
+	tokenStr, err := token.SignedString(testKey)
+	if err != nil {
+		panic(err)
+	}
+	return tokenStr
+}
+
 func Test_MissingAuthorizationHeader(t *testing.T) {
 	t.Logf("Given the authorization header is not set")
 	{
-		middleware := AuthMiddleware{UserPoolID: "some_user_id_pool", Region: "some_region"}
+		middleware := AuthMiddleware{UserPoolID: "some_user_id_pool", Region: "some_region", TokenLookup: "header:" + AuthorizationHeader}
 		emptyMap := http.Header{}
-		request := http.Request{Header: emptyMap}
-		ctx := gin.Context{Request: &request}
-		_, err := middleware.jwtFromHeader(&ctx, AuthorizationHeader)
+		_, err := middleware.ExtractToken(emptyMap.Get)
 		assert.NotNil(t, err)
 		assert.Equal(t, "auth header empty", err.Error())
 		expectedErrorMessage := "auth header empty"
@@ -33,6 +97,24 @@ func Test_MissingAuthorizationHeader(t *testing.T) {
 	}
 }
 
+func Test_BearerScheme(t *testing.T) {
+	t.Logf("Given tokens presented with and without the Bearer scheme of the challenges")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw)
+		token := signToken(testClaims())
+
+		t.Logf("Then the scheme is stripped whatever its case")
+		for _, value := range []string{token, "Bearer " + token, "bearer " + token, "BEARER " + token} {
+			assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", value).Code)
+		}
+
+		t.Logf("And the scheme alone is a missing token")
+		_, err := mw.ExtractToken(http.Header{AuthorizationHeader: {"Bearer "}}.Get)
+		assert.Equal(t, AuthHeaderEmptyError, err)
+	}
+}
+
 func Test_CognitoTokenExpiredShouldResultInUnauthorisedError(t *testing.T) {
 	t.Logf("Given the middleWareImpl method has been invoked with  an expired token")
 	{
@@ -52,6 +134,15 @@ func Test_CognitoTokenExpiredShouldResultInUnauthorisedError(t *testing.T) {
 	}
 }
 
+func Test_ValidCognitoTokenShouldBeAccepted(t *testing.T) {
+	t.Logf("Given the middleWareImpl method has been invoked with a valid token")
+	{
+		router := ginHandler(newTestMiddleware())
+		response := performRequest(router, "GET", "/auth/list", signToken(testClaims()))
+		assert.Equal(t, http.StatusOK, response.Code)
+	}
+}
+
 func performRequest(r http.Handler, method, path string, token string) *httptest.ResponseRecorder {
 	headers := http.Header{}
 	headers.Add(AuthorizationHeader, token)
@@ -79,3 +170,160 @@ func ginHandler(auth *AuthMiddleware) *gin.Engine {
 func testHandler(c *gin.Context) {
 	c.JSON(200, "success")
 }
+
+func Test_MachineToMachineMode(t *testing.T) {
+	t.Logf("Given a service only serving other services")
+	{
+		mw := newTestMiddleware()
+		mw.MachineToMachine = true
+		router := authzHandler(mw, mw.RequireScopes("orders/read"))
+
+		machine := testClaims()
+		delete(machine, "sub")
+		delete(machine, "username")
+		machine["scope"] = "orders/read"
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(machine)).Code)
+
+		user := testClaims()
+		user["scope"] = "orders/read"
+		w := performRequest(router, "GET", "/orders", signToken(user))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "wrong_token_use")
+	}
+}
+
+func Test_ExtractTokenDoesNotAllocate(t *testing.T) {
+	t.Logf("Given a request carrying its token in the authorization header")
+	{
+		mw := newTestMiddleware()
+		mw.MiddlewareInit()
+		header := func(string) string { return "token" }
+		allocs := testing.AllocsPerRun(100, func() {
+			mw.ExtractToken(header)
+		})
+		assert.Equal(t, 0.0, allocs)
+	}
+}
+
+func Test_NewForUserPool(t *testing.T) {
+	t.Logf("Given a middleware created for the user pool")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+
+		mw, err := NewForUserPool(TestRegion, TestUserPoolID)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v", TestRegion, TestUserPoolID), mw.Iss)
+		assert.Equal(t, 1, mw.KeyCount())
+		router := authzHandler(mw)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+	}
+
+	t.Logf("Given a middleware downloading the keys from another url")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+		url := fmt.Sprintf(jwkURLFormat, TestRegion, TestUserPoolID)
+		jwkURLFormat = "https://unreachable.invalid/%v/%v"
+
+		mw, err := NewForUserPool(TestRegion, TestUserPoolID, func(mw *AuthMiddleware) { mw.JWKURL = url })
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), downloads)
+		assert.Equal(t, 1, mw.KeyCount())
+	}
+
+	t.Logf("Given an invalid user pool")
+	{
+		_, err := NewForUserPool(TestRegion, "eu-west-1_x")
+		assert.ErrorContains(t, err, "the user pool eu-west-1_x does not belong to the region eu-west-2")
+	}
+}
+
+func Test_NewWithContext(t *testing.T) {
+	t.Logf("Given a middleware created once the startup deadline has passed")
+	{
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := NewWithContext(ctx, TestRegion, TestUserPoolID, func(mw *AuthMiddleware) {
+			mw.JWKURL = server.URL + "/jwks.json"
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, ErrJWKSUnavailable)
+	}
+
+	t.Logf("Given a token validated once the context is cancelled")
+	{
+		mw := newTestMiddleware()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := mw.ValidateTokenContext(ctx, signToken(testClaims()))
+		assert.ErrorIs(t, err, context.Canceled)
+
+		_, err = mw.ValidateTokenContext(context.Background(), signToken(testClaims()))
+		assert.NoError(t, err)
+	}
+}
+
+func Test_ConvertKey(t *testing.T) {
+	t.Logf("Given the exponent and modulus of a json web key")
+	{
+		jwk := testJWK()[TestKid]
+		key, err := convertKey(jwk.E, jwk.N)
+		assert.NoError(t, err)
+		assert.Equal(t, testKey.E, key.E)
+		assert.Equal(t, testKey.N, key.N)
+
+		t.Logf("Then the malformed values are reported as errors")
+		for _, raw := range [][2]string{{"not base64!", jwk.N}, {jwk.E, "not base64!"}, {"", jwk.N}, {jwk.E, ""}, {"AQIDBAUG", jwk.N}} {
+			key, err = convertKey(raw[0], raw[1])
+			assert.Error(t, err)
+			assert.Nil(t, key)
+		}
+	}
+}
+
+func Test_TimeFunc(t *testing.T) {
+	t.Logf("Given a middleware whose clock is simulated")
+	{
+		now := time.Now()
+		mw := newTestMiddleware()
+		mw.TokenCache = NewTokenCache(2)
+		mw.TimeFunc = func() time.Time { return now }
+		token := signToken(testClaims())
+		_, err := mw.validateToken(token, mw.log())
+		assert.NoError(t, err)
+
+		t.Logf("Then the token is expired once the clock passes its exp, cached or not")
+		now = now.Add(2 * time.Hour)
+		_, err = mw.validateToken(token, mw.log())
+		assert.ErrorIs(t, err, ErrTokenExpired)
+		_, err = mw.validateToken(token, mw.log())
+		assert.ErrorIs(t, err, ErrTokenExpired)
+
+		t.Logf("And the tokens issued, valid or authenticated after the clock are not valid yet")
+		now = time.Now().Add(-time.Hour)
+		for _, claim := range []string{"iat", "nbf", "auth_time"} {
+			claims := testClaims()
+			claims[claim] = time.Now().Unix()
+			_, err = mw.validateToken(signToken(claims), mw.log())
+			assert.ErrorIs(t, err, ErrTokenNotValidYet, claim)
+			var validationErr *jwtgo.ValidationError
+			assert.ErrorAs(t, err, &validationErr, claim)
+		}
+
+		t.Logf("And the expired tokens are accepted when the clock is set back")
+		claims := testClaims()
+		claims["iat"] = time.Now().Add(-3 * time.Hour).Unix()
+		claims["exp"] = time.Now().Add(-2 * time.Hour).Unix()
+		now = time.Now().Add(-150 * time.Minute)
+		_, err = mw.validateToken(signToken(claims), mw.log())
+		assert.NoError(t, err)
+	}
+}
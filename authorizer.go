@@ -0,0 +1,231 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AuthorizationRequest the input handed over to an external Authorizer
+type AuthorizationRequest struct {
+	Subject string          `json:"sub"`
+	Method  string          `json:"method"`
+	Route   string          `json:"route"`
+	Path    string          `json:"path"`
+	Claims  jwtgo.MapClaims `json:"claims"`
+
+	// IP and RequestID describe the request, they are not part of the cache key of the CachedAuthorizer
+	IP        string `json:"ip,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Authorizer an external authorization engine (OPA, Casbin, webhook...) consulted once the token is validated
+type Authorizer interface {
+	Authorize(ctx context.Context, request AuthorizationRequest) (bool, error)
+}
+
+// AuthorizerFunc adapter to use an ordinary function as an Authorizer
+type AuthorizerFunc func(ctx context.Context, request AuthorizationRequest) (bool, error)
+
+// Authorize calls f(ctx, request)
+func (f AuthorizerFunc) Authorize(ctx context.Context, request AuthorizationRequest) (bool, error) {
+	return f(ctx, request)
+}
+
+// authorizeExternal consults the external Authorizer, denying the request when it fails unless AuthorizerFailOpen
+// is on
+func (mw *AuthMiddleware) authorizeExternal(ctx context.Context, c *gin.Context, principal Principal) bool {
+	if mw.Authorizer == nil {
+		return true
+	}
+	if err := mw.deadline(ctx); err != nil {
+		mw.audit(c, principal, AuditUnauthenticated, failureReason(err))
+		mw.unauthorized(c, err)
+		return false
+	}
+	start := time.Now()
+	request := AuthorizationRequest{
+		Subject: principal.ID(),
+		Method:  c.Request.Method,
+		Route:   c.FullPath(),
+		Path:    c.Request.URL.Path,
+		Claims:  principal.Claims(),
+
+		IP:        c.ClientIP(),
+		RequestID: c.GetString(RequestIDKey),
+	}
+	allowed, err := mw.Authorizer.Authorize(ctx, request)
+	if timeout := mw.deadline(ctx); err != nil && timeout != nil {
+		mw.audit(c, principal, AuditUnauthenticated, failureReason(timeout))
+		mw.unauthorized(c, timeout)
+		return false
+	}
+	decision := Decision{Allowed: allowed && err == nil}
+	if err != nil {
+		mw.requestLog(c).Error("External authorization failed", "sub", principal.ID(), "fail_open", mw.AuthorizerFailOpen, "error", err)
+		mw.reportError(err, map[string]string{"operation": "external_authorization", "route": c.Request.Method + " " + c.FullPath()})
+		decision.Allowed = mw.AuthorizerFailOpen
+		decision.Reason = err.Error()
+	}
+	if !decision.Allowed {
+		if decision.Reason == "" {
+			decision.Reason = "denied by external authorizer"
+		}
+		mw.recordDecision(c, principal, decision, start)
+		mw.forbidden(c, accessError(ErrAccessDenied, "access denied"))
+		return false
+	}
+	mw.recordDecision(c, principal, decision, start)
+	return true
+}
+
+// CacheMetrics the counters of a cache
+type CacheMetrics struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// DefaultAuthorizerCacheSize the number of decisions held by a CachedAuthorizer created with no positive size
+const DefaultAuthorizerCacheSize = 10000
+
+// tokenInstanceClaims the claims identifying a token rather than its caller, left out of the cache key of the
+// CachedAuthorizer so that the tokens renewed by a caller share its decisions
+var tokenInstanceClaims = []string{"jti", "origin_jti", "event_id", "iat", "nbf", "exp", "auth_time"}
+
+// CachedAuthorizer caches the decisions of an Authorizer keyed by subject, route and a hash of the claims, but for the
+// ones identifying the token such as its jti, iat and exp, keeping the per request latency flat under load. The
+// Authorizer must hence not depend on them. Errors are never cached. It is safe for concurrent use.
+type CachedAuthorizer struct {
+	authorizer Authorizer
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]decisionEntry
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type decisionEntry struct {
+	subject string
+	allowed bool
+	expires time.Time
+}
+
+// NewCachedAuthorizer wraps the authorizer with a cache holding at most maxEntries decisions for ttl,
+// DefaultAuthorizerCacheSize when maxEntries is not positive
+func NewCachedAuthorizer(authorizer Authorizer, ttl time.Duration, maxEntries int) *CachedAuthorizer {
+	if maxEntries <= 0 {
+		maxEntries = DefaultAuthorizerCacheSize
+	}
+	return &CachedAuthorizer{
+		authorizer: authorizer,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]decisionEntry),
+	}
+}
+
+// Authorize returns the cached decision or consults the wrapped authorizer
+func (a *CachedAuthorizer) Authorize(ctx context.Context, request AuthorizationRequest) (bool, error) {
+	key := decisionKey(request)
+	now := time.Now()
+
+	a.mu.Lock()
+	entry, ok := a.entries[key]
+	a.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		atomic.AddUint64(&a.hits, 1)
+		return entry.allowed, nil
+	}
+	atomic.AddUint64(&a.misses, 1)
+
+	allowed, err := a.authorizer.Authorize(ctx, request)
+	if err != nil {
+		return allowed, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.entries[key]; !ok && len(a.entries) >= a.maxEntries {
+		a.evict(now)
+	}
+	a.entries[key] = decisionEntry{subject: request.Subject, allowed: allowed, expires: now.Add(a.ttl)}
+	return allowed, nil
+}
+
+// evict drops the expired entries, or an arbitrary one when none has expired. Callers must hold the lock.
+func (a *CachedAuthorizer) evict(now time.Time) {
+	evicted := 0
+	for key, entry := range a.entries {
+		if !now.Before(entry.expires) {
+			delete(a.entries, key)
+			evicted++
+		}
+	}
+	if evicted == 0 {
+		for key := range a.entries {
+			delete(a.entries, key)
+			evicted++
+			break
+		}
+	}
+	atomic.AddUint64(&a.evictions, uint64(evicted))
+}
+
+// Invalidate drops the decisions cached for the given subject
+func (a *CachedAuthorizer) Invalidate(subject string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, entry := range a.entries {
+		if entry.subject == subject {
+			delete(a.entries, key)
+		}
+	}
+}
+
+// Purge drops all the cached decisions
+func (a *CachedAuthorizer) Purge() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = make(map[string]decisionEntry)
+}
+
+// Metrics returns the cache counters
+func (a *CachedAuthorizer) Metrics() CacheMetrics {
+	a.mu.Lock()
+	entries := len(a.entries)
+	a.mu.Unlock()
+	return CacheMetrics{
+		Hits:      atomic.LoadUint64(&a.hits),
+		Misses:    atomic.LoadUint64(&a.misses),
+		Evictions: atomic.LoadUint64(&a.evictions),
+		Entries:   entries,
+	}
+}
+
+// decisionKey the cache key of a request: subject, method, route and a hash of the claims but the tokenInstanceClaims
+func decisionKey(request AuthorizationRequest) string {
+	claims := make(jwtgo.MapClaims, len(request.Claims))
+	for name, value := range request.Claims {
+		claims[name] = value
+	}
+	for _, name := range tokenInstanceClaims {
+		delete(claims, name)
+	}
+	// json encodes the map keys in sorted order, hence a stable hash
+	encoded, _ := json.Marshal(claims)
+	hash := sha256.Sum256(encoded)
+	return strings.Join([]string{request.Subject, request.Method, request.Route, request.Path, hex.EncodeToString(hash[:])}, "\x00")
+}
@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_ExternalAuthorizerIsConsulted(t *testing.T) {
+	t.Logf("Given an external authorizer allowing the admins only")
+	{
+		mw := newTestMiddleware()
+		mw.Authorizer = AuthorizerFunc(func(ctx context.Context, request AuthorizationRequest) (bool, error) {
+			if request.Route != "/auth/list" {
+				return false, errors.New("unexpected route " + request.Route)
+			}
+			return len(Groups(request.Claims)) > 0 && Groups(request.Claims)[0] == "admins", nil
+		})
+		router := ginHandler(mw)
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/auth/list", tokenWithGroups("admins")).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/auth/list", tokenWithGroups("users")).Code)
+	}
+}
+
+func Test_CachedAuthorizer(t *testing.T) {
+	t.Logf("Given a cached authorizer")
+	{
+		calls := 0
+		authorizer := NewCachedAuthorizer(AuthorizerFunc(func(ctx context.Context, request AuthorizationRequest) (bool, error) {
+			calls++
+			if request.Subject == "broken" {
+				return false, errors.New("unavailable")
+			}
+			return request.Subject == "alice", nil
+		}), time.Minute, 2)
+
+		alice := AuthorizationRequest{Subject: "alice", Method: "GET", Route: "/orders", Claims: testClaims()}
+		for i := 0; i < 3; i++ {
+			allowed, err := authorizer.Authorize(context.Background(), alice)
+			assert.Nil(t, err)
+			assert.True(t, allowed)
+		}
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, CacheMetrics{Hits: 2, Misses: 1, Entries: 1}, authorizer.Metrics())
+
+		t.Logf("\tWhen the authorizer fails the error is not cached")
+		broken := AuthorizationRequest{Subject: "broken", Method: "GET", Route: "/orders"}
+		authorizer.Authorize(context.Background(), broken)
+		authorizer.Authorize(context.Background(), broken)
+		assert.Equal(t, 3, calls)
+
+		t.Logf("\tWhen the subject is invalidated the authorizer is consulted again")
+		authorizer.Invalidate("alice")
+		authorizer.Authorize(context.Background(), alice)
+		assert.Equal(t, 4, calls)
+
+		t.Logf("\tWhen the cache is full an entry is evicted")
+		authorizer.Authorize(context.Background(), AuthorizationRequest{Subject: "bob"})
+		authorizer.Authorize(context.Background(), AuthorizationRequest{Subject: "carol"})
+		assert.Equal(t, 2, authorizer.Metrics().Entries)
+		assert.Equal(t, uint64(1), authorizer.Metrics().Evictions)
+
+		authorizer.Purge()
+		assert.Equal(t, 0, authorizer.Metrics().Entries)
+	}
+
+	t.Logf("Given a cached authorizer created without a positive size")
+	{
+		authorizer := NewCachedAuthorizer(AuthorizerFunc(func(ctx context.Context, request AuthorizationRequest) (bool, error) {
+			return true, nil
+		}), time.Minute, 0)
+
+		t.Logf("\tWhen the caller renews its token the decision is shared")
+		for i := 0; i < 3; i++ {
+			claims := testClaims()
+			claims["jti"] = fmt.Sprint("token-", i)
+			claims["iat"] = time.Now().Add(time.Duration(i) * time.Minute).Unix()
+			claims["exp"] = time.Now().Add(time.Hour + time.Duration(i)*time.Minute).Unix()
+			authorizer.Authorize(context.Background(), AuthorizationRequest{Subject: "alice", Method: "GET", Route: "/orders", Claims: claims})
+		}
+		assert.Equal(t, CacheMetrics{Hits: 2, Misses: 1, Entries: 1}, authorizer.Metrics())
+
+		t.Logf("\tWhen many subjects are authorized the cache stays bounded")
+		for i := 0; i < DefaultAuthorizerCacheSize+10; i++ {
+			authorizer.Authorize(context.Background(), AuthorizationRequest{Subject: fmt.Sprint("user-", i)})
+		}
+		assert.Equal(t, DefaultAuthorizerCacheSize, authorizer.Metrics().Entries)
+	}
+}
@@ -0,0 +1,214 @@
+package jwt
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"time"
+)
+
+const (
+
+	// InsufficientScope the RFC 6750 error code of a token lacking the required scopes
+	InsufficientScope = "insufficient_scope"
+
+	// InvalidToken the RFC 6750 error code of an expired, malformed or otherwise invalid token
+	InvalidToken = "invalid_token"
+
+	// InvalidDPoPProof the RFC 9449 error code of a missing or invalid DPoP proof
+	InvalidDPoPProof = "invalid_dpop_proof"
+)
+
+// MatchMode how a list of required values is matched against the values presented by the token
+type MatchMode int
+
+const (
+
+	// MatchAny at least one of the required values must be presented
+	MatchAny MatchMode = iota
+
+	// MatchAll every required value must be presented
+	MatchAll
+)
+
+// RequireGroups returns a handler rejecting with 403 the callers which are not members of the
+// given cognito:groups. Whether any or all of the groups are needed is driven by GroupsMatch.
+// It must be chained after MiddlewareFunc.
+func (mw *AuthMiddleware) RequireGroups(groups ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			mw.unauthorized(c, ErrMissingHeader)
+			return
+		}
+
+		if mw.authorizeGroups(c, principal, mw.GroupsMatch, groups) {
+			c.Next()
+		}
+	}
+}
+
+// RequireScopes returns a handler rejecting with 403 the access tokens which were not granted
+// all the given scopes. As per RFC 6750 the response carries an insufficient_scope error, the
+// missing scopes are recorded in the error detail. It must be chained after MiddlewareFunc.
+func (mw *AuthMiddleware) RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			mw.unauthorized(c, ErrMissingHeader)
+			return
+		}
+
+		if mw.authorizeScopes(c, principal, scopes) {
+			c.Next()
+		}
+	}
+}
+
+// RequireOwnership returns a handler rejecting with 403 the callers accessing a resource they do not own:
+// the path parameter (or query parameter when there is no such path parameter) named param must equal the
+// given claim, sub when empty. It must be chained after MiddlewareFunc.
+func (mw *AuthMiddleware) RequireOwnership(param, claim string) gin.HandlerFunc {
+	if claim == "" {
+		claim = "sub"
+	}
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			mw.unauthorized(c, ErrMissingHeader)
+			return
+		}
+
+		start := time.Now()
+		value, ok := c.Params.Get(param)
+		if !ok {
+			value = c.Query(param)
+		}
+		owner, _ := principal.Claims()[claim].(string)
+		decision := Decision{Allowed: value != "" && value == owner}
+		if !decision.Allowed {
+			mw.requestLog(c).Debug("Principal is not the owner of the resource", "sub", principal.ID(), param, value)
+			decision.Reason = fmt.Sprintf("%s does not match the %s claim", param, claim)
+			mw.recordDecision(c, principal, decision, start)
+			mw.forbidden(c, accessError(ErrNotOwner, "access restricted to the owner of the resource"))
+			return
+		}
+		mw.recordDecision(c, principal, decision, start)
+		c.Next()
+	}
+}
+
+// authorizeGroups aborts with 403 when the principal is not member of the required groups
+func (mw *AuthMiddleware) authorizeGroups(c *gin.Context, principal Principal, mode MatchMode, groups []string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	start := time.Now()
+	presented := mw.effectiveGroups(principal)
+	decision := Decision{RequiredGroups: groups, PresentedGroups: presented, Allowed: mw.HasGroups(principal, mode, groups...)}
+	if decision.Allowed {
+		mw.recordDecision(c, principal, decision, start)
+		return true
+	}
+
+	mw.requestLog(c).Debug("Principal is not member of the required groups", "sub", principal.ID(), "groups", groups)
+	decision.Reason = "missing groups"
+	mw.recordDecision(c, principal, decision, start)
+	mw.forbidden(c, accessError(ErrMissingGroups, fmt.Sprintf("requires membership of groups: %s", strings.Join(groups, ", "))))
+	return false
+}
+
+// authorizeScopes aborts with 403 when the principal is not an access token granted all the required scopes
+func (mw *AuthMiddleware) authorizeScopes(c *gin.Context, principal Principal, scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	start := time.Now()
+	absent := MissingScopes(principal, scopes...)
+	decision := Decision{RequiredScopes: scopes, PresentedScopes: principal.Scopes(), Allowed: len(absent) == 0}
+	if decision.Allowed {
+		mw.recordDecision(c, principal, decision, start)
+		return true
+	}
+
+	mw.requestLog(c).Debug("Principal has not been granted the scopes", "sub", principal.ID(), "scopes", absent)
+	decision.Reason = InsufficientScope
+	mw.recordDecision(c, principal, decision, start)
+	c.Header(AuthenticateHeader, mw.ScopeChallenge(scopes))
+	c.Set(ErrorCodeKey, InsufficientScope)
+	c.Set(ErrorDetailKey, absent)
+	mw.forbidden(c, accessError(ErrInsufficientScope, fmt.Sprintf("requires scopes: %s", strings.Join(absent, ", "))))
+	return false
+}
+
+// HasGroups whether the principal is member of any or all, as per mode, of the given groups taking
+// the GroupHierarchy into account
+func (mw *AuthMiddleware) HasGroups(principal Principal, mode MatchMode, groups ...string) bool {
+	return matches(mode, groups, mw.effectiveGroups(principal))
+}
+
+// MissingScopes returns the scopes which were not granted to the principal, all of them unless
+// it presented an access token
+func MissingScopes(principal Principal, scopes ...string) []string {
+	if tokenUse, _ := principal.Claims()["token_use"].(string); tokenUse != "access" {
+		return scopes
+	}
+	return missing(scopes, principal.Scopes())
+}
+
+// effectiveGroups the groups of the principal expanded with the groups they include as per GroupHierarchy
+func (mw *AuthMiddleware) effectiveGroups(principal Principal) []string {
+	groups := principal.Groups()
+	if len(mw.GroupHierarchy) == 0 {
+		return groups
+	}
+	return expandGroups(groups, mw.GroupHierarchy)
+}
+
+// expandGroups walks the hierarchy to add the groups transitively included by the given ones
+func expandGroups(groups []string, hierarchy map[string][]string) []string {
+	seen := make(map[string]bool, len(groups))
+	queue := append([]string{}, groups...)
+	for len(queue) > 0 {
+		group := queue[0]
+		queue = queue[1:]
+		if seen[group] {
+			continue
+		}
+		seen[group] = true
+		queue = append(queue, hierarchy[group]...)
+	}
+
+	expanded := make([]string, 0, len(seen))
+	for group := range seen {
+		expanded = append(expanded, group)
+	}
+	return normalize(expanded)
+}
+
+// matches checks the presented values against the required ones according to the given mode
+func matches(mode MatchMode, required, presented []string) bool {
+	if len(required) == 0 {
+		return true
+	}
+	absent := missing(required, presented)
+	if mode == MatchAll {
+		return len(absent) == 0
+	}
+	return len(absent) < len(required)
+}
+
+// missing returns the required values which are not presented
+func missing(required, presented []string) []string {
+	set := make(map[string]bool, len(presented))
+	for _, value := range presented {
+		set[value] = true
+	}
+	var result []string
+	for _, value := range required {
+		if !set[value] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+// authzHandler router with a single route protected by the middleware and the given handlers
+func authzHandler(mw *AuthMiddleware, handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/orders", append([]gin.HandlerFunc{mw.MiddlewareFunc()}, append(handlers, testHandler)...)...)
+	return r
+}
+
+func tokenWithGroups(groups ...string) string {
+	claims := testClaims()
+	claims[GroupsClaim] = groups
+	return signToken(claims)
+}
+
+func Test_RequireGroupsAnyOf(t *testing.T) {
+	t.Logf("Given a route requiring any of the admins or ops groups")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw, mw.RequireGroups("admins", "ops"))
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", tokenWithGroups("ops")).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", tokenWithGroups("users")).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+	}
+}
+
+func Test_RequireGroupsAllOf(t *testing.T) {
+	t.Logf("Given a route requiring both the admins and ops groups")
+	{
+		mw := newTestMiddleware()
+		mw.GroupsMatch = MatchAll
+		router := authzHandler(mw, mw.RequireGroups("admins", "ops"))
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", tokenWithGroups("ops", "admins")).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", tokenWithGroups("ops")).Code)
+	}
+}
+
+func Test_RequireGroupsWithoutToken(t *testing.T) {
+	t.Logf("Given a route requiring a group and no token")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw, mw.RequireGroups("admins"))
+
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", "").Code)
+	}
+}
+
+func Test_RequireScopes(t *testing.T) {
+	t.Logf("Given a route requiring the orders/read and orders/write scopes")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw, mw.RequireScopes("orders/read", "orders/write"))
+
+		claims := testClaims()
+		claims[ScopeClaim] = "orders/write orders/read"
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(claims)).Code)
+
+		claims[ScopeClaim] = "orders/read"
+		response := performRequest(router, "GET", "/orders", signToken(claims))
+		assert.Equal(t, http.StatusForbidden, response.Code)
+		assert.Contains(t, response.Header().Get(AuthenticateHeader), `error="insufficient_scope"`)
+		assert.JSONEq(t, `{"code":403,"message":"requires scopes: orders/write","error":"insufficient_scope","detail":["orders/write"]}`, withoutErrorID(t, response.Body.String()))
+	}
+}
+
+func Test_RequireScopesRejectsIdTokens(t *testing.T) {
+	t.Logf("Given an id token presented to a route requiring scopes")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw, mw.RequireScopes("orders/read"))
+
+		claims := testClaims()
+		claims["token_use"] = "id"
+		claims[ScopeClaim] = "orders/read"
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", signToken(claims)).Code)
+	}
+}
+
+func Test_RequireGroupsWithHierarchy(t *testing.T) {
+	t.Logf("Given the hierarchy admin > editor > viewer")
+	{
+		mw := newTestMiddleware()
+		mw.GroupHierarchy = map[string][]string{"admin": {"editor"}, "editor": {"viewer"}}
+		router := authzHandler(mw, mw.RequireGroups("viewer"))
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", tokenWithGroups("admin")).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", tokenWithGroups("viewer")).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", tokenWithGroups("guest")).Code)
+	}
+}
+
+func Test_ExpandGroupsWithCycle(t *testing.T) {
+	t.Logf("Given a hierarchy with a cycle")
+	{
+		hierarchy := map[string][]string{"a": {"b"}, "b": {"a", "c"}}
+		assert.Equal(t, []string{"a", "b", "c"}, expandGroups([]string{"a"}, hierarchy))
+	}
+}
+
+func Test_RequireOwnership(t *testing.T) {
+	t.Logf("Given routes restricted to the owner of the resource")
+	{
+		mw := newTestMiddleware()
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/users/:id", mw.MiddlewareFunc(), mw.RequireOwnership("id", ""), testHandler)
+		router.GET("/profiles", mw.MiddlewareFunc(), mw.RequireOwnership("username", "username"), testHandler)
+		token := signToken(testClaims())
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/users/user-123", token).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/users/user-456", token).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/profiles?username=jdoe", token).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/profiles", token).Code)
+	}
+}
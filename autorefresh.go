@@ -0,0 +1,106 @@
+package jwt
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"sync"
+	"time"
+)
+
+// autoRefreshTimeout the deadline of the automatic refresh of the tokens, the response waiting for it
+const autoRefreshTimeout = 10 * time.Second
+
+// autoRefresh refreshes the tokens of the request in the background, when they are about to expire in cookie
+// mode, while the handlers run. The refreshed token cookies are set on the response before it is written. The
+// refresh is shared by the concurrent requests presenting the same refresh token, e.g. the parallel requests of a
+// page: it is bounded by the autoRefreshTimeout alone, so that a cancelled request does not fail it for the others,
+// while each request waits for it within its own context. The returned func completes the refresh once the handlers
+// returned.
+func (mw *AuthMiddleware) autoRefresh(c *gin.Context) func() {
+	if mw.RefreshBefore <= 0 || !strings.HasPrefix(mw.TokenLookup, COOKIE+":") {
+		return func() {}
+	}
+	expiresAt, ok := c.Get(TokenExpiryKey)
+	if !ok || expiresAt.(time.Time).Sub(mw.now()) > mw.RefreshBefore {
+		return func() {}
+	}
+	refreshToken, err := c.Cookie(mw.RefreshTokenCookie)
+	if err != nil || refreshToken == "" {
+		return func() {}
+	}
+
+	var username string
+	if principal, ok := GetPrincipal(c); ok {
+		username = NewClaims(principal.Claims()).Username
+	}
+	logger := mw.requestLog(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), autoRefreshTimeout)
+	result := make(chan *Tokens, 1)
+	go func() {
+		defer cancel()
+		refreshed := mw.shared().tokenRefreshes.DoChan(refreshToken, func() (interface{}, error) {
+			refreshCtx, cancelRefresh := context.WithTimeout(context.WithoutCancel(ctx), autoRefreshTimeout)
+			defer cancelRefresh()
+			return mw.RefreshTokens(refreshCtx, refreshToken, username)
+		})
+		var tokens *Tokens
+		select {
+		case outcome := <-refreshed:
+			if outcome.Err != nil {
+				logger.Warn("Failed to refresh the tokens about to expire", "error", outcome.Err)
+				break
+			}
+			tokens = outcome.Val.(*Tokens)
+		case <-ctx.Done():
+			logger.Warn("Failed to refresh the tokens about to expire", "error", ctx.Err())
+		}
+		result <- tokens
+	}()
+
+	writer := &refreshingWriter{ResponseWriter: c.Writer, c: c, result: result, refreshCookie: mw.RefreshTokenCookie}
+	c.Writer = writer
+	return func() {
+		writer.apply()
+		c.Writer = writer.ResponseWriter
+	}
+}
+
+// refreshingWriter sets the refreshed token cookies before the response is written
+type refreshingWriter struct {
+	gin.ResponseWriter
+	c             *gin.Context
+	result        chan *Tokens
+	refreshCookie string
+	once          sync.Once
+}
+
+// apply waits for the refresh and sets the cookies of the refreshed tokens, if any
+func (w *refreshingWriter) apply() {
+	w.once.Do(func() {
+		tokens := <-w.result
+		if tokens != nil && !w.ResponseWriter.Written() {
+			setTokenCookies(w.c, tokens, w.refreshCookie)
+		}
+	})
+}
+
+func (w *refreshingWriter) WriteHeaderNow() {
+	w.apply()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *refreshingWriter) Write(data []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *refreshingWriter) WriteString(s string) (int, error) {
+	w.apply()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *refreshingWriter) Flush() {
+	w.apply()
+	w.ResponseWriter.Flush()
+}
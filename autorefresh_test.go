@@ -0,0 +1,142 @@
+package jwt
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_AutoRefresh(t *testing.T) {
+	t.Logf("Given a browser session whose access token is about to expire")
+	{
+		calls := 0
+		defer cognitoServer(func(action string, input map[string]interface{}) (int, interface{}) {
+			calls++
+			assert.Equal(t, "refresh", input["AuthParameters"].(map[string]interface{})["REFRESH_TOKEN"])
+			return http.StatusOK, map[string]interface{}{
+				"AuthenticationResult": map[string]interface{}{"IdToken": "new-id", "AccessToken": "new-access", "ExpiresIn": 3600},
+			}
+		})()
+		mw := newTestMiddleware()
+		mw.TokenLookup = COOKIE + ":" + AccessTokenCookie
+		mw.RefreshBefore = 5 * time.Minute
+		router := authzHandler(mw)
+
+		request := func(expiresIn time.Duration) *httptest.ResponseRecorder {
+			claims := testClaims()
+			claims["exp"] = time.Now().Add(expiresIn).Unix()
+			req, _ := http.NewRequest("GET", "/orders", nil)
+			req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: signToken(claims)})
+			req.AddCookie(&http.Cookie{Name: DefaultRefreshTokenCookie, Value: "refresh"})
+			req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: "csrf"})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		w := request(time.Hour)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+		assert.Equal(t, 0, calls)
+
+		w = request(2 * time.Minute)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"success"`, w.Body.String())
+		cookies := map[string]string{}
+		for _, cookie := range w.Result().Cookies() {
+			cookies[cookie.Name] = cookie.Value
+		}
+		assert.Equal(t, map[string]string{IDTokenCookie: "new-id", AccessTokenCookie: "new-access"}, cookies)
+		assert.Equal(t, 1, calls)
+	}
+
+	t.Logf("Given the concurrent requests of a page presenting the same refresh token")
+	{
+		var calls int32
+		defer cognitoServer(func(action string, input map[string]interface{}) (int, interface{}) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(200 * time.Millisecond)
+			return http.StatusOK, map[string]interface{}{
+				"AuthenticationResult": map[string]interface{}{"IdToken": "new-id", "AccessToken": "new-access", "ExpiresIn": 3600},
+			}
+		})()
+		mw := newTestMiddleware()
+		mw.TokenLookup = COOKIE + ":" + AccessTokenCookie
+		mw.RefreshBefore = 5 * time.Minute
+		router := authzHandler(mw)
+		claims := testClaims()
+		claims["exp"] = time.Now().Add(2 * time.Minute).Unix()
+		token := signToken(claims)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, _ := http.NewRequest("GET", "/orders", nil)
+				req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: token})
+				req.AddCookie(&http.Cookie{Name: DefaultRefreshTokenCookie, Value: "refresh"})
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.NotEmpty(t, w.Result().Cookies())
+			}()
+		}
+		wg.Wait()
+
+		t.Logf("Then the tokens are refreshed once for all of them")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	}
+
+	t.Logf("Given the request starting the shared refresh cancelled while it is in progress")
+	{
+		var calls int32
+		defer cognitoServer(func(action string, input map[string]interface{}) (int, interface{}) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(200 * time.Millisecond)
+			return http.StatusOK, map[string]interface{}{
+				"AuthenticationResult": map[string]interface{}{"IdToken": "new-id", "AccessToken": "new-access", "ExpiresIn": 3600},
+			}
+		})()
+		mw := newTestMiddleware()
+		mw.TokenLookup = COOKIE + ":" + AccessTokenCookie
+		mw.RefreshBefore = 5 * time.Minute
+		router := authzHandler(mw)
+		claims := testClaims()
+		claims["exp"] = time.Now().Add(2 * time.Minute).Unix()
+		token := signToken(claims)
+		request := func(ctx context.Context) *httptest.ResponseRecorder {
+			req, _ := http.NewRequestWithContext(ctx, "GET", "/orders", nil)
+			req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: token})
+			req.AddCookie(&http.Cookie{Name: DefaultRefreshTokenCookie, Value: "refresh"})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		cancelled := make(chan *httptest.ResponseRecorder)
+		go func() { cancelled <- request(ctx) }()
+		time.Sleep(20 * time.Millisecond)
+		w := request(context.Background())
+
+		t.Logf("Then the other requests still get the refreshed tokens")
+		refreshed := func(w *httptest.ResponseRecorder) bool {
+			for _, cookie := range w.Result().Cookies() {
+				if cookie.Name == AccessTokenCookie && cookie.Value == "new-access" {
+					return true
+				}
+			}
+			return false
+		}
+		assert.False(t, refreshed(<-cancelled))
+		assert.True(t, refreshed(w))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	}
+}
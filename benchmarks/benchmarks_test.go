@@ -0,0 +1,84 @@
+package benchmarks
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// benchmarkRouter a router serving /orders behind a middleware trusting the tokens of the issuer
+func benchmarkRouter(b *testing.B, configure func(*jwt.AuthMiddleware)) (*jwttest.Issuer, *gin.Engine) {
+	issuer, err := jwttest.NewIssuer()
+	if err != nil {
+		b.Fatal(err)
+	}
+	mw := issuer.Middleware()
+	if configure != nil {
+		configure(mw)
+	}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("/orders", mw.MiddlewareFunc(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return issuer, router
+}
+
+// benchmarkRequests serves a request carrying the token b.N times, expecting the given status
+func benchmarkRequests(b *testing.B, router *gin.Engine, token string, status int) {
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(jwt.AuthorizationHeader, token)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != status {
+			b.Fatalf("expected %d, got %d", status, w.Code)
+		}
+	}
+}
+
+func BenchmarkMiddlewareValidToken(b *testing.B) {
+	issuer, router := benchmarkRouter(b, nil)
+	token, _ := issuer.Sign(issuer.Claims("user-123"))
+	benchmarkRequests(b, router, token, http.StatusOK)
+}
+
+func BenchmarkMiddlewareCachedToken(b *testing.B) {
+	issuer, router := benchmarkRouter(b, func(mw *jwt.AuthMiddleware) {
+		mw.TokenCache = jwt.NewTokenCache(1000)
+	})
+	token, _ := issuer.Sign(issuer.Claims("user-123"))
+	benchmarkRequests(b, router, token, http.StatusOK)
+}
+
+func BenchmarkMiddlewareExpired(b *testing.B) {
+	issuer, router := benchmarkRouter(b, nil)
+	claims := issuer.Claims("user-123")
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	token, _ := issuer.Sign(claims)
+	benchmarkRequests(b, router, token, http.StatusUnauthorized)
+}
+
+func BenchmarkMiddlewareMalformed(b *testing.B) {
+	_, router := benchmarkRouter(b, nil)
+	benchmarkRequests(b, router, "garbage", http.StatusUnauthorized)
+}
+
+func BenchmarkExtractToken(b *testing.B) {
+	mw := &jwt.AuthMiddleware{Region: jwttest.Region, UserPoolID: jwttest.UserPoolID}
+	mw.MiddlewareInit()
+	header := func(string) string { return "token" }
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mw.ExtractToken(header); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package benchmarks benchmarks the hot path of the middleware, so that the changes do not regress the latency
+// and the allocations per request. Run them with
+//
+//	go test -bench . -benchmem ./benchmarks
+package benchmarks
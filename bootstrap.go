@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// UserPool a Cognito user pool
+type UserPool struct {
+	Region     string
+	UserPoolID string
+}
+
+// Iss the issuer of the tokens of the user pool
+func (p UserPool) Iss() string {
+	return fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v", p.Region, p.UserPoolID)
+}
+
+// BootstrapPolicy how Bootstrap handles the user pools whose json web key set could not be downloaded
+type BootstrapPolicy int
+
+const (
+
+	// RequireAllPools fails the bootstrap when the key set of any user pool could not be downloaded
+	RequireAllPools BootstrapPolicy = iota
+
+	// AllowPartialPools fails the bootstrap only when no key set could be downloaded. The middlewares of the
+	// other user pools reject every token until RefreshJWK succeeds, their Stats report the LastRefreshError.
+	AllowPartialPools
+)
+
+// Bootstrap creates the middlewares of the given user pools, downloading their json web key sets in parallel
+// rather than one after the other, within the deadline of ctx. The middlewares are returned in the order of
+// the user pools.
+func Bootstrap(ctx context.Context, pools []UserPool, policy BootstrapPolicy) ([]*AuthMiddleware, error) {
+	middlewares := make([]*AuthMiddleware, len(pools))
+	errs := make([]error, len(pools))
+	var wg sync.WaitGroup
+	for i, pool := range pools {
+		middlewares[i] = &AuthMiddleware{
+			Timeout:      time.Hour,
+			Unauthorized: errorResponse,
+			TokenLookup:  "header:" + AuthorizationHeader,
+			TimeFunc:     time.Now,
+			Iss:          pool.Iss(),
+			Region:       pool.Region,
+			UserPoolID:   pool.UserPoolID,
+		}
+		wg.Add(1)
+		go func(i int, mw *AuthMiddleware) {
+			defer wg.Done()
+			jwk, err := getJWKContext(ctx, NopLogger{}, mw.jwkURL())
+			if err != nil {
+				errs[i] = fmt.Errorf("user pool %s: %w", mw.UserPoolID, err)
+				mw.lastRefreshErr = err
+				return
+			}
+			mw.setJWK(jwk)
+		}(i, middlewares[i])
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 && (policy == RequireAllPools || failed == len(pools)) {
+		return nil, fmt.Errorf("bootstrapping the user pools: %w", errors.Join(errs...))
+	}
+	return middlewares, nil
+}
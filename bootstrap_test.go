@@ -0,0 +1,64 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Bootstrap(t *testing.T) {
+	t.Logf("Given user pools serving their json web key set slowly, one of them unavailable")
+	{
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			if strings.Contains(r.URL.Path, "unavailable") {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			keys := []JWKKey{}
+			for _, key := range testJWK() {
+				keys = append(keys, key)
+			}
+			json.NewEncoder(w).Encode(JWK{Keys: keys})
+		}))
+		defer server.Close()
+		defer func(format string) { jwkURLFormat = format }(jwkURLFormat)
+		jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+
+		pools := []UserPool{
+			{Region: TestRegion, UserPoolID: TestUserPoolID},
+			{Region: "eu-west-2", UserPoolID: "eu-west-2_second"},
+			{Region: "eu-west-2", UserPoolID: "eu-west-2_unavailable"},
+		}
+
+		t.Logf("When every user pool is required")
+		start := time.Now()
+		_, err := Bootstrap(context.Background(), pools, RequireAllPools)
+		assert.True(t, errors.Is(err, ErrJWKSUnavailable))
+		assert.Contains(t, err.Error(), "eu-west-2_unavailable")
+		assert.True(t, time.Since(start) < 500*time.Millisecond, "the key sets are downloaded in parallel")
+
+		t.Logf("When partial failures are allowed")
+		middlewares, err := Bootstrap(context.Background(), pools, AllowPartialPools)
+		assert.NoError(t, err)
+		assert.Len(t, middlewares, 3)
+		assert.Equal(t, 1, middlewares[0].KeyCount())
+		assert.Equal(t, pools[1].Iss(), middlewares[1].Iss)
+		assert.Equal(t, 0, middlewares[2].KeyCount())
+		assert.NotEmpty(t, middlewares[2].Stats().LastRefreshError)
+		_, err = middlewares[0].ValidateToken(signToken(testClaims()))
+		assert.NoError(t, err)
+
+		t.Logf("When the deadline expires before the key sets are downloaded")
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = Bootstrap(ctx, pools[:2], AllowPartialPools)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	}
+}
@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+)
+
+// MiddlewareBuilder builds an AuthMiddleware step by step, validating the configuration at Build time, e.g.
+//
+//	mw, err := jwt.Builder().Region("eu-west-2").UserPool("eu-west-2_x").RequireGroups("admin").Build()
+type MiddlewareBuilder struct {
+	mw *AuthMiddleware
+}
+
+// Builder starts building an AuthMiddleware
+func Builder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{mw: &AuthMiddleware{}}
+}
+
+// Region sets the AWS region of the user pool
+func (b *MiddlewareBuilder) Region(region string) *MiddlewareBuilder {
+	b.mw.Region = region
+	return b
+}
+
+// UserPool sets the ID of the user pool
+func (b *MiddlewareBuilder) UserPool(userPoolID string) *MiddlewareBuilder {
+	b.mw.UserPoolID = userPoolID
+	return b
+}
+
+// Issuer sets the expected issuer, the one of the user pool by default
+func (b *MiddlewareBuilder) Issuer(iss string) *MiddlewareBuilder {
+	b.mw.Iss = iss
+	return b
+}
+
+// ClientID sets the app client of the user pool, and its secret if any
+func (b *MiddlewareBuilder) ClientID(clientID, clientSecret string) *MiddlewareBuilder {
+	b.mw.ClientID = clientID
+	b.mw.ClientSecret = clientSecret
+	return b
+}
+
+// TokenLookup sets where the token is read from, e.g. "header:Authorization" or "cookie:access_token"
+func (b *MiddlewareBuilder) TokenLookup(lookup string) *MiddlewareBuilder {
+	b.mw.TokenLookup = lookup
+	return b
+}
+
+// RequireGroups requires the membership of any of the groups on every route, see GroupsMatch
+func (b *MiddlewareBuilder) RequireGroups(groups ...string) *MiddlewareBuilder {
+	b.mw.Required.Groups = append(b.mw.Required.Groups, groups...)
+	return b
+}
+
+// RequireScopes requires all the scopes on every route
+func (b *MiddlewareBuilder) RequireScopes(scopes ...string) *MiddlewareBuilder {
+	b.mw.Required.Scopes = append(b.mw.Required.Scopes, scopes...)
+	return b
+}
+
+// RequireTokenUse requires the given token_use, "id" or "access", on every route
+func (b *MiddlewareBuilder) RequireTokenUse(tokenUse string) *MiddlewareBuilder {
+	b.mw.Required.TokenUse = tokenUse
+	return b
+}
+
+// Routes sets the per route requirements
+func (b *MiddlewareBuilder) Routes(routes RouteTable) *MiddlewareBuilder {
+	b.mw.Routes = routes
+	return b
+}
+
+// PublicRoutes adds routes which do not require a token
+func (b *MiddlewareBuilder) PublicRoutes(routes ...string) *MiddlewareBuilder {
+	b.mw.PublicRoutes = append(b.mw.PublicRoutes, routes...)
+	return b
+}
+
+// Logger sets the logger of the middleware
+func (b *MiddlewareBuilder) Logger(logger Logger) *MiddlewareBuilder {
+	b.mw.Logger = logger
+	return b
+}
+
+// Metrics sets the metrics of the middleware
+func (b *MiddlewareBuilder) Metrics(metrics Metrics) *MiddlewareBuilder {
+	b.mw.Metrics = metrics
+	return b
+}
+
+// ErrorMode sets how much detail of the failures the responses disclose
+func (b *MiddlewareBuilder) ErrorMode(mode ErrorMode) *MiddlewareBuilder {
+	b.mw.ErrorMode = mode
+	return b
+}
+
+// JWK sets the json web keys, which are otherwise downloaded by Build
+func (b *MiddlewareBuilder) JWK(jwk map[string]JWKKey) *MiddlewareBuilder {
+	b.mw.JWK = jwk
+	return b
+}
+
+// Configure applies any other configuration to the middleware
+func (b *MiddlewareBuilder) Configure(configure func(*AuthMiddleware)) *MiddlewareBuilder {
+	configure(b.mw)
+	return b
+}
+
+// Build validates the configuration, returning all its problems at once (see Validate), and downloads the json web key set of
+// the user pool unless the keys were given
+func (b *MiddlewareBuilder) Build() (*AuthMiddleware, error) {
+	return b.BuildContext(context.Background())
+}
+
+// BuildContext is Build downloading the json web key set within the deadline of ctx
+func (b *MiddlewareBuilder) BuildContext(ctx context.Context) (*AuthMiddleware, error) {
+	mw := b.mw
+	if err := mw.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid middleware configuration: %w", err)
+	}
+	if mw.Iss == "" {
+		mw.Iss = UserPool{Region: mw.Region, UserPoolID: mw.UserPoolID}.Iss()
+	}
+	if mw.Unauthorized == nil {
+		mw.Unauthorized = errorResponse
+	}
+	if mw.JWK == nil && mw.Keys == nil {
+		jwk, err := getJWKContext(ctx, mw.log(), mw.jwkURL())
+		if err != nil {
+			return nil, err
+		}
+		mw.setJWK(jwk)
+	}
+	mw.MiddlewareInit()
+	return mw, nil
+}
@@ -0,0 +1,52 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_Builder(t *testing.T) {
+	t.Logf("Given a middleware built requiring the admin group on every route")
+	{
+		mw, err := Builder().
+			Region(TestRegion).
+			UserPool(TestUserPoolID).
+			JWK(testJWK()).
+			RequireGroups("admin").
+			Build()
+		assert.NoError(t, err)
+		assert.Equal(t, UserPool{Region: TestRegion, UserPoolID: TestUserPoolID}.Iss(), mw.Iss)
+		router := authzHandler(mw)
+
+		admin := testClaims()
+		admin[GroupsClaim] = []interface{}{"admin"}
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(admin)).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		assert.NotNil(t, mw.CheckRoute(NewPrincipal(testClaims()), "GET", "/orders"))
+	}
+
+	t.Logf("Given an invalid configuration")
+	{
+		_, err := Builder().
+			Region("Ireland").
+			UserPool("eu-west-2_x").
+			TokenLookup("query:token").
+			RequireTokenUse("refresh").
+			Build()
+		assert.Error(t, err)
+		for _, problem := range []string{
+			`the region "Ireland" is not an AWS region`,
+			`the token lookup "query:token"`,
+			`the required token_use "refresh"`,
+		} {
+			assert.Contains(t, err.Error(), problem)
+		}
+
+		_, err = Builder().Region("eu-west-1").UserPool("eu-west-2_x").Build()
+		assert.EqualError(t, err, "invalid middleware configuration: the user pool eu-west-2_x does not belong to the region eu-west-1")
+
+		_, err = Builder().Build()
+		assert.EqualError(t, err, "invalid middleware configuration: the region is required\nthe user pool ID is required")
+	}
+}
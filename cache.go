@@ -0,0 +1,44 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache a map whose entries expire after a time to live
+type ttlCache struct {
+	mu      sync.Mutex
+	entries map[string]ttlEntry
+}
+
+type ttlEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// get returns the value of the key unless it expired
+func (c *ttlCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !entry.expiresAt.After(time.Now()) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// set sets the value of the key until expiresAt, forgetting the entries which expired
+func (c *ttlCache) set(key string, value interface{}, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.entries == nil {
+		c.entries = map[string]ttlEntry{}
+	}
+	for k, entry := range c.entries {
+		if !entry.expiresAt.After(now) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = ttlEntry{value: value, expiresAt: expiresAt}
+}
@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"encoding/json"
+	jwtgo "github.com/golang-jwt/jwt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+
+	// GroupsClaim the claim holding the cognito user pool groups of the user
+	GroupsClaim = "cognito:groups"
+
+	// ScopeClaim the claim holding the space delimited OAuth scopes of an access token
+	ScopeClaim = "scope"
+
+	// RolesClaim the claim holding the IAM roles of the groups of the user
+	RolesClaim = "cognito:roles"
+
+	// PreferredRoleClaim the claim holding the IAM role of the group of the user with the best precedence
+	PreferredRoleClaim = "cognito:preferred_role"
+)
+
+// Claims the typed view of the claims of a Cognito token
+type Claims struct {
+
+	// Subject the sub claim, the unique identifier of the user
+	Subject string
+
+	// Issuer the iss claim, the user pool URL
+	Issuer string
+
+	// TokenUse the token_use claim: id or access
+	TokenUse string
+
+	// ClientID the app client the token was issued to: client_id for access tokens, aud for id tokens
+	ClientID string
+
+	// Username the username (access tokens) or cognito:username (id tokens) claim
+	Username string
+
+	// Groups the normalized cognito:groups claim
+	Groups []string
+
+	// Scopes the normalized scope claim
+	Scopes []string
+
+	// Roles the normalized cognito:roles claim, the IAM role ARNs of the groups of the user
+	Roles []string
+
+	// PreferredRole the cognito:preferred_role claim
+	PreferredRole string
+
+	// Machine whether the token was issued to an app client with the client credentials grant rather than
+	// to a user, see IsMachine
+	Machine bool
+
+	// ExpiresAt the exp claim
+	ExpiresAt time.Time
+
+	// IssuedAt the iat claim
+	IssuedAt time.Time
+
+	// Raw all the claims of the token
+	Raw jwtgo.MapClaims
+}
+
+// NewClaims creates the typed view of the given claims
+func NewClaims(raw jwtgo.MapClaims) *Claims {
+	claims := &Claims{
+		Groups: Groups(raw),
+		Scopes: Scopes(raw),
+		Roles:  Roles(raw),
+		Raw:    raw,
+	}
+	claims.PreferredRole, _ = raw[PreferredRoleClaim].(string)
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.TokenUse, _ = raw["token_use"].(string)
+	if clientID, ok := raw["client_id"].(string); ok {
+		claims.ClientID = clientID
+	} else {
+		claims.ClientID, _ = raw["aud"].(string)
+	}
+	if username, ok := raw["username"].(string); ok {
+		claims.Username = username
+	} else {
+		claims.Username, _ = raw["cognito:username"].(string)
+	}
+	claims.Machine = IsMachine(raw)
+	claims.ExpiresAt, _ = ExpiresAt(raw)
+	if iat, ok := raw["iat"].(float64); ok {
+		claims.IssuedAt = time.Unix(int64(iat), 0)
+	}
+	return claims
+}
+
+// Principal returns the Principal backed by the claims
+func (c *Claims) Principal() Principal {
+	return NewPrincipal(c.Raw)
+}
+
+// Groups returns the cognito:groups claim as a sorted list without duplicates or empty entries.
+// The claim is normally a JSON array, a single string value is tolerated.
+func Groups(claims jwtgo.MapClaims) []string {
+	return stringList(claims[GroupsClaim])
+}
+
+// Roles returns the cognito:roles claim as a sorted list without duplicates or empty entries
+func Roles(claims jwtgo.MapClaims) []string {
+	return stringList(claims[RolesClaim])
+}
+
+// stringList the normalized values of a JSON array claim, a single string value is tolerated
+func stringList(claim interface{}) []string {
+	var values []string
+	switch v := claim.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if value, ok := item.(string); ok {
+				values = append(values, value)
+			}
+		}
+	case []string:
+		values = append(values, v...)
+	case string:
+		values = append(values, v)
+	}
+	return normalize(values)
+}
+
+// Scopes returns the space delimited scope claim as a sorted list without duplicates.
+// A JSON array value is tolerated.
+func Scopes(claims jwtgo.MapClaims) []string {
+	var scopes []string
+	switch v := claims[ScopeClaim].(type) {
+	case string:
+		scopes = strings.Fields(v)
+	case []interface{}:
+		for _, item := range v {
+			if scope, ok := item.(string); ok {
+				scopes = append(scopes, strings.Fields(scope)...)
+			}
+		}
+	case []string:
+		for _, scope := range v {
+			scopes = append(scopes, strings.Fields(scope)...)
+		}
+	}
+	return normalize(scopes)
+}
+
+// IsMachine whether the claims are those of a machine to machine access token, issued to an app client with
+// the client credentials grant: such tokens carry the client_id and the resource server scopes but no username
+func IsMachine(claims jwtgo.MapClaims) bool {
+	tokenUse, _ := claims["token_use"].(string)
+	clientID, _ := claims["client_id"].(string)
+	_, hasUsername := claims["username"]
+	return tokenUse == "access" && clientID != "" && !hasUsername
+}
+
+// ExpiresAt returns the expiry time held in the exp claim
+func ExpiresAt(claims jwtgo.MapClaims) (time.Time, bool) {
+	switch exp := claims["exp"].(type) {
+	case float64:
+		return time.Unix(int64(exp), 0), true
+	case int64:
+		return time.Unix(exp, 0), true
+	case json.Number:
+		if v, err := exp.Int64(); err == nil {
+			return time.Unix(v, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalize trims, removes the empty and duplicate values and sorts the result
+func normalize(values []string) []string {
+	if len(values) == 0 {
+		return []string{}
+	}
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	sort.Strings(result)
+	return result
+}
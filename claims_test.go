@@ -0,0 +1,56 @@
+package jwt
+
+import (
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_GroupsAreNormalized(t *testing.T) {
+	t.Logf("Given cognito:groups claims in the different shapes found in tokens")
+	{
+		assert.Equal(t, []string{"admins", "ops"}, Groups(jwtgo.MapClaims{GroupsClaim: []interface{}{"ops", "admins", "ops", ""}}))
+		assert.Equal(t, []string{"admins", "ops"}, Groups(jwtgo.MapClaims{GroupsClaim: []string{"ops", "admins"}}))
+		assert.Equal(t, []string{"admins"}, Groups(jwtgo.MapClaims{GroupsClaim: "admins"}))
+		assert.Equal(t, []string{}, Groups(jwtgo.MapClaims{}))
+		assert.Equal(t, []string{}, Groups(jwtgo.MapClaims{GroupsClaim: 42.0}))
+	}
+}
+
+func Test_ScopesAreNormalized(t *testing.T) {
+	t.Logf("Given scope claims with irregular spacing and duplicates")
+	{
+		assert.Equal(t, []string{"openid", "orders/read"}, Scopes(jwtgo.MapClaims{ScopeClaim: " orders/read  openid orders/read "}))
+		assert.Equal(t, []string{"openid", "orders/read"}, Scopes(jwtgo.MapClaims{ScopeClaim: []interface{}{"orders/read", "openid"}}))
+		assert.Equal(t, []string{}, Scopes(jwtgo.MapClaims{}))
+	}
+}
+
+func Test_MachineToMachineClaims(t *testing.T) {
+	t.Logf("Given the claims of a client credentials access token")
+	{
+		raw := jwtgo.MapClaims{"token_use": "access", "client_id": "reporting-service", "scope": "orders/read"}
+		claims := NewClaims(raw)
+		assert.True(t, claims.Machine)
+		assert.Equal(t, "reporting-service", claims.Principal().ID())
+		assert.Equal(t, []string{"orders/read"}, claims.Principal().Scopes())
+
+		raw["sub"] = "reporting-service-sub"
+		assert.Equal(t, "reporting-service-sub", NewPrincipal(raw).ID())
+		assert.False(t, IsMachine(jwtgo.MapClaims{"token_use": "access", "client_id": "web", "username": "jdoe"}))
+	}
+}
+
+func Test_RoleClaims(t *testing.T) {
+	t.Logf("Given the claims of a user belonging to groups mapped to IAM roles")
+	{
+		claims := NewClaims(jwtgo.MapClaims{
+			"sub":              "user-1",
+			RolesClaim:         []interface{}{"arn:aws:iam::123:role/writers", "arn:aws:iam::123:role/readers"},
+			PreferredRoleClaim: "arn:aws:iam::123:role/writers",
+		})
+		assert.Equal(t, []string{"arn:aws:iam::123:role/readers", "arn:aws:iam::123:role/writers"}, claims.Roles)
+		assert.Equal(t, "arn:aws:iam::123:role/writers", claims.PreferredRole)
+		assert.Empty(t, NewClaims(jwtgo.MapClaims{"sub": "user-2"}).PreferredRole)
+	}
+}
@@ -0,0 +1,126 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// cognitoURLFormat the endpoint of the Cognito user pools API, formatted with the region
+var cognitoURLFormat = "https://cognito-idp.%v.amazonaws.com/"
+
+// awsTransport the transport of the calls to the json web key set and the Cognito APIs. The connections to the
+// few hosts called are kept alive and reused, avoiding the connection churn of the refresh storms.
+var awsTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   16,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: 10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// cognitoHTTPClient the client calling the json web key set and the Cognito API
+var cognitoHTTPClient = &http.Client{Timeout: 10 * time.Second, Transport: awsTransport}
+
+// CognitoError an error answered by the Cognito API, e.g. a NotAuthorizedException for a revoked refresh token
+type CognitoError struct {
+	StatusCode int
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+}
+
+func (e *CognitoError) Error() string {
+	return fmt.Sprintf("cognito %s: %s", e.Type, e.Message)
+}
+
+// Tokens the tokens issued by Cognito
+type Tokens struct {
+	IDToken      string `json:"id_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+
+	// DeviceKey and DeviceGroupKey the new device of the authentication, to confirm with ConfirmDevice
+	DeviceKey      string `json:"device_key,omitempty"`
+	DeviceGroupKey string `json:"device_group_key,omitempty"`
+}
+
+// authenticationResult the tokens of an InitiateAuth response
+type authenticationResult struct {
+	AccessToken  string
+	IDToken      string `json:"IdToken"`
+	RefreshToken string
+	ExpiresIn    int
+	TokenType    string
+
+	NewDeviceMetadata struct {
+		DeviceKey      string
+		DeviceGroupKey string
+	}
+}
+
+// callCognito calls the given action of the Cognito user pools JSON API, decoding the response into output.
+// Only the actions which do not require AWS credentials, such as InitiateAuth, are called this way.
+func (mw *AuthMiddleware) callCognito(ctx context.Context, action string, input, output interface{}) error {
+	return callAWS(ctx, fmt.Sprintf(cognitoURLFormat, mw.Region), "AWSCognitoIdentityProviderService."+action, input, output)
+}
+
+// callAWS calls the target of an AWS JSON API, decoding the response into output
+func callAWS(ctx context.Context, url, target string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	r, err := cognitoHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling %s: %w", target, err)
+	}
+	defer closeBody(r)
+	if r.StatusCode != http.StatusOK {
+		cognitoErr := &CognitoError{StatusCode: r.StatusCode}
+		if err := json.NewDecoder(r.Body).Decode(cognitoErr); err != nil {
+			cognitoErr.Message = r.Status
+		}
+		return cognitoErr
+	}
+	if err := json.NewDecoder(r.Body).Decode(output); err != nil {
+		return fmt.Errorf("decoding the %s response: %w", target, err)
+	}
+	return nil
+}
+
+// closeBody drains and closes the body of the response, so that its connection is reused
+func closeBody(r *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(r.Body, 64*1024))
+	r.Body.Close()
+}
+
+// secretHash the SECRET_HASH of the given username required when the app client has a secret
+func (mw *AuthMiddleware) secretHash(username string) string {
+	mac := hmac.New(sha256.New, []byte(mw.ClientSecret))
+	mac.Write([]byte(username + mw.ClientID))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test_ConcurrentRequests run with -race, exercises the middleware serving parallel requests while its keys
+// are refreshed
+func Test_ConcurrentRequests(t *testing.T) {
+	t.Logf("Given a middleware serving parallel requests while its json web key set is refreshed")
+	{
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []JWKKey{}
+			for _, key := range testJWK() {
+				keys = append(keys, key)
+			}
+			json.NewEncoder(w).Encode(JWK{Keys: keys})
+		}))
+		defer server.Close()
+		defer func(format string) { jwkURLFormat = format }(jwkURLFormat)
+		jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+
+		var audited int64
+		mw := newTestMiddleware()
+		mw.TokenCache = NewTokenCache(2)
+		mw.RejectionCache = NewRejectionCache(time.Minute, 2)
+		mw.Revocations = NewMemoryRevocationStore()
+		mw.FailureLogSampling = FailureSampling{Every: 10}
+		mw.AuditEvents = AuditSinkFunc(func(AuditEvent) { atomic.AddInt64(&audited, 1) })
+		router := authzHandler(mw)
+
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		tokens := []string{signToken(testClaims()), signToken(expired), "garbage", ""}
+		for i := 0; i < 3; i++ {
+			claims := testClaims()
+			claims["jti"] = i
+			tokens = append(tokens, signToken(claims))
+		}
+
+		const workers, requests = 8, 50
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+		refreshed := make(chan struct{})
+		go func() {
+			defer close(refreshed)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					assert.NoError(t, mw.RefreshJWK())
+					mw.Stats()
+				}
+			}
+		}()
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < requests; i++ {
+					token := tokens[(w+i)%len(tokens)]
+					code := performRequest(router, "GET", "/orders", token).Code
+					if token == tokens[1] || token == "garbage" || token == "" {
+						assert.Equal(t, http.StatusUnauthorized, code)
+					} else {
+						assert.Equal(t, http.StatusOK, code)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(stop)
+		<-refreshed
+
+		stats := mw.Stats()
+		var failures uint64
+		for _, count := range stats.Failures {
+			failures += count
+		}
+		assert.Equal(t, uint64(workers*requests), stats.Validated+failures)
+		assert.Equal(t, int64(workers*requests), atomic.LoadInt64(&audited))
+	}
+}
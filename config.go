@@ -0,0 +1,200 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config the declarative configuration of the middleware, loaded from a YAML or JSON file with LoadConfig so that
+// it is reviewed like any other deployment artifact, e.g.
+//
+//	region: eu-west-2
+//	user_pool_id: eu-west-2_x
+//	token_lookup: header:Authorization
+//	timeout: 2s
+//	required:
+//	  groups: [staff]
+//	routes:
+//	  GET /orders:
+//	    scopes: [orders/read]
+//	public_routes: [GET /health]
+type Config struct {
+	Region       string `json:"region" yaml:"region"`
+	UserPoolID   string `json:"user_pool_id" yaml:"user_pool_id"`
+	Issuer       string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	ClientID     string `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	Domain       string `json:"domain,omitempty" yaml:"domain,omitempty"`
+	RedirectURL  string `json:"redirect_url,omitempty" yaml:"redirect_url,omitempty"`
+	TokenLookup  string `json:"token_lookup,omitempty" yaml:"token_lookup,omitempty"`
+	Realm        string `json:"realm,omitempty" yaml:"realm,omitempty"`
+
+	// ErrorMode verbose (default) or production
+	ErrorMode        string `json:"error_mode,omitempty" yaml:"error_mode,omitempty"`
+	MachineToMachine bool   `json:"machine_to_machine,omitempty" yaml:"machine_to_machine,omitempty"`
+	StrictMode       bool   `json:"strict_mode,omitempty" yaml:"strict_mode,omitempty"`
+
+	// Timeout and RefreshBefore durations such as "2s" or "5m"
+	Timeout       Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	RefreshBefore Duration `json:"refresh_before,omitempty" yaml:"refresh_before,omitempty"`
+
+	// Required the requirements of every route
+	Required RouteConfig `json:"required,omitempty" yaml:"required,omitempty"`
+
+	// Routes the requirements keyed by route, e.g. "GET /orders/:id"
+	Routes       map[string]RouteConfig `json:"routes,omitempty" yaml:"routes,omitempty"`
+	PublicRoutes []string               `json:"public_routes,omitempty" yaml:"public_routes,omitempty"`
+}
+
+// RouteConfig the declarative RouteRequirement of a route
+type RouteConfig struct {
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+
+	// GroupsMatch any (default) or all
+	GroupsMatch string   `json:"groups_match,omitempty" yaml:"groups_match,omitempty"`
+	Scopes      []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	TokenUse    string   `json:"token_use,omitempty" yaml:"token_use,omitempty"`
+}
+
+// Duration a time.Duration written as a string, e.g. "1m30s", in the configuration files
+type Duration time.Duration
+
+// UnmarshalText parses the duration
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalText formats the duration
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// LoadConfig loads the configuration from a YAML (.yaml or .yml) or JSON (.json) file. The unknown fields are
+// rejected, so that a misspelt option does not go unnoticed.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return decodeConfig(path, data, false)
+	case ".json":
+		return decodeConfig(path, data, true)
+	default:
+		return nil, fmt.Errorf("unsupported configuration file extension %q, expecting .yaml, .yml or .json", ext)
+	}
+}
+
+// decodeConfig decodes the JSON or YAML configuration loaded from the source, rejecting the unknown fields
+func decodeConfig(source string, data []byte, isJSON bool) (*Config, error) {
+	config := &Config{}
+	var err error
+	if isJSON {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", source, err)
+	}
+	return config, nil
+}
+
+// Middleware creates the middleware of the configuration, validated as by the Builder, downloading the json web
+// key set of the user pool
+func (c *Config) Middleware() (*AuthMiddleware, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid middleware configuration: %w", err)
+	}
+	builder, _ := c.Builder()
+	return builder.Build()
+}
+
+// Validate checks the configuration, returning all its problems at once, see AuthMiddleware.Validate
+func (c *Config) Validate() error {
+	builder, err := c.Builder()
+	return errors.Join(err, builder.mw.Validate())
+}
+
+// Builder a Builder configured by the configuration, to be completed, e.g. with a Logger, before Build
+func (c *Config) Builder() (*MiddlewareBuilder, error) {
+	var errs []error
+	required, err := c.Required.requirement()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("required: %w", err))
+	}
+	var routes RouteTable
+	for route, config := range c.Routes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method == "" || !strings.HasPrefix(path, ForwardSlash) {
+			errs = append(errs, fmt.Errorf("the route %q is not of the form \"METHOD /path\"", route))
+			continue
+		}
+		requirement, err := config.requirement()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("route %s: %w", route, err))
+			continue
+		}
+		if routes == nil {
+			routes = RouteTable{}
+		}
+		routes[routeKey(method, path)] = requirement
+	}
+
+	builder := Builder().
+		Region(c.Region).
+		UserPool(c.UserPoolID).
+		Issuer(c.Issuer).
+		ClientID(c.ClientID, c.ClientSecret).
+		TokenLookup(c.TokenLookup).
+		Routes(routes).
+		PublicRoutes(c.PublicRoutes...)
+	switch c.ErrorMode {
+	case "", "verbose":
+	case "production":
+		builder.ErrorMode(ProductionErrors)
+	default:
+		errs = append(errs, fmt.Errorf("the error mode %q is neither verbose nor production", c.ErrorMode))
+	}
+	builder.Configure(func(mw *AuthMiddleware) {
+		mw.Required = required
+		mw.Domain = c.Domain
+		mw.RedirectURL = c.RedirectURL
+		mw.Realm = c.Realm
+		mw.MachineToMachine = c.MachineToMachine
+		mw.StrictMode = c.StrictMode
+		mw.Timeout = time.Duration(c.Timeout)
+		mw.RefreshBefore = time.Duration(c.RefreshBefore)
+	})
+	return builder, errors.Join(errs...)
+}
+
+// requirement the RouteRequirement of the configuration
+func (c RouteConfig) requirement() (RouteRequirement, error) {
+	requirement := RouteRequirement{Groups: c.Groups, Scopes: c.Scopes, TokenUse: c.TokenUse}
+	switch c.GroupsMatch {
+	case "", "any":
+	case "all":
+		requirement.GroupsMatch = MatchAll
+	default:
+		return requirement, fmt.Errorf("the groups match %q is neither any nor all", c.GroupsMatch)
+	}
+	return requirement, nil
+}
@@ -0,0 +1,104 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_LoadConfig(t *testing.T) {
+	t.Logf("Given the configuration of the middleware in a YAML file")
+	{
+		path := writeConfig(t, "auth.yaml", `
+region: eu-west-2
+user_pool_id: eu-west-2_testpool
+client_id: web
+token_lookup: cookie:access_token
+error_mode: production
+timeout: 2s
+refresh_before: 5m
+required:
+  groups: [staff]
+routes:
+  GET /orders/:id:
+    groups: [admin, ops]
+    groups_match: all
+    scopes: [orders/read]
+    token_use: access
+public_routes:
+  - GET /health
+`)
+		config, err := LoadConfig(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "eu-west-2_testpool", config.UserPoolID)
+		assert.Equal(t, Duration(2*time.Second), config.Timeout)
+
+		builder, err := config.Builder()
+		assert.NoError(t, err)
+		mw := builder.mw
+		assert.Equal(t, "web", mw.ClientID)
+		assert.Equal(t, ProductionErrors, mw.ErrorMode)
+		assert.Equal(t, 2*time.Second, mw.Timeout)
+		assert.Equal(t, 5*time.Minute, mw.RefreshBefore)
+		assert.Equal(t, []string{"staff"}, mw.Required.Groups)
+		assert.Equal(t, RouteRequirement{Groups: []string{"admin", "ops"}, GroupsMatch: MatchAll,
+			Scopes: []string{"orders/read"}, TokenUse: "access"}, mw.Routes["GET /orders/:id"])
+		assert.Equal(t, []string{"GET /health"}, mw.PublicRoutes)
+	}
+
+	t.Logf("Given the configuration of the middleware in a JSON file")
+	{
+		path := writeConfig(t, "auth.json", `{
+  "region": "eu-west-2",
+  "user_pool_id": "eu-west-2_testpool",
+  "routes": {"post /orders": {"scopes": ["orders/write"]}}
+}`)
+		config, err := LoadConfig(path)
+		assert.NoError(t, err)
+		builder, err := config.Builder()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"orders/write"}, builder.mw.Routes["POST /orders"].Scopes)
+	}
+
+	t.Logf("Given a configuration file with a misspelt option")
+	{
+		_, err := LoadConfig(writeConfig(t, "auth.yml", "region: eu-west-2\nuser_pool: eu-west-2_testpool\n"))
+		assert.ErrorContains(t, err, "user_pool")
+
+		_, err = LoadConfig(writeConfig(t, "auth.json", `{"region": "eu-west-2", "timeout": "soon"}`))
+		assert.ErrorContains(t, err, "soon")
+
+		_, err = LoadConfig(writeConfig(t, "auth.toml", "region = 'eu-west-2'"))
+		assert.ErrorContains(t, err, "unsupported configuration file extension")
+	}
+
+	t.Logf("Given an invalid configuration")
+	{
+		config := &Config{
+			UserPoolID: TestUserPoolID,
+			ErrorMode:  "quiet",
+			Required:   RouteConfig{GroupsMatch: "most"},
+			Routes:     map[string]RouteConfig{"/orders": {}},
+		}
+		_, err := config.Middleware()
+		assert.Error(t, err)
+		for _, problem := range []string{
+			`the error mode "quiet" is neither verbose nor production`,
+			`required: the groups match "most" is neither any nor all`,
+			`the route "/orders" is not of the form "METHOD /path"`,
+			"the region is required",
+		} {
+			assert.ErrorContains(t, err, problem)
+		}
+	}
+}
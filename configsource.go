@@ -0,0 +1,105 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ParameterStore fetches the parameters of the AWS Systems Manager Parameter Store. It is typically implemented with
+// the GetParameter API of the AWS SDK, with the decryption of the SecureString parameters, which requires AWS
+// credentials.
+type ParameterStore interface {
+	GetParameter(ctx context.Context, name string) (string, error)
+}
+
+// ParameterStoreFunc adapter to use an ordinary function as a ParameterStore
+type ParameterStoreFunc func(ctx context.Context, name string) (string, error)
+
+// GetParameter calls f(ctx, name)
+func (f ParameterStoreFunc) GetParameter(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// SecretStore fetches the secrets of AWS Secrets Manager. It is typically implemented with the GetSecretValue API
+// of the AWS SDK, returning the SecretString of the secret, which requires AWS credentials.
+type SecretStore interface {
+	GetSecretValue(ctx context.Context, secretID string) (string, error)
+}
+
+// SecretStoreFunc adapter to use an ordinary function as a SecretStore
+type SecretStoreFunc func(ctx context.Context, secretID string) (string, error)
+
+// GetSecretValue calls f(ctx, secretID)
+func (f SecretStoreFunc) GetSecretValue(ctx context.Context, secretID string) (string, error) {
+	return f(ctx, secretID)
+}
+
+// ConfigLoader loads the configuration of the middleware from a remote source, see ParameterConfig and SecretConfig
+type ConfigLoader func(ctx context.Context) (*Config, error)
+
+// ParameterConfig loads the configuration held by the parameter, a YAML or JSON document, so that the user pools,
+// the app clients and the route policies of each account are not baked into the images
+func ParameterConfig(store ParameterStore, name string) ConfigLoader {
+	return func(ctx context.Context) (*Config, error) {
+		value, err := store.GetParameter(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("fetching the parameter %s: %w", name, err)
+		}
+		return decodeRemoteConfig("parameter "+name, value)
+	}
+}
+
+// SecretConfig loads the configuration held by the secret, a YAML or JSON document, e.g. to keep the client secret
+// of the app client along with the rest of the configuration
+func SecretConfig(store SecretStore, secretID string) ConfigLoader {
+	return func(ctx context.Context) (*Config, error) {
+		value, err := store.GetSecretValue(ctx, secretID)
+		if err != nil {
+			return nil, fmt.Errorf("fetching the secret %s: %w", secretID, err)
+		}
+		return decodeRemoteConfig("secret "+secretID, value)
+	}
+}
+
+// decodeRemoteConfig decodes the configuration fetched from the source, a JSON document when it is an object,
+// a YAML document otherwise
+func decodeRemoteConfig(source, value string) (*Config, error) {
+	data := bytes.TrimSpace([]byte(value))
+	return decodeConfig(source, data, bytes.HasPrefix(data, []byte("{")))
+}
+
+// Poll loads the configuration every interval in the background until the context is done, reloading the
+// middleware when it changed. The configurations failing to load or validate are logged and ignored.
+func (r *Reloader) Poll(ctx context.Context, load ConfigLoader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			config, err := load(ctx)
+			if err == nil && !r.changed(config) {
+				continue
+			}
+			if err == nil {
+				err = r.Reload(config)
+			}
+			if err != nil && ctx.Err() == nil {
+				r.Middleware().log().Error("failed to reload the configuration", "error", err)
+			}
+		}
+	}()
+}
+
+// changed whether the configuration differs from the one of the current middleware
+func (r *Reloader) changed(config *Config) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !reflect.DeepEqual(config, r.config)
+}
@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_ParameterConfig(t *testing.T) {
+	t.Logf("Given the configuration of the middleware in a parameter")
+	{
+		store := ParameterStoreFunc(func(ctx context.Context, name string) (string, error) {
+			assert.Equal(t, "/orders/auth", name)
+			return "region: eu-west-2\nuser_pool_id: eu-west-2_testpool\nclient_id: web\n", nil
+		})
+		config, err := ParameterConfig(store, "/orders/auth")(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, &Config{Region: TestRegion, UserPoolID: TestUserPoolID, ClientID: "web"}, config)
+	}
+
+	t.Logf("Given a parameter which can not be fetched")
+	{
+		store := ParameterStoreFunc(func(ctx context.Context, name string) (string, error) {
+			return "", errors.New("AccessDeniedException")
+		})
+		_, err := ParameterConfig(store, "/orders/auth")(context.Background())
+		assert.ErrorContains(t, err, "fetching the parameter /orders/auth: AccessDeniedException")
+	}
+}
+
+func Test_SecretConfig(t *testing.T) {
+	t.Logf("Given the configuration of the middleware in a JSON secret")
+	{
+		store := SecretStoreFunc(func(ctx context.Context, secretID string) (string, error) {
+			return ` {"region": "eu-west-2", "user_pool_id": "eu-west-2_testpool", "client_id": "web", "client_secret": "s3cr3t"}`, nil
+		})
+		config, err := SecretConfig(store, "orders/auth")(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cr3t", config.ClientSecret)
+	}
+
+	t.Logf("Given a secret with an unknown option")
+	{
+		store := SecretStoreFunc(func(ctx context.Context, secretID string) (string, error) {
+			return `{"region": "eu-west-2", "pool": "eu-west-2_testpool"}`, nil
+		})
+		_, err := SecretConfig(store, "orders/auth")(context.Background())
+		assert.ErrorContains(t, err, "decoding secret orders/auth")
+	}
+}
+
+func Test_Poll(t *testing.T) {
+	t.Logf("Given a configuration polled from a remote source")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+
+		var mu sync.Mutex
+		value := `{"region": "eu-west-2", "user_pool_id": "eu-west-2_testpool"}`
+		load := SecretConfig(SecretStoreFunc(func(ctx context.Context, secretID string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return value, nil
+		}), "orders/auth")
+
+		config, err := load(context.Background())
+		assert.NoError(t, err)
+		var builds int32
+		reloader, err := NewReloader(config, func(mw *AuthMiddleware) { atomic.AddInt32(&builds, 1) })
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reloader.Poll(ctx, load, 5*time.Millisecond)
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&builds), "the unchanged configuration is not reloaded")
+
+		mu.Lock()
+		value = `{"region": "eu-west-2", "user_pool_id": "eu-west-2_testpool", "client_id": "rotated"}`
+		mu.Unlock()
+		assert.Eventually(t, func() bool { return reloader.Middleware().ClientID == "rotated" }, time.Second, 5*time.Millisecond)
+	}
+}
@@ -0,0 +1,88 @@
+// Package connectjwt provides a connect-go interceptor validating the Cognito tokens of RPC calls
+// through a jwt.AuthMiddleware, so RPC and REST services share one auth layer. The public routes and the
+// route requirements table are keyed by procedure, e.g. "POST /acme.orders.v1.OrderService/DeleteOrder".
+package connectjwt
+
+import (
+	"connectrpc.com/connect"
+	"context"
+	"errors"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+)
+
+// Interceptor validates the token of the incoming calls and stores the jwt.Principal in the context
+type Interceptor struct {
+	mw *jwt.AuthMiddleware
+}
+
+// NewInterceptor creates an interceptor for the given middleware, to be registered with connect.WithInterceptors
+func NewInterceptor(mw *jwt.AuthMiddleware) *Interceptor {
+	mw.MiddlewareInit()
+	return &Interceptor{mw: mw}
+}
+
+// WrapUnary authenticates the unary calls handled by the server
+func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		ctx, err := i.authenticate(ctx, req.Spec().Procedure, req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient leaves the client streams untouched
+func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler authenticates the streaming calls handled by the server
+func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.authenticate(ctx, conn.Spec().Procedure, conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *Interceptor) authenticate(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+	if i.mw.IsPublic(http.MethodPost, procedure) {
+		return ctx, nil
+	}
+
+	tokenStr, err := i.mw.ExtractToken(header.Get)
+	if err != nil {
+		return ctx, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+	token, err := i.mw.ValidateToken(tokenStr)
+	if err != nil {
+		return ctx, connect.NewError(errorCode(err), err)
+	}
+
+	principal := jwt.NewPrincipal(token.Claims.(jwtgo.MapClaims))
+	if authErr := i.mw.CheckRoute(principal, http.MethodPost, procedure); authErr != nil {
+		return ctx, connect.NewError(connect.CodePermissionDenied, errors.New(authErr.Message))
+	}
+	return jwt.ContextWithPrincipal(ctx, principal), nil
+}
+
+// errorCode the connect error code of the failed validation, as per its jwt.StatusCode
+func errorCode(err error) connect.Code {
+	switch jwt.StatusCode(err) {
+	case http.StatusForbidden:
+		return connect.CodePermissionDenied
+	case http.StatusTooManyRequests:
+		return connect.CodeResourceExhausted
+	case http.StatusServiceUnavailable:
+		return connect.CodeUnavailable
+	}
+	return connect.CodeUnauthenticated
+}
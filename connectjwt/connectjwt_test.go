@@ -0,0 +1,55 @@
+package connectjwt
+
+import (
+	"connectrpc.com/connect"
+	"context"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+// request a server side unary request for the given procedure
+type request struct {
+	connect.AnyRequest
+	procedure string
+	header    http.Header
+}
+
+func (r *request) Spec() connect.Spec {
+	return connect.Spec{Procedure: r.procedure}
+}
+
+func (r *request) Header() http.Header {
+	return r.header
+}
+
+func Test_WrapUnary(t *testing.T) {
+	t.Logf("Given a connect service protected by the interceptor")
+	{
+		issuer, _ := jwttest.NewIssuer()
+		mw := issuer.Middleware()
+		mw.Routes = jwt.RouteTable{"POST /orders.v1.OrderService/DeleteOrder": {Groups: []string{"admins"}}}
+		interceptor := NewInterceptor(mw)
+
+		var subject string
+		call := interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			principal, _ := jwt.PrincipalFromContext(ctx)
+			subject = principal.ID()
+			return nil, nil
+		})
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+		header := http.Header{jwt.AuthorizationHeader: {token}}
+
+		_, err := call(context.Background(), &request{procedure: "/orders.v1.OrderService/GetOrder", header: header})
+		assert.Nil(t, err)
+		assert.Equal(t, "user-123", subject)
+
+		_, err = call(context.Background(), &request{procedure: "/orders.v1.OrderService/GetOrder", header: http.Header{}})
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+		_, err = call(context.Background(), &request{procedure: "/orders.v1.OrderService/DeleteOrder", header: header})
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	}
+}
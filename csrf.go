@@ -0,0 +1,99 @@
+package jwt
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+const (
+
+	// CSRFCookie the cookie holding the CSRF token of the double submit protection, readable by the scripts of the site
+	CSRFCookie = "csrf_token"
+
+	// CSRFHeader the header the unsafe requests echo the CSRF token in
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CSRFMode the cross site request forgery protection of the requests authenticated by a cookie, either the token
+// cookie of the TokenLookup or the SessionCookie. The requests of the safe methods, GET, HEAD, OPTIONS and TRACE,
+// and the ones carrying their token in a header are never checked.
+type CSRFMode int
+
+const (
+
+	// CSRFDoubleSubmit the unsafe requests must echo the value of the CSRFCookie in the CSRFHeader. The cookie is set
+	// along with the token cookies and on the authenticated requests lacking it.
+	CSRFDoubleSubmit CSRFMode = iota
+
+	// CSRFCustomHeader the unsafe requests must carry the CSRFHeader, whatever its value, which a cross site form
+	// cannot set and a cross site script cannot set without passing the CORS preflight
+	CSRFCustomHeader
+
+	// CSRFDisabled the requests are not checked, e.g. when another middleware protects them
+	CSRFDisabled
+)
+
+// safeMethods the methods which must not change the state of the server, exempted from the CSRF protection
+var safeMethods = map[string]bool{http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true, http.MethodTrace: true}
+
+// cookieAuthenticated whether the request is authenticated by a cookie rather than a header
+func (mw *AuthMiddleware) cookieAuthenticated(c *gin.Context) bool {
+	if mw.Sessions != nil {
+		if _, err := c.Cookie(SessionCookie); err == nil {
+			return true
+		}
+	}
+	if name, ok := strings.CutPrefix(mw.TokenLookup, COOKIE+":"); ok && mw.Extractor == nil {
+		_, err := c.Cookie(name)
+		return err == nil
+	}
+	return false
+}
+
+// checkCSRF answers the unsafe requests authenticated by a cookie lacking the CSRF proof with a 403
+func (mw *AuthMiddleware) checkCSRF(c *gin.Context, logger Logger) bool {
+	if mw.CSRF == CSRFDisabled || safeMethods[c.Request.Method] || !mw.cookieAuthenticated(c) {
+		return true
+	}
+	header := c.GetHeader(CSRFHeader)
+	var err error
+	switch {
+	case header == "":
+		err = accessError(ErrCSRF, "the "+CSRFHeader+" header is missing")
+	case mw.CSRF == CSRFDoubleSubmit:
+		cookie, _ := c.Cookie(CSRFCookie)
+		if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			err = accessError(ErrCSRF, "the "+CSRFHeader+" header does not match the "+CSRFCookie+" cookie")
+		}
+	}
+	if err == nil {
+		return true
+	}
+	logger.Warn("Rejected a request lacking the CSRF proof", "error", err)
+	mw.forbidden(c, err)
+	return false
+}
+
+// issueCSRFCookie sets the CSRFCookie on the authenticated requests of the double submit protection lacking it
+func (mw *AuthMiddleware) issueCSRFCookie(c *gin.Context) {
+	if mw.CSRF != CSRFDoubleSubmit || !mw.cookieAuthenticated(c) {
+		return
+	}
+	if _, err := c.Cookie(CSRFCookie); err == nil {
+		return
+	}
+	setCSRFCookie(c)
+}
+
+// setCSRFCookie sets a new CSRF token in the CSRFCookie, readable by the scripts of the site to echo it
+func setCSRFCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    randomString(),
+		Path:     ForwardSlash,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,119 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// csrfHandler serves GET and POST /orders behind the middleware
+func csrfHandler(mw *AuthMiddleware) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/orders", mw.MiddlewareFunc(), testHandler)
+	r.POST("/orders", mw.MiddlewareFunc(), testHandler)
+	return r
+}
+
+// cookieRequest performs a request carrying the given cookies and CSRF header, if any
+func cookieRequest(r http.Handler, method, csrfHeader string, cookies map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/orders", nil)
+	for name, value := range cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	if csrfHeader != "" {
+		req.Header.Set(CSRFHeader, csrfHeader)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_CSRFDoubleSubmit(t *testing.T) {
+	t.Logf("Given a middleware reading the token from a cookie")
+	{
+		mw := newTestMiddleware()
+		mw.TokenLookup = COOKIE + ":" + AccessTokenCookie
+		router := csrfHandler(mw)
+		token := signToken(testClaims())
+
+		t.Logf("Then the safe requests are not checked and get the CSRF cookie")
+		w := cookieRequest(router, "GET", "", map[string]string{AccessTokenCookie: token})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var csrf string
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == CSRFCookie {
+				csrf = cookie.Value
+				assert.False(t, cookie.HttpOnly)
+			}
+		}
+		assert.NotEmpty(t, csrf)
+
+		t.Logf("And the cookie is not issued again")
+		w = cookieRequest(router, "GET", "", map[string]string{AccessTokenCookie: token, CSRFCookie: csrf})
+		assert.Empty(t, w.Result().Cookies())
+
+		t.Logf("And the unsafe requests must echo the CSRF cookie in the header")
+		w = cookieRequest(router, "POST", csrf, map[string]string{AccessTokenCookie: token, CSRFCookie: csrf})
+		assert.Equal(t, http.StatusOK, w.Code)
+		for name, header := range map[string]string{"missing": "", "mismatching": "forged"} {
+			w = cookieRequest(router, "POST", header, map[string]string{AccessTokenCookie: token, CSRFCookie: csrf})
+			assert.Equal(t, http.StatusForbidden, w.Code, name)
+			assert.Contains(t, w.Body.String(), CSRFHeader, name)
+		}
+		w = cookieRequest(router, "POST", csrf, map[string]string{AccessTokenCookie: token})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+
+	t.Logf("Given a middleware reading the token from a header")
+	{
+		router := csrfHandler(newTestMiddleware())
+
+		t.Logf("Then the unsafe requests are not checked")
+		req := httptest.NewRequest("POST", "/orders", nil)
+		req.Header.Set(AuthorizationHeader, signToken(testClaims()))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	t.Logf("Given a middleware with server side sessions")
+	{
+		mw := newTestMiddleware()
+		mw.Sessions = NewMemorySessionStore()
+		router := csrfHandler(mw)
+
+		t.Logf("Then the unsafe requests carrying the session cookie are checked")
+		w := cookieRequest(router, "POST", "", map[string]string{SessionCookie: "session-id"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+}
+
+func Test_CSRFModes(t *testing.T) {
+	t.Logf("Given the custom header protection")
+	{
+		mw := newTestMiddleware()
+		mw.TokenLookup = COOKIE + ":" + AccessTokenCookie
+		mw.CSRF = CSRFCustomHeader
+		router := csrfHandler(mw)
+		cookies := map[string]string{AccessTokenCookie: signToken(testClaims())}
+
+		t.Logf("Then the unsafe requests must carry the header, whatever its value")
+		assert.Equal(t, http.StatusOK, cookieRequest(router, "POST", "1", cookies).Code)
+		assert.Equal(t, http.StatusForbidden, cookieRequest(router, "POST", "", cookies).Code)
+		assert.Empty(t, cookieRequest(router, "GET", "", cookies).Result().Cookies())
+	}
+
+	t.Logf("Given the protection disabled")
+	{
+		mw := newTestMiddleware()
+		mw.TokenLookup = COOKIE + ":" + AccessTokenCookie
+		mw.CSRF = CSRFDisabled
+		router := csrfHandler(mw)
+
+		t.Logf("Then the unsafe requests are not checked")
+		assert.Equal(t, http.StatusOK, cookieRequest(router, "POST", "", map[string]string{AccessTokenCookie: signToken(testClaims())}).Code)
+	}
+}
@@ -0,0 +1,66 @@
+package jwt
+
+import (
+	jwtgo "github.com/golang-jwt/jwt"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the sensitive values in the debug logs
+const Redacted = "[REDACTED]"
+
+// DefaultSensitiveClaims the claims always redacted from the debug logs, in addition to the SensitiveClaims
+var DefaultSensitiveClaims = []string{"email", "phone_number", "address", "birthdate"}
+
+var emailPattern = regexp.MustCompile(`[^@\s]+@[^@\s]+\.[^@\s]+`)
+
+// logRejectedToken logs the decoded header and claims of a rejected token when Debug is on. The signature,
+// the sensitive claims and any value looking like an email address are redacted.
+func (mw *AuthMiddleware) logRejectedToken(logger Logger, token *jwtgo.Token) {
+	if !mw.Debug || token == nil {
+		return
+	}
+	claims, _ := token.Claims.(jwtgo.MapClaims)
+	logger.Debug("Decoded the rejected jwt token",
+		"header", redact(token.Header, nil),
+		"claims", redact(claims, mw.SensitiveClaims),
+		"signature", Redacted)
+}
+
+// redact returns a copy of values with the sensitive entries redacted
+func redact(values map[string]interface{}, sensitive []string) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if isSensitive(key, sensitive) {
+			redacted[key] = Redacted
+			continue
+		}
+		redacted[key] = redactValue(value)
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return emailPattern.ReplaceAllString(v, Redacted)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = redactValue(item)
+		}
+		return values
+	}
+	return value
+}
+
+func isSensitive(key string, sensitive []string) bool {
+	for _, candidates := range [][]string{DefaultSensitiveClaims, sensitive} {
+		for _, candidate := range candidates {
+			if strings.EqualFold(key, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_DebugLogsRedactedClaimsOfRejectedTokens(t *testing.T) {
+	t.Logf("Given a middleware in debug mode")
+	{
+		logger := &recordingLogger{}
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.Debug = true
+		mw.SensitiveClaims = []string{"custom:tenant_secret"}
+
+		claims := testClaims()
+		claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		claims["email"] = "jdoe@example.com"
+		claims["custom:tenant_secret"] = "s3cr3t"
+		claims["custom:contacts"] = []string{"ops@example.com"}
+		claims["custom:note"] = "escalate to boss@example.com"
+		mw.ValidateToken(signToken(claims))
+
+		entry := logger.entries[len(logger.entries)-1]
+		assert.Equal(t, "Decoded the rejected jwt token", entry.msg)
+		logged := map[string]interface{}{}
+		for i := 0; i < len(entry.keysAndValues); i += 2 {
+			logged[entry.keysAndValues[i].(string)] = entry.keysAndValues[i+1]
+		}
+		redacted := logged["claims"].(map[string]interface{})
+		assert.Equal(t, Redacted, redacted["email"])
+		assert.Equal(t, Redacted, redacted["custom:tenant_secret"])
+		assert.Equal(t, []interface{}{Redacted}, redacted["custom:contacts"])
+		assert.Equal(t, "escalate to "+Redacted, redacted["custom:note"])
+		assert.Equal(t, "user-123", redacted["sub"])
+		assert.Equal(t, TestKid, logged["header"].(map[string]interface{})["kid"])
+		assert.Equal(t, Redacted, logged["signature"])
+	}
+
+	t.Logf("Given a middleware not in debug mode")
+	{
+		logger := &recordingLogger{}
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.ValidateToken("not-a-token")
+		assert.Empty(t, logger.messages("debug"))
+	}
+}
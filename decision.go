@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// Decision an allow/deny authorization decision taken for a request
+type Decision struct {
+
+	// Subject the sub of the caller
+	Subject string
+
+	// Method the request method
+	Method string
+
+	// Route the gin route pattern
+	Route string
+
+	// RequiredGroups the groups required by the route
+	RequiredGroups []string `json:",omitempty"`
+
+	// PresentedGroups the groups of the caller, expanded as per the group hierarchy
+	PresentedGroups []string `json:",omitempty"`
+
+	// RequiredScopes the scopes required by the route
+	RequiredScopes []string `json:",omitempty"`
+
+	// PresentedScopes the scopes granted to the caller
+	PresentedScopes []string `json:",omitempty"`
+
+	// RequiredPermissions the RBAC permissions required by the route
+	RequiredPermissions []string `json:",omitempty"`
+
+	// Policy the ABAC policy expression evaluated for the route
+	Policy string `json:",omitempty"`
+
+	// Allowed the outcome of the decision
+	Allowed bool
+
+	// Reason why the request has been denied
+	Reason string `json:",omitempty"`
+
+	// Latency the time taken to reach the decision
+	Latency time.Duration
+}
+
+// DecisionSink receives every authorization decision, e.g. to build an audit trail
+type DecisionSink interface {
+	Record(Decision)
+}
+
+// DecisionSinkFunc adapter to use an ordinary function as a DecisionSink
+type DecisionSinkFunc func(Decision)
+
+// Record calls f(d)
+func (f DecisionSinkFunc) Record(d Decision) {
+	f(d)
+}
+
+// recordDecision completes the decision with the request details and hands it over to the sink
+func (mw *AuthMiddleware) recordDecision(c *gin.Context, principal Principal, decision Decision, start time.Time) {
+	if mw.Decisions == nil {
+		return
+	}
+	decision.Subject = principal.ID()
+	decision.Method = c.Request.Method
+	decision.Route = c.FullPath()
+	decision.Latency = time.Since(start)
+	mw.hook(func() { mw.Decisions.Record(decision) })
+}
@@ -0,0 +1,34 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_DecisionsAreRecorded(t *testing.T) {
+	t.Logf("Given a decision sink and a route requiring the admins group")
+	{
+		var decisions []Decision
+		mw := newTestMiddleware()
+		mw.Decisions = DecisionSinkFunc(func(d Decision) {
+			decisions = append(decisions, d)
+		})
+		router := authzHandler(mw, mw.RequireGroups("admins"))
+
+		performRequest(router, "GET", "/orders", tokenWithGroups("admins"))
+		performRequest(router, "GET", "/orders", tokenWithGroups("users"))
+
+		if assert.Len(t, decisions, 2) {
+			assert.True(t, decisions[0].Allowed)
+			assert.Equal(t, "user-123", decisions[0].Subject)
+			assert.Equal(t, "/orders", decisions[0].Route)
+			assert.Equal(t, http.MethodGet, decisions[0].Method)
+
+			assert.False(t, decisions[1].Allowed)
+			assert.Equal(t, []string{"admins"}, decisions[1].RequiredGroups)
+			assert.Equal(t, []string{"users"}, decisions[1].PresentedGroups)
+			assert.NotEmpty(t, decisions[1].Reason)
+		}
+	}
+}
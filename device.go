@@ -0,0 +1,153 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+
+	// DeviceKeyClaim the claim of the access tokens holding the key of the device the user signed in from
+	DeviceKeyClaim = "device_key"
+
+	// deviceRememberedStatusAttribute the device attribute holding whether the device is remembered
+	deviceRememberedStatusAttribute = "dev:device_remembered_status"
+)
+
+// Device a device tracked by the user pool, as returned by the GetDevice API
+type Device struct {
+	Key        string
+	Remembered bool
+	Attributes map[string]string
+}
+
+// ConfirmedDevice a device confirmed with ConfirmDevice. The client must keep the Password along the keys to
+// sign in later with the DEVICE_SRP_AUTH flow.
+type ConfirmedDevice struct {
+	Key      string
+	GroupKey string
+	Password string
+
+	// UserConfirmationNecessary whether the user must be asked to remember the device, with UpdateDeviceStatus,
+	// when the user pool remembers the devices on the user opt-in only
+	UserConfirmationNecessary bool
+}
+
+// ConfirmDevice confirms the device returned in the NewDeviceMetadata of an authentication, generating its
+// password and SRP verifier. The access token is the one issued by that authentication.
+func (mw *AuthMiddleware) ConfirmDevice(ctx context.Context, accessToken, deviceKey, deviceGroupKey, deviceName string) (*ConfirmedDevice, error) {
+	b := make([]byte, 40)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	password := base64.StdEncoding.EncodeToString(b)
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	hexSalt := padHex(hex.EncodeToString(salt))
+	verifier := new(big.Int).Exp(srpG, srpX(deviceGroupKey, deviceKey, password, hexSalt), srpPrime)
+	verifierBytes, _ := hex.DecodeString(padHex(verifier.Text(16)))
+	saltBytes, _ := hex.DecodeString(hexSalt)
+
+	var output struct {
+		UserConfirmationNecessary bool
+	}
+	input := map[string]interface{}{
+		"AccessToken": accessToken,
+		"DeviceKey":   deviceKey,
+		"DeviceName":  deviceName,
+		"DeviceSecretVerifierConfig": map[string]string{
+			"PasswordVerifier": base64.StdEncoding.EncodeToString(verifierBytes),
+			"Salt":             base64.StdEncoding.EncodeToString(saltBytes),
+		},
+	}
+	if err := mw.callCognito(ctx, "ConfirmDevice", input, &output); err != nil {
+		return nil, err
+	}
+	return &ConfirmedDevice{
+		Key:                       deviceKey,
+		GroupKey:                  deviceGroupKey,
+		Password:                  password,
+		UserConfirmationNecessary: output.UserConfirmationNecessary,
+	}, nil
+}
+
+// UpdateDeviceStatus remembers the device of the user, a "trust this browser" choice, or stops remembering it
+func (mw *AuthMiddleware) UpdateDeviceStatus(ctx context.Context, accessToken, deviceKey string, remembered bool) error {
+	status := "not_remembered"
+	if remembered {
+		status = "remembered"
+	}
+	input := map[string]string{"AccessToken": accessToken, "DeviceKey": deviceKey, "DeviceRememberedStatus": status}
+	return mw.callCognito(ctx, "UpdateDeviceStatus", input, &struct{}{})
+}
+
+// ForgetDevice forgets the device of the user, the tokens signed in from it are then rejected by the
+// RememberedDeviceEnricher
+func (mw *AuthMiddleware) ForgetDevice(ctx context.Context, accessToken, deviceKey string) error {
+	input := map[string]string{"AccessToken": accessToken, "DeviceKey": deviceKey}
+	return mw.callCognito(ctx, "ForgetDevice", input, &struct{}{})
+}
+
+// GetDevice fetches the device of the user, returning ErrDeviceNotRemembered when the device was forgotten
+func (mw *AuthMiddleware) GetDevice(ctx context.Context, accessToken, deviceKey string) (*Device, error) {
+	var output struct {
+		Device struct {
+			DeviceKey        string
+			DeviceAttributes []struct {
+				Name  string
+				Value string
+			}
+		}
+	}
+	input := map[string]string{"AccessToken": accessToken, "DeviceKey": deviceKey}
+	if err := mw.callCognito(ctx, "GetDevice", input, &output); err != nil {
+		var cognitoErr *CognitoError
+		if errors.As(err, &cognitoErr) && cognitoErr.StatusCode == http.StatusBadRequest &&
+			cognitoErr.Type == "ResourceNotFoundException" {
+			return nil, tokenError(ErrDeviceNotRemembered, fmt.Errorf("device %s not found", deviceKey))
+		}
+		return nil, err
+	}
+	device := &Device{Key: output.Device.DeviceKey, Attributes: map[string]string{}}
+	for _, attribute := range output.Device.DeviceAttributes {
+		device.Attributes[attribute.Name] = attribute.Value
+	}
+	device.Remembered = device.Attributes[deviceRememberedStatusAttribute] == "remembered"
+	return device, nil
+}
+
+// RememberedDeviceEnricher an Enricher rejecting the access tokens signed in from a device which is no longer
+// remembered, e.g. forgotten from another browser. The devices are cached per key for the given time to live.
+// The tokens without a device_key claim are left untouched.
+func (mw *AuthMiddleware) RememberedDeviceEnricher(ttl time.Duration) Enricher {
+	cache := &ttlCache{}
+	return EnricherFunc(func(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error) {
+		deviceKey, _ := claims[DeviceKeyClaim].(string)
+		if deviceKey == "" {
+			return nil, nil
+		}
+		cached, ok := cache.get(deviceKey)
+		if !ok {
+			device, err := mw.GetDevice(ctx, token, deviceKey)
+			if err != nil {
+				return nil, err
+			}
+			cached = device
+			cache.set(deviceKey, device, time.Now().Add(ttl))
+		}
+		if !cached.(*Device).Remembered {
+			return nil, tokenError(ErrDeviceNotRemembered, fmt.Errorf("device %s is not remembered", deviceKey))
+		}
+		return nil, nil
+	})
+}
@@ -0,0 +1,89 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_ConfirmDevice(t *testing.T) {
+	t.Logf("Given a user pool tracking the devices of the users")
+	{
+		var config map[string]interface{}
+		defer cognitoServer(func(action string, input map[string]interface{}) (int, interface{}) {
+			switch action {
+			case "ConfirmDevice":
+				assert.Equal(t, "access-token", input["AccessToken"])
+				assert.Equal(t, "eu-west-1_device", input["DeviceKey"])
+				assert.Equal(t, "Firefox", input["DeviceName"])
+				config = input["DeviceSecretVerifierConfig"].(map[string]interface{})
+				return http.StatusOK, map[string]bool{"UserConfirmationNecessary": true}
+			case "UpdateDeviceStatus":
+				assert.Equal(t, "remembered", input["DeviceRememberedStatus"])
+				return http.StatusOK, map[string]string{}
+			}
+			return http.StatusBadRequest, map[string]string{"__type": "InvalidParameterException"}
+		})()
+
+		mw := newTestMiddleware()
+		device, err := mw.ConfirmDevice(context.Background(), "access-token", "eu-west-1_device", "group", "Firefox")
+		assert.NoError(t, err)
+		assert.True(t, device.UserConfirmationNecessary)
+		assert.NotEmpty(t, device.Password)
+
+		t.Logf("Then the verifier proves the generated password")
+		salt, _ := base64.StdEncoding.DecodeString(config["Salt"].(string))
+		verifier, _ := base64.StdEncoding.DecodeString(config["PasswordVerifier"].(string))
+		x := srpX("group", "eu-west-1_device", device.Password, hex.EncodeToString(salt))
+		assert.Equal(t, new(big.Int).Exp(srpG, x, srpPrime), new(big.Int).SetBytes(verifier))
+
+		assert.NoError(t, mw.UpdateDeviceStatus(context.Background(), "access-token", "eu-west-1_device", true))
+	}
+}
+
+func Test_RememberedDeviceEnricher(t *testing.T) {
+	t.Logf("Given the devices remembered by the user pool")
+	{
+		calls := map[string]int{}
+		defer cognitoServer(func(action string, input map[string]interface{}) (int, interface{}) {
+			assert.Equal(t, "GetDevice", action)
+			key := input["DeviceKey"].(string)
+			calls[key]++
+			if key == "forgotten" {
+				return http.StatusBadRequest, map[string]string{"__type": "ResourceNotFoundException", "message": "Device does not exist."}
+			}
+			status := "remembered"
+			if key == "not-remembered" {
+				status = "not_remembered"
+			}
+			return http.StatusOK, map[string]interface{}{"Device": map[string]interface{}{
+				"DeviceKey":        key,
+				"DeviceAttributes": []map[string]string{{"Name": "dev:device_remembered_status", "Value": status}},
+			}}
+		})()
+
+		mw := newTestMiddleware()
+		mw.Enrichers = []Enricher{mw.RememberedDeviceEnricher(time.Minute)}
+		router := authzHandler(mw)
+
+		remembered := testClaims()
+		remembered[DeviceKeyClaim] = "remembered"
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(remembered)).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(remembered)).Code)
+		assert.Equal(t, 1, calls["remembered"])
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+
+		for _, key := range []string{"not-remembered", "forgotten"} {
+			claims := testClaims()
+			claims[DeviceKeyClaim] = key
+			w := performRequest(router, "GET", "/orders", signToken(claims))
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Header().Get(AuthenticateHeader), "device_not_remembered")
+		}
+	}
+}
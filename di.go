@@ -0,0 +1,140 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+)
+
+// KeyProvider provides the public keys verifying the signatures of the tokens. The AuthMiddleware provides the keys
+// of its json web key set.
+type KeyProvider interface {
+
+	// PublicKey the public key of the kid and its alg, empty to accept any RSA signing method. The unknown kids
+	// are answered with ErrUnknownKeyID.
+	PublicKey(kid string) (key *rsa.PublicKey, alg string, err error)
+}
+
+// KeyProviderFunc adapter to use an ordinary function as a KeyProvider
+type KeyProviderFunc func(kid string) (*rsa.PublicKey, string, error)
+
+// PublicKey calls f(kid)
+func (f KeyProviderFunc) PublicKey(kid string) (*rsa.PublicKey, string, error) {
+	return f(kid)
+}
+
+// TokenExtractor extracts the token of a request, as per the TokenLookup by default
+type TokenExtractor interface {
+	ExtractToken(r *http.Request) (string, error)
+}
+
+// TokenExtractorFunc adapter to use an ordinary function as a TokenExtractor
+type TokenExtractorFunc func(r *http.Request) (string, error)
+
+// ExtractToken calls f(r)
+func (f TokenExtractorFunc) ExtractToken(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// ClaimsValidator checks the claims of the tokens on top of the standard validation, e.g. a tenant claim
+type ClaimsValidator interface {
+	ValidateClaims(claims jwtgo.MapClaims) error
+}
+
+// ClaimsValidatorFunc adapter to use an ordinary function as a ClaimsValidator
+type ClaimsValidatorFunc func(claims jwtgo.MapClaims) error
+
+// ValidateClaims calls f(claims)
+func (f ClaimsValidatorFunc) ValidateClaims(claims jwtgo.MapClaims) error {
+	return f(claims)
+}
+
+// PublicKey the public key of the kid in the json web key set of the middleware
+func (mw *AuthMiddleware) PublicKey(kid string) (*rsa.PublicKey, string, error) {
+	key, ok := mw.verificationKey(kid)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+	}
+	if key.err != nil {
+		return nil, "", key.err
+	}
+	if key.method == nil {
+		return key.publicKey, "", nil
+	}
+	return key.publicKey, key.method.Alg(), nil
+}
+
+// keyProvider the Keys, the middleware itself when nil
+func (mw *AuthMiddleware) keyProvider() KeyProvider {
+	if mw.Keys != nil {
+		return mw.Keys
+	}
+	return mw
+}
+
+// extractWith extracts the token of the request with the Extractor, its errors being missing token errors
+func (mw *AuthMiddleware) extractWith(r *http.Request, logger Logger) (string, error) {
+	tokenStr, err := mw.Extractor.ExtractToken(r)
+	if err == nil && tokenStr == "" {
+		err = errors.New("no token extracted")
+	}
+	if err != nil {
+		var tokenErr *TokenError
+		if !errors.As(err, &tokenErr) {
+			err = tokenError(ErrMissingHeader, err)
+		}
+		mw.extractionFailed(logger, err)
+		return "", err
+	}
+	return tokenStr, nil
+}
+
+// validateClaims runs the ClaimsValidators, their errors being invalid claims errors
+func (mw *AuthMiddleware) validateClaims(claims jwtgo.MapClaims) error {
+	for _, validator := range mw.ClaimsValidators {
+		if err := validator.ValidateClaims(claims); err != nil {
+			var tokenErr *TokenError
+			if !errors.As(err, &tokenErr) {
+				err = tokenError(ErrInvalidClaims, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Option configures the middleware created by NewForUserPool and NewWithContext, so that the dependency injection
+// frameworks such as fx or wire provide its collaborators
+type Option = func(*AuthMiddleware)
+
+// WithKeyProvider sets the provider of the public keys, the json web key set of the user pool is then not downloaded
+func WithKeyProvider(keys KeyProvider) Option {
+	return func(mw *AuthMiddleware) { mw.Keys = keys }
+}
+
+// WithTokenExtractor sets the extraction of the tokens from the requests
+func WithTokenExtractor(extractor TokenExtractor) Option {
+	return func(mw *AuthMiddleware) { mw.Extractor = extractor }
+}
+
+// WithClaimsValidators adds checks of the claims of the tokens
+func WithClaimsValidators(validators ...ClaimsValidator) Option {
+	return func(mw *AuthMiddleware) { mw.ClaimsValidators = append(mw.ClaimsValidators, validators...) }
+}
+
+// WithRevocationChecker sets the check of the revoked tokens
+func WithRevocationChecker(checker RevocationChecker) Option {
+	return func(mw *AuthMiddleware) { mw.RevocationChecker = checker }
+}
+
+// WithMetrics sets the sink of the outcome of the validations
+func WithMetrics(metrics Metrics) Option {
+	return func(mw *AuthMiddleware) { mw.Metrics = metrics }
+}
+
+// WithLogger sets the logger of the middleware
+func WithLogger(logger Logger) Option {
+	return func(mw *AuthMiddleware) { mw.Logger = logger }
+}
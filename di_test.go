@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Collaborators(t *testing.T) {
+	t.Logf("Given a middleware assembled from its collaborators")
+	{
+		keys := KeyProviderFunc(func(kid string) (*rsa.PublicKey, string, error) {
+			if kid != TestKid {
+				return nil, "", fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+			}
+			return &testKey.PublicKey, "RS256", nil
+		})
+		extractor := TokenExtractorFunc(func(r *http.Request) (string, error) {
+			return r.URL.Query().Get("access_token"), nil
+		})
+		tenant := ClaimsValidatorFunc(func(claims jwtgo.MapClaims) error {
+			if claims["custom:tenant"] != "acme" {
+				return errors.New("unknown tenant")
+			}
+			return nil
+		})
+		revoked := RevocationCheckerFunc(func(originJTI string) (bool, error) { return originJTI == "revoked", nil })
+
+		mw, err := NewForUserPool(TestRegion, TestUserPoolID,
+			WithKeyProvider(keys),
+			WithTokenExtractor(extractor),
+			WithClaimsValidators(tenant),
+			WithRevocationChecker(revoked),
+			WithLogger(NopLogger{}))
+		assert.NoError(t, err, "the keys are not downloaded")
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/orders", mw.MiddlewareFunc(), testHandler)
+		request := func(claims jwtgo.MapClaims) int {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/orders?access_token="+signToken(claims), nil))
+			return w.Code
+		}
+
+		claims := testClaims()
+		claims["custom:tenant"] = "acme"
+		assert.Equal(t, http.StatusOK, request(claims))
+
+		claims[OriginJTIClaim] = "revoked"
+		assert.Equal(t, http.StatusUnauthorized, request(claims))
+
+		assert.Equal(t, http.StatusUnauthorized, request(testClaims()))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		assert.Equal(t, map[string]uint64{"revoked": 1, "invalid_claims": 1, "missing_token": 1}, mw.Stats().Failures)
+	}
+
+	t.Logf("Given a token signed by a key unknown to the key provider")
+	{
+		mw := newTestMiddleware()
+		mw.Keys = KeyProviderFunc(func(kid string) (*rsa.PublicKey, string, error) {
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+		})
+		_, err := mw.ValidateToken(signToken(testClaims()))
+		assert.ErrorIs(t, err, ErrUnknownKeyID)
+
+		mw.Keys = KeyProviderFunc(func(kid string) (*rsa.PublicKey, string, error) {
+			return &testKey.PublicKey, "RS512", nil
+		})
+		_, err = mw.ValidateToken(signToken(testClaims()))
+		assert.ErrorIs(t, err, ErrUnexpectedSigningMethod)
+	}
+}
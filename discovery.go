@@ -0,0 +1,156 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveredPool a user pool of the account along with its tags, as listed by a PoolLister
+type DiscoveredPool struct {
+	Region     string
+	UserPoolID string
+	Name       string
+	Tags       map[string]string
+}
+
+// PoolLister lists the user pools of the account along with their tags. It is typically implemented with the
+// paginated ListUserPools API of the AWS SDK, and the DescribeUserPool API for the UserPoolTags of each pool, which
+// require AWS credentials.
+type PoolLister interface {
+	ListUserPools(ctx context.Context) ([]DiscoveredPool, error)
+}
+
+// PoolListerFunc adapter to use an ordinary function as a PoolLister
+type PoolListerFunc func(ctx context.Context) ([]DiscoveredPool, error)
+
+// ListUserPools calls f(ctx)
+func (f PoolListerFunc) ListUserPools(ctx context.Context) ([]DiscoveredPool, error) {
+	return f(ctx)
+}
+
+// PoolDiscovery the discovery of the user pools of the account by their tags, e.g. for the platforms creating a
+// user pool per customer, see AuthMiddleware.DiscoverUserPools
+type PoolDiscovery struct {
+
+	// Lister lists the user pools of the account
+	Lister PoolLister
+
+	// Tags the tags the user pools must carry to be trusted, any value of the tag matching the empty values
+	Tags map[string]string
+
+	// TenantTag the tag naming the tenant of the user pool, read when the pool is discovered. The pools are
+	// trusted as per their issuer only when empty or when the pool lacks the tag.
+	TenantTag string
+
+	// Configure sets the options of the middlewares of the discovered user pools, e.g. their Policy
+	Configure func(pool *AuthMiddleware)
+
+	mu         sync.Mutex
+	discovered map[string]bool
+}
+
+// matches whether the user pool carries the Tags of the discovery
+func (d *PoolDiscovery) matches(pool DiscoveredPool) bool {
+	for name, value := range d.Tags {
+		tag, ok := pool.Tags[name]
+		if !ok || (value != "" && tag != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// DiscoverUserPools lists the user pools of the account with the Lister of the Discovery, trusting the ones carrying
+// its Tags with AddUserPool, and no longer trusting the ones it discovered before which are gone or lost their tags.
+// The user pools which are trusted already are left as configured. The pools failing to be added are reported and
+// retried on the next discovery.
+func (mw *AuthMiddleware) DiscoverUserPools(ctx context.Context) error {
+	d := mw.Discovery
+	if d == nil {
+		return errors.New("no user pool discovery configured")
+	}
+	pools, err := d.Lister.ListUserPools(ctx)
+	if err != nil {
+		return fmt.Errorf("listing the user pools: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.discovered == nil {
+		d.discovered = make(map[string]bool)
+	}
+	var errs []error
+	matching := make(map[string]bool, len(pools))
+	for _, discovered := range pools {
+		if !d.matches(discovered) {
+			continue
+		}
+		pool := &AuthMiddleware{Region: discovered.Region, UserPoolID: discovered.UserPoolID}
+		if pool.Region == "" {
+			pool.Region, _, _ = strings.Cut(discovered.UserPoolID, "_")
+		}
+		iss := pool.issuer()
+		matching[iss] = true
+		if d.discovered[iss] || mw.trusts(iss) {
+			continue
+		}
+		if d.Configure != nil {
+			d.Configure(pool)
+		}
+		var tenants []string
+		if tenant := discovered.Tags[d.TenantTag]; d.TenantTag != "" && tenant != "" {
+			tenants = append(tenants, tenant)
+		}
+		if err := mw.AddUserPool(ctx, pool, tenants...); err != nil {
+			errs = append(errs, fmt.Errorf("user pool %s: %w", discovered.UserPoolID, err))
+			continue
+		}
+		d.discovered[iss] = true
+	}
+
+	gone := make([]string, 0, len(d.discovered))
+	for iss := range d.discovered {
+		if !matching[iss] {
+			gone = append(gone, iss)
+		}
+	}
+	sort.Strings(gone)
+	for _, iss := range gone {
+		mw.RemoveUserPool(iss)
+		delete(d.discovered, iss)
+	}
+	return errors.Join(errs...)
+}
+
+// PollUserPools discovers the user pools every interval in the background until the context is done, see
+// DiscoverUserPools. The failures are logged and the discovery retried on the next tick.
+func (mw *AuthMiddleware) PollUserPools(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := mw.DiscoverUserPools(ctx); err != nil && ctx.Err() == nil {
+				mw.log().Error("failed to discover the user pools", "error", err)
+			}
+		}
+	}()
+}
+
+// trusts whether the user pool of the issuer is trusted
+func (mw *AuthMiddleware) trusts(iss string) bool {
+	pools := mw.poolIndex()
+	pools.mu.RLock()
+	defer pools.mu.RUnlock()
+	_, ok := pools.byIssuer[iss]
+	return ok
+}
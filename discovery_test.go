@@ -0,0 +1,102 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_DiscoverUserPools(t *testing.T) {
+	t.Logf("Given a middleware discovering the user pools tagged for the platform")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+		var mu sync.Mutex
+		listed := []DiscoveredPool{
+			{UserPoolID: TestUserPoolID, Tags: map[string]string{"platform": "orders", "tenant": "acme"}},
+			{Region: TestRegion, UserPoolID: otherUserPoolID, Tags: map[string]string{"platform": "orders", "tenant": "globex"}},
+			{Region: TestRegion, UserPoolID: "eu-west-2_staff", Tags: map[string]string{"team": "ops"}},
+		}
+		var listErr error
+		mw := &AuthMiddleware{
+			TenantResolver: TenantFromHeader(""),
+			Discovery: &PoolDiscovery{
+				Lister: PoolListerFunc(func(ctx context.Context) ([]DiscoveredPool, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					return listed, listErr
+				}),
+				Tags:      map[string]string{"platform": ""},
+				TenantTag: "tenant",
+				Configure: func(pool *AuthMiddleware) {
+					if pool.UserPoolID == otherUserPoolID {
+						pool.JWK = otherPool().JWK
+					}
+				},
+			},
+		}
+		assert.NoError(t, mw.Validate())
+		router := authzHandler(mw)
+		acmeToken, globexToken := signToken(testClaims()), otherPoolToken(testClaims())
+
+		t.Logf("Then the tagged user pools are trusted and routed to their tenant, their region read from their ID")
+		assert.NoError(t, mw.DiscoverUserPools(context.Background()))
+		assert.EqualValues(t, 1, downloads, "the keys of the other pool are configured")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", acmeToken).Code)
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "globex", globexToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/orders", "acme", globexToken).Code)
+		assert.False(t, mw.trusts(UserPool{Region: TestRegion, UserPoolID: "eu-west-2_staff"}.Iss()))
+
+		t.Logf("And the pools discovered already are not added again")
+		assert.NoError(t, mw.DiscoverUserPools(context.Background()))
+		assert.EqualValues(t, 1, downloads)
+
+		t.Logf("And the pools are kept when the listing fails, then removed once gone or untagged")
+		mu.Lock()
+		listErr = errors.New("throttled")
+		mu.Unlock()
+		assert.ErrorContains(t, mw.DiscoverUserPools(context.Background()), "throttled")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "globex", globexToken).Code)
+		mu.Lock()
+		listed, listErr = listed[:1], nil
+		mu.Unlock()
+		assert.NoError(t, mw.DiscoverUserPools(context.Background()))
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", acmeToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/orders", "globex", globexToken).Code)
+	}
+
+	t.Logf("Given a middleware polling the user pools")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+		listed := make(chan struct{}, 10)
+		mw := &AuthMiddleware{Discovery: &PoolDiscovery{
+			Lister: PoolListerFunc(func(ctx context.Context) ([]DiscoveredPool, error) {
+				listed <- struct{}{}
+				return []DiscoveredPool{{Region: TestRegion, UserPoolID: TestUserPoolID}}, nil
+			}),
+		}}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mw.PollUserPools(ctx, 10*time.Millisecond)
+
+		t.Logf("Then the user pools are discovered in the background")
+		<-listed
+		<-listed
+		assert.True(t, mw.trusts(UserPool{Region: TestRegion, UserPoolID: TestUserPoolID}.Iss()))
+		_, err := mw.validateToken(signToken(testClaims()), mw.log())
+		assert.NoError(t, err)
+	}
+
+	t.Logf("Given a discovery without lister")
+	{
+		mw := &AuthMiddleware{Discovery: &PoolDiscovery{}}
+
+		t.Logf("Then the configuration is rejected")
+		assert.ErrorContains(t, mw.Validate(), "the user pool discovery requires a lister")
+	}
+}
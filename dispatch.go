@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Dispatcher runs the hooks of the middleware, the AuditEvents, Decisions, Metrics and ErrorReporter, on a
+// bounded pool of workers, so that the slow sinks never add latency to the requests. The hooks are dropped,
+// and counted, when the buffer is full. It is safe for concurrent use.
+type Dispatcher struct {
+	queue   chan func()
+	dropped uint64
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewDispatcher creates a Dispatcher buffering up to buffer hooks run by the given number of workers
+func NewDispatcher(buffer, workers int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &Dispatcher{queue: make(chan func(), buffer)}
+	d.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// work runs the queued hooks until the Dispatcher is closed. The panics of the hooks are recovered.
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for hook := range d.queue {
+		func() {
+			defer func() { recover() }()
+			hook()
+		}()
+	}
+}
+
+// Dispatch queues the hook, which is dropped when the buffer is full or the Dispatcher closed
+func (d *Dispatcher) Dispatch(hook func()) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if !d.closed {
+		select {
+		case d.queue <- hook:
+			return true
+		default:
+		}
+	}
+	atomic.AddUint64(&d.dropped, 1)
+	return false
+}
+
+// Dropped the number of hooks dropped
+func (d *Dispatcher) Dropped() uint64 {
+	return atomic.LoadUint64(&d.dropped)
+}
+
+// Close stops accepting hooks and waits for the queued ones to run, e.g. on the shutdown of the server
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// hook runs the hook through the Hooks dispatcher when set, straight away otherwise
+func (mw *AuthMiddleware) hook(hook func()) {
+	if mw.Hooks != nil {
+		mw.Hooks.Dispatch(hook)
+		return
+	}
+	hook()
+}
@@ -0,0 +1,47 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_DispatcherDoesNotHoldUpRequests(t *testing.T) {
+	t.Logf("Given an audit sink blocked until released, dispatched in the background")
+	{
+		release := make(chan struct{})
+		var audited uint64
+		mw := newTestMiddleware()
+		mw.Hooks = NewDispatcher(1, 1)
+		mw.AuditEvents = AuditSinkFunc(func(AuditEvent) {
+			<-release
+			atomic.AddUint64(&audited, 1)
+		})
+		router := authzHandler(mw)
+
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		}
+		dropped := mw.Stats().DroppedHooks
+		assert.True(t, dropped >= 3)
+
+		close(release)
+		mw.Hooks.Close()
+		assert.Equal(t, uint64(5), atomic.LoadUint64(&audited)+dropped)
+		assert.False(t, mw.Hooks.Dispatch(func() {}))
+	}
+}
+
+func Test_DispatcherRecoversPanickingHooks(t *testing.T) {
+	t.Logf("Given a hook panicking")
+	{
+		d := NewDispatcher(10, 1)
+		ran := false
+		assert.True(t, d.Dispatch(func() { panic("boom") }))
+		assert.True(t, d.Dispatch(func() { ran = true }))
+		d.Close()
+		assert.True(t, ran)
+		assert.Equal(t, uint64(0), d.Dropped())
+	}
+}
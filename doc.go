@@ -0,0 +1,22 @@
+// Package jwt is a Gin middleware validating the JSON web tokens issued by the AWS Cognito user pools.
+//
+// NewForUserPool is the constructor of the middleware. The Builder, NewFromEnv and the Config validate the
+// configuration before creating it, and the Reloader swaps it at runtime.
+//
+//	mw, err := jwt.NewForUserPool("eu-west-2", "eu-west-2_x")
+//	router.Use(mw.MiddlewareFunc())
+//
+// # Compatibility
+//
+// The package follows semantic versioning: within a major version the exported identifiers are neither removed
+// nor changed in a backward incompatible way, and the sentinel errors and the failure reasons are kept. The
+// deprecated identifiers remain until the next major version. The default JSON body of the responses only gains
+// fields, the ProblemJSON and ErrorMode options opting in to other bodies. The v1 releases broke this promise twice:
+// the code of the AuthError body, encoded as "Code" by the first releases, is encoded as "code", and the Trace, Info,
+// Warning and Error package loggers, kept as deprecated, are no longer written to.
+//
+// The options are the exported fields of the AuthMiddleware. The handlers are created from a frozen copy of them,
+// so that changing a field once a handler is created has no effect on it. They are kept exported so that the
+// existing configurations keep compiling. The v2 module, github.com/akhettar/gin-jwt-cognito/v2, hides them behind
+// its single constructor and its options.
+package jwt
@@ -11,7 +11,7 @@ func ExampleAuthMiddleware() {
 	router := gin.Default()
 
 	// Create Cognito JWT auth middleware and set it  in all authenticated endpoints
-	mw, err := jwt.AuthJWTMiddleware("<some_iss>", "<some_userpool_id>", "region")
+	mw, err := jwt.NewForUserPool("eu-west-2", "<some_userpool_id>")
 	if err != nil {
 		panic(err)
 	}
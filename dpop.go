@@ -0,0 +1,259 @@
+package jwt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+
+	// DPoPHeader the header holding the DPoP proof of the request
+	DPoPHeader = "DPoP"
+
+	// DPoPProofType the typ of the header of the DPoP proofs
+	DPoPProofType = "dpop+jwt"
+
+	// DefaultDPoPMaxAge the default maximum age of the DPoP proofs
+	DefaultDPoPMaxAge = time.Minute
+
+	// dpopLeeway the clock skew allowed on the iat of the DPoP proofs issued in the future
+	dpopLeeway = 5 * time.Second
+
+	// minDPoPKeyBits the minimum size of the RSA keys of the DPoP proofs
+	minDPoPKeyBits = 2048
+)
+
+// dpopAlgs the signing algorithms accepted for the DPoP proofs, all asymmetric
+var dpopAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// DPoP the validation of the DPoP proofs of possession, RFC 9449. The access tokens bound to a key of the client by
+// their cnf.jkt claim, e.g. added by a pre token generation Lambda, are only accepted along with a DPoP header
+// proving the possession of that key for the request, so that a stolen token cannot be replayed. The token is read
+// with or without the DPoP authorization scheme.
+type DPoP struct {
+
+	// Required rejects the access tokens which are not bound to a key, the bound ones always requiring a proof
+	Required bool
+
+	// MaxAge the maximum age of the proofs as per their iat, DefaultDPoPMaxAge by default. A proof can be replayed
+	// for the same request within its MaxAge.
+	MaxAge time.Duration
+
+	// URL returns the URL the proofs of the request must be issued for, without query nor fragment. It is derived
+	// from the Host header and the TLS state of the request by default, set it behind a TLS terminating proxy.
+	URL func(*http.Request) string
+}
+
+// maxAge the maximum age of the proofs
+func (d *DPoP) maxAge() time.Duration {
+	if d.MaxAge <= 0 {
+		return DefaultDPoPMaxAge
+	}
+	return d.MaxAge
+}
+
+// url the URL of the request the proofs must be issued for
+func (d *DPoP) url(r *http.Request) string {
+	if d.URL != nil {
+		return d.URL(r)
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// dpopToken strips the DPoP authorization scheme of the token when DPoP is enabled
+func (mw *AuthMiddleware) dpopToken(tokenStr string) string {
+	if mw.DPoP == nil {
+		return tokenStr
+	}
+	if scheme, token, ok := strings.Cut(tokenStr, " "); ok && strings.EqualFold(scheme, DPoPHeader) {
+		return token
+	}
+	return tokenStr
+}
+
+// checkDPoP rejects with an ErrInvalidDPoPProof the bound access tokens presented without a valid proof of
+// possession of their key, and the unbound ones when DPoP is Required
+func (mw *AuthMiddleware) checkDPoP(r *http.Request, token *jwtgo.Token, logger Logger) error {
+	if mw.DPoP == nil {
+		return nil
+	}
+	err := mw.verifyDPoP(r, token)
+	if err != nil {
+		mw.observe(failureReason(err), 0)
+		if mw.sampleFailure(failureReason(err)) {
+			logger.Warn("Failed to validate the dpop proof", "error_class", failureReason(err), "error", err)
+		}
+	}
+	return err
+}
+
+// boundKey the thumbprint of the key the token is bound to by its cnf.jkt claim, empty when it is not bound to one
+func boundKey(claims jwtgo.MapClaims) string {
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	jkt, _ := cnf["jkt"].(string)
+	return jkt
+}
+
+func (mw *AuthMiddleware) verifyDPoP(r *http.Request, token *jwtgo.Token) error {
+	jkt := boundKey(token.Claims.(jwtgo.MapClaims))
+	if jkt == "" {
+		if mw.DPoP.Required {
+			return tokenError(ErrInvalidDPoPProof, errors.New("the access token is not bound to a key"))
+		}
+		return nil
+	}
+
+	proofs := r.Header.Values(DPoPHeader)
+	if len(proofs) != 1 {
+		return tokenError(ErrInvalidDPoPProof, fmt.Errorf("expecting one dpop proof, got %d", len(proofs)))
+	}
+	var thumbprint string
+	parser := &jwtgo.Parser{ValidMethods: dpopAlgs, SkipClaimsValidation: true}
+	proof, err := parser.Parse(proofs[0], func(proof *jwtgo.Token) (interface{}, error) {
+		if typ, _ := proof.Header["typ"].(string); typ != DPoPProofType {
+			return nil, fmt.Errorf("the typ of the proof is %q, expecting %s", typ, DPoPProofType)
+		}
+		jwk, ok := proof.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("the proof has no jwk header")
+		}
+		key, keyThumbprint, err := dpopKey(jwk)
+		if err != nil {
+			return nil, err
+		}
+		thumbprint = keyThumbprint
+		return key, nil
+	})
+	if err != nil {
+		return tokenError(ErrInvalidDPoPProof, fmt.Errorf("invalid proof: %w", err))
+	}
+
+	if thumbprint != jkt {
+		return tokenError(ErrInvalidDPoPProof, errors.New("the proof is not signed with the key the access token is bound to"))
+	}
+	proofClaims := proof.Claims.(jwtgo.MapClaims)
+	if jti, _ := proofClaims["jti"].(string); jti == "" {
+		return tokenError(ErrInvalidDPoPProof, errors.New("the proof has no jti"))
+	}
+	if htm, _ := proofClaims["htm"].(string); htm != r.Method {
+		return tokenError(ErrInvalidDPoPProof, fmt.Errorf("the proof is issued for the method %q, not %s", htm, r.Method))
+	}
+	htu, _ := proofClaims["htu"].(string)
+	if !sameURL(htu, mw.DPoP.url(r)) {
+		return tokenError(ErrInvalidDPoPProof, fmt.Errorf("the proof is issued for the url %q, not %s", htu, mw.DPoP.url(r)))
+	}
+	iat, ok := proofClaims["iat"].(float64)
+	if !ok {
+		return tokenError(ErrInvalidDPoPProof, errors.New("the proof has no iat"))
+	}
+	issued := time.Unix(int64(iat), 0)
+	now := mw.now()
+	if issued.After(now.Add(dpopLeeway)) || issued.Before(now.Add(-mw.DPoP.maxAge())) {
+		return tokenError(ErrInvalidDPoPProof, fmt.Errorf("the proof is issued at %v, out of the %v window", issued, mw.DPoP.maxAge()))
+	}
+	hash := sha256.Sum256([]byte(token.Raw))
+	if ath, _ := proofClaims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(hash[:]) {
+		return tokenError(ErrInvalidDPoPProof, errors.New("the proof is not issued for the access token"))
+	}
+	return nil
+}
+
+// sameURL whether the htu of a proof is the url, ignoring the case of the scheme and host, the query and fragment
+func sameURL(htu, expected string) bool {
+	proofURL, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	requestURL, err := url.Parse(expected)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(proofURL.Scheme, requestURL.Scheme) && strings.EqualFold(proofURL.Host, requestURL.Host) &&
+		proofURL.Path == requestURL.Path
+}
+
+// dpopKey returns the public key of the jwk header of a proof, and its RFC 7638 thumbprint
+func dpopKey(jwk map[string]interface{}) (interface{}, string, error) {
+	member := func(name string) string {
+		value, _ := jwk[name].(string)
+		return value
+	}
+	if member("d") != "" {
+		return nil, "", errors.New("the jwk of the proof holds a private key")
+	}
+	var key interface{}
+	var members map[string]string
+	switch kty := member("kty"); kty {
+	case "RSA":
+		publicKey, err := convertKey(member("e"), member("n"))
+		if err != nil {
+			return nil, "", fmt.Errorf("the jwk of the proof is malformed: %w", err)
+		}
+		if publicKey.N.BitLen() < minDPoPKeyBits {
+			return nil, "", fmt.Errorf("the RSA key of the proof is shorter than %d bits", minDPoPKeyBits)
+		}
+		key = publicKey
+		members = map[string]string{"e": member("e"), "kty": kty, "n": member("n")}
+	case "EC":
+		publicKey, err := ecKey(member("crv"), member("x"), member("y"))
+		if err != nil {
+			return nil, "", fmt.Errorf("the jwk of the proof is malformed: %w", err)
+		}
+		key = publicKey
+		members = map[string]string{"crv": member("crv"), "kty": kty, "x": member("x"), "y": member("y")}
+	default:
+		return nil, "", fmt.Errorf("the kty %q of the jwk of the proof is not supported", kty)
+	}
+	// the members are marshalled in lexicographic order, without whitespace
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return nil, "", err
+	}
+	thumbprint := sha256.Sum256(canonical)
+	return key, base64.RawURLEncoding.EncodeToString(thumbprint[:]), nil
+}
+
+// ecKey returns the public key of the given curve and coordinates, checking that the point is on the curve
+func ecKey(crv, rawX, rawY string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var point ecdh.Curve
+	switch crv {
+	case "P-256":
+		curve, point = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, point = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, point = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("the curve %q is not supported", crv)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	x, err := base64.RawURLEncoding.DecodeString(rawX)
+	if err != nil || len(x) != size {
+		return nil, errors.New("invalid x coordinate")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(rawY)
+	if err != nil || len(y) != size {
+		return nil, errors.New("invalid y coordinate")
+	}
+	if _, err := point.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+		return nil, fmt.Errorf("the point is not on the curve %s", crv)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
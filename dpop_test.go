@@ -0,0 +1,213 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// dpopClientKey the key of the client the test access tokens are bound to
+var dpopClientKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// dpopJWK the public jwk of the given client key
+func dpopJWK(key *ecdsa.PrivateKey) map[string]interface{} {
+	return map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// boundToken returns an access token bound to the client key
+func boundToken() string {
+	_, jkt, _ := dpopKey(dpopJWK(dpopClientKey))
+	claims := testClaims()
+	claims["cnf"] = map[string]interface{}{"jkt": jkt}
+	return signToken(claims)
+}
+
+// dpopProof returns a proof of the possession of the key for the given request and access token
+func dpopProof(key *ecdsa.PrivateKey, method, url, accessToken string, iat time.Time) string {
+	hash := sha256.Sum256([]byte(accessToken))
+	proof := jwtgo.NewWithClaims(jwtgo.SigningMethodES256, jwtgo.MapClaims{
+		"jti": "proof-" + iat.String(),
+		"htm": method,
+		"htu": url,
+		"iat": iat.Unix(),
+		"ath": base64.RawURLEncoding.EncodeToString(hash[:]),
+	})
+	proof.Header["typ"] = DPoPProofType
+	proof.Header["jwk"] = dpopJWK(key)
+	proofStr, _ := proof.SignedString(key)
+	return proofStr
+}
+
+// dpopRequest performs a request of https://api.example.com/orders with the given token and proofs
+func dpopRequest(r http.Handler, token string, proofs ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "https://api.example.com/orders", nil)
+	req.Header.Set(AuthorizationHeader, "DPoP "+token)
+	for _, proof := range proofs {
+		req.Header.Add(DPoPHeader, proof)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_DPoP(t *testing.T) {
+	t.Logf("Given a middleware validating the DPoP proofs")
+	{
+		mw := newTestMiddleware()
+		mw.DPoP = &DPoP{}
+		router := authzHandler(mw)
+		token := boundToken()
+		url := "https://api.example.com/orders"
+
+		t.Logf("Then the bound token is accepted along with a proof of possession of its key")
+		w := dpopRequest(router, token, dpopProof(dpopClientKey, "GET", url, token, time.Now()))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		t.Logf("And the unbound tokens are accepted without proof")
+		assert.Equal(t, http.StatusOK, dpopRequest(router, signToken(testClaims())).Code)
+
+		t.Logf("And the bound token is rejected without a valid proof")
+		otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		invalid := map[string][]string{
+			"no proof":          nil,
+			"two proofs":        {dpopProof(dpopClientKey, "GET", url, token, time.Now()), dpopProof(dpopClientKey, "GET", url, token, time.Now())},
+			"another key":       {dpopProof(otherKey, "GET", url, token, time.Now())},
+			"another method":    {dpopProof(dpopClientKey, "POST", url, token, time.Now())},
+			"another url":       {dpopProof(dpopClientKey, "GET", "https://api.example.com/users", token, time.Now())},
+			"another token":     {dpopProof(dpopClientKey, "GET", url, signToken(testClaims()), time.Now())},
+			"too old":           {dpopProof(dpopClientKey, "GET", url, token, time.Now().Add(-2*time.Minute))},
+			"issued the future": {dpopProof(dpopClientKey, "GET", url, token, time.Now().Add(time.Minute))},
+			"not a jwt":         {"garbage"},
+		}
+		for name, proofs := range invalid {
+			w := dpopRequest(router, token, proofs...)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+			assert.Equal(t, `DPoP realm="gin jwt", error="invalid_dpop_proof", algs="RS256 RS384 RS512 PS256 PS384 PS512 ES256 ES384 ES512"`,
+				w.Header().Get(AuthenticateHeader), name)
+		}
+		assert.Equal(t, uint64(len(invalid)), mw.Stats().Failures["invalid_dpop_proof"])
+
+		t.Logf("And the query of the request is ignored")
+		req := httptest.NewRequest("GET", url+"?page=2", nil)
+		req.Header.Set(AuthorizationHeader, token)
+		req.Header.Set(DPoPHeader, dpopProof(dpopClientKey, "GET", url, token, time.Now()))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	t.Logf("Given a middleware requiring DPoP")
+	{
+		mw := newTestMiddleware()
+		mw.DPoP = &DPoP{Required: true}
+		router := authzHandler(mw)
+
+		t.Logf("Then the unbound tokens are rejected")
+		w := dpopRequest(router, signToken(testClaims()))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "not bound to a key")
+	}
+}
+
+func Test_DPoPEntryPoints(t *testing.T) {
+	t.Logf("Given a middleware validating the DPoP proofs and issuing server side sessions")
+	{
+		mw := newTestMiddleware()
+		mw.DPoP = &DPoP{}
+		mw.Sessions = NewMemorySessionStore()
+		router := authzHandler(mw)
+		router.GET("/auth", mw.ForwardAuthHandler())
+		token := boundToken()
+		url := "https://api.example.com/orders"
+
+		t.Logf("Then the bound token gets no session")
+		w := dpopRequest(router, token, dpopProof(dpopClientKey, "GET", url, token, time.Now()))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+
+		t.Logf("And the forward auth rejects the bound token without a proof")
+		req := httptest.NewRequest("GET", "https://api.example.com/auth", nil)
+		req.Header.Set(AuthorizationHeader, "DPoP "+token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		t.Logf("And the bound token is rejected without its request, accepted along with its proof")
+		_, err := mw.ValidateToken(token)
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+		_, err = mw.ValidateToken(signToken(testClaims()))
+		assert.NoError(t, err)
+		req = httptest.NewRequest("GET", url, nil)
+		req.Header.Set(DPoPHeader, dpopProof(dpopClientKey, "GET", url, token, time.Now()))
+		_, err = mw.ValidateRequest(req, token)
+		assert.NoError(t, err)
+		_, err = mw.ValidateRequest(httptest.NewRequest("GET", url, nil), token)
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	}
+}
+
+func Test_DPoPProofHeader(t *testing.T) {
+	t.Logf("Given proofs whose header is invalid")
+	{
+		mw := newTestMiddleware()
+		mw.DPoP = &DPoP{}
+		router := authzHandler(mw)
+		token := boundToken()
+		sign := func(method jwtgo.SigningMethod, key interface{}, header map[string]interface{}) string {
+			hash := sha256.Sum256([]byte(token))
+			proof := jwtgo.NewWithClaims(method, jwtgo.MapClaims{"jti": "1", "htm": "GET", "htu": "https://api.example.com/orders",
+				"iat": time.Now().Unix(), "ath": base64.RawURLEncoding.EncodeToString(hash[:])})
+			proof.Header["typ"] = DPoPProofType
+			proof.Header["jwk"] = dpopJWK(dpopClientKey)
+			for name, value := range header {
+				proof.Header[name] = value
+			}
+			proofStr, _ := proof.SignedString(key)
+			return proofStr
+		}
+		private := dpopJWK(dpopClientKey)
+		private["d"] = base64.RawURLEncoding.EncodeToString(dpopClientKey.D.Bytes())
+		offCurve := dpopJWK(dpopClientKey)
+		offCurve["y"] = offCurve["x"]
+
+		assert.Equal(t, http.StatusOK, dpopRequest(router, token, sign(jwtgo.SigningMethodES256, dpopClientKey, nil)).Code)
+		for name, proof := range map[string]string{
+			"symmetric alg":   sign(jwtgo.SigningMethodHS256, []byte("secret"), nil),
+			"typ":             sign(jwtgo.SigningMethodES256, dpopClientKey, map[string]interface{}{"typ": "JWT"}),
+			"no jwk":          sign(jwtgo.SigningMethodES256, dpopClientKey, map[string]interface{}{"jwk": nil}),
+			"private jwk":     sign(jwtgo.SigningMethodES256, dpopClientKey, map[string]interface{}{"jwk": private}),
+			"point off curve": sign(jwtgo.SigningMethodES256, dpopClientKey, map[string]interface{}{"jwk": offCurve}),
+			"short RSA key":   sign(jwtgo.SigningMethodRS256, testKey, map[string]interface{}{"jwk": map[string]interface{}{"kty": "RSA", "e": "AQAB", "n": "AQAB"}}),
+		} {
+			assert.Equal(t, http.StatusUnauthorized, dpopRequest(router, token, proof).Code, name)
+		}
+	}
+}
+
+func Test_DPoPKeyThumbprint(t *testing.T) {
+	t.Logf("Given the RSA key of the example of RFC 7638")
+	{
+		_, thumbprint, err := dpopKey(map[string]interface{}{
+			"kty": "RSA",
+			"n":   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+			"e":   "AQAB",
+			"alg": "RS256",
+			"kid": "2011-04-29",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+	}
+}
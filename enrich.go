@@ -0,0 +1,50 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	jwtgo "github.com/golang-jwt/jwt"
+)
+
+// Enricher fetches additional claims of the caller once the token is validated, e.g. the profile attributes
+// missing from the access tokens. The claims are merged into the claims of the principal without overriding
+// those of the token.
+//
+// Enrichers reject the token by returning a *TokenError, any other error is logged and reported while the
+// request goes on without the additional claims.
+type Enricher interface {
+	Enrich(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error)
+}
+
+// EnricherFunc adapter to use an ordinary function as an Enricher
+type EnricherFunc func(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error)
+
+// Enrich calls f(ctx, token, claims)
+func (f EnricherFunc) Enrich(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error) {
+	return f(ctx, token, claims)
+}
+
+// enrich merges the claims of the Enrichers into the claims of the token
+func (mw *AuthMiddleware) enrich(ctx context.Context, logger Logger, token string, claims jwtgo.MapClaims) error {
+	for _, enricher := range mw.Enrichers {
+		if err := mw.deadline(ctx); err != nil {
+			return err
+		}
+		additional, err := enricher.Enrich(ctx, token, claims)
+		var tokenErr *TokenError
+		if errors.As(err, &tokenErr) {
+			return err
+		}
+		if err != nil {
+			logger.Error("Failed to enrich the claims", "error", err)
+			mw.reportError(err, map[string]string{"operation": "enrichment"})
+			continue
+		}
+		for name, value := range additional {
+			if _, ok := claims[name]; !ok {
+				claims[name] = value
+			}
+		}
+	}
+	return mw.deadline(ctx)
+}
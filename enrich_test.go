@@ -0,0 +1,31 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_EnrichmentFailures(t *testing.T) {
+	t.Logf("Given enrichers failing or rejecting the token")
+	{
+		var reported int
+		mw := newTestMiddleware()
+		mw.ErrorReporter = ErrorReporterFunc(func(err error, tags map[string]string) { reported++ })
+		mw.Enrichers = []Enricher{EnricherFunc(func(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error) {
+			return nil, errors.New("userInfo unavailable")
+		})}
+		router := authzHandler(mw)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		assert.Equal(t, 1, reported)
+
+		mw.Enrichers = []Enricher{EnricherFunc(func(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error) {
+			return nil, tokenError(ErrInvalidClaims, errors.New("user deleted"))
+		})}
+		router = authzHandler(mw)
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+	}
+}
@@ -0,0 +1,126 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The environment variables read by NewFromEnv
+const (
+
+	// EnvRegion the AWS region of the user pool, required
+	EnvRegion = "COGNITO_REGION"
+
+	// EnvUserPoolID the ID of the user pool, required
+	EnvUserPoolID = "COGNITO_USER_POOL_ID"
+
+	// EnvAppClientID the ID of the app client
+	EnvAppClientID = "COGNITO_APP_CLIENT_ID"
+
+	// EnvAppClientSecret the secret of the app client
+	EnvAppClientSecret = "COGNITO_APP_CLIENT_SECRET"
+
+	// EnvIssuer the expected issuer, the one of the user pool by default
+	EnvIssuer = "COGNITO_ISSUER"
+
+	// EnvDomain the domain of the Hosted UI
+	EnvDomain = "COGNITO_DOMAIN"
+
+	// EnvRedirectURL the callback URL of the Hosted UI login
+	EnvRedirectURL = "COGNITO_REDIRECT_URL"
+
+	// EnvTokenLookup where the token is read from, e.g. header:Authorization or cookie:access_token
+	EnvTokenLookup = "JWT_TOKEN_LOOKUP"
+
+	// EnvRealm the realm of the WWW-Authenticate challenges
+	EnvRealm = "JWT_REALM"
+
+	// EnvRequiredGroups the comma separated groups required on every route
+	EnvRequiredGroups = "JWT_REQUIRED_GROUPS"
+
+	// EnvRequiredScopes the comma or space separated scopes required on every route
+	EnvRequiredScopes = "JWT_REQUIRED_SCOPES"
+
+	// EnvRequiredTokenUse the token_use required on every route, id or access
+	EnvRequiredTokenUse = "JWT_REQUIRED_TOKEN_USE"
+
+	// EnvPublicRoutes the comma separated routes which do not require a token, e.g. "GET /health,/metrics"
+	EnvPublicRoutes = "JWT_PUBLIC_ROUTES"
+
+	// EnvErrorMode how much detail of the failures the responses disclose: verbose or production
+	EnvErrorMode = "JWT_ERROR_MODE"
+
+	// EnvMachineToMachine whether only the machine to machine tokens are accepted, a boolean
+	EnvMachineToMachine = "JWT_MACHINE_TO_MACHINE"
+
+	// EnvStrictMode whether the StrictMode is on, a boolean
+	EnvStrictMode = "JWT_STRICT_MODE"
+)
+
+// NewFromEnv creates a middleware configured by the environment variables named by the Env constants, so that
+// the twelve-factor deployments need no wiring code. The configuration is validated as by the Builder, and the
+// json web key set of the user pool is downloaded.
+func NewFromEnv() (*AuthMiddleware, error) {
+	builder, err := builderFromEnv(os.Getenv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid middleware configuration: %w", errors.Join(err, builder.mw.Validate()))
+	}
+	return builder.Build()
+}
+
+// builderFromEnv a Builder configured by the environment variables looked up with getenv
+func builderFromEnv(getenv func(string) string) (*MiddlewareBuilder, error) {
+	var errs []error
+	builder := Builder().
+		Region(getenv(EnvRegion)).
+		UserPool(getenv(EnvUserPoolID)).
+		Issuer(getenv(EnvIssuer)).
+		ClientID(getenv(EnvAppClientID), getenv(EnvAppClientSecret)).
+		TokenLookup(getenv(EnvTokenLookup)).
+		RequireGroups(splitList(getenv(EnvRequiredGroups), ",")...).
+		RequireScopes(splitList(getenv(EnvRequiredScopes), ", ")...).
+		RequireTokenUse(getenv(EnvRequiredTokenUse)).
+		PublicRoutes(splitList(getenv(EnvPublicRoutes), ",")...)
+
+	switch mode := getenv(EnvErrorMode); mode {
+	case "", "verbose":
+	case "production":
+		builder.ErrorMode(ProductionErrors)
+	default:
+		errs = append(errs, fmt.Errorf("%s %q is neither verbose nor production", EnvErrorMode, mode))
+	}
+	if value := getenv(EnvMachineToMachine); value != "" {
+		machineToMachine, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %q is not a boolean", EnvMachineToMachine, value))
+		}
+		builder.Configure(func(mw *AuthMiddleware) { mw.MachineToMachine = machineToMachine })
+	}
+	if value := getenv(EnvStrictMode); value != "" {
+		strictMode, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %q is not a boolean", EnvStrictMode, value))
+		}
+		builder.Configure(func(mw *AuthMiddleware) { mw.StrictMode = strictMode })
+	}
+	builder.Configure(func(mw *AuthMiddleware) {
+		mw.Realm = getenv(EnvRealm)
+		mw.Domain = getenv(EnvDomain)
+		mw.RedirectURL = getenv(EnvRedirectURL)
+	})
+	return builder, errors.Join(errs...)
+}
+
+// splitList splits the list on any of the separators, trimming the items and dropping the empty ones
+func splitList(list, separators string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool { return strings.ContainsRune(separators, r) }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,64 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_NewFromEnv(t *testing.T) {
+	t.Logf("Given the configuration of the middleware in the environment")
+	{
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []JWKKey{}
+			for _, key := range testJWK() {
+				keys = append(keys, key)
+			}
+			json.NewEncoder(w).Encode(JWK{Keys: keys})
+		}))
+		defer server.Close()
+		defer func(format string) { jwkURLFormat = format }(jwkURLFormat)
+		jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+
+		t.Setenv(EnvRegion, TestRegion)
+		t.Setenv(EnvUserPoolID, TestUserPoolID)
+		t.Setenv(EnvAppClientID, "web")
+		t.Setenv(EnvTokenLookup, "cookie:access_token")
+		t.Setenv(EnvRequiredGroups, "admin, ops")
+		t.Setenv(EnvRequiredScopes, "orders/read orders/write")
+		t.Setenv(EnvPublicRoutes, "GET /health, /metrics")
+		t.Setenv(EnvErrorMode, "production")
+		t.Setenv(EnvMachineToMachine, "true")
+
+		mw, err := NewFromEnv()
+		assert.NoError(t, err)
+		assert.Equal(t, "web", mw.ClientID)
+		assert.Equal(t, "cookie:access_token", mw.TokenLookup)
+		assert.Equal(t, []string{"admin", "ops"}, mw.Required.Groups)
+		assert.Equal(t, []string{"orders/read", "orders/write"}, mw.Required.Scopes)
+		assert.Equal(t, []string{"GET /health", "/metrics"}, mw.PublicRoutes)
+		assert.Equal(t, ProductionErrors, mw.ErrorMode)
+		assert.True(t, mw.MachineToMachine)
+		assert.Equal(t, 1, mw.KeyCount())
+	}
+
+	t.Logf("Given an invalid configuration in the environment")
+	{
+		t.Setenv(EnvRegion, "")
+		t.Setenv(EnvUserPoolID, TestUserPoolID)
+		t.Setenv(EnvErrorMode, "quiet")
+		t.Setenv(EnvMachineToMachine, "sometimes")
+
+		_, err := NewFromEnv()
+		assert.Error(t, err)
+		for _, problem := range []string{
+			`JWT_ERROR_MODE "quiet" is neither verbose nor production`,
+			`JWT_MACHINE_TO_MACHINE "sometimes" is not a boolean`,
+			"the region is required",
+		} {
+			assert.Contains(t, err.Error(), problem)
+		}
+	}
+}
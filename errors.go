@@ -0,0 +1,334 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+)
+
+var (
+
+	// ErrMissingHeader the header holding the token is missing or empty
+	ErrMissingHeader = AuthHeaderEmptyError
+
+	// ErrInvalidTokenLookup the TokenLookup of the middleware is not supported
+	ErrInvalidTokenLookup = InvalidAuthHeaderError
+
+	// ErrMalformedToken the token is not a well formed JWT
+	ErrMalformedToken = errors.New("malformed token")
+
+	// ErrUnexpectedSigningMethod the token is not signed with RSA
+	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
+
+	// ErrUnknownKeyID the kid of the token is not part of the json web key set
+	ErrUnknownKeyID = errors.New("unknown key id")
+
+	// ErrMalformedKey the json web key of the kid of the token is malformed, e.g. its modulus is not base64url
+	ErrMalformedKey = errors.New("malformed json web key")
+
+	// ErrWeakKey the json web key of the kid of the token is too short, as per the StrictMode of the middleware
+	ErrWeakKey = errors.New("weak json web key")
+
+	// ErrInvalidSignature the signature of the token does not verify
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrTokenExpired the token is expired
+	ErrTokenExpired = errors.New("token is expired")
+
+	// ErrTokenNotValidYet the token is used before its nbf or iat
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+
+	// ErrMissingIssuer the token has no iss claim
+	ErrMissingIssuer = errors.New("token does not contain issuer")
+
+	// ErrBadIssuer the iss claim does not match the user pool
+	ErrBadIssuer = errors.New("bad issuer")
+
+	// ErrWrongTokenUse the token_use claim is neither id nor access
+	ErrWrongTokenUse = errors.New("token_use should be id or access")
+
+	// ErrTokenRevoked the token was revoked, e.g. by the LogoutHandler
+	ErrTokenRevoked = errors.New("token is revoked")
+
+	// ErrUserDisabled the user of the token is disabled in the user pool
+	ErrUserDisabled = errors.New("user is disabled")
+
+	// ErrUserNotFound the user of the token no longer exists in the user pool
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrDeviceNotRemembered the device the token was signed in from is no longer remembered by the user pool
+	ErrDeviceNotRemembered = errors.New("device not remembered")
+
+	// ErrInvalidClaims any other invalid claim
+	ErrInvalidClaims = errors.New("invalid claims")
+)
+
+var (
+
+	// ErrMissingGroups the caller is not a member of the required groups
+	ErrMissingGroups = errors.New("missing groups")
+
+	// ErrInsufficientScope the token was not granted the required scopes
+	ErrInsufficientScope = errors.New("insufficient scope")
+
+	// ErrMissingPermissions the groups of the caller do not grant the required RBAC permissions
+	ErrMissingPermissions = errors.New("missing permissions")
+
+	// ErrPolicyDenied the request does not satisfy the ABAC policy of the route
+	ErrPolicyDenied = errors.New("denied by policy")
+
+	// ErrWrongTokenType the route requires the other type of token, id or access
+	ErrWrongTokenType = errors.New("wrong token type")
+
+	// ErrNotOwner the caller does not own the resource
+	ErrNotOwner = errors.New("not the owner of the resource")
+
+	// ErrClaimsRejected the ClaimsMapper rejected the claims of the token
+	ErrClaimsRejected = errors.New("claims rejected")
+
+	// ErrAccessDenied the external Authorizer denied the request
+	ErrAccessDenied = errors.New("access denied")
+
+	// ErrCSRF the unsafe request authenticated by a cookie lacks the proof it is not a cross site request forgery,
+	// see CSRFMode
+	ErrCSRF = errors.New("csrf check failed")
+)
+
+var (
+
+	// ErrJWKSUnavailable the json web key set of the user pool could not be downloaded
+	ErrJWKSUnavailable = errors.New("json web key set unavailable")
+
+	// ErrMissingRefreshToken the request to the RefreshHandler carries no refresh token
+	ErrMissingRefreshToken = errors.New("refresh token missing")
+
+	// ErrInvalidLoginState the callback of the Hosted UI does not match the login in progress
+	ErrInvalidLoginState = errors.New("invalid login state")
+
+	// ErrMissingAuthorizationCode the request to the CodeExchangeHandler carries no authorization code
+	ErrMissingAuthorizationCode = errors.New("authorization code missing")
+
+	// ErrNonceMismatch the nonce of the ID token issued by the user pool is not the one of the login
+	ErrNonceMismatch = errors.New("nonce mismatch")
+
+	// ErrUnsupportedChallenge the user pool answered AuthenticateSRP with a challenge it does not support, e.g. MFA
+	ErrUnsupportedChallenge = errors.New("unsupported challenge")
+
+	// ErrInvalidChallenge the user pool answered AuthenticateSRP with challenge parameters missing or unsafe to use
+	ErrInvalidChallenge = errors.New("invalid challenge")
+
+	// ErrInternal an unexpected failure of the middleware, e.g. a recovered panic
+	ErrInternal = errors.New("internal error")
+
+	// ErrValidationTimeout the validation of the request did not complete within the Timeout of the middleware,
+	// the request is answered with a 503
+	ErrValidationTimeout = errors.New("validation timed out")
+
+	// ErrInvalidDPoPProof the DPoP proof of possession of the key the access token is bound to is missing or invalid,
+	// as per the DPoP of the middleware
+	ErrInvalidDPoPProof = errors.New("invalid dpop proof")
+
+	// ErrCertificateMismatch the access token is bound to another certificate than the client certificate of the
+	// request, RFC 8705
+	ErrCertificateMismatch = errors.New("certificate bound token mismatch")
+
+	// ErrFingerprintMismatch the token is used from a client of another fingerprint than the one it was first used
+	// from, as per the Fingerprint of the middleware
+	ErrFingerprintMismatch = errors.New("token used from another client")
+
+	// ErrUnknownTenant the TenantResolver of the middleware resolved the request to a tenant which is not one of its
+	// Tenants
+	ErrUnknownTenant = errors.New("unknown tenant")
+
+	// ErrTenantMismatch the token is not issued by the user pool of the tenant of the request
+	ErrTenantMismatch = errors.New("token of another tenant")
+
+	// ErrTooManyFailures the client failed too many validations, as per the FailureLimiter of the middleware, the
+	// request is answered with a 429
+	ErrTooManyFailures = errors.New("too many failed authentications")
+
+	// ErrPrincipalLockedOut the principal of the token is temporarily locked out after too many revoked tokens, as
+	// per the Lockout of the middleware
+	ErrPrincipalLockedOut = errors.New("principal is locked out")
+
+	// ErrQuotaExceeded the principal exceeded the Quota of its tenant, the request is answered with a 429
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// failureReasons the failure reason of each error, as reported in the logs, metrics and audit events
+var failureReasons = []struct {
+	err    error
+	reason string
+}{
+	{ErrInternal, "internal_error"},
+	{ErrValidationTimeout, "timeout"},
+	{ErrInvalidDPoPProof, "invalid_dpop_proof"},
+	{ErrCertificateMismatch, "certificate_mismatch"},
+	{ErrFingerprintMismatch, "fingerprint_mismatch"},
+	{ErrUnknownTenant, "unknown_tenant"},
+	{ErrTenantMismatch, "tenant_mismatch"},
+	{ErrTooManyFailures, "rate_limited"},
+	{ErrPrincipalLockedOut, "locked_out"},
+	{ErrQuotaExceeded, "quota_exceeded"},
+	{ErrMissingHeader, "missing_token"},
+	{ErrInvalidTokenLookup, "invalid_header"},
+	{ErrMalformedToken, "malformed"},
+	{ErrUnexpectedSigningMethod, "unverifiable"},
+	{ErrUnknownKeyID, "unknown_kid"},
+	{ErrMalformedKey, "malformed_key"},
+	{ErrWeakKey, "weak_key"},
+	{ErrInvalidSignature, "invalid_signature"},
+	{ErrTokenExpired, "expired"},
+	{ErrTokenNotValidYet, "not_valid_yet"},
+	{ErrMissingIssuer, "bad_issuer"},
+	{ErrBadIssuer, "bad_issuer"},
+	{ErrWrongTokenUse, "wrong_token_use"},
+	{ErrTokenRevoked, "revoked"},
+	{ErrUserDisabled, "user_disabled"},
+	{ErrUserNotFound, "user_not_found"},
+	{ErrDeviceNotRemembered, "device_not_remembered"},
+}
+
+// ErrorMode how much detail of the failures the error responses disclose
+type ErrorMode int
+
+const (
+
+	// VerboseErrors the responses carry the concrete failure, e.g. "Token is expired", handy in development
+	VerboseErrors ErrorMode = iota
+
+	// ProductionErrors the responses carry the generic status text, e.g. "Unauthorized", while the concrete
+	// failure only goes to the logs and audit events
+	ProductionErrors
+)
+
+// TokenError a token validation failure. errors.Is matches Kind, one of the Err* variables, and errors.As
+// reaches the underlying Err, e.g. the *jwtgo.ValidationError of the jwt library.
+type TokenError struct {
+	Kind error
+	Err  error
+}
+
+// Error the message of the underlying error, the message of the Kind when there is none
+func (e *TokenError) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns both the Kind and the underlying error
+func (e *TokenError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// JWKSError a failure to download the json web key set of a user pool. errors.Is matches ErrJWKSUnavailable
+// and errors.As reaches the underlying Err, e.g. the *url.Error of the HTTP client or the *json.SyntaxError
+// of the decoder.
+type JWKSError struct {
+	URL string
+
+	// StatusCode the status of the response, zero when no response was received
+	StatusCode int
+
+	Err error
+}
+
+func (e *JWKSError) Error() string {
+	return fmt.Sprintf("failed to download the jwk from %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns both ErrJWKSUnavailable and the underlying error
+func (e *JWKSError) Unwrap() []error {
+	return []error{ErrJWKSUnavailable, e.Err}
+}
+
+// AccessError an authorization failure of an authenticated caller. errors.Is matches Kind, one of the
+// authorization Err* variables, while the message describes the requirement which is not met.
+type AccessError struct {
+	Kind    error
+	Message string
+}
+
+func (e *AccessError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the Kind
+func (e *AccessError) Unwrap() error {
+	return e.Kind
+}
+
+func accessError(kind error, message string) error {
+	return &AccessError{Kind: kind, Message: message}
+}
+
+// tokenError wraps err in a TokenError of the given kind
+func tokenError(kind, err error) error {
+	return &TokenError{Kind: kind, Err: err}
+}
+
+// classify turns the error returned by the jwt library into a TokenError
+func (mw *AuthMiddleware) classify(token *jwtgo.Token, err error) error {
+	var tokenErr *TokenError
+	if errors.As(err, &tokenErr) {
+		return err
+	}
+	validationErr, ok := err.(*jwtgo.ValidationError)
+	if !ok {
+		return tokenError(ErrInvalidClaims, err)
+	}
+	switch {
+	case validationErr.Errors&jwtgo.ValidationErrorMalformed != 0:
+		return tokenError(ErrMalformedToken, err)
+	case validationErr.Errors&jwtgo.ValidationErrorUnverifiable != 0:
+		if errors.Is(validationErr.Inner, ErrUnknownKeyID) {
+			return tokenError(ErrUnknownKeyID, err)
+		}
+		if errors.Is(validationErr.Inner, ErrUnexpectedSigningMethod) {
+			return tokenError(ErrUnexpectedSigningMethod, err)
+		}
+		var tokenErr *TokenError
+		if errors.As(validationErr.Inner, &tokenErr) {
+			return validationErr.Inner
+		}
+		return tokenError(ErrInvalidSignature, err)
+	case validationErr.Errors&jwtgo.ValidationErrorSignatureInvalid != 0:
+		return tokenError(ErrInvalidSignature, err)
+	case validationErr.Errors&jwtgo.ValidationErrorExpired != 0:
+		return tokenError(ErrTokenExpired, err)
+	case validationErr.Errors&(jwtgo.ValidationErrorNotValidYet|jwtgo.ValidationErrorIssuedAt) != 0:
+		return tokenError(ErrTokenNotValidYet, err)
+	}
+	return tokenError(ErrInvalidClaims, err)
+}
+
+// StatusCode the status of the response to a request failing with err, as the gin middleware answers it: 429 for the
+// exceeded quotas, 403 for the AccessError, 503 for the timeouts and 401 otherwise. The adapters of the other web
+// frameworks answer the failures of ValidateRequest with it.
+func StatusCode(err error) int {
+	var accessErr *AccessError
+	switch {
+	case errors.Is(err, ErrQuotaExceeded), errors.Is(err, ErrTooManyFailures):
+		return http.StatusTooManyRequests
+	case errors.As(err, &accessErr):
+		return http.StatusForbidden
+	case errors.Is(err, ErrValidationTimeout):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusUnauthorized
+}
+
+// failureReason classifies the validation errors for the logs, metrics and audit events
+func failureReason(err error) string {
+	for _, candidate := range failureReasons {
+		if errors.Is(err, candidate.err) {
+			return candidate.reason
+		}
+	}
+	return "invalid_claims"
+}
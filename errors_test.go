@@ -0,0 +1,175 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_ValidationErrorsAreTyped(t *testing.T) {
+	t.Logf("Given tokens failing the validation for different reasons")
+	{
+		mw := newTestMiddleware()
+		mw.MiddlewareInit()
+
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		otherPool := testClaims()
+		otherPool["iss"] = "https://cognito-idp.eu-west-2.amazonaws.com/eu-west-2_other"
+		refresh := testClaims()
+		refresh["token_use"] = "refresh"
+		noIssuer := testClaims()
+		delete(noIssuer, "iss")
+		unknownKid := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+		unknownKid.Header["kid"] = "rotated-kid"
+		unknownKidStr, _ := unknownKid.SignedString(testKey)
+		hmac, _ := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, testClaims()).SignedString([]byte("secret"))
+
+		cases := []struct {
+			token    string
+			expected error
+		}{
+			{"not-a-token", ErrMalformedToken},
+			{signToken(expired), ErrTokenExpired},
+			{signToken(otherPool), ErrBadIssuer},
+			{signToken(refresh), ErrWrongTokenUse},
+			{signToken(noIssuer), ErrMissingIssuer},
+			{unknownKidStr, ErrUnknownKeyID},
+			{hmac, ErrUnexpectedSigningMethod},
+			{ExpiredCognitoToken, ErrUnknownKeyID},
+		}
+		for _, tc := range cases {
+			_, err := mw.ValidateToken(tc.token)
+			assert.True(t, errors.Is(err, tc.expected), "expected %v, got %v", tc.expected, err)
+		}
+
+		_, err := mw.ValidateToken(signToken(expired))
+		var validationErr *jwtgo.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "Token is expired", err.Error())
+
+		_, err = mw.ExtractToken(http.Header{}.Get)
+		assert.True(t, errors.Is(err, ErrMissingHeader))
+	}
+}
+
+func Test_ErrorMapper(t *testing.T) {
+	t.Logf("Given a middleware mapping expired tokens to a refresh hint")
+	{
+		mw := newTestMiddleware()
+		mw.ErrorMapper = func(err error) (int, interface{}) {
+			switch {
+			case errors.Is(err, ErrTokenExpired):
+				return http.StatusUnauthorized, map[string]string{"error": "token_expired", "refresh": "/auth/refresh"}
+			case errors.Is(err, ErrInsufficientScope):
+				return http.StatusForbidden, map[string]string{"error": "wrong_scope", "message": err.Error()}
+			}
+			return 0, nil
+		}
+		router := authzHandler(mw, mw.RequireScopes("orders/write"))
+
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		w := performRequest(router, "GET", "/orders", signToken(expired))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error":"token_expired","refresh":"/auth/refresh"}`, w.Body.String())
+
+		w = performRequest(router, "GET", "/orders", signToken(testClaims()))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"error":"wrong_scope","message":"requires scopes: orders/write"}`, w.Body.String())
+
+		w = performRequest(router, "GET", "/orders", "not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":401`)
+	}
+}
+
+func Test_AuthenticationAndAuthorizationFailures(t *testing.T) {
+	t.Logf("Given a route requiring the admins group")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw, mw.RequireGroups("admins"))
+
+		w := performRequest(router, "GET", "/orders", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="gin jwt"`, w.Header().Get(AuthenticateHeader))
+
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		w = performRequest(router, "GET", "/orders", signToken(expired))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="gin jwt", error="invalid_token", error_description="expired"`, w.Header().Get(AuthenticateHeader))
+
+		w = performRequest(router, "GET", "/orders", tokenWithGroups("users"))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get(AuthenticateHeader))
+	}
+
+	t.Logf("Given a realm holding quotes")
+	{
+		mw := newTestMiddleware()
+		mw.Realm = `the "orders" api`
+		assert.Equal(t, `Bearer realm="the \"orders\" api"`, mw.Challenge(nil))
+	}
+}
+
+func Test_ProductionErrorsHideTheFailure(t *testing.T) {
+	t.Logf("Given a middleware in production error mode")
+	{
+		mw := newTestMiddleware()
+		mw.ErrorMode = ProductionErrors
+		events := make(chan AuditEvent, 3)
+		mw.AuditEvents = AuditChannel(events)
+		router := authzHandler(mw, mw.RequireScopes("orders/write"))
+
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		w := performRequest(router, "GET", "/orders", signToken(expired))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"code":401,"message":"Unauthorized"}`, withoutErrorID(t, w.Body.String()))
+		assert.Equal(t, "expired", (<-events).Reason)
+
+		w = performRequest(router, "GET", "/orders", signToken(testClaims()))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"code":403,"message":"Forbidden","error":"insufficient_scope"}`, withoutErrorID(t, w.Body.String()))
+		assert.Equal(t, AuditAuthenticated, (<-events).Outcome)
+		assert.Equal(t, "requires scopes: orders/write", (<-events).Reason)
+	}
+}
+
+func Test_JWKSErrorsWrapTheCause(t *testing.T) {
+	t.Logf("Given user pools failing to serve their json web key set")
+	{
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/unavailable" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("<html>"))
+		}))
+		defer server.Close()
+
+		_, err := getJWK(NopLogger{}, server.URL+"/unavailable")
+		var jwksErr *JWKSError
+		assert.True(t, errors.As(err, &jwksErr))
+		assert.True(t, errors.Is(err, ErrJWKSUnavailable))
+		assert.Equal(t, http.StatusServiceUnavailable, jwksErr.StatusCode)
+
+		_, err = getJWK(NopLogger{}, server.URL+"/html")
+		var syntaxErr *json.SyntaxError
+		assert.True(t, errors.Is(err, ErrJWKSUnavailable))
+		assert.True(t, errors.As(err, &syntaxErr))
+
+		server.Close()
+		_, err = getJWK(NopLogger{}, server.URL)
+		var urlErr *url.Error
+		assert.True(t, errors.Is(err, ErrJWKSUnavailable))
+		assert.True(t, errors.As(err, &urlErr))
+	}
+}
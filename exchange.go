@@ -0,0 +1,86 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+)
+
+// TokenSink hands the tokens obtained by the CodeExchangeHandler over to the client
+type TokenSink interface {
+	DeliverTokens(c *gin.Context, tokens *Tokens)
+}
+
+// TokenSinkFunc adapter to use an ordinary function as a TokenSink
+type TokenSinkFunc func(c *gin.Context, tokens *Tokens)
+
+// DeliverTokens calls f(c, tokens)
+func (f TokenSinkFunc) DeliverTokens(c *gin.Context, tokens *Tokens) {
+	f(c, tokens)
+}
+
+// JSONTokenSink answers with the tokens as a JSON document, for single page and native apps
+func JSONTokenSink() TokenSink {
+	return TokenSinkFunc(func(c *gin.Context, tokens *Tokens) {
+		c.JSON(http.StatusOK, tokens)
+	})
+}
+
+// CookieTokenSink sets the tokens as HTTP only cookies, the refresh token under refreshCookie, and answers
+// with a 204
+func CookieTokenSink(refreshCookie string) TokenSink {
+	return TokenSinkFunc(func(c *gin.Context, tokens *Tokens) {
+		setTokenCookies(c, tokens, refreshCookie)
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// codeExchangeRequest the JSON body accepted by the CodeExchangeHandler
+type codeExchangeRequest struct {
+	Code         string `json:"code"`
+	CodeVerifier string `json:"code_verifier"`
+
+	// RedirectURI the redirect URI the code was issued to, the RedirectURL of the middleware by default
+	RedirectURI string `json:"redirect_uri"`
+
+	// Nonce the nonce of the login, checked against the ID token when set
+	Nonce string `json:"nonce"`
+}
+
+// CodeExchangeHandler exchanges the authorization code, and the PKCE code verifier, posted by the client at the
+// token endpoint of the user pool. The ID token is validated as any other token before the tokens are handed
+// over to the TokenSink.
+func (mw *AuthMiddleware) CodeExchangeHandler() gin.HandlerFunc {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(c *gin.Context) {
+		mw.correlate(c)
+		logger := mw.requestLog(c)
+		var request codeExchangeRequest
+		if err := c.ShouldBindJSON(&request); err != nil || request.Code == "" {
+			mw.respond(c, http.StatusBadRequest, ErrMissingAuthorizationCode, nil)
+			return
+		}
+		if request.RedirectURI == "" {
+			request.RedirectURI = mw.RedirectURL
+		}
+
+		tokens, err := mw.exchangeCode(c.Request.Context(), request.Code, request.CodeVerifier, request.RedirectURI)
+		if err != nil {
+			mw.tokenRequestFailed(c, logger, "code_exchange", err)
+			return
+		}
+		token, err := mw.validateToken(tokens.IDToken, logger)
+		if err == nil && request.Nonce != "" {
+			if nonce, _ := token.Claims.(jwtgo.MapClaims)["nonce"].(string); nonce != request.Nonce {
+				err = ErrNonceMismatch
+			}
+		}
+		if err != nil {
+			logger.Warn("Rejected the ID token of the code exchange", "error", err)
+			mw.respond(c, http.StatusUnauthorized, err, nil)
+			return
+		}
+		mw.TokenSink.DeliverTokens(c, tokens)
+	}
+}
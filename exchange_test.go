@@ -0,0 +1,68 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func exchangeCode(router http.Handler, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/auth/token", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func Test_CodeExchangeHandler(t *testing.T) {
+	t.Logf("Given a single page app exchanging its authorization code with PKCE")
+	{
+		nonce, verifier := "spa-nonce", ""
+		server := hostedUI(t, &nonce, &verifier)
+		defer server.Close()
+
+		mw := newTestMiddleware()
+		mw.Domain = server.URL
+		mw.RedirectURL = "https://app.example.com/callback"
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/auth/token", mw.CodeExchangeHandler())
+
+		w := exchangeCode(router, `{"code":"valid-code","code_verifier":"the-verifier","nonce":"spa-nonce"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"refresh_token":"refresh"`)
+		assert.Equal(t, "the-verifier", verifier)
+
+		w = exchangeCode(router, `{"code":"valid-code","code_verifier":"the-verifier","nonce":"replayed"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = exchangeCode(router, `{"code":"expired-code","code_verifier":"the-verifier"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+
+		w = exchangeCode(router, `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	t.Logf("Given the tokens are handed over as cookies")
+	{
+		nonce, verifier := "", ""
+		server := hostedUI(t, &nonce, &verifier)
+		defer server.Close()
+
+		mw := newTestMiddleware()
+		mw.Domain = server.URL
+		mw.RedirectURL = "https://app.example.com/callback"
+		mw.TokenSink = CookieTokenSink(DefaultRefreshTokenCookie)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/auth/token", mw.CodeExchangeHandler())
+
+		w := exchangeCode(router, `{"code":"valid-code","code_verifier":"the-verifier"}`)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Len(t, w.Result().Cookies(), 3)
+	}
+}
@@ -0,0 +1,52 @@
+// Package fiberjwt adapts the Cognito JWT middleware to the Fiber web framework. The validation is
+// delegated to the jwt.AuthMiddleware, hence the configuration is shared with the gin services.
+package fiberjwt
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+)
+
+// New returns a Fiber handler validating the token as configured in the given middleware. The parsed
+// token, the raw token string and the jwt.Principal are stored in the fiber Locals under the same keys
+// the gin middleware uses.
+func New(mw *jwt.AuthMiddleware) fiber.Handler {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(c *fiber.Ctx) error {
+		tokenStr, err := mw.ExtractToken(func(key string) string {
+			// fasthttp reuses the header buffers once the handler returns
+			return utils.CopyString(c.Get(key))
+		})
+		if err != nil {
+			return unauthorized(c, mw, err)
+		}
+
+		token, err := mw.ValidateToken(tokenStr)
+		if err != nil {
+			return unauthorized(c, mw, err)
+		}
+
+		c.Locals(jwt.TokenKey, token)
+		c.Locals(jwt.TokenStringKey, tokenStr)
+		c.Locals(jwt.PrincipalKey, jwt.NewPrincipal(token.Claims.(jwtgo.MapClaims)))
+		return c.Next()
+	}
+}
+
+// GetPrincipal returns the jwt.Principal stored in the Locals by the handler
+func GetPrincipal(c *fiber.Ctx) (jwt.Principal, bool) {
+	principal, ok := c.Locals(jwt.PrincipalKey).(jwt.Principal)
+	return principal, ok
+}
+
+func unauthorized(c *fiber.Ctx, mw *jwt.AuthMiddleware, err error) error {
+	status := jwt.StatusCode(err)
+	if status == http.StatusUnauthorized {
+		c.Set(jwt.AuthenticateHeader, mw.Challenge(err))
+	}
+	return c.Status(status).JSON(jwt.AuthError{Code: status, Message: err.Error()})
+}
@@ -0,0 +1,39 @@
+package fiberjwt
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_FiberHandler(t *testing.T) {
+	t.Logf("Given a fiber app protected by the middleware")
+	{
+		issuer, err := jwttest.NewIssuer()
+		assert.Nil(t, err)
+
+		app := fiber.New()
+		app.Get("/me", New(issuer.Middleware()), func(c *fiber.Ctx) error {
+			principal, _ := GetPrincipal(c)
+			return c.SendString(principal.ID())
+		})
+
+		token, err := issuer.Sign(issuer.Claims("user-123"))
+		assert.Nil(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(jwt.AuthorizationHeader, token)
+		resp, err := app.Test(req)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/me", nil))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `Bearer realm="gin jwt"`, resp.Header.Get(jwt.AuthenticateHeader))
+	}
+}
@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FingerprintStore keeps the fingerprint of the client each token was first used from
+type FingerprintStore interface {
+
+	// Bind binds the token of the given key to fingerprint until it expires, unless it is already bound to one.
+	// It returns the fingerprint the token is bound to. The until time is on the wall clock, whatever the TimeFunc
+	// of the middleware.
+	Bind(key, fingerprint string, until time.Time) (string, error)
+}
+
+// FingerprintStoreFunc adapter to use an ordinary function as a FingerprintStore
+type FingerprintStoreFunc func(key, fingerprint string, until time.Time) (string, error)
+
+// Bind calls f(key, fingerprint, until)
+func (f FingerprintStoreFunc) Bind(key, fingerprint string, until time.Time) (string, error) {
+	return f(key, fingerprint, until)
+}
+
+// FingerprintBinding binds the tokens to the fingerprint of the client they are first used from, the prefix of its
+// IP and a hash of its User-Agent, and rejects them with an ErrFingerprintMismatch once used from a client of another
+// fingerprint. It mitigates the theft of the tokens, at the cost of the clients changing network mid session, e.g.
+// mobile clients: widen the prefixes or leave the IP out for them. The requests are allowed when the Store fails.
+type FingerprintBinding struct {
+
+	// Store keeps the fingerprints, a MemoryFingerprintStore for a single instance, see the redisjwt package to share them
+	Store FingerprintStore
+
+	// IPv4Prefix the number of leading bits of the IPv4 addresses part of the fingerprint, the IP is left out when 0
+	IPv4Prefix int
+
+	// IPv6Prefix the number of leading bits of the IPv6 addresses part of the fingerprint, the IP is left out when 0
+	IPv6Prefix int
+
+	// UserAgent whether the User-Agent is part of the fingerprint
+	UserAgent bool
+
+	// ClientIP returns the IP of the client of the requests validated by ValidateRequest, the host of their RemoteAddr
+	// by default, set it behind a proxy. The gin handlers use the ClientIP of gin, as per its trusted proxies.
+	ClientIP func(*http.Request) string
+}
+
+// NewFingerprintBinding creates a FingerprintBinding of the /24 IPv4 and /64 IPv6 networks and the User-Agent
+func NewFingerprintBinding(store FingerprintStore) *FingerprintBinding {
+	return &FingerprintBinding{Store: store, IPv4Prefix: 24, IPv6Prefix: 64, UserAgent: true}
+}
+
+// valid whether the binding has a store, valid prefixes and a part of the fingerprint
+func (b *FingerprintBinding) valid() bool {
+	return b.Store != nil && b.IPv4Prefix >= 0 && b.IPv4Prefix <= 32 && b.IPv6Prefix >= 0 && b.IPv6Prefix <= 128 &&
+		(b.IPv4Prefix > 0 || b.IPv6Prefix > 0 || b.UserAgent)
+}
+
+// clientIP the IP of the client of the request validated outside of gin
+func (b *FingerprintBinding) clientIP(r *http.Request) string {
+	if b.ClientIP != nil {
+		return b.ClientIP(r)
+	}
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(r.RemoteAddr)
+	}
+	return host
+}
+
+// fingerprint the fingerprint of the client of the request of the given IP
+func (b *FingerprintBinding) fingerprint(r *http.Request, clientIP string) string {
+	var parts []string
+	if ip := net.ParseIP(clientIP); ip != nil {
+		if ipv4 := ip.To4(); ipv4 != nil && b.IPv4Prefix > 0 {
+			parts = append(parts, fmt.Sprintf("ip=%s/%d", ipv4.Mask(net.CIDRMask(b.IPv4Prefix, 32)), b.IPv4Prefix))
+		} else if ipv4 == nil && b.IPv6Prefix > 0 {
+			parts = append(parts, fmt.Sprintf("ip=%s/%d", ip.Mask(net.CIDRMask(b.IPv6Prefix, 128)), b.IPv6Prefix))
+		}
+	}
+	if b.UserAgent {
+		sum := sha256.Sum256([]byte(r.UserAgent()))
+		parts = append(parts, "ua="+base64.RawURLEncoding.EncodeToString(sum[:12]))
+	}
+	return strings.Join(parts, ";")
+}
+
+// checkFingerprint binds the token to the fingerprint of the client of the request, of the given IP, on first use,
+// rejecting it with an ErrFingerprintMismatch when it is bound to another one
+func (mw *AuthMiddleware) checkFingerprint(r *http.Request, clientIP string, token *jwtgo.Token, logger Logger) error {
+	if mw.Fingerprint == nil {
+		return nil
+	}
+	// the validity left is measured with the TimeFunc, the stores expiring the bindings on the wall clock
+	validity := time.Hour
+	if exp, ok := ExpiresAt(token.Claims.(jwtgo.MapClaims)); ok {
+		validity = exp.Sub(mw.now())
+	}
+	until := time.Now().Add(validity)
+	sum := sha256.Sum256([]byte(token.Raw))
+	fingerprint := mw.Fingerprint.fingerprint(r, clientIP)
+	bound, err := mw.Fingerprint.Store.Bind(base64.RawURLEncoding.EncodeToString(sum[:]), fingerprint, until)
+	if err != nil {
+		logger.Warn("Failed to bind the jwt token to the client fingerprint", "error", err)
+		mw.reportError(err, map[string]string{"operation": "fingerprint"})
+		return nil
+	}
+	if bound == fingerprint {
+		return nil
+	}
+	err = tokenError(ErrFingerprintMismatch, errors.New("the token is bound to the fingerprint of another client"))
+	mw.observe(failureReason(err), 0)
+	if mw.sampleFailure(failureReason(err)) {
+		logger.Warn("Rejected the jwt token used from another client", "error_class", failureReason(err), "error", err,
+			"fingerprint", fingerprint, "bound_fingerprint", bound)
+	}
+	return err
+}
+
+// MemoryFingerprintStore an in memory FingerprintStore, the fingerprints are not shared between instances. The
+// fingerprints of the expired tokens are forgotten as the store grows. It is safe for concurrent use.
+type MemoryFingerprintStore struct {
+	mu           sync.Mutex
+	fingerprints map[string]boundFingerprint
+	sweepAt      int
+}
+
+type boundFingerprint struct {
+	fingerprint string
+	until       time.Time
+}
+
+// minFingerprintSweep the number of fingerprints from which the ones of the expired tokens are forgotten
+const minFingerprintSweep = 1024
+
+// NewMemoryFingerprintStore creates an empty MemoryFingerprintStore
+func NewMemoryFingerprintStore() *MemoryFingerprintStore {
+	return &MemoryFingerprintStore{fingerprints: map[string]boundFingerprint{}, sweepAt: minFingerprintSweep}
+}
+
+// Bind binds the token to the fingerprint unless it is bound to one which has not expired
+func (s *MemoryFingerprintStore) Bind(key, fingerprint string, until time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if bound, ok := s.fingerprints[key]; ok && now.Before(bound.until) {
+		return bound.fingerprint, nil
+	}
+	s.fingerprints[key] = boundFingerprint{fingerprint: fingerprint, until: until}
+
+	if len(s.fingerprints) >= s.sweepAt {
+		for key, bound := range s.fingerprints {
+			if !now.Before(bound.until) {
+				delete(s.fingerprints, key)
+			}
+		}
+		s.sweepAt = max(2*len(s.fingerprints), minFingerprintSweep)
+	}
+	return fingerprint, nil
+}
@@ -0,0 +1,155 @@
+package jwt
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// requestFromAgent performs a request of the given client IP and User-Agent
+func requestFromAgent(r http.Handler, ip, userAgent, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req.RemoteAddr = net.JoinHostPort(ip, "4321")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(AuthorizationHeader, token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_FingerprintBinding(t *testing.T) {
+	t.Logf("Given tokens bound to the /24 network and the User-Agent of the client they are first used from")
+	{
+		mw := newTestMiddleware()
+		mw.Fingerprint = NewFingerprintBinding(NewMemoryFingerprintStore())
+		router := authzHandler(mw)
+		token := signToken(testClaims())
+
+		assert.Equal(t, http.StatusOK, requestFromAgent(router, "192.0.2.1", "app/1.0", token).Code)
+
+		t.Logf("Then the token is accepted from the same network and User-Agent")
+		assert.Equal(t, http.StatusOK, requestFromAgent(router, "192.0.2.77", "app/1.0", token).Code)
+
+		t.Logf("And it is rejected from another network or User-Agent")
+		for name, client := range map[string][2]string{"network": {"198.51.100.1", "app/1.0"}, "user agent": {"192.0.2.1", "curl/8.0"}} {
+			w := requestFromAgent(router, client[0], client[1], token)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+			assert.Equal(t, `Bearer realm="gin jwt", error="invalid_token", error_description="fingerprint_mismatch"`, w.Header().Get(AuthenticateHeader), name)
+		}
+		assert.Equal(t, uint64(2), mw.Stats().Failures["fingerprint_mismatch"])
+
+		t.Logf("And the other tokens are bound to their own client")
+		other := testClaims()
+		other["sub"] = "user-456"
+		assert.Equal(t, http.StatusOK, requestFromAgent(router, "198.51.100.1", "curl/8.0", signToken(other)).Code)
+	}
+
+	t.Logf("Given a middleware whose clock is behind the wall clock")
+	{
+		mw := newTestMiddleware()
+		mw.Fingerprint = NewFingerprintBinding(NewMemoryFingerprintStore())
+		now := time.Now().Add(-24 * time.Hour)
+		mw.TimeFunc = func() time.Time { return now }
+		router := authzHandler(mw)
+		claims := testClaims()
+		claims["iat"] = now.Unix()
+		claims["exp"] = now.Add(time.Hour).Unix()
+		token := signToken(claims)
+
+		t.Logf("Then the binding lasts for the validity of the token as per the clock of the middleware")
+		assert.Equal(t, http.StatusOK, requestFromAgent(router, "192.0.2.1", "app/1.0", token).Code)
+		assert.Equal(t, http.StatusUnauthorized, requestFromAgent(router, "198.51.100.1", "curl/8.0", token).Code)
+	}
+
+	t.Logf("Given tokens bound to the /64 IPv6 network of the client alone")
+	{
+		mw := newTestMiddleware()
+		mw.Fingerprint = &FingerprintBinding{Store: NewMemoryFingerprintStore(), IPv6Prefix: 64}
+		router := authzHandler(mw)
+		token := signToken(testClaims())
+
+		assert.Equal(t, http.StatusOK, requestFromAgent(router, "2001:db8:0:1::1", "app/1.0", token).Code)
+		assert.Equal(t, http.StatusOK, requestFromAgent(router, "2001:db8:0:1::2", "app/2.0", token).Code)
+		assert.Equal(t, http.StatusUnauthorized, requestFromAgent(router, "2001:db8:0:2::1", "app/1.0", token).Code)
+	}
+
+	t.Logf("Given tokens bound to their client and presented to the other entry points")
+	{
+		mw := newTestMiddleware()
+		mw.Fingerprint = NewFingerprintBinding(NewMemoryFingerprintStore())
+		mw.Sessions = NewMemorySessionStore()
+		router := authzHandler(mw)
+		router.GET("/auth", mw.ForwardAuthHandler())
+		token := signToken(testClaims())
+
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.RemoteAddr = "192.0.2.1:4321"
+		req.Header.Set("User-Agent", "app/1.0")
+		req.Header.Set("Accept", "text/html")
+		req.Header.Set(AuthorizationHeader, token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		session := w.Result().Cookies()[0]
+
+		t.Logf("Then the session is rejected from another client")
+		req = httptest.NewRequest("GET", "/orders", nil)
+		req.RemoteAddr = "198.51.100.1:4321"
+		req.Header.Set("User-Agent", "app/1.0")
+		req.AddCookie(session)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		t.Logf("And the forward auth rejects the token from another client")
+		req = httptest.NewRequest("GET", "/auth", nil)
+		req.RemoteAddr = "198.51.100.1:4321"
+		req.Header.Set("User-Agent", "app/1.0")
+		req.Header.Set(AuthorizationHeader, token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		t.Logf("And the token is rejected without its request, checked against the client of the request otherwise")
+		_, err := mw.ValidateToken(token)
+		assert.ErrorIs(t, err, ErrFingerprintMismatch)
+		req = httptest.NewRequest("GET", "/orders", nil)
+		req.RemoteAddr = "192.0.2.9:4321"
+		req.Header.Set("User-Agent", "app/1.0")
+		_, err = mw.ValidateRequest(req, token)
+		assert.NoError(t, err)
+		req.Header.Set("User-Agent", "curl/8.0")
+		_, err = mw.ValidateRequest(req, token)
+		assert.ErrorIs(t, err, ErrFingerprintMismatch)
+	}
+
+	t.Logf("Given a fingerprint store which fails")
+	{
+		mw := newTestMiddleware()
+		mw.Fingerprint = NewFingerprintBinding(FingerprintStoreFunc(func(key, fingerprint string, until time.Time) (string, error) {
+			return "", errors.New("store unavailable")
+		}))
+		assert.Equal(t, http.StatusOK, requestFromAgent(authzHandler(mw), "192.0.2.1", "app/1.0", signToken(testClaims())).Code)
+	}
+}
+
+func Test_MemoryFingerprintStore(t *testing.T) {
+	t.Logf("Given a token bound until it expires")
+	{
+		store := NewMemoryFingerprintStore()
+		bound, err := store.Bind("token", "ip=192.0.2.0/24", time.Now().Add(50*time.Millisecond))
+		assert.NoError(t, err)
+		assert.Equal(t, "ip=192.0.2.0/24", bound)
+		bound, _ = store.Bind("token", "ip=198.51.100.0/24", time.Now().Add(time.Hour))
+		assert.Equal(t, "ip=192.0.2.0/24", bound)
+
+		t.Logf("Then the fingerprint is forgotten once the token expired")
+		time.Sleep(60 * time.Millisecond)
+		bound, _ = store.Bind("token", "ip=198.51.100.0/24", time.Now().Add(time.Hour))
+		assert.Equal(t, "ip=198.51.100.0/24", bound)
+	}
+}
@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+	"strings"
+)
+
+const (
+
+	// ForwardAuthUserHeader the identity header holding the sub of the caller
+	ForwardAuthUserHeader = "X-Auth-User"
+
+	// ForwardAuthUsernameHeader the identity header holding the username of the caller
+	ForwardAuthUsernameHeader = "X-Auth-Username"
+
+	// ForwardAuthGroupsHeader the identity header holding the comma separated groups of the caller
+	ForwardAuthGroupsHeader = "X-Auth-Groups"
+
+	// ForwardAuthScopesHeader the identity header holding the space separated scopes of the caller
+	ForwardAuthScopesHeader = "X-Auth-Scopes"
+
+	// ForwardAuthClientIDHeader the identity header holding the app client id the token was issued to
+	ForwardAuthClientIDHeader = "X-Auth-Client-Id"
+)
+
+// ForwardAuthHandler returns a handler implementing the forward auth contract of reverse proxies such as
+// Traefik (forwardAuth), Caddy (forward_auth) and NGINX (auth_request): it answers 200 with the identity
+// headers of the caller when the token is valid and authorized by the policy of the tenant of the request, 401, 403
+// or 429 otherwise. Configure the proxy to copy the X-Auth-*
+// headers to the upstream request. The proxy must forward the DPoP header of the bound tokens, and their URL set
+// in the DPoP options, e.g. from the X-Forwarded-Uri header, or the client certificate, see ClientCertificate.
+func (mw *AuthMiddleware) ForwardAuthHandler() gin.HandlerFunc {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(c *gin.Context) {
+		mw.correlate(c)
+		logger := mw.requestLog(c)
+		ctx, cancel := mw.validationContext(c.Request.Context())
+		defer cancel()
+		tokenStr, err := mw.requestToken(c.Request, logger)
+		if err != nil {
+			mw.unauthorized(c, err)
+			return
+		}
+		token, err := mw.validateTokenContext(ctx, tokenStr, logger)
+		if err == nil {
+			err = mw.checkBindings(c.Request, c.ClientIP(), token, logger)
+		}
+		var tenant *Tenant
+		var policy *TenantPolicy
+		if err == nil {
+			tenant, policy, err = mw.checkTenant(c.Request, token, logger)
+		}
+		if err != nil {
+			mw.unauthorized(c, err)
+			return
+		}
+
+		principal := NewPrincipal(token.Claims.(jwtgo.MapClaims))
+		if !mw.checkQuota(c, logger, principal, tenant, policy) {
+			return
+		}
+		if policy != nil && !mw.authorizeRequirement(c, principal, policy.Required) {
+			return
+		}
+		username, _ := principal.Claims()["username"].(string)
+		clientID, _ := principal.Claims()["client_id"].(string)
+		c.Header(ForwardAuthUserHeader, principal.ID())
+		c.Header(ForwardAuthUsernameHeader, username)
+		c.Header(ForwardAuthGroupsHeader, strings.Join(principal.Groups(), ","))
+		c.Header(ForwardAuthScopesHeader, strings.Join(principal.Scopes(), " "))
+		c.Header(ForwardAuthClientIDHeader, clientID)
+		c.Status(http.StatusOK)
+	}
+}
@@ -0,0 +1,28 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_ForwardAuthHandler(t *testing.T) {
+	t.Logf("Given a forward auth endpoint")
+	{
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/auth", newTestMiddleware().ForwardAuthHandler())
+
+		response := performRequest(router, "GET", "/auth", tokenWithGroups("ops", "admins"))
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, "user-123", response.Header().Get(ForwardAuthUserHeader))
+		assert.Equal(t, "jdoe", response.Header().Get(ForwardAuthUsernameHeader))
+		assert.Equal(t, "admins,ops", response.Header().Get(ForwardAuthGroupsHeader))
+		assert.Equal(t, "test-client", response.Header().Get(ForwardAuthClientIDHeader))
+
+		response = performRequest(router, "GET", "/auth", "")
+		assert.Equal(t, http.StatusUnauthorized, response.Code)
+		assert.Empty(t, response.Header().Get(ForwardAuthUserHeader))
+	}
+}
@@ -0,0 +1,64 @@
+package jwt
+
+import "reflect"
+
+// Freeze returns a copy of the middleware whose options no longer follow the changes of mw: the options are copied,
+// their maps and slices included, so that the later changes of the fields of mw do not reach the requests validated
+// by the copy and do not race with them. The copy shares the json web keys, their refreshes and the statistics
+// with mw.
+//
+// The handlers are created from a frozen copy, MiddlewareFunc and Validator included: the options changed after
+// their creation are ignored. Use a Reloader to change the configuration at runtime.
+func (mw *AuthMiddleware) Freeze() *AuthMiddleware {
+	frozen := &AuthMiddleware{root: mw.shared()}
+	src, dst := reflect.ValueOf(mw).Elem(), reflect.ValueOf(frozen).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if src.Type().Field(i).IsExported() {
+			dst.Field(i).Set(cloneOption(src.Field(i)))
+		}
+	}
+	return frozen
+}
+
+// shared the middleware holding the json web keys and the statistics, the one a frozen copy was made of
+func (mw *AuthMiddleware) shared() *AuthMiddleware {
+	if mw.root != nil {
+		return mw.root
+	}
+	return mw
+}
+
+// cloneOption copies the maps, slices and structs of an option deeply, the other values, pointers and funcs
+// included, are copied as is
+func cloneOption(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeMapWithSize(value.Type(), value.Len())
+		for iter := value.MapRange(); iter.Next(); {
+			clone.SetMapIndex(iter.Key(), cloneOption(iter.Value()))
+		}
+		return clone
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			clone.Index(i).Set(cloneOption(value.Index(i)))
+		}
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				clone.Field(i).Set(cloneOption(value.Field(i)))
+			}
+		}
+		return clone
+	}
+	return value
+}
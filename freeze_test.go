@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func Test_Freeze(t *testing.T) {
+	t.Logf("Given the options of a middleware changed once its handler is created")
+	{
+		mw := newTestMiddleware()
+		mw.Required = RouteRequirement{Groups: []string{"admin"}}
+		mw.PublicRoutes = []string{"/health"}
+		router := authzHandler(mw)
+
+		mw.TokenLookup = "cookie:access_token"
+		mw.Required.Groups[0] = "staff"
+		mw.PublicRoutes[0] = "/orders"
+
+		admin := testClaims()
+		admin[GroupsClaim] = []interface{}{"admin"}
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(admin)).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+	}
+
+	t.Logf("Given a frozen copy of a middleware")
+	{
+		mw := newTestMiddleware()
+		mw.MiddlewareInit()
+		frozen := mw.Freeze()
+		assert.Same(t, mw, frozen.Freeze().shared())
+
+		_, err := frozen.ValidateToken(signToken(testClaims()))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), mw.Stats().Validated, "the statistics are shared")
+
+		mw.setJWK(map[string]JWKKey{})
+		assert.Equal(t, 0, frozen.KeyCount(), "the keys are shared")
+	}
+
+	t.Logf("Given the options of a middleware changed while requests are validated")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw)
+		token := signToken(testClaims())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", token).Code)
+				}
+			}()
+		}
+		for i := 0; i < 20; i++ {
+			mw.TokenLookup = "cookie:access_token"
+			mw.Required = RouteRequirement{Scopes: []string{"orders/write"}}
+		}
+		wg.Wait()
+	}
+}
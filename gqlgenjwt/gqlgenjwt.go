@@ -0,0 +1,97 @@
+// Package gqlgenjwt integrates the Cognito JWT middleware with gqlgen GraphQL servers.
+//
+// Declare the directive in the schema:
+//
+//	directive @auth(groups: [String!], scopes: [String!]) on FIELD_DEFINITION | OBJECT
+//
+// then wire it and the HTTP middleware:
+//
+//	cfg := generated.Config{Resolvers: resolvers}
+//	cfg.Directives.Auth = gqlgenjwt.Directive(mw)
+//	http.Handle("/query", gqlgenjwt.Middleware(mw)(handler.NewDefaultServer(generated.NewExecutableSchema(cfg))))
+package gqlgenjwt
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/99designs/gqlgen/graphql"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"net/http"
+	"strings"
+)
+
+const (
+
+	// Unauthenticated the error extension code of the fields requiring a token when none was presented
+	Unauthenticated = "UNAUTHENTICATED"
+
+	// Forbidden the error extension code of the fields the caller is not authorized to resolve
+	Forbidden = "FORBIDDEN"
+)
+
+// Middleware returns an HTTP middleware storing the jwt.Principal of the request in the context. Requests
+// without a token go through anonymously, the @auth directive then protecting the fields; requests with
+// an invalid token are rejected with 401.
+func Middleware(mw *jwt.AuthMiddleware) func(http.Handler) http.Handler {
+	mw.MiddlewareInit()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenStr, err := mw.ExtractToken(r.Header.Get)
+			if err == jwt.AuthHeaderEmptyError {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err == nil {
+				var token *jwtgo.Token
+				if token, err = mw.ValidateRequest(r, tokenStr); err == nil {
+					principal := jwt.NewPrincipal(token.Claims.(jwtgo.MapClaims))
+					next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+					return
+				}
+			}
+
+			status := jwt.StatusCode(err)
+			if status == http.StatusUnauthorized {
+				w.Header().Set(jwt.AuthenticateHeader, mw.Challenge(err))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(jwt.AuthError{Code: status, Message: err.Error()})
+		})
+	}
+}
+
+// Directive returns the implementation of the @auth(groups, scopes) directive. The caller must have
+// presented a valid token, be member of the groups (any or all of them as per the GroupsMatch of the
+// middleware) and have been granted all the scopes.
+func Directive(mw *jwt.AuthMiddleware) func(ctx context.Context, obj interface{}, next graphql.Resolver, groups []string, scopes []string) (interface{}, error) {
+	return func(ctx context.Context, obj interface{}, next graphql.Resolver, groups []string, scopes []string) (interface{}, error) {
+		principal, ok := PrincipalFromContext(ctx)
+		if !ok {
+			return nil, authError(Unauthenticated, "authentication required")
+		}
+		if !mw.HasGroups(principal, mw.GroupsMatch, groups...) {
+			return nil, authError(Forbidden, "requires membership of groups: "+strings.Join(groups, ", "))
+		}
+		if absent := jwt.MissingScopes(principal, scopes...); len(absent) > 0 {
+			return nil, authError(Forbidden, "requires scopes: "+strings.Join(absent, ", "))
+		}
+		return next(ctx)
+	}
+}
+
+// WithPrincipal returns a copy of the context holding the principal, e.g. for the resolver tests
+func WithPrincipal(ctx context.Context, principal jwt.Principal) context.Context {
+	return jwt.ContextWithPrincipal(ctx, principal)
+}
+
+// PrincipalFromContext returns the jwt.Principal available to the resolvers
+func PrincipalFromContext(ctx context.Context) (jwt.Principal, bool) {
+	return jwt.PrincipalFromContext(ctx)
+}
+
+func authError(code, message string) error {
+	return &gqlerror.Error{Message: message, Extensions: map[string]interface{}{"code": code}}
+}
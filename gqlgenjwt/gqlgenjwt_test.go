@@ -0,0 +1,75 @@
+package gqlgenjwt
+
+import (
+	"context"
+	"errors"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func resolver(ctx context.Context) (interface{}, error) {
+	return "resolved", nil
+}
+
+func Test_Directive(t *testing.T) {
+	t.Logf("Given a field protected by @auth(groups: [\"admins\"], scopes: [\"reports/read\"])")
+	{
+		issuer, _ := jwttest.NewIssuer()
+		directive := Directive(issuer.Middleware())
+		groups, scopes := []string{"admins"}, []string{"reports/read"}
+
+		_, err := directive(context.Background(), nil, resolver, groups, scopes)
+		var gqlErr *gqlerror.Error
+		assert.True(t, errors.As(err, &gqlErr))
+		assert.Equal(t, Unauthenticated, gqlErr.Extensions["code"])
+
+		claims := issuer.Claims("user-123")
+		claims["cognito:groups"] = []string{"admins"}
+		_, err = directive(WithPrincipal(context.Background(), jwt.NewPrincipal(claims)), nil, resolver, groups, scopes)
+		assert.True(t, errors.As(err, &gqlErr))
+		assert.Equal(t, Forbidden, gqlErr.Extensions["code"])
+
+		claims["scope"] = "reports/read"
+		res, err := directive(WithPrincipal(context.Background(), jwt.NewPrincipal(claims)), nil, resolver, groups, scopes)
+		assert.Nil(t, err)
+		assert.Equal(t, "resolved", res)
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	t.Logf("Given the HTTP middleware in front of the GraphQL handler")
+	{
+		issuer, _ := jwttest.NewIssuer()
+		var subject string
+		handler := Middleware(issuer.Middleware())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject = ""
+			if principal, ok := PrincipalFromContext(r.Context()); ok {
+				subject = principal.ID()
+			}
+		}))
+
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+		req := httptest.NewRequest("POST", "/query", nil)
+		req.Header.Set(jwt.AuthorizationHeader, token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "user-123", subject)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/query", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "", subject)
+
+		req = httptest.NewRequest("POST", "/query", nil)
+		req.Header.Set(jwt.AuthorizationHeader, "garbage")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+}
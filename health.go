@@ -0,0 +1,61 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// Health the report of the HealthHandler
+type Health struct {
+
+	// Status ok when the key set is loaded, unavailable otherwise
+	Status string `json:"status"`
+
+	// Keys the number of json web keys known to the middleware
+	Keys int `json:"keys"`
+
+	// JWKLoadedAt when the key set has been downloaded, omitted when the keys have been set by hand
+	JWKLoadedAt *time.Time `json:"jwk_loaded_at,omitempty"`
+
+	// JWKAge the age of the key set, e.g. 1h2m0s
+	JWKAge string `json:"jwk_age,omitempty"`
+
+	// LastRefreshError the error of the last refresh of the key set
+	LastRefreshError string `json:"last_refresh_error,omitempty"`
+
+	// Issuer the issuer the tokens are validated against
+	Issuer string `json:"issuer,omitempty"`
+
+	// Region the aws region of the user pool
+	Region string `json:"region"`
+
+	// UserPoolID the user pool ID
+	UserPoolID string `json:"user_pool_id"`
+}
+
+// HealthHandler returns a handler reporting the state of the key set and the issuer config, to be wired into
+// /healthz and /readyz. It answers 503 Service Unavailable until the key set is loaded.
+func (mw *AuthMiddleware) HealthHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats := mw.Stats()
+		health := Health{
+			Status:           "ok",
+			Keys:             stats.Keys,
+			LastRefreshError: stats.LastRefreshError,
+			Issuer:           mw.Iss,
+			Region:           mw.Region,
+			UserPoolID:       mw.UserPoolID,
+		}
+		if !stats.JWKLoadedAt.IsZero() {
+			health.JWKLoadedAt = &stats.JWKLoadedAt
+			health.JWKAge = stats.JWKAge.Round(time.Second).String()
+		}
+		code := http.StatusOK
+		if stats.Keys == 0 {
+			health.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, health)
+	}
+}
@@ -0,0 +1,31 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_HealthHandler(t *testing.T) {
+	t.Logf("Given a middleware exposing its health")
+	{
+		mw := newTestMiddleware()
+		mw.Iss = "https://cognito-idp.eu-west-2.amazonaws.com/eu-west-2_testpool"
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/readyz", mw.HealthHandler())
+
+		w := performRequest(router, "GET", "/readyz", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var health Health
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &health))
+		assert.Equal(t, Health{Status: "ok", Keys: 1, Issuer: mw.Iss, Region: TestRegion, UserPoolID: TestUserPoolID}, health)
+
+		mw.JWK = nil
+		w = performRequest(router, "GET", "/readyz", "")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"unavailable"`)
+	}
+}
@@ -0,0 +1,173 @@
+// Package httpjwt adapts the Cognito JWT middleware to plain net/http routers such as chi and gorilla/mux.
+// The validation is delegated to the jwt.AuthMiddleware, hence the configuration, including the public
+// routes and the route requirements table, is shared with the gin services.
+package httpjwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const tokenStringKey contextKey = iota
+
+// RoutePattern returns the route pattern matched by the router for the request, e.g.
+//
+//	chi:     func(r *http.Request) string { return chi.RouteContext(r.Context()).RoutePattern() }
+//	gorilla: func(r *http.Request) string { t, _ := mux.CurrentRoute(r).GetPathTemplate(); return t }
+//
+// With chi the pattern is only known once the request has been routed: install the adapter with
+// chi's With or inside a Route group rather than on the root router.
+type RoutePattern func(r *http.Request) string
+
+// Adapter authenticates the requests and enforces the route requirements of a jwt.AuthMiddleware
+type Adapter struct {
+	mw      *jwt.AuthMiddleware
+	pattern RoutePattern
+}
+
+// New creates an adapter for the given middleware. The route pattern is used to look up the public
+// routes and the route requirements, the request path is used when nil.
+func New(mw *jwt.AuthMiddleware, pattern RoutePattern) *Adapter {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	if pattern == nil {
+		pattern = func(r *http.Request) string { return r.URL.Path }
+	}
+	return &Adapter{mw: mw, pattern: pattern}
+}
+
+// Handler returns a middleware validating the token and storing the jwt.Principal in the request context
+func (a *Adapter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := a.pattern(r)
+		if a.mw.IsPublic(r.Method, route) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tokenStr, err := a.mw.ExtractToken(r.Header.Get)
+		if err != nil {
+			a.unauthorized(w, r, err)
+			return
+		}
+		token, err := a.mw.ValidateRequest(r, tokenStr)
+		if err != nil {
+			a.rejected(w, r, err)
+			return
+		}
+
+		principal := jwt.NewPrincipal(token.Claims.(jwtgo.MapClaims))
+		if authErr := a.mw.CheckRoute(principal, r.Method, route); authErr != nil {
+			if absent, ok := authErr.Detail.([]string); ok && authErr.Error == jwt.InsufficientScope {
+				w.Header().Set(jwt.AuthenticateHeader, a.mw.ScopeChallenge(absent))
+			}
+			a.fail(w, r, *authErr)
+			return
+		}
+
+		ctx := jwt.ContextWithPrincipal(r.Context(), principal)
+		ctx = context.WithValue(ctx, tokenStringKey, tokenStr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireGroups returns a middleware rejecting with 403 the callers which are not members of the given
+// groups, any or all of them as per the GroupsMatch of the jwt.AuthMiddleware. It must be chained after Handler.
+func (a *Adapter) RequireGroups(groups ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				a.unauthorized(w, r, jwt.AuthHeaderEmptyError)
+				return
+			}
+			if !a.mw.HasGroups(principal, a.mw.GroupsMatch, groups...) {
+				a.forbidden(w, r, &jwt.AccessError{Kind: jwt.ErrMissingGroups, Message: "requires membership of groups: " + strings.Join(groups, ", ")})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScopes returns a middleware rejecting with 403 the access tokens which were not granted all the
+// given scopes. It must be chained after Handler.
+func (a *Adapter) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				a.unauthorized(w, r, jwt.AuthHeaderEmptyError)
+				return
+			}
+			if absent := jwt.MissingScopes(principal, scopes...); len(absent) > 0 {
+				a.insufficientScope(w, r, scopes, absent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PrincipalFromContext returns the jwt.Principal stored in the context by the Handler
+func PrincipalFromContext(ctx context.Context) (jwt.Principal, bool) {
+	return jwt.PrincipalFromContext(ctx)
+}
+
+// TokenFromContext returns the raw token string stored in the context by the Handler
+func TokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenStringKey).(string)
+	return token, ok
+}
+
+func (a *Adapter) unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	authErr := jwt.AuthError{Code: http.StatusUnauthorized, Cause: err}
+	if errors.Is(err, jwt.ErrInvalidDPoPProof) {
+		authErr.Error = jwt.InvalidDPoPProof
+	}
+	w.Header().Set(jwt.AuthenticateHeader, a.mw.Challenge(err))
+	a.fail(w, r, authErr)
+}
+
+// rejected answers the failed validation with the status of the gin middleware, 401 with its challenge by default
+func (a *Adapter) rejected(w http.ResponseWriter, r *http.Request, err error) {
+	status := jwt.StatusCode(err)
+	if status == http.StatusUnauthorized {
+		a.unauthorized(w, r, err)
+		return
+	}
+	a.fail(w, r, jwt.AuthError{Code: status, Cause: err})
+}
+
+func (a *Adapter) forbidden(w http.ResponseWriter, r *http.Request, err error) {
+	a.fail(w, r, jwt.AuthError{Code: http.StatusForbidden, Cause: err})
+}
+
+func (a *Adapter) insufficientScope(w http.ResponseWriter, r *http.Request, required, absent []string) {
+	w.Header().Set(jwt.AuthenticateHeader, a.mw.ScopeChallenge(required))
+	a.fail(w, r, jwt.AuthError{
+		Code:   http.StatusForbidden,
+		Error:  jwt.InsufficientScope,
+		Detail: absent,
+		Cause:  &jwt.AccessError{Kind: jwt.ErrInsufficientScope, Message: "requires scopes: " + strings.Join(absent, ", ")},
+	})
+}
+
+// fail writes the response of the gin middleware to the failure, see jwt.AuthMiddleware.ErrorBody
+func (a *Adapter) fail(w http.ResponseWriter, r *http.Request, authErr jwt.AuthError) {
+	status, body := a.mw.ErrorBody(r, authErr)
+	contentType := "application/json; charset=utf-8"
+	if _, ok := body.(jwt.Problem); ok {
+		contentType = jwt.ProblemContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,187 @@
+package httpjwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func perform(handler http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set(jwt.AuthorizationHeader, token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func Test_HttpHandler(t *testing.T) {
+	t.Logf("Given a net/http router protected by the adapter")
+	{
+		issuer, err := jwttest.NewIssuer()
+		assert.Nil(t, err)
+		mw := issuer.Middleware()
+		mw.PublicRoutes = []string{"/health"}
+		mw.Routes = jwt.RouteTable{"DELETE /orders": {Groups: []string{"admins"}}}
+		adapter := New(mw, nil)
+
+		var subject string
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal, found := PrincipalFromContext(r.Context()); found {
+				subject = principal.ID()
+			}
+		})
+		mux := http.NewServeMux()
+		mux.Handle("/health", adapter.Handler(ok))
+		mux.Handle("/orders", adapter.Handler(ok))
+		mux.Handle("/reports", adapter.Handler(adapter.RequireScopes("reports/read")(ok)))
+		mux.Handle("/admin", adapter.Handler(adapter.RequireGroups("admins")(ok)))
+
+		claims := issuer.Claims("user-123")
+		token, _ := issuer.Sign(claims)
+		claims["cognito:groups"] = []string{"admins"}
+		claims["scope"] = "reports/read"
+		admin, _ := issuer.Sign(claims)
+
+		assert.Equal(t, http.StatusOK, perform(mux, "GET", "/health", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, perform(mux, "GET", "/orders", "").Code)
+		assert.Equal(t, http.StatusOK, perform(mux, "GET", "/orders", token).Code)
+		assert.Equal(t, "user-123", subject)
+
+		assert.Equal(t, http.StatusForbidden, perform(mux, "DELETE", "/orders", token).Code)
+		assert.Equal(t, http.StatusOK, perform(mux, "DELETE", "/orders", admin).Code)
+
+		response := perform(mux, "GET", "/reports", token)
+		assert.Equal(t, http.StatusForbidden, response.Code)
+		assert.Contains(t, response.Header().Get(jwt.AuthenticateHeader), "insufficient_scope")
+		assert.Equal(t, http.StatusOK, perform(mux, "GET", "/reports", admin).Code)
+
+		assert.Equal(t, http.StatusForbidden, perform(mux, "GET", "/admin", token).Code)
+		assert.Equal(t, http.StatusOK, perform(mux, "GET", "/admin", admin).Code)
+	}
+}
+
+func Test_HttpChallenges(t *testing.T) {
+	t.Logf("Given a realm holding quotes and a route requiring scopes")
+	{
+		issuer, err := jwttest.NewIssuer()
+		assert.Nil(t, err)
+		mw := issuer.Middleware()
+		mw.Realm = `the "orders" api`
+		mw.Routes = jwt.RouteTable{"GET /orders": {Scopes: []string{"orders/read"}}}
+		adapter := New(mw, nil)
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+		mux := http.NewServeMux()
+		mux.Handle("/orders", adapter.Handler(ok))
+		mux.Handle("/reports", adapter.Handler(adapter.RequireScopes("reports/read")(ok)))
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+
+		t.Logf("Then the challenges are the ones of the gin middleware")
+		response := perform(mux, "GET", "/orders", "")
+		assert.Equal(t, `Bearer realm="the \"orders\" api"`, response.Header().Get(jwt.AuthenticateHeader))
+
+		response = perform(mux, "GET", "/orders", token)
+		assert.Equal(t, http.StatusForbidden, response.Code)
+		assert.Equal(t, `Bearer realm="the \"orders\" api", error="insufficient_scope", error_description="the access token lacks the required scopes", scope="orders/read"`,
+			response.Header().Get(jwt.AuthenticateHeader))
+
+		response = perform(mux, "GET", "/reports", token)
+		assert.Equal(t, mw.ScopeChallenge([]string{"reports/read"}), response.Header().Get(jwt.AuthenticateHeader))
+	}
+}
+
+func Test_HttpErrorContract(t *testing.T) {
+	t.Logf("Given an adapter of a middleware in production error mode rendering the problem details")
+	{
+		issuer, err := jwttest.NewIssuer()
+		assert.Nil(t, err)
+		mw := issuer.Middleware()
+		mw.ErrorMode = jwt.ProductionErrors
+		mw.ProblemJSON = true
+		adapter := New(mw, nil)
+		handler := adapter.Handler(adapter.RequireScopes("reports/read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+
+		t.Logf("Then the failures are hidden as by the gin middleware")
+		response := perform(handler, "GET", "/reports", "not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, response.Code)
+		assert.Equal(t, jwt.ProblemContentType, response.Header().Get("Content-Type"))
+		var problem jwt.Problem
+		assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &problem))
+		assert.Equal(t, "Unauthorized", problem.Detail)
+		assert.Equal(t, "/reports", problem.Instance)
+		assert.NotEmpty(t, problem.ErrorID)
+
+		response = perform(handler, "GET", "/reports", token)
+		assert.Equal(t, http.StatusForbidden, response.Code)
+		problem = jwt.Problem{}
+		assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &problem))
+		assert.Equal(t, "Forbidden", problem.Detail)
+		assert.Equal(t, jwt.InsufficientScope, problem.Error)
+		assert.Nil(t, problem.ErrorDetail)
+	}
+
+	t.Logf("Given an adapter of a middleware mapping the insufficient scopes")
+	{
+		issuer, err := jwttest.NewIssuer()
+		assert.Nil(t, err)
+		mw := issuer.Middleware()
+		mw.Routes = jwt.RouteTable{"GET /orders": {Scopes: []string{"orders/read"}}}
+		mw.ErrorMapper = func(err error) (int, interface{}) {
+			if errors.Is(err, jwt.ErrInsufficientScope) {
+				return http.StatusForbidden, map[string]string{"error": "wrong_scope"}
+			}
+			return 0, nil
+		}
+		handler := New(mw, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+
+		response := perform(handler, "GET", "/orders", token)
+		assert.Equal(t, http.StatusForbidden, response.Code)
+		assert.JSONEq(t, `{"error":"wrong_scope"}`, response.Body.String())
+
+		response = perform(handler, "GET", "/orders", "")
+		assert.Equal(t, http.StatusUnauthorized, response.Code)
+		assert.Contains(t, response.Body.String(), `"message":"auth header empty"`)
+	}
+}
+
+func Test_HttpErrorsRedactTheTokens(t *testing.T) {
+	t.Logf("Given an adapter rejecting malformed tokens and the tokens exchanged for another one, quoting it")
+	{
+		issuer, err := jwttest.NewIssuer()
+		assert.Nil(t, err)
+		mw := issuer.Middleware()
+		mw.ClaimsValidators = []jwt.ClaimsValidator{jwt.ClaimsValidatorFunc(func(claims jwtgo.MapClaims) error {
+			if original, ok := claims["original_token"]; ok {
+				return fmt.Errorf("the token %s was exchanged", original)
+			}
+			return nil
+		})}
+		handler := New(mw, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		original, _ := issuer.Sign(issuer.Claims("user-123"))
+		claims := issuer.Claims("user-123")
+		claims["original_token"] = original
+		exchanged, _ := issuer.Sign(claims)
+		segments := strings.Split(original, ".")
+		malformed := segments[0] + "." + segments[1] + "x." + segments[2]
+
+		t.Logf("Then the responses carry no fragment of the tokens")
+		for _, presented := range []string{exchanged, malformed} {
+			response := perform(handler, "GET", "/orders", presented)
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+			for _, segment := range append(strings.Split(presented, "."), segments...) {
+				assert.NotContains(t, response.Body.String(), segment)
+			}
+		}
+	}
+}
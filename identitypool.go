@@ -0,0 +1,148 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// identityURLFormat the endpoint of the Cognito identity pools API, formatted with the region
+var identityURLFormat = "https://cognito-identity.%v.amazonaws.com/"
+
+// credentialsExpiryMargin how long before their expiry the credentials are vended again
+const credentialsExpiryMargin = time.Minute
+
+// AWSCredentials temporary AWS credentials vended by an identity pool to a user
+type AWSCredentials struct {
+	IdentityID      string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// IdentityPool exchanges the validated ID tokens of the users for temporary AWS credentials of a Cognito
+// identity pool, so that the handlers can act on AWS resources as the user. The identities are cached per issuer
+// and sub, and the credentials per identity until they are about to expire.
+type IdentityPool struct {
+	mw         *AuthMiddleware
+	poolID     string
+	mu         sync.Mutex
+	identities map[string]string
+	cache      ttlCache
+}
+
+// IdentityPool creates the IdentityPool of the given ID, e.g. "eu-west-1:0f2b...", trusting the user pools of
+// the middleware, the one of each token being the one of its issuer
+func (mw *AuthMiddleware) IdentityPool(identityPoolID string) *IdentityPool {
+	return &IdentityPool{mw: mw, poolID: identityPoolID, identities: map[string]string{}}
+}
+
+// CredentialsFor vends the credentials of the user of the request, which must carry a validated ID token
+func (p *IdentityPool) CredentialsFor(c *gin.Context) (*AWSCredentials, error) {
+	token, err := idToken(c)
+	if err != nil {
+		return nil, err
+	}
+	return p.Credentials(c.Request.Context(), token.Raw, NewPrincipal(token.Claims.(jwtgo.MapClaims)).ID())
+}
+
+// PreferredRoleCredentialsFor vends the credentials of the cognito:preferred_role of the user of the request,
+// which must carry a validated ID token. The identity pool must choose the role from the token.
+func (p *IdentityPool) PreferredRoleCredentialsFor(c *gin.Context) (*AWSCredentials, error) {
+	token, err := idToken(c)
+	if err != nil {
+		return nil, err
+	}
+	claims := NewClaims(token.Claims.(jwtgo.MapClaims))
+	if claims.PreferredRole == "" {
+		return nil, fmt.Errorf("the token carries no %s claim", PreferredRoleClaim)
+	}
+	return p.RoleCredentials(c.Request.Context(), token.Raw, claims.Subject, claims.PreferredRole)
+}
+
+// idToken the validated ID token of the request
+func idToken(c *gin.Context) (*jwtgo.Token, error) {
+	value, ok := c.Get(TokenKey)
+	if !ok {
+		return nil, ErrMissingHeader
+	}
+	token := value.(*jwtgo.Token)
+	if tokenUse, _ := token.Claims.(jwtgo.MapClaims)["token_use"].(string); tokenUse != "id" {
+		return nil, tokenError(ErrWrongTokenUse, fmt.Errorf("expecting an id token, got %q", tokenUse))
+	}
+	return token, nil
+}
+
+// Credentials vends the credentials of the user of the given sub with its validated ID token
+func (p *IdentityPool) Credentials(ctx context.Context, idToken, sub string) (*AWSCredentials, error) {
+	return p.RoleCredentials(ctx, idToken, sub, "")
+}
+
+// RoleCredentials vends the credentials of the given IAM role, one of the cognito:roles of the user, the
+// default role of the identity pool when empty
+func (p *IdentityPool) RoleCredentials(ctx context.Context, idToken, sub, roleARN string) (*AWSCredentials, error) {
+	iss, err := unverifiedIssuer(idToken)
+	if err != nil {
+		return nil, err
+	}
+	logins := map[string]string{strings.TrimPrefix(iss, "https://"): idToken}
+
+	// the subs are unique within a user pool only
+	identityKey := iss + "\x00" + sub
+	p.mu.Lock()
+	identityID, ok := p.identities[identityKey]
+	p.mu.Unlock()
+	if !ok {
+		var output struct {
+			IdentityID string `json:"IdentityId"`
+		}
+		input := map[string]interface{}{"IdentityPoolId": p.poolID, "Logins": logins}
+		if err := p.call(ctx, "GetId", input, &output); err != nil {
+			return nil, err
+		}
+		identityID = output.IdentityID
+		p.mu.Lock()
+		p.identities[identityKey] = identityID
+		p.mu.Unlock()
+	}
+	cacheKey := identityID + "\x00" + roleARN
+	if cached, ok := p.cache.get(cacheKey); ok {
+		return cached.(*AWSCredentials), nil
+	}
+
+	var output struct {
+		Credentials struct {
+			AccessKeyID  string `json:"AccessKeyId"`
+			SecretKey    string
+			SessionToken string
+			Expiration   float64
+		}
+	}
+	input := map[string]interface{}{"IdentityId": identityID, "Logins": logins}
+	if roleARN != "" {
+		input["CustomRoleArn"] = roleARN
+	}
+	if err := p.call(ctx, "GetCredentialsForIdentity", input, &output); err != nil {
+		return nil, err
+	}
+	credentials := &AWSCredentials{
+		IdentityID:      identityID,
+		AccessKeyID:     output.Credentials.AccessKeyID,
+		SecretAccessKey: output.Credentials.SecretKey,
+		SessionToken:    output.Credentials.SessionToken,
+		Expiration:      time.Unix(int64(output.Credentials.Expiration), 0),
+	}
+	p.cache.set(cacheKey, credentials, credentials.Expiration.Add(-credentialsExpiryMargin))
+	return credentials, nil
+}
+
+// call calls the given action of the identity pools API in the region of the identity pool
+func (p *IdentityPool) call(ctx context.Context, action string, input, output interface{}) error {
+	region := strings.SplitN(p.poolID, ":", 2)[0]
+	return callAWS(ctx, fmt.Sprintf(identityURLFormat, region), "AWSCognitoIdentityService."+action, input, output)
+}
@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_IdentityPoolCredentials(t *testing.T) {
+	t.Logf("Given an identity pool trusting the user pool")
+	{
+		calls := map[string]int{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/eu-west-1", r.URL.Path)
+			var input map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&input)
+			logins := input["Logins"].(map[string]interface{})
+			assert.NotEmpty(t, logins[fmt.Sprintf("cognito-idp.%v.amazonaws.com/%v", TestRegion, TestUserPoolID)])
+
+			target := r.Header.Get("X-Amz-Target")
+			calls[target]++
+			switch target {
+			case "AWSCognitoIdentityService.GetId":
+				assert.Equal(t, "eu-west-1:pool", input["IdentityPoolId"])
+				json.NewEncoder(w).Encode(map[string]string{"IdentityId": "eu-west-1:identity"})
+			case "AWSCognitoIdentityService.GetCredentialsForIdentity":
+				assert.Equal(t, "eu-west-1:identity", input["IdentityId"])
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"IdentityId": "eu-west-1:identity",
+					"Credentials": map[string]interface{}{
+						"AccessKeyId":  "ASIA123",
+						"SecretKey":    "secret",
+						"SessionToken": "session",
+						"Expiration":   time.Now().Add(time.Hour).Unix(),
+					},
+				})
+			}
+		}))
+		defer server.Close()
+		defer func(format string) { identityURLFormat = format }(identityURLFormat)
+		identityURLFormat = server.URL + "/%v"
+
+		mw := newTestMiddleware()
+		pool := mw.IdentityPool("eu-west-1:pool")
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/files", mw.MiddlewareFunc(), func(c *gin.Context) {
+			credentials, err := pool.CredentialsFor(c)
+			if err != nil {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			c.String(http.StatusOK, credentials.AccessKeyID)
+		})
+
+		idClaims := testClaims()
+		idClaims["token_use"] = "id"
+		for i := 0; i < 2; i++ {
+			w := performRequest(router, "GET", "/files", signToken(idClaims))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "ASIA123", w.Body.String())
+		}
+		assert.Equal(t, 1, calls["AWSCognitoIdentityService.GetId"])
+		assert.Equal(t, 1, calls["AWSCognitoIdentityService.GetCredentialsForIdentity"])
+
+		w := performRequest(router, "GET", "/files", signToken(testClaims()))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func Test_IdentityPoolPreferredRoleCredentials(t *testing.T) {
+	t.Logf("Given an identity pool choosing the role from the token")
+	{
+		roles := map[string]int{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var input map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&input)
+			switch r.Header.Get("X-Amz-Target") {
+			case "AWSCognitoIdentityService.GetId":
+				json.NewEncoder(w).Encode(map[string]string{"IdentityId": "eu-west-1:identity"})
+			case "AWSCognitoIdentityService.GetCredentialsForIdentity":
+				role, _ := input["CustomRoleArn"].(string)
+				roles[role]++
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"IdentityId": "eu-west-1:identity",
+					"Credentials": map[string]interface{}{
+						"AccessKeyId":  "ASIA-" + role,
+						"SecretKey":    "secret",
+						"SessionToken": "session",
+						"Expiration":   time.Now().Add(time.Hour).Unix(),
+					},
+				})
+			}
+		}))
+		defer server.Close()
+		defer func(format string) { identityURLFormat = format }(identityURLFormat)
+		identityURLFormat = server.URL + "/%v"
+
+		mw := newTestMiddleware()
+		pool := mw.IdentityPool("eu-west-1:pool")
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/files", mw.MiddlewareFunc(), func(c *gin.Context) {
+			credentials, err := pool.PreferredRoleCredentialsFor(c)
+			if err != nil {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			c.String(http.StatusOK, credentials.AccessKeyID)
+		})
+
+		idClaims := testClaims()
+		idClaims["token_use"] = "id"
+		idClaims[RolesClaim] = []interface{}{"arn:aws:iam::123:role/readers", "arn:aws:iam::123:role/writers"}
+		idClaims[PreferredRoleClaim] = "arn:aws:iam::123:role/writers"
+		for i := 0; i < 2; i++ {
+			w := performRequest(router, "GET", "/files", signToken(idClaims))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "ASIA-arn:aws:iam::123:role/writers", w.Body.String())
+		}
+		assert.Equal(t, map[string]int{"arn:aws:iam::123:role/writers": 1}, roles)
+
+		t.Logf("When the user belongs to no group mapped to a role")
+		delete(idClaims, PreferredRoleClaim)
+		w := performRequest(router, "GET", "/files", signToken(idClaims))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), PreferredRoleClaim)
+	}
+}
+
+func Test_IdentityPoolUserPools(t *testing.T) {
+	t.Logf("Given an identity pool trusting two user pools")
+	{
+		var logins []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var input map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&input)
+			switch r.Header.Get("X-Amz-Target") {
+			case "AWSCognitoIdentityService.GetId":
+				for login := range input["Logins"].(map[string]interface{}) {
+					logins = append(logins, login)
+				}
+				json.NewEncoder(w).Encode(map[string]string{"IdentityId": "eu-west-1:" + logins[len(logins)-1]})
+			case "AWSCognitoIdentityService.GetCredentialsForIdentity":
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"IdentityId": input["IdentityId"],
+					"Credentials": map[string]interface{}{
+						"AccessKeyId":  input["IdentityId"],
+						"SecretKey":    "secret",
+						"SessionToken": "session",
+						"Expiration":   time.Now().Add(time.Hour).Unix(),
+					},
+				})
+			}
+		}))
+		defer server.Close()
+		defer func(format string) { identityURLFormat = format }(identityURLFormat)
+		identityURLFormat = server.URL + "/%v"
+
+		mw := &AuthMiddleware{UserPools: []*AuthMiddleware{newTestMiddleware(), otherPool()}}
+		pool := mw.IdentityPool("eu-west-1:pool")
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/files", mw.MiddlewareFunc(), func(c *gin.Context) {
+			credentials, err := pool.CredentialsFor(c)
+			if err != nil {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			c.String(http.StatusOK, credentials.AccessKeyID)
+		})
+
+		idClaims := func() jwtgo.MapClaims {
+			claims := testClaims()
+			claims["token_use"] = "id"
+			return claims
+		}
+		other := fmt.Sprintf("cognito-idp.%v.amazonaws.com/%v", TestRegion, otherUserPoolID)
+		w := performRequest(router, "GET", "/files", otherPoolToken(idClaims()))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "eu-west-1:"+other, w.Body.String())
+
+		t.Logf("When a user of the other pool has the same sub")
+		w = performRequest(router, "GET", "/files", signToken(idClaims()))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "eu-west-1:"+fmt.Sprintf("cognito-idp.%v.amazonaws.com/%v", TestRegion, TestUserPoolID), w.Body.String(),
+			"the identity of the user of the other pool is not reused")
+		assert.Equal(t, []string{other, fmt.Sprintf("cognito-idp.%v.amazonaws.com/%v", TestRegion, TestUserPoolID)}, logins)
+	}
+}
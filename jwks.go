@@ -0,0 +1,170 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"time"
+)
+
+// jwkURLFormat the url of the json web key set of a user pool, formatted with the region and user pool ID
+var jwkURLFormat = "https://cognito-idp.%v.amazonaws.com/%v/.well-known/jwks.json"
+
+// jwkURL the url of the json web key set, the JWKURL if set
+func (mw *AuthMiddleware) jwkURL() string {
+	if mw.JWKURL != "" {
+		return mw.JWKURL
+	}
+	return fmt.Sprintf(jwkURLFormat, mw.Region, mw.UserPoolID)
+}
+
+// RefreshJWK downloads the json web key set of the user pool again, e.g. after a key rotation. The keys
+// in use are kept when the download fails. The concurrent refreshes share a single download and its result.
+func (mw *AuthMiddleware) RefreshJWK() error {
+	return mw.RefreshJWKContext(context.Background())
+}
+
+// RefreshJWKContext is RefreshJWK giving up once ctx is done. The download shared with the concurrent refreshes is
+// not cancelled with ctx, it completes within the timeout of the http client.
+func (mw *AuthMiddleware) RefreshJWKContext(ctx context.Context) error {
+	mw = mw.shared()
+	result := mw.refreshes.DoChan(mw.jwkURL(), func() (interface{}, error) {
+		return nil, mw.refreshJWK(context.WithoutCancel(ctx))
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case refreshed := <-result:
+		return refreshed.Err
+	}
+}
+
+// refreshJWK downloads the json web key set of the user pool
+func (mw *AuthMiddleware) refreshJWK(ctx context.Context) error {
+	jwk, err := getJWKContext(ctx, mw.log(), mw.jwkURL())
+	if err != nil {
+		mw.log().Error("Failed to refresh the jwk", "url", mw.jwkURL(), "error", err)
+		mw.reportError(err, map[string]string{"operation": "jwk_refresh", "url": mw.jwkURL()})
+	}
+
+	if err != nil {
+		mw.keysMu.Lock()
+		mw.lastRefreshErr = err
+		mw.keysMu.Unlock()
+		return err
+	}
+	mw.setJWK(jwk)
+	return nil
+}
+
+// refreshUnknownKey refreshes the json web key set of the user pool of a token naming an unknown kid when
+// RefreshOnUnknownKey is set, at most once per UnknownKeyRefreshInterval. It reports whether the keys have been
+// refreshed.
+func (mw *AuthMiddleware) refreshUnknownKey(ctx context.Context, tokenStr string, token *jwtgo.Token, logger Logger) bool {
+	if !mw.RefreshOnUnknownKey || token == nil {
+		return false
+	}
+	kid, _ := token.Header["kid"].(string)
+	pool, err := mw.resolvePool(tokenStr)
+	if kid == "" || err != nil || pool.Keys != nil {
+		return false
+	}
+	shared := pool.shared()
+	shared.keysMu.Lock()
+	if !shared.unknownKeyAt.IsZero() && time.Since(shared.unknownKeyAt) < mw.UnknownKeyRefreshInterval {
+		shared.keysMu.Unlock()
+		return false
+	}
+	shared.unknownKeyAt = time.Now()
+	shared.unknownKeyCount++
+	shared.keysMu.Unlock()
+
+	logger.Info("Refreshing the jwk on an unknown kid", "kid", kid)
+	if err := pool.RefreshJWKContext(ctx); err != nil {
+		logger.Warn("Failed to refresh the jwk on an unknown kid", "kid", kid, "error", err)
+		return false
+	}
+	return true
+}
+
+// setJWK replaces the json web key set, precomputing the verification keys
+func (mw *AuthMiddleware) setJWK(jwk map[string]JWKKey) {
+	mw = mw.shared()
+	mw.keysMu.Lock()
+	mw.JWK = jwk
+	mw.verificationKeys = nil
+	mw.jwkLoadedAt = time.Now()
+	mw.lastRefreshErr = nil
+	mw.keysMu.Unlock()
+	mw.precomputeKeys()
+}
+
+// verificationKey the verification state of a json web key, computed once per key set
+type verificationKey struct {
+
+	// method the signing method of the key, any RSA method when the key does not name its alg
+	method jwtgo.SigningMethod
+
+	publicKey *rsa.PublicKey
+
+	// err why the key could not be converted, failing the validation of its tokens
+	err error
+}
+
+// verificationKey returns the verification key of the given kid, computing it on first use when the keys have
+// been set by hand
+func (mw *AuthMiddleware) verificationKey(kid string) (*verificationKey, bool) {
+	mw = mw.shared()
+	mw.keysMu.RLock()
+	key, ok := mw.verificationKeys[kid]
+	mw.keysMu.RUnlock()
+	if ok {
+		return key, true
+	}
+
+	mw.keysMu.Lock()
+	defer mw.keysMu.Unlock()
+	if key, ok := mw.verificationKeys[kid]; ok {
+		return key, true
+	}
+	jwk, ok := mw.JWK[kid]
+	if !ok {
+		return nil, false
+	}
+	publicKey, err := convertKey(jwk.E, jwk.N)
+	if err != nil {
+		err = tokenError(ErrMalformedKey, fmt.Errorf("the json web key %s is malformed: %w", kid, err))
+	}
+	key = &verificationKey{method: jwtgo.GetSigningMethod(jwk.Alg), publicKey: publicKey, err: err}
+	if mw.verificationKeys == nil {
+		mw.verificationKeys = make(map[string]*verificationKey, len(mw.JWK))
+	}
+	mw.verificationKeys[kid] = key
+	return key, true
+}
+
+// precomputeKeys computes the verification keys of the key set once loaded. The malformed keys are logged and left
+// to fail the validation of their tokens.
+func (mw *AuthMiddleware) precomputeKeys() {
+	mw = mw.shared()
+	mw.keysMu.RLock()
+	kids := make([]string, 0, len(mw.JWK))
+	for kid := range mw.JWK {
+		kids = append(kids, kid)
+	}
+	mw.keysMu.RUnlock()
+	for _, kid := range kids {
+		if key, _ := mw.verificationKey(kid); key.err != nil {
+			mw.log().Warn("Ignoring the malformed jwk", "kid", kid, "error", key.err)
+		}
+	}
+}
+
+// KeyCount the number of json web keys known to the middleware
+func (mw *AuthMiddleware) KeyCount() int {
+	mw = mw.shared()
+	mw.keysMu.RLock()
+	defer mw.keysMu.RUnlock()
+	return len(mw.JWK)
+}
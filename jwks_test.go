@@ -0,0 +1,159 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_VerificationKeysArePrecomputed(t *testing.T) {
+	t.Logf("Given a json web key set downloaded from the user pool")
+	{
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []JWKKey{}
+			for _, key := range testJWK() {
+				keys = append(keys, key)
+			}
+			json.NewEncoder(w).Encode(JWK{Keys: keys})
+		}))
+		defer server.Close()
+		defer func(format string) { jwkURLFormat = format }(jwkURLFormat)
+		jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+
+		mw := &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID}
+		assert.NoError(t, mw.RefreshJWK())
+		assert.Len(t, mw.verificationKeys, 1)
+		key := mw.verificationKeys[TestKid]
+		assert.Equal(t, testKey.PublicKey.N, key.publicKey.N)
+		assert.Equal(t, jwtgo.SigningMethodRS256, key.method)
+
+		_, err := mw.ValidateToken(signToken(testClaims()))
+		assert.NoError(t, err)
+		assert.True(t, key == mw.verificationKeys[TestKid])
+	}
+}
+
+func Test_VerificationKeysOfHandSetKeys(t *testing.T) {
+	t.Logf("Given json web keys set by hand")
+	{
+		mw := newTestMiddleware()
+		assert.Nil(t, mw.verificationKeys)
+		_, err := mw.ValidateToken(signToken(testClaims()))
+		assert.NoError(t, err)
+		assert.Len(t, mw.verificationKeys, 1)
+
+		t.Logf("Then the tokens signed with another algorithm than the one of their key are rejected")
+		token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS512, testClaims())
+		token.Header["kid"] = TestKid
+		tokenStr, _ := token.SignedString(testKey)
+		_, err = mw.ValidateToken(tokenStr)
+		assert.True(t, errors.Is(err, ErrUnexpectedSigningMethod))
+
+		token = jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+		token.Header["kid"] = "rotated-kid"
+		tokenStr, _ = token.SignedString(testKey)
+		_, err = mw.ValidateToken(tokenStr)
+		assert.True(t, errors.Is(err, ErrUnknownKeyID))
+	}
+}
+
+func Test_MalformedVerificationKeys(t *testing.T) {
+	t.Logf("Given json web key sets holding a corrupted key next to a valid one")
+	{
+		corruptions := map[string]func(key *JWKKey){
+			"modulus not base64":  func(key *JWKKey) { key.N = "not base64!" },
+			"empty modulus":       func(key *JWKKey) { key.N = "" },
+			"exponent not base64": func(key *JWKKey) { key.E = "AQ*B" },
+			"empty exponent":      func(key *JWKKey) { key.E = "" },
+			"exponent too long":   func(key *JWKKey) { key.E = "AQIDBAUG" },
+			"even exponent":       func(key *JWKKey) { key.E = "AAI" },
+		}
+		for name, corrupt := range corruptions {
+			t.Logf("When the key is corrupted with: %s", name)
+			mw := newTestMiddleware()
+			key := mw.JWK[TestKid]
+			key.Kid = "corrupted-kid"
+			corrupt(&key)
+			mw.JWK[key.Kid] = key
+
+			assert.NotPanics(t, mw.precomputeKeys)
+
+			t.Logf("Then the tokens of the corrupted key are rejected")
+			token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+			token.Header["kid"] = key.Kid
+			tokenStr, _ := token.SignedString(testKey)
+			_, err := mw.ValidateToken(tokenStr)
+			assert.True(t, errors.Is(err, ErrMalformedKey), name)
+
+			w := performRequest(authzHandler(mw), "GET", "/orders", tokenStr)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+			assert.Equal(t, `Bearer realm="gin jwt", error="invalid_token", error_description="malformed_key"`, w.Header().Get(AuthenticateHeader), name)
+
+			t.Logf("And the tokens of the valid key are still accepted")
+			_, err = mw.ValidateToken(signToken(testClaims()))
+			assert.NoError(t, err, name)
+		}
+	}
+}
+
+func Test_TokenWithoutKid(t *testing.T) {
+	t.Logf("Given a token whose header has no kid")
+	{
+		mw := newTestMiddleware()
+		token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+		tokenStr, _ := token.SignedString(testKey)
+
+		_, err := mw.ValidateToken(tokenStr)
+		assert.True(t, errors.Is(err, ErrUnknownKeyID))
+		assert.Equal(t, "unknown_kid", failureReason(err))
+		assert.Equal(t, uint64(1), mw.Stats().Failures["unknown_kid"])
+	}
+}
+
+func Test_RefreshOnUnknownKey(t *testing.T) {
+	t.Logf("Given a middleware whose keys predate the rotation of the signing key")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+		stale := func() map[string]JWKKey {
+			key := testJWK()[TestKid]
+			key.Kid = "retired-kid"
+			return map[string]JWKKey{key.Kid: key}
+		}
+
+		mw := &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID, JWK: stale(), RefreshOnUnknownKey: true}
+		mw.MiddlewareInit()
+
+		t.Logf("Then the key set is refreshed and the token signed with the new key is accepted")
+		_, err := mw.ValidateTokenContext(context.Background(), signToken(testClaims()))
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+		assert.Equal(t, uint64(1), mw.Stats().UnknownKeyRefreshes)
+		assert.Equal(t, uint64(1), mw.Stats().Validated)
+		assert.Empty(t, mw.Stats().Failures)
+
+		t.Logf("And the following unknown kids are rejected without a download until the interval has elapsed")
+		token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+		token.Header["kid"] = "forged-kid"
+		tokenStr, _ := token.SignedString(testKey)
+		for i := 0; i < 3; i++ {
+			_, err = mw.ValidateToken(tokenStr)
+			assert.True(t, errors.Is(err, ErrUnknownKeyID))
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+		assert.Equal(t, uint64(3), mw.Stats().Failures["unknown_kid"])
+
+		t.Logf("And the key set is not refreshed when RefreshOnUnknownKey is not set")
+		mw = &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID, JWK: stale()}
+		mw.MiddlewareInit()
+		_, err = mw.ValidateToken(signToken(testClaims()))
+		assert.True(t, errors.Is(err, ErrUnknownKeyID))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+	}
+}
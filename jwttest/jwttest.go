@@ -0,0 +1,84 @@
+// Package jwttest issues Cognito like tokens signed with a throw away RSA key, so the services using the
+// middleware, whatever their web framework, can test their authenticated routes.
+package jwttest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	jwtgo "github.com/golang-jwt/jwt"
+	"math/big"
+	"time"
+)
+
+const (
+
+	// Region the region of the test user pool
+	Region = "eu-west-2"
+
+	// UserPoolID the id of the test user pool
+	UserPoolID = "eu-west-2_jwttest"
+
+	// Kid the key id of the signing key
+	Kid = "jwttest"
+)
+
+// Issuer issues tokens for the test user pool
+type Issuer struct {
+	key *rsa.PrivateKey
+}
+
+// NewIssuer creates an issuer with a freshly generated signing key
+func NewIssuer() (*Issuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{key: key}, nil
+}
+
+// Iss the issuer claim of the test user pool
+func (i *Issuer) Iss() string {
+	return fmt.Sprintf("https://cognito-idp.%v.amazonaws.com/%v", Region, UserPoolID)
+}
+
+// JWK the public json web key of the issuer
+func (i *Issuer) JWK() map[string]jwt.JWKKey {
+	return map[string]jwt.JWKKey{
+		Kid: {
+			Alg: "RS256",
+			Kid: Kid,
+			Kty: "RSA",
+			Use: "sig",
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+			N:   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+		},
+	}
+}
+
+// Middleware creates a middleware trusting the tokens of the issuer
+func (i *Issuer) Middleware() *jwt.AuthMiddleware {
+	return &jwt.AuthMiddleware{Iss: i.Iss(), Region: Region, UserPoolID: UserPoolID, JWK: i.JWK()}
+}
+
+// Claims valid access token claims for the given subject, valid for an hour
+func (i *Issuer) Claims(sub string) jwtgo.MapClaims {
+	return jwtgo.MapClaims{
+		"sub":       sub,
+		"iss":       i.Iss(),
+		"token_use": "access",
+		"client_id": "jwttest",
+		"username":  sub,
+		"iat":       time.Now().Unix(),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// Sign signs the given claims
+func (i *Issuer) Sign(claims jwtgo.MapClaims) (string, error) {
+	token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims)
+	token.Header["kid"] = Kid
+	return token.SignedString(i.key)
+}
@@ -0,0 +1,67 @@
+package lambdajwt
+
+import (
+	"context"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/aws/aws-lambda-go/events"
+	jwtgo "github.com/golang-jwt/jwt"
+	"strings"
+)
+
+// AppSyncMaxTTL the longest ttlOverride, in seconds, accepted by AppSync
+const AppSyncMaxTTL = 3600
+
+// DeniedFieldsFunc returns the fields the principal is not allowed to resolve, as type.field names
+// (e.g. "Mutation.deleteOrder") or full ARNs
+type DeniedFieldsFunc func(principal jwt.Principal, req events.AppSyncLambdaAuthorizerRequestContext) []string
+
+// AppSyncAuthorizer returns an AppSync Lambda authorizer. Invalid tokens are answered isAuthorized false,
+// valid ones get a resolver context carrying the sub, groups, scopes, username and client_id of the caller
+// and the fields returned by deniedFields (which may be nil).
+func AppSyncAuthorizer(mw *jwt.AuthMiddleware, deniedFields DeniedFieldsFunc) func(context.Context, events.AppSyncLambdaAuthorizerRequest) (events.AppSyncLambdaAuthorizerResponse, error) {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(ctx context.Context, req events.AppSyncLambdaAuthorizerRequest) (events.AppSyncLambdaAuthorizerResponse, error) {
+		tokenStr := req.AuthorizationToken
+		if len(tokenStr) > 7 && strings.EqualFold(tokenStr[:7], "Bearer ") {
+			tokenStr = tokenStr[7:]
+		}
+		token, err := mw.ValidateToken(tokenStr)
+		if err != nil {
+			return AppSyncResponse(nil, nil, 0), nil
+		}
+
+		principal := jwt.NewPrincipal(token.Claims.(jwtgo.MapClaims))
+		var denied []string
+		if deniedFields != nil {
+			denied = deniedFields(principal, req.RequestContext)
+		}
+		ttl := 0
+		if exp, ok := jwt.ExpiresAt(principal.Claims()); ok {
+			ttl = int(exp.Sub(mw.TimeFunc()).Seconds())
+		}
+		return AppSyncResponse(principal, denied, ttl), nil
+	}
+}
+
+// AppSyncResponse formats the outcome of a validation as an AppSync Lambda authorizer response: a nil
+// principal is not authorized. The ttl, in seconds, is capped to AppSyncMaxTTL so that AppSync never
+// caches the decision beyond the expiry of the token; zero or less disables the caching of the decision.
+func AppSyncResponse(principal jwt.Principal, deniedFields []string, ttl int) events.AppSyncLambdaAuthorizerResponse {
+	if principal == nil {
+		return events.AppSyncLambdaAuthorizerResponse{IsAuthorized: false}
+	}
+	resp := events.AppSyncLambdaAuthorizerResponse{
+		IsAuthorized:    true,
+		ResolverContext: authorizerContext(principal),
+		DeniedFields:    deniedFields,
+	}
+	switch {
+	case ttl > AppSyncMaxTTL:
+		ttl = AppSyncMaxTTL
+	case ttl < 0:
+		ttl = 0
+	}
+	resp.TTLOverride = &ttl
+	return resp
+}
@@ -0,0 +1,54 @@
+package lambdajwt
+
+import (
+	"context"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_AppSyncAuthorizer(t *testing.T) {
+	t.Logf("Given an AppSync authorizer denying the deleteOrder mutation to non admins")
+	{
+		issuer := newIssuer(t)
+		mw := issuer.Middleware()
+		authorizer := AppSyncAuthorizer(mw, func(principal jwt.Principal, req events.AppSyncLambdaAuthorizerRequestContext) []string {
+			if mw.HasGroups(principal, jwt.MatchAny, "admins") {
+				return nil
+			}
+			return []string{"Mutation.deleteOrder"}
+		})
+
+		claims := issuer.Claims("user-123")
+		claims["exp"] = time.Now().Add(10 * time.Minute).Unix()
+		token, _ := issuer.Sign(claims)
+		resp, err := authorizer(context.Background(), events.AppSyncLambdaAuthorizerRequest{AuthorizationToken: "Bearer " + token})
+		assert.Nil(t, err)
+		assert.True(t, resp.IsAuthorized)
+		assert.Equal(t, "user-123", resp.ResolverContext["sub"])
+		assert.Equal(t, []string{"Mutation.deleteOrder"}, resp.DeniedFields)
+		assert.InDelta(t, 600, *resp.TTLOverride, 5)
+
+		resp, err = authorizer(context.Background(), events.AppSyncLambdaAuthorizerRequest{AuthorizationToken: "invalid"})
+		assert.Nil(t, err)
+		assert.False(t, resp.IsAuthorized)
+		assert.Nil(t, resp.ResolverContext)
+	}
+}
+
+func Test_AppSyncResponseCapsTTL(t *testing.T) {
+	t.Logf("Given a token valid for longer than AppSync caches decisions")
+	{
+		resp := AppSyncResponse(jwt.NewPrincipal(map[string]interface{}{"sub": "user-123"}), nil, 7200)
+		assert.Equal(t, AppSyncMaxTTL, *resp.TTLOverride)
+	}
+
+	t.Logf("Given a token without remaining validity")
+	{
+		t.Logf("Then AppSync does not cache the decision")
+		assert.Equal(t, 0, *AppSyncResponse(jwt.NewPrincipal(map[string]interface{}{"sub": "user-123"}), nil, 0).TTLOverride)
+		assert.Equal(t, 0, *AppSyncResponse(jwt.NewPrincipal(map[string]interface{}{"sub": "user-123"}), nil, -30).TTLOverride)
+	}
+}
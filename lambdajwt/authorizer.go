@@ -0,0 +1,79 @@
+package lambdajwt
+
+import (
+	"context"
+	"errors"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/aws/aws-lambda-go/events"
+	"strings"
+)
+
+// ErrUnauthorized the error API Gateway expects from an authorizer to answer 401 Unauthorized
+var ErrUnauthorized = errors.New("Unauthorized")
+
+// RequestAuthorizer returns a REST API REQUEST authorizer: requests with an invalid token are answered
+// 401, requests not satisfying the route table get a Deny policy (403) and the others an Allow policy.
+// The authorizer context carries the sub, groups, scopes, username and client_id of the caller, available
+// to the integration as $context.authorizer.<key>.
+func RequestAuthorizer(mw *jwt.AuthMiddleware) func(context.Context, events.APIGatewayCustomAuthorizerRequestTypeRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(ctx context.Context, req events.APIGatewayCustomAuthorizerRequestTypeRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+		header := headerLookup(req.Headers)
+		if len(req.Headers) == 0 {
+			header = multiValueHeaderLookup(req.MultiValueHeaders)
+		}
+		principal, _, err := validate(mw, header)
+		if err != nil {
+			return events.APIGatewayCustomAuthorizerResponse{}, ErrUnauthorized
+		}
+
+		effect := "Allow"
+		if mw.CheckRoute(principal, req.HTTPMethod, req.Resource) != nil {
+			effect = "Deny"
+		}
+		return events.APIGatewayCustomAuthorizerResponse{
+			PrincipalID: principal.ID(),
+			PolicyDocument: events.APIGatewayCustomAuthorizerPolicy{
+				Version: "2012-10-17",
+				Statement: []events.IAMPolicyStatement{
+					{Action: []string{"execute-api:Invoke"}, Effect: effect, Resource: []string{req.MethodArn}},
+				},
+			},
+			Context: authorizerContext(principal),
+		}, nil
+	}
+}
+
+// SimpleAuthorizer returns an HTTP API authorizer using the simple response format. The authorizer
+// context carries the sub, groups, scopes, username and client_id of the caller.
+func SimpleAuthorizer(mw *jwt.AuthMiddleware) func(context.Context, events.APIGatewayV2CustomAuthorizerV2Request) (events.APIGatewayV2CustomAuthorizerSimpleResponse, error) {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(ctx context.Context, req events.APIGatewayV2CustomAuthorizerV2Request) (events.APIGatewayV2CustomAuthorizerSimpleResponse, error) {
+		principal, _, err := validate(mw, headerLookup(req.Headers))
+		if err != nil {
+			return events.APIGatewayV2CustomAuthorizerSimpleResponse{IsAuthorized: false}, nil
+		}
+
+		method, route := splitRouteKey(req.RouteKey)
+		return events.APIGatewayV2CustomAuthorizerSimpleResponse{
+			IsAuthorized: mw.CheckRoute(principal, method, route) == nil,
+			Context:      authorizerContext(principal),
+		}, nil
+	}
+}
+
+// authorizerContext the values handed over to the integration, API Gateway only accepts flat values
+func authorizerContext(principal jwt.Principal) map[string]interface{} {
+	claims := principal.Claims()
+	username, _ := claims["username"].(string)
+	clientID, _ := claims["client_id"].(string)
+	return map[string]interface{}{
+		"sub":       principal.ID(),
+		"groups":    strings.Join(principal.Groups(), ","),
+		"scopes":    strings.Join(principal.Scopes(), " "),
+		"username":  username,
+		"client_id": clientID,
+	}
+}
@@ -0,0 +1,58 @@
+package lambdajwt
+
+import (
+	"context"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_RequestAuthorizer(t *testing.T) {
+	t.Logf("Given a REQUEST authorizer built from the middleware config")
+	{
+		issuer := newIssuer(t)
+		mw := issuer.Middleware()
+		mw.Routes = jwt.RouteTable{"DELETE /orders/{id}": {Groups: []string{"admins"}}}
+		authorizer := RequestAuthorizer(mw)
+
+		claims := issuer.Claims("user-123")
+		claims["cognito:groups"] = []string{"ops"}
+		token, _ := issuer.Sign(claims)
+		headers := map[string]string{jwt.AuthorizationHeader: token}
+
+		resp, err := authorizer(context.Background(), events.APIGatewayCustomAuthorizerRequestTypeRequest{
+			MethodArn: "arn:aws:execute-api:eu-west-2:1:api/prod/GET/orders/1", HTTPMethod: "GET", Resource: "/orders/{id}", Headers: headers})
+		assert.Nil(t, err)
+		assert.Equal(t, "user-123", resp.PrincipalID)
+		assert.Equal(t, "Allow", resp.PolicyDocument.Statement[0].Effect)
+		assert.Equal(t, "ops", resp.Context["groups"])
+
+		resp, err = authorizer(context.Background(), events.APIGatewayCustomAuthorizerRequestTypeRequest{
+			HTTPMethod: "DELETE", Resource: "/orders/{id}", Headers: headers})
+		assert.Nil(t, err)
+		assert.Equal(t, "Deny", resp.PolicyDocument.Statement[0].Effect)
+
+		_, err = authorizer(context.Background(), events.APIGatewayCustomAuthorizerRequestTypeRequest{HTTPMethod: "GET", Resource: "/orders/{id}"})
+		assert.Equal(t, ErrUnauthorized, err)
+	}
+}
+
+func Test_SimpleAuthorizer(t *testing.T) {
+	t.Logf("Given an HTTP API simple authorizer built from the middleware config")
+	{
+		issuer := newIssuer(t)
+		authorizer := SimpleAuthorizer(issuer.Middleware())
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+
+		resp, err := authorizer(context.Background(), events.APIGatewayV2CustomAuthorizerV2Request{
+			RouteKey: "GET /orders", Headers: map[string]string{"authentication": token}})
+		assert.Nil(t, err)
+		assert.True(t, resp.IsAuthorized)
+		assert.Equal(t, "user-123", resp.Context["sub"])
+
+		resp, err = authorizer(context.Background(), events.APIGatewayV2CustomAuthorizerV2Request{RouteKey: "GET /orders"})
+		assert.Nil(t, err)
+		assert.False(t, resp.IsAuthorized)
+	}
+}
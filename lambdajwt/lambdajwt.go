@@ -0,0 +1,147 @@
+// Package lambdajwt validates the Cognito tokens of API Gateway events handled by AWS Lambda functions,
+// using the configuration of a jwt.AuthMiddleware so that Lambda functions and gin services behave
+// identically. Route patterns are the API Gateway resources, e.g. "GET /orders/{id}".
+package lambdajwt
+
+import (
+	"context"
+	"encoding/json"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/aws/aws-lambda-go/events"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const tokenStringKey contextKey = iota
+
+// ProxyHandler handles API Gateway REST API (payload v1) proxy events
+type ProxyHandler func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// HTTPHandler handles API Gateway HTTP API (payload v2) events
+type HTTPHandler func(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error)
+
+// WrapProxy returns a handler validating the token of the event before invoking next with the
+// jwt.Principal stored in the context
+func WrapProxy(mw *jwt.AuthMiddleware, next ProxyHandler) ProxyHandler {
+	mw.MiddlewareInit()
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		header := headerLookup(req.Headers)
+		if len(req.MultiValueHeaders) > 0 && len(req.Headers) == 0 {
+			header = multiValueHeaderLookup(req.MultiValueHeaders)
+		}
+		ctx, authErr, err := authenticate(ctx, mw, req.HTTPMethod, req.Resource, header)
+		if authErr != nil {
+			status, headers, body := errorResponse(mw, authErr, err)
+			return events.APIGatewayProxyResponse{StatusCode: status, Headers: headers, Body: body}, nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapHTTP returns a handler validating the token of the event before invoking next with the
+// jwt.Principal stored in the context
+func WrapHTTP(mw *jwt.AuthMiddleware, next HTTPHandler) HTTPHandler {
+	mw.MiddlewareInit()
+	return func(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		method, route := splitRouteKey(req.RouteKey)
+		if method == "" {
+			method, route = req.RequestContext.HTTP.Method, req.RawPath
+		}
+		ctx, authErr, err := authenticate(ctx, mw, method, route, headerLookup(req.Headers))
+		if authErr != nil {
+			status, headers, body := errorResponse(mw, authErr, err)
+			return events.APIGatewayV2HTTPResponse{StatusCode: status, Headers: headers, Body: body}, nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// PrincipalFromContext returns the jwt.Principal stored in the context by the wrappers
+func PrincipalFromContext(ctx context.Context) (jwt.Principal, bool) {
+	return jwt.PrincipalFromContext(ctx)
+}
+
+// TokenFromContext returns the raw token string stored in the context by the wrappers
+func TokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenStringKey).(string)
+	return token, ok
+}
+
+// authenticate validates the token and enforces the route table, returning the context holding the principal, or
+// the failure along with the validation error of the token, if any
+func authenticate(ctx context.Context, mw *jwt.AuthMiddleware, method, route string, header func(string) string) (context.Context, *jwt.AuthError, error) {
+	if mw.IsPublic(method, route) {
+		return ctx, nil, nil
+	}
+
+	principal, tokenStr, err := validate(mw, header)
+	if err != nil {
+		return ctx, &jwt.AuthError{Code: jwt.StatusCode(err), Message: err.Error()}, err
+	}
+	if authErr := mw.CheckRoute(principal, method, route); authErr != nil {
+		return ctx, authErr, nil
+	}
+
+	ctx = jwt.ContextWithPrincipal(ctx, principal)
+	return context.WithValue(ctx, tokenStringKey, tokenStr), nil, nil
+}
+
+// validate extracts and validates the token of the event
+func validate(mw *jwt.AuthMiddleware, header func(string) string) (jwt.Principal, string, error) {
+	tokenStr, err := mw.ExtractToken(header)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := mw.ValidateToken(tokenStr)
+	if err != nil {
+		return nil, "", err
+	}
+	return jwt.NewPrincipal(token.Claims.(jwtgo.MapClaims)), tokenStr, nil
+}
+
+func errorResponse(mw *jwt.AuthMiddleware, authErr *jwt.AuthError, err error) (int, map[string]string, string) {
+	headers := map[string]string{"Content-Type": "application/json"}
+	if authErr.Code == http.StatusUnauthorized {
+		headers[jwt.AuthenticateHeader] = mw.Challenge(err)
+	}
+	body, _ := json.Marshal(authErr)
+	return authErr.Code, headers, string(body)
+}
+
+// headerLookup case insensitive lookup, API Gateway HTTP APIs lower case the header names
+func headerLookup(headers map[string]string) func(string) string {
+	return func(key string) string {
+		if value, ok := headers[key]; ok {
+			return value
+		}
+		for name, value := range headers {
+			if strings.EqualFold(name, key) {
+				return value
+			}
+		}
+		return ""
+	}
+}
+
+func multiValueHeaderLookup(headers map[string][]string) func(string) string {
+	return func(key string) string {
+		for name, values := range headers {
+			if strings.EqualFold(name, key) && len(values) > 0 {
+				return values[0]
+			}
+		}
+		return ""
+	}
+}
+
+// splitRouteKey splits an HTTP API route key such as "GET /orders/{id}", the $default route has no method
+func splitRouteKey(routeKey string) (string, string) {
+	parts := strings.SplitN(routeKey, " ", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,77 @@
+package lambdajwt
+
+import (
+	"context"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func newIssuer(t *testing.T) *jwttest.Issuer {
+	issuer, err := jwttest.NewIssuer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+func Test_WrapProxy(t *testing.T) {
+	t.Logf("Given a REST API proxy handler protected by the middleware")
+	{
+		issuer := newIssuer(t)
+		mw := issuer.Middleware()
+		mw.Routes = jwt.RouteTable{"DELETE /orders/{id}": {Groups: []string{"admins"}}}
+
+		handler := WrapProxy(mw, func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			principal, _ := PrincipalFromContext(ctx)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: principal.ID()}, nil
+		})
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+
+		resp, err := handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod: "GET", Resource: "/orders/{id}", Headers: map[string]string{"authentication": token}})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "user-123", resp.Body)
+
+		resp, _ = handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Resource: "/orders/{id}"})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp, _ = handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod: "DELETE", Resource: "/orders/{id}", Headers: map[string]string{jwt.AuthorizationHeader: token}})
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func Test_WrapHTTP(t *testing.T) {
+	t.Logf("Given an HTTP API handler protected by the middleware")
+	{
+		issuer := newIssuer(t)
+		mw := issuer.Middleware()
+		mw.PublicRoutes = []string{"GET /health"}
+
+		handler := WrapHTTP(mw, func(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+			return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK}, nil
+		})
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+
+		resp, _ := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+			RouteKey: "GET /orders", Headers: map[string]string{"authentication": token}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = handler(context.Background(), events.APIGatewayV2HTTPRequest{RouteKey: "GET /orders"})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `Bearer realm="gin jwt"`, resp.Headers[jwt.AuthenticateHeader])
+
+		resp, _ = handler(context.Background(), events.APIGatewayV2HTTPRequest{
+			RouteKey: "GET /orders", Headers: map[string]string{"authentication": token + "x"}})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `Bearer realm="gin jwt", error="invalid_token", error_description="invalid_signature"`, resp.Headers[jwt.AuthenticateHeader])
+
+		resp, _ = handler(context.Background(), events.APIGatewayV2HTTPRequest{RouteKey: "GET /health"})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
@@ -1,39 +1,156 @@
 package jwt
 
 import (
-	"io/ioutil"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 )
 
 var (
 	// Trace logger
-	Trace *log.Logger
+	//
+	// Deprecated: the middleware logs through its Logger and no longer writes to the package loggers.
+	Trace = log.New(io.Discard, "TRACE: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	// Info logger
-	Info *log.Logger
+	//
+	// Deprecated: the middleware logs through its Logger and no longer writes to the package loggers.
+	Info = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	// Warning logger
-	Warning *log.Logger
+	//
+	// Deprecated: the middleware logs through its Logger and no longer writes to the package loggers.
+	Warning = log.New(os.Stdout, "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	// Error logger
-	Error *log.Logger
+	//
+	// Deprecated: the middleware logs through its Logger and no longer writes to the package loggers.
+	Error = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 )
 
-func init() {
-	Trace = log.New(ioutil.Discard,
-		"TRACE: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
+// Logger the logging abstraction used by the middleware. Messages come with alternating key/value pairs,
+// the signature of *slog.Logger, so structured loggers can be plugged in as they are.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// LogLevel the minimum level of the logs of the middleware, the values match the slog levels
+type LogLevel int
+
+const (
+
+	// LogLevelDebug logs everything, including a line for every validated token
+	LogLevelDebug LogLevel = -4
+
+	// LogLevelInfo the default level, per request logs are suppressed except for rejected tokens
+	LogLevelInfo LogLevel = 0
+
+	// LogLevelWarn logs the rejected tokens and the errors
+	LogLevelWarn LogLevel = 4
+
+	// LogLevelError logs the errors only
+	LogLevelError LogLevel = 8
+)
+
+// NopLogger discards all the logs, the default logger of the middleware
+type NopLogger struct{}
+
+func (NopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (NopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (NopLogger) Warn(msg string, keysAndValues ...interface{})  {}
+func (NopLogger) Error(msg string, keysAndValues ...interface{}) {}
+
+// stdLogger Logger writing key=value lines to a standard library logger
+type stdLogger struct {
+	logger *log.Logger
+}
+
+// NewStdLogger creates a Logger writing to the given standard library logger, e.g. log.Default()
+func NewStdLogger(logger *log.Logger) Logger {
+	return &stdLogger{logger: logger}
+}
+
+func (l *stdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.print("DEBUG", msg, keysAndValues)
+}
+
+func (l *stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.print("INFO", msg, keysAndValues)
+}
 
-	Info = log.New(os.Stdout,
-		"INFO: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
+func (l *stdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.print("WARNING", msg, keysAndValues)
+}
+
+func (l *stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.print("ERROR", msg, keysAndValues)
+}
+
+func (l *stdLogger) print(level, msg string, keysAndValues []interface{}) {
+	var b strings.Builder
+	b.WriteString(level + ": " + msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&b, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", keysAndValues[i])
+		}
+	}
+	l.logger.Print(b.String())
+}
+
+// log returns the configured logger filtered by the LogLevel, the tokens redacted, NopLogger when none is set
+func (mw *AuthMiddleware) log() Logger {
+	if mw.Logger == nil {
+		return NopLogger{}
+	}
+	return &levelLogger{logger: &redactingLogger{logger: mw.Logger}, level: mw.logLevel()}
+}
+
+// logLevel the effective log level, the debug mode lowers it to LogLevelDebug
+func (mw *AuthMiddleware) logLevel() LogLevel {
+	if mw.Debug && mw.LogLevel > LogLevelDebug {
+		return LogLevelDebug
+	}
+	return mw.LogLevel
+}
 
-	Warning = log.New(os.Stdout,
-		"WARNING: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
+// logEnabled whether the logs of the given level are written, so that their fields are not built in vain
+func (mw *AuthMiddleware) logEnabled(level LogLevel) bool {
+	return mw.Logger != nil && level >= mw.logLevel()
+}
+
+// levelLogger Logger dropping the logs below the given level
+type levelLogger struct {
+	logger Logger
+	level  LogLevel
+}
+
+func (l *levelLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if l.level <= LogLevelDebug {
+		l.logger.Debug(msg, keysAndValues...)
+	}
+}
+
+func (l *levelLogger) Info(msg string, keysAndValues ...interface{}) {
+	if l.level <= LogLevelInfo {
+		l.logger.Info(msg, keysAndValues...)
+	}
+}
+
+func (l *levelLogger) Warn(msg string, keysAndValues ...interface{}) {
+	if l.level <= LogLevelWarn {
+		l.logger.Warn(msg, keysAndValues...)
+	}
+}
 
-	Error = log.New(os.Stdout,
-		"ERROR: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
+func (l *levelLogger) Error(msg string, keysAndValues ...interface{}) {
+	if l.level <= LogLevelError {
+		l.logger.Error(msg, keysAndValues...)
+	}
 }
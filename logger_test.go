@@ -0,0 +1,140 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logEntry a log line captured by the recordingLogger
+type logEntry struct {
+	level         string
+	msg           string
+	keysAndValues []interface{}
+}
+
+// recordingLogger Logger keeping the logs in memory
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.record("debug", msg, keysAndValues)
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.record("info", msg, keysAndValues)
+}
+
+func (l *recordingLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.record("warn", msg, keysAndValues)
+}
+
+func (l *recordingLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.record("error", msg, keysAndValues)
+}
+
+func (l *recordingLogger) record(level, msg string, keysAndValues []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, keysAndValues: keysAndValues})
+}
+
+func (l *recordingLogger) messages(level string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var messages []string
+	for _, entry := range l.entries {
+		if entry.level == level {
+			messages = append(messages, entry.msg)
+		}
+	}
+	return messages
+}
+
+func Test_LoggerReceivesMiddlewareLogs(t *testing.T) {
+	t.Logf("Given a middleware configured with its own logger")
+	{
+		logger := &recordingLogger{}
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.LogLevel = LogLevelDebug
+		router := authzHandler(mw, mw.RequireGroups("admins"))
+
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", ExpiredCognitoToken).Code)
+		assert.Equal(t, []string{"Failed to validate the jwt token"}, logger.messages("warn"))
+
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", tokenWithGroups("users")).Code)
+		assert.Contains(t, logger.messages("debug"), "Principal is not member of the required groups")
+	}
+}
+
+func Test_LogLevelSuppressesPerRequestLogs(t *testing.T) {
+	t.Logf("Given a middleware logging at the default info level")
+	{
+		logger := &recordingLogger{}
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		router := authzHandler(mw, mw.RequireGroups("admins"))
+
+		performRequest(router, "GET", "/orders", tokenWithGroups("admins"))
+		performRequest(router, "GET", "/orders", tokenWithGroups("users"))
+		assert.Empty(t, logger.entries)
+
+		performRequest(router, "GET", "/orders", "not-a-token")
+		assert.Equal(t, []string{"Failed to validate the jwt token"}, logger.messages("warn"))
+	}
+
+	t.Logf("Given a middleware logging errors only")
+	{
+		logger := &recordingLogger{}
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.LogLevel = LogLevelError
+		performRequest(authzHandler(mw), "GET", "/orders", "not-a-token")
+		assert.Empty(t, logger.entries)
+	}
+}
+
+func Test_StdLogger(t *testing.T) {
+	t.Logf("Given a logger writing to a standard library logger")
+	{
+		var buf bytes.Buffer
+		NewStdLogger(log.New(&buf, "", 0)).Warn("Failed to validate the jwt token", "sub", "user-123", "error")
+		assert.Equal(t, "WARNING: Failed to validate the jwt token sub=user-123 error\n", buf.String())
+	}
+}
+
+func Test_SlogLogger(t *testing.T) {
+	t.Logf("Given a middleware logging to a *slog.Logger")
+	{
+		var buf bytes.Buffer
+		mw := newTestMiddleware()
+		mw.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		mw.LogLevel = LogLevelDebug
+		router := authzHandler(mw)
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		var record map[string]interface{}
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "DEBUG", record["level"])
+		assert.Equal(t, "user-123", record["sub"])
+		assert.Equal(t, TestKid, record["kid"])
+		assert.Contains(t, record, "latency")
+
+		buf.Reset()
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", signToken(expired)).Code)
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "WARN", record["level"])
+		assert.Equal(t, "expired", record["error_class"])
+	}
+}
@@ -0,0 +1,188 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+
+	// IDTokenCookie the cookie holding the ID token set by the CallbackHandler
+	IDTokenCookie = "id_token"
+
+	// AccessTokenCookie the cookie holding the access token set by the CallbackHandler
+	AccessTokenCookie = "access_token"
+
+	// loginCookie the cookie holding the state of a login in progress
+	loginCookie = "cognito_login"
+
+	// loginTimeout how long a login may take at the Hosted UI
+	loginTimeout = 10 * time.Minute
+)
+
+// loginState the state of a login in progress, kept in a cookie until the callback
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	ReturnTo string `json:"return_to"`
+
+	// Verifier the PKCE code verifier of the login
+	Verifier string `json:"verifier"`
+}
+
+// acceptsHTML whether the request is a browser navigation
+func acceptsHTML(c *gin.Context) bool {
+	return c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html")
+}
+
+// randomString a random URL safe string
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// safeReturnTo the path to return to after the login, only local paths are allowed to avoid open redirects
+func safeReturnTo(path string) string {
+	if !strings.HasPrefix(path, ForwardSlash) || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return ForwardSlash
+	}
+	return path
+}
+
+// setCookie sets an HTTP only cookie on the whole site
+func setCookie(c *gin.Context, name, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     ForwardSlash,
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// setTokenCookies sets the tokens as HTTP only cookies, the ID and access tokens expiring with the tokens
+func setTokenCookies(c *gin.Context, tokens *Tokens, refreshCookie string) {
+	setCookie(c, IDTokenCookie, tokens.IDToken, tokens.ExpiresIn)
+	setCookie(c, AccessTokenCookie, tokens.AccessToken, tokens.ExpiresIn)
+	if tokens.RefreshToken != "" {
+		setCookie(c, refreshCookie, tokens.RefreshToken, 0)
+	}
+}
+
+// authorizeURL the Hosted UI URL starting the login
+func (mw *AuthMiddleware) authorizeURL(login loginState) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {mw.ClientID},
+		"redirect_uri":          {mw.RedirectURL},
+		"scope":                 {strings.Join(mw.LoginScopes, " ")},
+		"state":                 {login.State},
+		"nonce":                 {login.Nonce},
+		"code_challenge":        {codeChallenge(login.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	return mw.domainURL() + "/oauth2/authorize?" + query.Encode()
+}
+
+// redirectToLogin redirects the browser to the Hosted UI, remembering the page to return to after the login
+func (mw *AuthMiddleware) redirectToLogin(c *gin.Context, returnTo string) {
+	login := loginState{State: randomString(), Nonce: randomString(), ReturnTo: safeReturnTo(returnTo), Verifier: randomString()}
+	value, _ := json.Marshal(login)
+	setCookie(c, loginCookie, base64.RawURLEncoding.EncodeToString(value), int(loginTimeout.Seconds()))
+	c.Redirect(http.StatusFound, mw.authorizeURL(login))
+	c.Abort()
+}
+
+// loginState reads and clears the state of the login in progress
+func (mw *AuthMiddleware) loginState(c *gin.Context) (loginState, error) {
+	var login loginState
+	cookie, err := c.Cookie(loginCookie)
+	if err != nil {
+		return login, ErrInvalidLoginState
+	}
+	setCookie(c, loginCookie, "", -1)
+	value, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil || json.Unmarshal(value, &login) != nil || login.State == "" || login.State != c.Query("state") {
+		return login, ErrInvalidLoginState
+	}
+	return login, nil
+}
+
+// LoginHandler redirects the browser to the Hosted UI, returning to the local path given by the return_to
+// query parameter after the login
+func (mw *AuthMiddleware) LoginHandler() gin.HandlerFunc {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(c *gin.Context) {
+		mw.redirectToLogin(c, c.Query("return_to"))
+	}
+}
+
+// CallbackHandler serves the RedirectURL: it exchanges the authorization code issued by the Hosted UI for the
+// tokens of the user, validates the ID token, sets the IDTokenCookie, AccessTokenCookie and RefreshTokenCookie
+// cookies and redirects the browser to the page which required the login.
+func (mw *AuthMiddleware) CallbackHandler() gin.HandlerFunc {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(c *gin.Context) {
+		mw.correlate(c)
+		logger := mw.requestLog(c)
+		login, err := mw.loginState(c)
+		if err != nil {
+			logger.Warn("Failed to complete the login", "error", err)
+			mw.respond(c, http.StatusBadRequest, err, nil)
+			return
+		}
+		if code := c.Query("error"); code != "" {
+			err := &OAuthError{StatusCode: http.StatusUnauthorized, Code: code, Description: c.Query("error_description")}
+			logger.Warn("The login was denied", "error", err)
+			mw.respond(c, http.StatusUnauthorized, err, nil)
+			return
+		}
+
+		tokens, err := mw.exchangeCode(c.Request.Context(), c.Query("code"), login.Verifier, mw.RedirectURL)
+		if err != nil {
+			mw.tokenRequestFailed(c, logger, "code_exchange", err)
+			return
+		}
+		token, err := mw.validateToken(tokens.IDToken, logger)
+		if err == nil {
+			if nonce, _ := token.Claims.(jwtgo.MapClaims)["nonce"].(string); nonce != login.Nonce {
+				err = ErrNonceMismatch
+			}
+		}
+		if err != nil {
+			logger.Warn("Rejected the ID token of the login", "error", err)
+			mw.respond(c, http.StatusUnauthorized, err, nil)
+			return
+		}
+
+		setTokenCookies(c, tokens, mw.RefreshTokenCookie)
+		c.Redirect(http.StatusFound, login.ReturnTo)
+	}
+}
+
+// tokenRequestFailed answers a failed call to the token endpoint, with a 401 when the grant was rejected
+// and a 502 when the user pool could not be reached
+func (mw *AuthMiddleware) tokenRequestFailed(c *gin.Context, logger Logger, operation string, err error) {
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) && oauthErr.StatusCode < http.StatusInternalServerError {
+		logger.Warn("The token endpoint rejected the grant", "operation", operation, "error", err)
+		mw.respond(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+	logger.Error("Failed to call the token endpoint", "operation", operation, "error", err)
+	mw.reportError(err, map[string]string{"operation": operation})
+	mw.respond(c, http.StatusBadGateway, err, nil)
+}
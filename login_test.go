@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// hostedUI fakes the token endpoint of the user pool, issuing tokens for the code "valid-code" and recording
+// the PKCE code verifier
+func hostedUI(t *testing.T, nonce, verifier *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/oauth2/token", r.URL.Path)
+		r.ParseForm()
+		assert.Equal(t, "authorization_code", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "https://app.example.com/callback", r.PostForm.Get("redirect_uri"))
+		*verifier = r.PostForm.Get("code_verifier")
+		if r.PostForm.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		idClaims := testClaims()
+		idClaims["token_use"] = "id"
+		idClaims["nonce"] = *nonce
+		json.NewEncoder(w).Encode(Tokens{
+			IDToken:      signToken(idClaims),
+			AccessToken:  signToken(testClaims()),
+			RefreshToken: "refresh",
+			ExpiresIn:    3600,
+			TokenType:    "Bearer",
+		})
+	}))
+}
+
+func browserRequest(router http.Handler, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func Test_BrowserLogin(t *testing.T) {
+	t.Logf("Given a server rendered app logging in its users with the Hosted UI")
+	{
+		var nonce, verifier string
+		server := hostedUI(t, &nonce, &verifier)
+		defer server.Close()
+
+		mw := newTestMiddleware()
+		mw.BrowserLogin = true
+		mw.Domain = server.URL
+		mw.ClientID = "test-client"
+		mw.RedirectURL = "https://app.example.com/callback"
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/callback", mw.CallbackHandler())
+		router.GET("/orders", mw.MiddlewareFunc(), testHandler)
+
+		w := browserRequest(router, "/orders?page=2", nil)
+		assert.Equal(t, http.StatusFound, w.Code)
+		authorize, _ := url.Parse(w.Header().Get("Location"))
+		assert.Equal(t, server.URL+"/oauth2/authorize", authorize.Scheme+"://"+authorize.Host+authorize.Path)
+		assert.Equal(t, "code", authorize.Query().Get("response_type"))
+		assert.Equal(t, "openid", authorize.Query().Get("scope"))
+		state := authorize.Query().Get("state")
+		nonce = authorize.Query().Get("nonce")
+		loginCookies := w.Result().Cookies()
+
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", "").Code)
+
+		w = browserRequest(router, "/callback?code=valid-code&state=forged", loginCookies)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = browserRequest(router, "/callback?code=valid-code&state="+state, loginCookies)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/orders?page=2", w.Header().Get("Location"))
+		assert.Equal(t, "S256", authorize.Query().Get("code_challenge_method"))
+		assert.Equal(t, authorize.Query().Get("code_challenge"), codeChallenge(verifier))
+		sessionCookies := map[string]*http.Cookie{}
+		for _, cookie := range w.Result().Cookies() {
+			sessionCookies[cookie.Name] = cookie
+		}
+		assert.True(t, sessionCookies[AccessTokenCookie].HttpOnly)
+		assert.Equal(t, "refresh", sessionCookies[DefaultRefreshTokenCookie].Value)
+
+		w = browserRequest(router, "/orders?page=2", []*http.Cookie{sessionCookies[AccessTokenCookie]})
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func Test_CallbackRejectsAForeignNonce(t *testing.T) {
+	t.Logf("Given an ID token issued for another login")
+	{
+		nonce, verifier := "another-login", ""
+		server := hostedUI(t, &nonce, &verifier)
+		defer server.Close()
+
+		mw := newTestMiddleware()
+		mw.Domain = server.URL
+		mw.RedirectURL = "https://app.example.com/callback"
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/login", mw.LoginHandler())
+		router.GET("/callback", mw.CallbackHandler())
+
+		w := browserRequest(router, "/login?return_to=//evil.example.com", nil)
+		authorize, _ := url.Parse(w.Header().Get("Location"))
+		w = browserRequest(router, "/callback?code=valid-code&state="+authorize.Query().Get("state"), w.Result().Cookies())
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), ErrNonceMismatch.Error())
+		assert.Equal(t, ForwardSlash, safeReturnTo("//evil.example.com"))
+	}
+}
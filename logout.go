@@ -0,0 +1,83 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LogoutHandler signs the caller out: the session of the caller is deleted, the tokens of the caller are
+// recorded in the Revocations store, so the middleware rejects them straight away, the Cognito GlobalSignOut
+// is called with the access token when GlobalSignOut is on and the token cookies are cleared in cookie mode.
+// It answers with a 204, whether the caller presented a valid token or not.
+func (mw *AuthMiddleware) LogoutHandler() gin.HandlerFunc {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(c *gin.Context) {
+		mw.correlate(c)
+		logger := mw.requestLog(c)
+		if strings.HasPrefix(mw.TokenLookup, COOKIE+":") {
+			setCookie(c, IDTokenCookie, "", -1)
+			setCookie(c, AccessTokenCookie, "", -1)
+			setCookie(c, mw.RefreshTokenCookie, "", -1)
+		}
+
+		token := mw.sessionToken(c, logger)
+		if err := mw.endSession(c); err != nil {
+			logger.Error("Failed to delete the session", "error", err)
+			mw.reportError(err, map[string]string{"operation": "session_delete"})
+		}
+		if token == nil {
+			tokenStr, err := mw.requestToken(c.Request, logger)
+			if err == nil {
+				token, err = mw.validateToken(tokenStr, logger)
+			}
+			if err != nil {
+				c.Status(http.StatusNoContent)
+				return
+			}
+		}
+		tokenStr, claims := token.Raw, token.Claims.(jwtgo.MapClaims)
+		if mw.TokenCache != nil {
+			mw.TokenCache.Remove(tokenStr)
+		}
+
+		if originJTI, _ := claims[OriginJTIClaim].(string); originJTI != "" && mw.Revocations != nil {
+			until := mw.revokedUntil(claims)
+			if err := mw.Revocations.Revoke(originJTI, until); err != nil {
+				logger.Error("Failed to revoke the tokens", "error", err)
+				mw.reportError(err, map[string]string{"operation": "revocation"})
+				mw.respond(c, http.StatusInternalServerError, err, nil)
+				return
+			}
+		}
+
+		if tokenUse, _ := claims["token_use"].(string); mw.GlobalSignOut && tokenUse == "access" {
+			input := map[string]string{"AccessToken": tokenStr}
+			if err := mw.callCognito(c.Request.Context(), "GlobalSignOut", input, &struct{}{}); err != nil {
+				logger.Error("Failed to sign the user out of cognito", "error", err)
+				mw.reportError(err, map[string]string{"operation": "global_sign_out"})
+				mw.respond(c, http.StatusBadGateway, err, nil)
+				return
+			}
+		}
+		logger.Info("Signed the user out", "sub", NewPrincipal(claims).ID())
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// revokedUntil the time until which the tokens of the origin_jti of the claims are revoked: until the refresh token
+// issued along with them at the authentication of the user expires, the tokens it refreshes sharing the origin_jti
+func (mw *AuthMiddleware) revokedUntil(claims jwtgo.MapClaims) time.Time {
+	issuedAt := mw.now()
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		issuedAt = time.Unix(int64(authTime), 0)
+	}
+	until := issuedAt.Add(mw.RefreshTokenValidity)
+	if expiresAt, ok := ExpiresAt(claims); ok && expiresAt.After(until) {
+		return expiresAt
+	}
+	return until
+}
@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_LogoutRevokesTheTokens(t *testing.T) {
+	t.Logf("Given a user signing out of every device")
+	{
+		var signedOut []string
+		defer cognitoServer(func(action string, input map[string]interface{}) (int, interface{}) {
+			assert.Equal(t, "GlobalSignOut", action)
+			signedOut = append(signedOut, input["AccessToken"].(string))
+			return http.StatusOK, map[string]string{}
+		})()
+		mw := newTestMiddleware()
+		mw.Revocations = NewMemoryRevocationStore()
+		mw.GlobalSignOut = true
+		router := authzHandler(mw)
+		router.POST("/logout", mw.LogoutHandler())
+
+		claims := testClaims()
+		claims[OriginJTIClaim] = "origin-1"
+		token := signToken(claims)
+		refreshed := testClaims()
+		refreshed[OriginJTIClaim] = "origin-1"
+		refreshed["iat"] = time.Now().Add(time.Minute).Unix()
+		other := testClaims()
+		other[OriginJTIClaim] = "origin-2"
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", token).Code)
+		assert.Equal(t, http.StatusNoContent, performRequest(router, "POST", "/logout", token).Code)
+		assert.Equal(t, []string{token}, signedOut)
+
+		w := performRequest(router, "GET", "/orders", token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), `error_description="revoked"`)
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", signToken(refreshed)).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(other)).Code)
+	}
+
+	t.Logf("Given a user signing out an hour after signing in")
+	{
+		store := NewMemoryRevocationStore()
+		mw := newTestMiddleware()
+		mw.Revocations = store
+		router := authzHandler(mw)
+		router.POST("/logout", mw.LogoutHandler())
+
+		claims := testClaims()
+		claims[OriginJTIClaim] = "origin-1"
+		authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+		claims["auth_time"] = authTime.Unix()
+		assert.Equal(t, http.StatusNoContent, performRequest(router, "POST", "/logout", signToken(claims)).Code)
+
+		t.Logf("Then the tokens are revoked until the refresh token expires, not the access token")
+		assert.Equal(t, authTime.Add(DefaultRefreshTokenValidity), store.revoked["origin-1"])
+
+		mw.RefreshTokenValidity = 24 * time.Hour
+		router = authzHandler(mw)
+		router.POST("/logout", mw.LogoutHandler())
+		claims[OriginJTIClaim] = "origin-2"
+		assert.Equal(t, http.StatusNoContent, performRequest(router, "POST", "/logout", signToken(claims)).Code)
+		assert.Equal(t, authTime.Add(24*time.Hour), store.revoked["origin-2"])
+	}
+}
+
+func Test_LogoutClearsTheCookies(t *testing.T) {
+	t.Logf("Given a user signed in with cookies")
+	{
+		mw := newTestMiddleware()
+		mw.BrowserLogin = true
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/logout", mw.LogoutHandler())
+
+		req, _ := http.NewRequest("POST", "/logout", nil)
+		req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: signToken(testClaims())})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		for _, cookie := range w.Result().Cookies() {
+			assert.Empty(t, cookie.Value)
+			assert.True(t, cookie.MaxAge < 0)
+		}
+		assert.Len(t, w.Result().Cookies(), 3)
+	}
+}
+
+func Test_MemoryRevocationStore(t *testing.T) {
+	t.Logf("Given revocations of tokens expired or not")
+	{
+		store := NewMemoryRevocationStore()
+		store.Revoke("expired", time.Now().Add(-time.Minute))
+		store.Revoke("live", time.Now().Add(time.Minute))
+
+		revoked, _ := store.IsRevoked("live")
+		assert.True(t, revoked)
+		revoked, _ = store.IsRevoked("expired")
+		assert.False(t, revoked)
+		revoked, _ = store.IsRevoked("unknown")
+		assert.False(t, revoked)
+	}
+}
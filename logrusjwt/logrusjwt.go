@@ -0,0 +1,50 @@
+// Package logrusjwt adapts a logrus logger to the jwt.Logger interface, the key/value pairs of the
+// middleware logs becoming logrus fields.
+package logrusjwt
+
+import (
+	"fmt"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/sirupsen/logrus"
+)
+
+type logger struct {
+	entry *logrus.Entry
+}
+
+// New returns a jwt.Logger writing to the given logrus logger or entry
+func New(l logrus.FieldLogger) jwt.Logger {
+	return &logger{entry: l.WithFields(nil)}
+}
+
+func (l *logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.with(keysAndValues).Debug(msg)
+}
+
+func (l *logger) Info(msg string, keysAndValues ...interface{}) {
+	l.with(keysAndValues).Info(msg)
+}
+
+func (l *logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.with(keysAndValues).Warn(msg)
+}
+
+func (l *logger) Error(msg string, keysAndValues ...interface{}) {
+	l.with(keysAndValues).Error(msg)
+}
+
+// with converts the key/value pairs to logrus fields, a dangling key is logged under !BADKEY as slog does
+func (l *logger) with(keysAndValues []interface{}) *logrus.Entry {
+	if len(keysAndValues) == 0 {
+		return l.entry
+	}
+	fields := make(logrus.Fields, len(keysAndValues)/2+1)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fields["!BADKEY"] = keysAndValues[i]
+			break
+		}
+		fields[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+	return l.entry.WithFields(fields)
+}
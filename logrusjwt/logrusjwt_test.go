@@ -0,0 +1,27 @@
+package logrusjwt
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_LogrusLogger(t *testing.T) {
+	t.Logf("Given a jwt.Logger backed by a logrus logger")
+	{
+		l, hook := test.NewNullLogger()
+		l.SetLevel(logrus.DebugLevel)
+		logger := New(l)
+
+		logger.Debug("Validated the jwt token", "sub", "user-123")
+		logger.Error("External authorization failed", "sub", "user-123", "error")
+
+		assert.Len(t, hook.Entries, 2)
+		assert.Equal(t, logrus.DebugLevel, hook.Entries[0].Level)
+		assert.Equal(t, logrus.Fields{"sub": "user-123"}, hook.Entries[0].Data)
+		assert.Equal(t, logrus.ErrorLevel, hook.Entries[1].Level)
+		assert.Equal(t, "External authorization failed", hook.Entries[1].Message)
+		assert.Equal(t, logrus.Fields{"sub": "user-123", "!BADKEY": "error"}, hook.Entries[1].Data)
+	}
+}
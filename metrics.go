@@ -0,0 +1,13 @@
+package jwt
+
+import "time"
+
+// Metrics receives the outcome of every token extraction and validation, see the promjwt package for a
+// Prometheus collector
+type Metrics interface {
+
+	// ObserveValidation reason is empty for valid tokens, otherwise one of missing_token, invalid_header,
+	// malformed, unverifiable, unknown_kid, malformed_key, weak_key, invalid_signature, expired, not_valid_yet,
+	// bad_issuer, wrong_token_use, unknown_tenant, tenant_mismatch or invalid_claims
+	ObserveValidation(reason string, latency time.Duration)
+}
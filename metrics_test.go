@@ -0,0 +1,40 @@
+package jwt
+
+import (
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// recordingMetrics Metrics keeping the observed reasons
+type recordingMetrics struct {
+	reasons []string
+}
+
+func (m *recordingMetrics) ObserveValidation(reason string, latency time.Duration) {
+	m.reasons = append(m.reasons, reason)
+}
+
+func Test_MetricsObserveValidationReasons(t *testing.T) {
+	t.Logf("Given a middleware reporting its validations")
+	{
+		metrics := &recordingMetrics{}
+		mw := newTestMiddleware()
+		mw.Metrics = metrics
+		router := authzHandler(mw)
+
+		unknownKid := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+		unknownKid.Header["kid"] = "rotated-kid"
+		unknownKidStr, _ := unknownKid.SignedString(testKey)
+
+		performRequest(router, "GET", "/orders", signToken(testClaims()))
+		performRequest(router, "GET", "/orders", unknownKidStr)
+		performRequest(router, "GET", "/orders", "not-a-token")
+		assert.Equal(t, []string{"", "unknown_kid", "malformed"}, metrics.reasons)
+
+		mw.ExtractToken(http.Header{}.Get)
+		assert.Equal(t, "missing_token", metrics.reasons[3])
+	}
+}
@@ -0,0 +1,89 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+	"net/url"
+)
+
+// CertificateThumbprintClaim the member of the cnf claim holding the SHA-256 thumbprint of the certificate the
+// access token is bound to, RFC 8705
+const CertificateThumbprintClaim = "x5t#S256"
+
+// ClientCertificateFromHeader returns the client certificate forwarded by a TLS terminating proxy as the URL
+// encoded PEM of the given header, e.g. X-Amzn-Mtls-Clientcert with the mutual TLS passthrough of the AWS load
+// balancers. The header must be set by the proxy alone, never by the clients.
+func ClientCertificateFromHeader(name string) func(*http.Request) (*x509.Certificate, error) {
+	return func(r *http.Request) (*x509.Certificate, error) {
+		value := r.Header.Get(name)
+		if value == "" {
+			return nil, nil
+		}
+		decoded, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("decoding the %s header: %w", name, err)
+		}
+		block, _ := pem.Decode([]byte(decoded))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("the %s header holds no PEM certificate", name)
+		}
+		return x509.ParseCertificate(block.Bytes)
+	}
+}
+
+// clientCertificate the client certificate of the request, nil when the client presented none
+func (mw *AuthMiddleware) clientCertificate(r *http.Request) (*x509.Certificate, error) {
+	if mw.ClientCertificate != nil {
+		return mw.ClientCertificate(r)
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, nil
+	}
+	return r.TLS.PeerCertificates[0], nil
+}
+
+// checkCertificate rejects with an ErrCertificateMismatch the access tokens bound to a certificate by their
+// cnf.x5t#S256 claim which are not presented over a connection authenticated with that certificate
+func (mw *AuthMiddleware) checkCertificate(r *http.Request, token *jwtgo.Token, logger Logger) error {
+	thumbprint := boundCertificate(token.Claims.(jwtgo.MapClaims))
+	if thumbprint == "" {
+		return nil
+	}
+	err := mw.verifyCertificate(r, thumbprint)
+	if err != nil {
+		mw.observe(failureReason(err), 0)
+		if mw.sampleFailure(failureReason(err)) {
+			logger.Warn("Failed to validate the certificate bound jwt token", "error_class", failureReason(err), "error", err)
+		}
+	}
+	return err
+}
+
+// boundCertificate the thumbprint of the certificate the token is bound to by its cnf.x5t#S256 claim, empty when it
+// is not bound to one
+func boundCertificate(claims jwtgo.MapClaims) string {
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	thumbprint, _ := cnf[CertificateThumbprintClaim].(string)
+	return thumbprint
+}
+
+func (mw *AuthMiddleware) verifyCertificate(r *http.Request, thumbprint string) error {
+	certificate, err := mw.clientCertificate(r)
+	if err != nil {
+		return tokenError(ErrCertificateMismatch, fmt.Errorf("reading the client certificate: %w", err))
+	}
+	if certificate == nil {
+		return tokenError(ErrCertificateMismatch, errors.New("the token is bound to a certificate, the client presented none"))
+	}
+	sum := sha256.Sum256(certificate.Raw)
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != thumbprint {
+		return tokenError(ErrCertificateMismatch, errors.New("the token is bound to another certificate than the one of the client"))
+	}
+	return nil
+}
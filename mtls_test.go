@@ -0,0 +1,135 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// clientCertificate creates a self signed client certificate
+func clientCertificate(t *testing.T, name string) *x509.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	template.Subject.CommonName = name
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return certificate
+}
+
+// certificateBoundToken returns an access token bound to the given certificate
+func certificateBoundToken(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	claims := testClaims()
+	claims["cnf"] = map[string]interface{}{CertificateThumbprintClaim: base64.RawURLEncoding.EncodeToString(sum[:])}
+	return signToken(claims)
+}
+
+// mtlsRequest performs a request with the given token over a connection authenticated with certificate, if any
+func mtlsRequest(r http.Handler, token string, certificate *x509.Certificate) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "https://api.example.com/orders", nil)
+	req.Header.Set(AuthorizationHeader, token)
+	if certificate != nil {
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{certificate}}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_CertificateBoundTokens(t *testing.T) {
+	t.Logf("Given an access token bound to a client certificate")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw)
+		certificate := clientCertificate(t, "partner")
+		token := certificateBoundToken(certificate)
+
+		t.Logf("Then it is accepted over a connection authenticated with the certificate")
+		assert.Equal(t, http.StatusOK, mtlsRequest(router, token, certificate).Code)
+
+		t.Logf("And it is rejected without the certificate or with another one")
+		for name, presented := range map[string]*x509.Certificate{"none": nil, "another": clientCertificate(t, "attacker")} {
+			w := mtlsRequest(router, token, presented)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+			assert.Equal(t, `Bearer realm="gin jwt", error="invalid_token", error_description="certificate_mismatch"`, w.Header().Get(AuthenticateHeader), name)
+		}
+		assert.Equal(t, uint64(2), mw.Stats().Failures["certificate_mismatch"])
+
+		t.Logf("And the unbound tokens are accepted without certificate")
+		assert.Equal(t, http.StatusOK, mtlsRequest(router, signToken(testClaims()), nil).Code)
+	}
+
+	t.Logf("Given a certificate bound token presented to the other entry points")
+	{
+		mw := newTestMiddleware()
+		mw.Sessions = NewMemorySessionStore()
+		router := authzHandler(mw)
+		router.GET("/auth", mw.ForwardAuthHandler())
+		certificate := clientCertificate(t, "partner")
+		token := certificateBoundToken(certificate)
+
+		t.Logf("Then it gets no session")
+		w := mtlsRequest(router, token, certificate)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+
+		t.Logf("And the forward auth rejects it without the certificate")
+		req := httptest.NewRequest("GET", "https://api.example.com/auth", nil)
+		req.Header.Set(AuthorizationHeader, token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		t.Logf("And it is rejected without its request, accepted along with the certificate")
+		_, err := mw.ValidateToken(token)
+		assert.ErrorIs(t, err, ErrCertificateMismatch)
+		req = httptest.NewRequest("GET", "https://api.example.com/orders", nil)
+		_, err = mw.ValidateRequest(req, token)
+		assert.ErrorIs(t, err, ErrCertificateMismatch)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{certificate}}
+		_, err = mw.ValidateRequest(req, token)
+		assert.NoError(t, err)
+	}
+
+	t.Logf("Given a client certificate forwarded by a TLS terminating proxy")
+	{
+		mw := newTestMiddleware()
+		mw.ClientCertificate = ClientCertificateFromHeader("X-Amzn-Mtls-Clientcert")
+		router := authzHandler(mw)
+		certificate := clientCertificate(t, "partner")
+		encoded := url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})))
+
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set(AuthorizationHeader, certificateBoundToken(certificate))
+		req.Header.Set("X-Amzn-Mtls-Clientcert", encoded)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		t.Logf("Then a header holding no certificate is rejected")
+		req.Header.Set("X-Amzn-Mtls-Clientcert", "garbage")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "holds no PEM certificate")
+	}
+}
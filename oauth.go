@@ -0,0 +1,92 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OAuthError an error answered by the OAuth endpoints of the user pool, e.g. invalid_grant for an expired
+// authorization code
+type OAuthError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return "oauth " + e.Code
+	}
+	return fmt.Sprintf("oauth %s: %s", e.Code, e.Description)
+}
+
+// oauthError decodes the error answered by an OAuth endpoint
+func oauthError(r *http.Response) error {
+	oauthErr := &OAuthError{StatusCode: r.StatusCode}
+	if err := json.NewDecoder(r.Body).Decode(oauthErr); err != nil {
+		oauthErr.Code = r.Status
+	}
+	return oauthErr
+}
+
+// domainURL the base URL of the Hosted UI, the Domain is used as is when it has a scheme
+func (mw *AuthMiddleware) domainURL() string {
+	if strings.HasPrefix(mw.Domain, "http://") || strings.HasPrefix(mw.Domain, "https://") {
+		return strings.TrimSuffix(mw.Domain, ForwardSlash)
+	}
+	return "https://" + strings.TrimSuffix(mw.Domain, ForwardSlash)
+}
+
+// requestTokens posts the given grant to the token endpoint of the user pool, authenticating the app
+// client with its secret, if any
+func (mw *AuthMiddleware) requestTokens(ctx context.Context, form url.Values) (*Tokens, error) {
+	form.Set("client_id", mw.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mw.domainURL()+"/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if mw.ClientSecret != "" {
+		req.SetBasicAuth(mw.ClientID, mw.ClientSecret)
+	}
+
+	r, err := cognitoHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling the token endpoint: %w", err)
+	}
+	defer closeBody(r)
+	if r.StatusCode != http.StatusOK {
+		return nil, oauthError(r)
+	}
+	tokens := &Tokens{}
+	if err := json.NewDecoder(r.Body).Decode(tokens); err != nil {
+		return nil, fmt.Errorf("decoding the token endpoint response: %w", err)
+	}
+	return tokens, nil
+}
+
+// exchangeCode exchanges the authorization code issued to the redirect URI for the tokens of the user, the
+// verifier being the PKCE code verifier of the login, if any
+func (mw *AuthMiddleware) exchangeCode(ctx context.Context, code, verifier, redirectURI string) (*Tokens, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	}
+	if verifier != "" {
+		form.Set("code_verifier", verifier)
+	}
+	return mw.requestTokens(ctx, form)
+}
+
+// codeChallenge the S256 PKCE code challenge of the given verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,174 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// Policy an attribute based access control expression evaluated against the token claims and the request,
+// e.g. claims.tenant == path.tenant && 'editor' in claims.groups
+//
+// The expression has access to:
+//   - claims: the token claims, plus the normalized groups and scopes lists
+//   - path: the route parameters
+//   - query: the first value of each query parameter
+//   - header: the first value of each request header, keyed by canonical name
+//   - method: the request method
+//
+// Expressions are evaluated by a sandboxed engine: they can neither call Go code nor mutate state. The policies fail
+// closed: the expressions naming another variable do not compile, and the requests lacking a claim, a route
+// parameter, a query parameter or a header read by the expression are denied, unless it is read as optional, e.g.
+// claims?.tenant.
+type Policy struct {
+	expression string
+	program    *vm.Program
+	reads      []policyRead
+}
+
+// policyRead a claim, route parameter, query parameter or header read by a policy, which must be defined
+type policyRead struct {
+	variable string
+	name     string
+}
+
+// errUndefined the policy reads a claim, route parameter, query parameter or header which is not defined
+var errUndefined = errors.New("undefined in the request")
+
+// NewPolicy compiles the given boolean expression
+func NewPolicy(expression string) (*Policy, error) {
+	env := map[string]interface{}{
+		"claims": map[string]interface{}{},
+		"path":   map[string]interface{}{},
+		"query":  map[string]interface{}{},
+		"header": map[string]interface{}{},
+		"method": "",
+	}
+	program, err := expr.Compile(expression, expr.Env(env), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %q: %w", expression, err)
+	}
+	reads := &policyReads{}
+	node := program.Node()
+	ast.Walk(&node, reads)
+	return &Policy{expression: expression, program: program, reads: reads.reads}, nil
+}
+
+// policyReads collects the required reads of a policy, the members of the variables named by a constant, e.g.
+// claims.tenant or header["X-Tenant"]
+type policyReads struct {
+	reads []policyRead
+}
+
+func (r *policyReads) Visit(node *ast.Node) {
+	member, ok := (*node).(*ast.MemberNode)
+	if !ok || member.Optional {
+		return
+	}
+	variable, ok := member.Node.(*ast.IdentifierNode)
+	if !ok || variable.Value == "method" {
+		return
+	}
+	if name, ok := member.Property.(*ast.StringNode); ok {
+		r.reads = append(r.reads, policyRead{variable: variable.Value, name: name.Value})
+	}
+}
+
+// String returns the policy expression
+func (p *Policy) String() string {
+	return p.expression
+}
+
+// Evaluate runs the policy for the given request and principal. The requests lacking a value read by the policy are
+// denied with an error.
+func (p *Policy) Evaluate(c *gin.Context, principal Principal) (bool, error) {
+	env := policyEnv(c, principal)
+	for _, read := range p.reads {
+		if _, ok := env[read.variable].(map[string]interface{})[read.name]; !ok {
+			return false, fmt.Errorf("%s %q %w", read.variable, read.name, errUndefined)
+		}
+	}
+	result, err := expr.Run(p.program, env)
+	if err != nil {
+		return false, err
+	}
+	allowed, _ := result.(bool)
+	return allowed, nil
+}
+
+// RequirePolicy returns a handler rejecting with 403 the requests for which the given expression does
+// not hold. It panics when the expression does not compile, so that mistakes surface at route
+// registration. It must be chained after MiddlewareFunc.
+func (mw *AuthMiddleware) RequirePolicy(expression string) gin.HandlerFunc {
+	policy, err := NewPolicy(expression)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			mw.unauthorized(c, ErrMissingHeader)
+			return
+		}
+
+		start := time.Now()
+		allowed, err := policy.Evaluate(c, principal)
+		decision := Decision{Policy: policy.String(), Allowed: allowed}
+		if errors.Is(err, errUndefined) {
+			decision.Reason = err.Error()
+		} else if err != nil {
+			mw.requestLog(c).Error("Failed to evaluate the policy", "policy", policy.String(), "error", err)
+			mw.reportError(err, map[string]string{"operation": "policy_evaluation", "policy": policy.String()})
+			decision.Reason = err.Error()
+		}
+		if !allowed {
+			mw.requestLog(c).Debug("Principal does not satisfy the policy", "sub", principal.ID(), "policy", policy.String())
+			if decision.Reason == "" {
+				decision.Reason = "policy not satisfied"
+			}
+			mw.recordDecision(c, principal, decision, start)
+			mw.forbidden(c, accessError(ErrPolicyDenied, "access denied by policy"))
+			return
+		}
+		mw.recordDecision(c, principal, decision, start)
+		c.Next()
+	}
+}
+
+// policyEnv the variables exposed to the policy expressions
+func policyEnv(c *gin.Context, principal Principal) map[string]interface{} {
+	claims := make(map[string]interface{}, len(principal.Claims())+2)
+	for k, v := range principal.Claims() {
+		claims[k] = v
+	}
+	claims["groups"] = principal.Groups()
+	claims["scopes"] = principal.Scopes()
+
+	path := make(map[string]interface{}, len(c.Params))
+	for _, param := range c.Params {
+		path[param.Key] = param.Value
+	}
+
+	query := make(map[string]interface{})
+	for k, v := range c.Request.URL.Query() {
+		query[k] = v[0]
+	}
+
+	header := make(map[string]interface{}, len(c.Request.Header))
+	for k, v := range c.Request.Header {
+		header[k] = v[0]
+	}
+
+	return map[string]interface{}{
+		"claims": claims,
+		"path":   path,
+		"query":  query,
+		"header": header,
+		"method": c.Request.Method,
+	}
+}
@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_RequirePolicy(t *testing.T) {
+	t.Logf("Given a route restricted to the editors of the tenant in the path")
+	{
+		mw := newTestMiddleware()
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/tenants/:tenant/docs", mw.MiddlewareFunc(),
+			mw.RequirePolicy("claims.tenant == path.tenant && 'editor' in claims.groups"), testHandler)
+
+		claims := testClaims()
+		claims["tenant"] = "acme"
+		claims[GroupsClaim] = []string{"editor"}
+		token := signToken(claims)
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/tenants/acme/docs", token).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/tenants/globex/docs", token).Code)
+
+		claims[GroupsClaim] = []string{"viewer"}
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/tenants/acme/docs", signToken(claims)).Code)
+	}
+
+	t.Logf("Given a policy reading a claim and a header missing from the request")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw, mw.RequirePolicy(`claims.tenant == header["X-Tenant"]`))
+
+		t.Logf("Then the request is denied rather than comparing the undefined values")
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+
+		t.Logf("And the optional reads are left to the expression")
+		router = authzHandler(mw, mw.RequirePolicy(`claims?.tenant == nil`))
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+	}
+}
+
+func Test_InvalidPolicy(t *testing.T) {
+	t.Logf("Given an expression which does not compile")
+	{
+		_, err := NewPolicy("claims.tenant ==")
+		assert.NotNil(t, err)
+		assert.Panics(t, func() { newTestMiddleware().RequirePolicy("claims.tenant ==") })
+	}
+
+	t.Logf("Given an expression naming an undefined variable")
+	{
+		_, err := NewPolicy("claim.tenant == 'acme'")
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,243 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// userPools the index of the trusted user pools by issuer and by tenant, built from the UserPools and the Tenants on
+// first use, then updated by AddUserPool and RemoveUserPool
+type userPools struct {
+	mu       sync.RWMutex
+	indexed  bool
+	byIssuer map[string]*AuthMiddleware
+	byTenant map[string]*AuthMiddleware
+}
+
+// issuer the issuer of the tokens of the middleware, its Iss or the one of its user pool
+func (mw *AuthMiddleware) issuer() string {
+	if mw.Iss != "" {
+		return mw.Iss
+	}
+	return UserPool{Region: mw.Region, UserPoolID: mw.UserPoolID}.Iss()
+}
+
+// poolIndex returns the index of the user pools of the middleware, building it from the UserPools and the Tenants
+// on first use. The callers read it under its lock.
+func (mw *AuthMiddleware) poolIndex() *userPools {
+	shared := mw.shared()
+	pools := &shared.pools
+	pools.mu.RLock()
+	indexed := pools.indexed
+	pools.mu.RUnlock()
+	if indexed {
+		return pools
+	}
+	pools.mu.Lock()
+	if !pools.indexed {
+		pools.byIssuer = make(map[string]*AuthMiddleware, len(shared.UserPools)+len(shared.Tenants))
+		for _, pool := range shared.trustedPools() {
+			pools.byIssuer[pool.issuer()] = pool
+		}
+		pools.byTenant = make(map[string]*AuthMiddleware, len(shared.Tenants))
+		for tenant, pool := range shared.Tenants {
+			pools.byTenant[tenant] = pool
+		}
+		pools.indexed = true
+	}
+	pools.mu.Unlock()
+	return pools
+}
+
+// resolvePool returns the middleware of the user pool of the token as per its unverified iss claim, the middleware
+// itself when it serves a single user pool. The tokens of the issuers which are not trusted are rejected with an
+// ErrBadIssuer.
+func (mw *AuthMiddleware) resolvePool(tokenStr string) (*AuthMiddleware, error) {
+	pools := mw.poolIndex()
+	pools.mu.RLock()
+	defer pools.mu.RUnlock()
+	if len(pools.byIssuer) == 0 && !mw.multiPool() {
+		return mw, nil
+	}
+	iss, err := unverifiedIssuer(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	pool, ok := pools.byIssuer[iss]
+	if !ok {
+		return nil, tokenError(ErrBadIssuer, fmt.Errorf("the issuer %q is not one of the trusted user pools", iss))
+	}
+	return pool, nil
+}
+
+// issuerPool returns the middleware of the user pool of the given issuer, the middleware itself when it serves a
+// single user pool, nil when the issuer is not trusted
+func (mw *AuthMiddleware) issuerPool(iss string) *AuthMiddleware {
+	pools := mw.poolIndex()
+	pools.mu.RLock()
+	defer pools.mu.RUnlock()
+	if len(pools.byIssuer) == 0 && !mw.multiPool() {
+		return mw
+	}
+	return pools.byIssuer[iss]
+}
+
+// checkTrusted rejects with an ErrBadIssuer the tokens validated before their user pool was removed, e.g. found in
+// the TokenCache or resumed from a session
+func (mw *AuthMiddleware) checkTrusted(claims jwtgo.MapClaims) error {
+	pools := mw.poolIndex()
+	pools.mu.RLock()
+	defer pools.mu.RUnlock()
+	if len(pools.byIssuer) == 0 && !mw.multiPool() {
+		return nil
+	}
+	iss, _ := claims["iss"].(string)
+	if _, ok := pools.byIssuer[iss]; !ok {
+		return tokenError(ErrBadIssuer, fmt.Errorf("the issuer %q is not one of the trusted user pools", iss))
+	}
+	return nil
+}
+
+// AddUserPool trusts the user pool of the given middleware at runtime, e.g. to onboard a tenant without redeploying,
+// and routes the given tenants to it. Its json web key set is downloaded within the deadline of ctx unless its JWK
+// or Keys are set. The pool replaces the one of the same issuer, if any, along with its tenants. It is safe for
+// concurrent use with the validation of the tokens.
+func (mw *AuthMiddleware) AddUserPool(ctx context.Context, pool *AuthMiddleware, tenants ...string) error {
+	if err := pool.Validate(); err != nil {
+		return fmt.Errorf("invalid user pool configuration: %w", err)
+	}
+	if pool.Keys == nil && pool.KeyCount() == 0 {
+		if err := pool.RefreshJWKContext(ctx); err != nil {
+			return fmt.Errorf("user pool %s: %w", pool.UserPoolID, err)
+		}
+	}
+	pools := mw.poolIndex()
+	pools.mu.Lock()
+	previous, replaced := pools.byIssuer[pool.issuer()]
+	remapped := map[string]*AuthMiddleware{}
+	for tenant, tenantPool := range pools.byTenant {
+		if replaced && tenantPool == previous {
+			remapped[tenant] = pool
+		}
+	}
+	for _, tenant := range tenants {
+		remapped[tenant] = pool
+	}
+	if err := mw.checkSharedPools(remapped); err != nil {
+		pools.mu.Unlock()
+		return err
+	}
+	for tenant := range remapped {
+		pools.byTenant[tenant] = pool
+	}
+	pools.byIssuer[pool.issuer()] = pool
+	pools.mu.Unlock()
+
+	// the tokens of the pool may have been rejected as issued by an untrusted issuer
+	if mw.RejectionCache != nil {
+		mw.RejectionCache.Purge()
+	}
+	mw.log().Info("Added the user pool", "issuer", pool.issuer(), "tenants", tenants)
+	return nil
+}
+
+// RemoveUserPool stops trusting the user pool of the given issuer at runtime, along with its tenants, e.g. to
+// offboard a tenant. Its tokens are rejected from then on, cached or resumed from a session included. It reports
+// whether the user pool was trusted.
+func (mw *AuthMiddleware) RemoveUserPool(iss string) bool {
+	pools := mw.poolIndex()
+	pools.mu.Lock()
+	defer pools.mu.Unlock()
+	pool, ok := pools.byIssuer[iss]
+	if !ok {
+		return false
+	}
+	delete(pools.byIssuer, iss)
+	for tenant, tenantPool := range pools.byTenant {
+		if tenantPool == pool {
+			delete(pools.byTenant, tenant)
+		}
+	}
+	mw.log().Info("Removed the user pool", "issuer", iss)
+	return true
+}
+
+// unverifiedIssuer the iss claim of the token, read before its signature is verified to pick the keys verifying it
+func unverifiedIssuer(tokenStr string) (string, error) {
+	segments := strings.Split(tokenStr, ".")
+	if len(segments) != 3 {
+		return "", tokenError(ErrMalformedToken, errors.New("token contains an invalid number of segments"))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segments[1], "="))
+	if err != nil {
+		return "", tokenError(ErrMalformedToken, fmt.Errorf("decoding the token claims: %w", err))
+	}
+	var claims struct {
+		Iss *string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", tokenError(ErrMalformedToken, fmt.Errorf("decoding the token claims: %w", err))
+	}
+	if claims.Iss == nil {
+		return "", ErrMissingIssuer
+	}
+	return *claims.Iss, nil
+}
+
+// trustedPools the middlewares of the UserPools and of the Tenants, each one once, the ones of the tenants in the
+// order of their IDs
+func (mw *AuthMiddleware) trustedPools() []*AuthMiddleware {
+	pools := append([]*AuthMiddleware{}, mw.UserPools...)
+	tenants := make([]string, 0, len(mw.Tenants))
+	for tenant := range mw.Tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		if pool := mw.Tenants[tenant]; !slices.Contains(pools, pool) {
+			pools = append(pools, pool)
+		}
+	}
+	return pools
+}
+
+// multiPool whether the middleware is configured to serve several user pools, possibly added at runtime to route
+// the tenants to them
+func (mw *AuthMiddleware) multiPool() bool {
+	return len(mw.UserPools) > 0 || len(mw.Tenants) > 0 || mw.TenantResolver != nil || mw.Discovery != nil
+}
+
+// validatePools checks the configuration of every user pool, that no two pools share an issuer, and the discovery
+func (mw *AuthMiddleware) validatePools() error {
+	var errs []error
+	issuers := make(map[string]bool, len(mw.UserPools))
+	for i, pool := range mw.trustedPools() {
+		if pool == nil {
+			errs = append(errs, fmt.Errorf("the user pool %d is nil", i))
+			continue
+		}
+		if err := pool.Validate(); err != nil {
+			name := pool.UserPoolID
+			if name == "" {
+				name = fmt.Sprint(i)
+			}
+			errs = append(errs, fmt.Errorf("user pool %s: %w", name, err))
+		}
+		if issuers[pool.issuer()] {
+			errs = append(errs, fmt.Errorf("the issuer %s is the one of several user pools", pool.issuer()))
+		}
+		issuers[pool.issuer()] = true
+	}
+	if mw.Discovery != nil && mw.Discovery.Lister == nil {
+		errs = append(errs, errors.New("the user pool discovery requires a lister"))
+	}
+	return errors.Join(errs...)
+}
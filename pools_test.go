@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+const otherUserPoolID = "eu-west-2_otherpool"
+
+var otherKey = mustGenerateKey()
+
+// otherPool the middleware of a second user pool, with its own key
+func otherPool() *AuthMiddleware {
+	return &AuthMiddleware{Region: TestRegion, UserPoolID: otherUserPoolID, JWK: map[string]JWKKey{
+		"other-kid": {
+			Alg: "RS256",
+			Kid: "other-kid",
+			Kty: "RSA",
+			Use: "sig",
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(otherKey.E)).Bytes()),
+			N:   base64.RawURLEncoding.EncodeToString(otherKey.N.Bytes()),
+		},
+	}}
+}
+
+// otherPoolToken signs the given claims, issued by the second user pool, with its key
+func otherPoolToken(claims jwtgo.MapClaims) string {
+	claims["iss"] = UserPool{Region: TestRegion, UserPoolID: otherUserPoolID}.Iss()
+	token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims)
+	token.Header["kid"] = "other-kid"
+	signed, err := token.SignedString(otherKey)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func Test_UserPools(t *testing.T) {
+	t.Logf("Given a middleware serving two user pools")
+	{
+		mw := &AuthMiddleware{UserPools: []*AuthMiddleware{newTestMiddleware(), otherPool()}}
+		assert.NoError(t, mw.Validate(), "the region and user pool of the middleware are optional")
+		router := authzHandler(mw)
+
+		t.Logf("Then the tokens of both pools are accepted, each verified with the keys of its pool")
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", otherPoolToken(testClaims())).Code)
+
+		t.Logf("And a token claiming a pool is not verified with the keys of another one")
+		claims := testClaims()
+		claims["iss"] = UserPool{Region: TestRegion, UserPoolID: otherUserPoolID}.Iss()
+		_, err := mw.validateToken(signToken(claims), mw.log())
+		assert.ErrorIs(t, err, ErrUnknownKeyID)
+
+		t.Logf("And the tokens of untrusted issuers or without issuer are rejected")
+		claims["iss"] = UserPool{Region: TestRegion, UserPoolID: "eu-west-2_unknown"}.Iss()
+		_, err = mw.validateToken(signToken(claims), mw.log())
+		assert.ErrorIs(t, err, ErrBadIssuer)
+		delete(claims, "iss")
+		_, err = mw.validateToken(signToken(claims), mw.log())
+		assert.ErrorIs(t, err, ErrMissingIssuer)
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", signToken(claims)).Code)
+	}
+
+	t.Logf("Given a strict middleware serving two user pools")
+	{
+		mw := &AuthMiddleware{UserPools: []*AuthMiddleware{newTestMiddleware(), otherPool()}, StrictMode: true}
+		mw.ClaimsValidators = []ClaimsValidator{ClaimsValidatorFunc(func(claims jwtgo.MapClaims) error {
+			if claims["username"] == "blocked" {
+				return errors.New("blocked user")
+			}
+			return nil
+		})}
+
+		t.Logf("Then its StrictMode and ClaimsValidators apply to the tokens of the pools")
+		claims := testClaims()
+		delete(claims, "iat")
+		_, err := mw.validateToken(otherPoolToken(claims), mw.log())
+		assert.ErrorIs(t, err, ErrInvalidClaims)
+		claims = testClaims()
+		claims["username"] = "blocked"
+		_, err = mw.validateToken(otherPoolToken(claims), mw.log())
+		assert.ErrorIs(t, err, ErrInvalidClaims)
+		_, err = mw.validateToken(otherPoolToken(testClaims()), mw.log())
+		assert.NoError(t, err)
+
+		t.Logf("And its MachineToMachine too")
+		mw.MachineToMachine = true
+		_, err = mw.validateToken(otherPoolToken(testClaims()), mw.log())
+		assert.ErrorIs(t, err, ErrWrongTokenUse)
+	}
+
+	t.Logf("Given user pools sharing an issuer or misconfigured")
+	{
+		mw := &AuthMiddleware{UserPools: []*AuthMiddleware{newTestMiddleware(), newTestMiddleware(), {Region: TestRegion}}}
+		err := mw.Validate()
+		assert.ErrorContains(t, err, "the issuer "+newTestMiddleware().issuer()+" is the one of several user pools")
+		assert.ErrorContains(t, err, "user pool 2: the user pool ID is required")
+	}
+}
+
+func Test_AddAndRemoveUserPools(t *testing.T) {
+	t.Logf("Given a middleware routing the tenants to their user pool, onboarded at runtime")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+		mw := &AuthMiddleware{TenantResolver: TenantFromHeader(""), TokenCache: NewTokenCache(10), RejectionCache: NewRejectionCache(time.Minute, 10)}
+		assert.NoError(t, mw.Validate())
+		router := authzHandler(mw)
+		acmeToken, globexToken := signToken(testClaims()), otherPoolToken(testClaims())
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/orders", "acme", acmeToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/orders", "", acmeToken).Code)
+
+		t.Logf("Then the tenants are served once their user pool is added, its key set being downloaded")
+		assert.NoError(t, mw.AddUserPool(context.Background(), &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID}, "acme"))
+		assert.EqualValues(t, 1, downloads)
+		assert.NoError(t, mw.AddUserPool(context.Background(), otherPool(), "globex", "initech"))
+		assert.EqualValues(t, 1, downloads, "the keys of the pool are set")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", acmeToken).Code)
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "", acmeToken).Code)
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "initech", globexToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/orders", "acme", globexToken).Code)
+
+		t.Logf("And the tenants and tokens of a removed user pool are rejected, the cached ones included")
+		assert.True(t, mw.RemoveUserPool(UserPool{Region: TestRegion, UserPoolID: TestUserPoolID}.Iss()))
+		assert.False(t, mw.RemoveUserPool(UserPool{Region: TestRegion, UserPoolID: TestUserPoolID}.Iss()))
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/orders", "acme", acmeToken).Code)
+		_, err := mw.validateToken(acmeToken, mw.log())
+		assert.ErrorIs(t, err, ErrBadIssuer)
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "globex", globexToken).Code)
+
+		t.Logf("And the invalid user pools are not added")
+		assert.ErrorContains(t, mw.AddUserPool(context.Background(), &AuthMiddleware{Region: "europe"}), "invalid user pool configuration")
+	}
+
+	t.Logf("Given user pools added and removed while tokens are validated")
+	{
+		mw := &AuthMiddleware{UserPools: []*AuthMiddleware{newTestMiddleware()}}
+		token := signToken(testClaims())
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					_, err := mw.validateToken(token, mw.log())
+					assert.NoError(t, err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					assert.NoError(t, mw.AddUserPool(context.Background(), otherPool(), "globex"))
+					mw.RemoveUserPool(otherPool().issuer())
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,52 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxTokenLength the length of the longest token accepted, well above the few kilobytes of the Cognito tokens
+// of the users belonging to many groups
+const MaxTokenLength = 16 * 1024
+
+// precheck rejects the obviously malformed tokens before the JWT library and the RSA math are involved: the
+// token must be three non empty base64url segments of sane length, the first one a JSON header naming its
+// algorithm
+func precheck(tokenStr string) error {
+	if len(tokenStr) > MaxTokenLength {
+		return tokenError(ErrMalformedToken, fmt.Errorf("token longer than %d bytes", MaxTokenLength))
+	}
+	headerEnd := strings.IndexByte(tokenStr, '.')
+	if headerEnd <= 0 {
+		return tokenError(ErrMalformedToken, errors.New("token contains an invalid number of segments"))
+	}
+	payloadEnd := strings.IndexByte(tokenStr[headerEnd+1:], '.') + headerEnd + 1
+	if payloadEnd <= headerEnd+1 || payloadEnd == len(tokenStr)-1 || strings.IndexByte(tokenStr[payloadEnd+1:], '.') >= 0 {
+		return tokenError(ErrMalformedToken, errors.New("token contains an invalid number of segments"))
+	}
+	for i := 0; i < len(tokenStr); i++ {
+		if !isBase64URL(tokenStr[i]) && tokenStr[i] != '.' {
+			return tokenError(ErrMalformedToken, fmt.Errorf("invalid character %q in token", tokenStr[i]))
+		}
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(tokenStr[:headerEnd], "="))
+	if err != nil {
+		return tokenError(ErrMalformedToken, fmt.Errorf("decoding the token header: %w", err))
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(decoded, &header); err != nil || header.Alg == "" {
+		return tokenError(ErrMalformedToken, errors.New("token header is not a JSON object naming its alg"))
+	}
+	return nil
+}
+
+// isBase64URL whether the byte belongs to the base64url alphabet, padding included
+func isBase64URL(b byte) bool {
+	return 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '='
+}
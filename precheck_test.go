@@ -0,0 +1,36 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_Precheck(t *testing.T) {
+	t.Logf("Given tokens which are obviously malformed")
+	{
+		valid := signToken(testClaims())
+		segments := strings.Split(valid, ".")
+		noAlg := base64.RawURLEncoding.EncodeToString([]byte(`{"kid":"1"}`))
+		for _, token := range []string{
+			"garbage",
+			".payload.signature",
+			segments[0] + ".." + segments[2],
+			segments[0] + "." + segments[1] + ".",
+			valid + ".extra",
+			segments[0] + "." + segments[1] + ".sig+nature",
+			"bm90IGpzb24." + segments[1] + "." + segments[2],
+			noAlg + "." + segments[1] + "." + segments[2],
+			segments[0] + "." + strings.Repeat("a", MaxTokenLength) + "." + segments[2],
+		} {
+			err := precheck(token)
+			assert.True(t, errors.Is(err, ErrMalformedToken), token)
+		}
+		assert.NoError(t, precheck(valid))
+
+		_, err := newTestMiddleware().ValidateToken("garbage")
+		assert.True(t, errors.Is(err, ErrMalformedToken))
+	}
+}
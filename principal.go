@@ -0,0 +1,116 @@
+package jwt
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+)
+
+const (
+
+	// TokenKey the gin context key holding the parsed *jwtgo.Token
+	TokenKey = "JWT_TOKEN"
+
+	// PrincipalKey the gin context key holding the authenticated Principal
+	PrincipalKey = "JWT_PRINCIPAL"
+
+	// MappedClaimsKey the gin context key holding the result of the ClaimsMapper
+	MappedClaimsKey = "JWT_MAPPED_CLAIMS"
+
+	// TokenStringKey the gin context key holding the original compact token string, handy to
+	// forward the token to downstream services (AppSync, API Gateway...)
+	TokenStringKey = "JWT_TOKEN_STRING"
+
+	// TokenExpiryKey the gin context key holding the expiry time.Time of the token
+	TokenExpiryKey = "JWT_TOKEN_EXPIRY"
+
+	// TenantKey the gin context key holding the *Tenant of the request, see GetTenant
+	TenantKey = "JWT_TENANT"
+
+	// RequestIDKey the gin context key holding the correlation ID of the request
+	RequestIDKey = "JWT_REQUEST_ID"
+
+	// ErrorIDKey the gin context key holding the ID of the failure of a rejected request
+	ErrorIDKey = "JWT_ERROR_ID"
+
+	// ErrorKey the gin context key holding the error of a rejected request
+	ErrorKey = "JWT_ERROR"
+
+	// ErrorCodeKey the gin context key holding the RFC 6750 error code of a rejected request
+	ErrorCodeKey = "JWT_ERROR_CODE"
+
+	// ErrorDetailKey the gin context key holding the detail of a rejected request, e.g. the missing scopes
+	ErrorDetailKey = "JWT_ERROR_DETAIL"
+)
+
+// Principal the authenticated caller of a request. Application code and the authorization
+// helpers are written against this abstraction rather than the raw token claims.
+type Principal interface {
+
+	// ID the unique identifier of the caller: the sub claim, the client_id of the machine to machine tokens
+	// lacking one
+	ID() string
+
+	// Groups the cognito:groups the caller belongs to
+	Groups() []string
+
+	// Scopes the OAuth scopes granted to the token
+	Scopes() []string
+
+	// Claims the raw token claims
+	Claims() jwtgo.MapClaims
+}
+
+// cognitoPrincipal Principal backed by the claims of a Cognito issued token
+type cognitoPrincipal struct {
+	claims jwtgo.MapClaims
+}
+
+// NewPrincipal creates a Principal from the given token claims
+func NewPrincipal(claims jwtgo.MapClaims) Principal {
+	return &cognitoPrincipal{claims: claims}
+}
+
+func (p *cognitoPrincipal) ID() string {
+	sub, _ := p.claims["sub"].(string)
+	if sub == "" && IsMachine(p.claims) {
+		sub, _ = p.claims["client_id"].(string)
+	}
+	return sub
+}
+
+func (p *cognitoPrincipal) Groups() []string {
+	return Groups(p.claims)
+}
+
+func (p *cognitoPrincipal) Scopes() []string {
+	return Scopes(p.claims)
+}
+
+func (p *cognitoPrincipal) Claims() jwtgo.MapClaims {
+	return p.claims
+}
+
+// GetPrincipal returns the Principal stored in the context by the middleware
+func GetPrincipal(c *gin.Context) (Principal, bool) {
+	value, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx holding the principal. The framework adapters store the
+// principal this way, so that code shared by REST and RPC services finds it whatever the transport.
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored in ctx by ContextWithPrincipal
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_PrincipalIsStoredInContext(t *testing.T) {
+	t.Logf("Given a valid token with groups and scopes")
+	{
+		claims := testClaims()
+		claims["cognito:groups"] = []string{"admins", "ops"}
+		claims["scope"] = "orders/read orders/write"
+
+		var principal Principal
+		router := gin.New()
+		router.GET("/me", newTestMiddleware().MiddlewareFunc(), func(c *gin.Context) {
+			principal, _ = GetPrincipal(c)
+			c.Status(http.StatusOK)
+		})
+
+		response := performRequest(router, "GET", "/me", signToken(claims))
+		assert.Equal(t, http.StatusOK, response.Code)
+		if assert.NotNil(t, principal) {
+			assert.Equal(t, "user-123", principal.ID())
+			assert.Equal(t, []string{"admins", "ops"}, principal.Groups())
+			assert.Equal(t, []string{"orders/read", "orders/write"}, principal.Scopes())
+			assert.Equal(t, "jdoe", principal.Claims()["username"])
+		}
+	}
+}
+
+func Test_GetPrincipalWithoutMiddleware(t *testing.T) {
+	t.Logf("Given the middleware did not run")
+	{
+		_, ok := GetPrincipal(&gin.Context{})
+		assert.False(t, ok)
+	}
+}
+
+func Test_ClaimsMapperOutputIsStoredInContext(t *testing.T) {
+	t.Logf("Given a claims mapper deriving the application roles")
+	{
+		middleware := newTestMiddleware()
+		middleware.ClaimsMapper = func(claims jwtgo.MapClaims) (interface{}, error) {
+			return []string{"role:" + claims["username"].(string)}, nil
+		}
+
+		var mapped interface{}
+		router := gin.New()
+		router.GET("/me", middleware.MiddlewareFunc(), func(c *gin.Context) {
+			mapped, _ = c.Get(MappedClaimsKey)
+			c.Status(http.StatusOK)
+		})
+
+		response := performRequest(router, "GET", "/me", signToken(testClaims()))
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, []string{"role:jdoe"}, mapped)
+	}
+}
+
+func Test_ClaimsMapperErrorResultsInForbiddenError(t *testing.T) {
+	t.Logf("Given a claims mapper rejecting the claims")
+	{
+		middleware := newTestMiddleware()
+		middleware.ClaimsMapper = func(claims jwtgo.MapClaims) (interface{}, error) {
+			return nil, errors.New("unknown tenant")
+		}
+
+		response := performRequest(ginHandler(middleware), "GET", "/auth/list", signToken(testClaims()))
+		assert.Equal(t, http.StatusForbidden, response.Code)
+		assert.Empty(t, response.Header().Get(AuthenticateHeader))
+		assert.Contains(t, response.Body.String(), "unknown tenant")
+	}
+}
+
+func Test_RawTokenAndExpiryAreStoredInContext(t *testing.T) {
+	t.Logf("Given a valid token")
+	{
+		claims := testClaims()
+		tokenStr := signToken(claims)
+
+		var raw, expiry interface{}
+		router := gin.New()
+		router.GET("/me", newTestMiddleware().MiddlewareFunc(), func(c *gin.Context) {
+			raw, _ = c.Get(TokenStringKey)
+			expiry, _ = c.Get(TokenExpiryKey)
+			c.Status(http.StatusOK)
+		})
+
+		response := performRequest(router, "GET", "/me", tokenStr)
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, tokenStr, raw)
+		assert.Equal(t, time.Unix(claims["exp"].(int64), 0), expiry)
+	}
+}
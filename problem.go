@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ProblemContentType the media type of the RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem an RFC 7807 problem details document
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Error the RFC 6750 error code, e.g. insufficient_scope
+	Error string `json:"error,omitempty"`
+
+	// ErrorDetail the detail recorded by the authorization helpers, e.g. the missing scopes
+	ErrorDetail interface{} `json:"error_detail,omitempty"`
+
+	// RequestID the correlation ID of the request, see AuthMiddleware.RequestID
+	RequestID string `json:"request_id,omitempty"`
+
+	// ErrorID the ID of the failure, also found in the logs and the audit event of the request
+	ErrorID string `json:"error_id,omitempty"`
+}
+
+// ProblemResponse renders the failure as an application/problem+json document. It is the default
+// Unauthorized and Forbidden func when ProblemJSON is set.
+func ProblemResponse(c *gin.Context, code int, message string) {
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(code),
+		Status:    code,
+		Detail:    message,
+		Instance:  c.Request.URL.Path,
+		Error:     c.GetString(ErrorCodeKey),
+		RequestID: c.GetString(RequestIDKey),
+		ErrorID:   c.GetString(ErrorIDKey),
+	}
+	if detail, ok := c.Get(ErrorDetailKey); ok {
+		problem.ErrorDetail = detail
+	}
+	c.Header("Content-Type", ProblemContentType)
+	c.JSON(code, problem)
+}
+
+// defaultResponse the renderer used when no Unauthorized or Forbidden func is set
+func (mw *AuthMiddleware) defaultResponse() func(*gin.Context, int, string) {
+	if mw.ProblemJSON {
+		return ProblemResponse
+	}
+	return errorResponse
+}
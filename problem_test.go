@@ -0,0 +1,33 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_ProblemJSON(t *testing.T) {
+	t.Logf("Given a middleware rendering the failures as problem details")
+	{
+		mw := newTestMiddleware()
+		mw.ProblemJSON = true
+		router := authzHandler(mw, mw.RequireScopes("orders/write"))
+
+		w := performRequest(router, "GET", "/orders", "not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+		var problem Problem
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "about:blank", problem.Type)
+		assert.Equal(t, "Unauthorized", problem.Title)
+		assert.Equal(t, http.StatusUnauthorized, problem.Status)
+		assert.Equal(t, "/orders", problem.Instance)
+		assert.NotEmpty(t, problem.Detail)
+
+		w = performRequest(router, "GET", "/orders", signToken(testClaims()))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Forbidden","status":403,"detail":"requires scopes: orders/write",
+			"instance":"/orders","error":"insufficient_scope","error_detail":["orders/write"]}`, withoutErrorID(t, w.Body.String()))
+	}
+}
@@ -0,0 +1,64 @@
+// Package promjwt exposes the outcome of the token validations of the middleware as Prometheus metrics.
+//
+//	collector := promjwt.NewCollector(mw)
+//	mw.Metrics = collector
+//	prometheus.MustRegister(collector)
+package promjwt
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// Collector prometheus.Collector and jwt.Metrics counting the validations by outcome and reason, timing
+// them and reporting the number of json web keys of the middleware
+type Collector struct {
+	mw          *jwt.AuthMiddleware
+	validations *prometheus.CounterVec
+	latency     prometheus.Histogram
+	keys        *prometheus.Desc
+}
+
+// NewCollector creates a collector for the given middleware, to be set as its Metrics
+func NewCollector(mw *jwt.AuthMiddleware) *Collector {
+	return &Collector{
+		mw: mw,
+		validations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cognito_jwt_validations_total",
+			Help: "Number of token validations by outcome (success, failure) and failure reason.",
+		}, []string{"outcome", "reason"}),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "cognito_jwt_validation_duration_seconds",
+			Help:    "Time taken to validate the tokens.",
+			Buckets: []float64{.00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05},
+		}),
+		keys: prometheus.NewDesc("cognito_jwt_jwks_keys", "Number of json web keys known to the middleware.", nil, nil),
+	}
+}
+
+// ObserveValidation implements jwt.Metrics
+func (c *Collector) ObserveValidation(reason string, latency time.Duration) {
+	if reason == "" {
+		c.validations.WithLabelValues("success", "").Inc()
+	} else {
+		c.validations.WithLabelValues("failure", reason).Inc()
+	}
+	if latency > 0 {
+		c.latency.Observe(latency.Seconds())
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.validations.Describe(ch)
+	c.latency.Describe(ch)
+	ch <- c.keys
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.validations.Collect(ch)
+	c.latency.Collect(ch)
+	ch <- prometheus.MustNewConstMetric(c.keys, prometheus.GaugeValue, float64(c.mw.KeyCount()))
+}
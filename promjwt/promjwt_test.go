@@ -0,0 +1,50 @@
+package promjwt
+
+import (
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Collector(t *testing.T) {
+	t.Logf("Given a middleware reporting to a prometheus collector")
+	{
+		issuer, err := jwttest.NewIssuer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mw := issuer.Middleware()
+		mw.MiddlewareInit()
+		collector := NewCollector(mw)
+		mw.Metrics = collector
+		registry := prometheus.NewPedanticRegistry()
+		registry.MustRegister(collector)
+
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+		expiredClaims := issuer.Claims("user-123")
+		expiredClaims["exp"] = time.Now().Add(-time.Minute).Unix()
+		expired, _ := issuer.Sign(expiredClaims)
+
+		mw.ValidateToken(token)
+		mw.ValidateToken(expired)
+		mw.ExtractToken(http.Header{}.Get)
+
+		expected := `
+# HELP cognito_jwt_jwks_keys Number of json web keys known to the middleware.
+# TYPE cognito_jwt_jwks_keys gauge
+cognito_jwt_jwks_keys 1
+# HELP cognito_jwt_validations_total Number of token validations by outcome (success, failure) and failure reason.
+# TYPE cognito_jwt_validations_total counter
+cognito_jwt_validations_total{outcome="failure",reason="expired"} 1
+cognito_jwt_validations_total{outcome="failure",reason="missing_token"} 1
+cognito_jwt_validations_total{outcome="success",reason=""} 1
+`
+		assert.Nil(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "cognito_jwt_validations_total", "cognito_jwt_jwks_keys"))
+		assert.Equal(t, 1, testutil.CollectAndCount(collector, "cognito_jwt_validation_duration_seconds"))
+	}
+}
@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QuotaStore counts the requests of the principals of the tenants over fixed windows, see Quota
+type QuotaStore interface {
+
+	// Take counts a request of key, starting a window of the given length on its first request, and returns the
+	// requests of key within the window, this one included, and the time left until the window ends
+	Take(key string, window time.Duration) (int, time.Duration, error)
+}
+
+// Quota the requests allowed per principal of a tenant within a window, set in the TenantPolicy of the tenant or of
+// its user pool, so that a noisy tenant cannot starve the others. The requests over the quota are answered with a
+// 429, counted by the QuotaStore of the middleware. The requests are allowed when the store fails.
+type Quota struct {
+
+	// MaxRequests the requests allowed per principal within the Window
+	MaxRequests int
+
+	// Window the length of the window over which the requests are counted
+	Window time.Duration
+}
+
+// validate checks that the limits of the quota are positive
+func (q *Quota) validate() error {
+	if q.MaxRequests <= 0 || q.Window <= 0 {
+		return fmt.Errorf("the quota of %d requests per %v is not positive", q.MaxRequests, q.Window)
+	}
+	return nil
+}
+
+// quotaKey the key of the requests of the principal, scoped by its tenant, or by the issuer of its token when the
+// request has no tenant
+func quotaKey(tenant *Tenant, principal Principal) string {
+	if tenant != nil && tenant.ID != "" {
+		return "tenant:" + tenant.ID + ":" + principal.ID()
+	}
+	iss, _ := principal.Claims()["iss"].(string)
+	return "pool:" + iss + ":" + principal.ID()
+}
+
+// takeQuota counts the request of the principal against the Quota of the policy of its tenant, failing with an
+// ErrQuotaExceeded and the time left until the window ends once the quota is exceeded
+func (mw *AuthMiddleware) takeQuota(logger Logger, principal Principal, tenant *Tenant, policy *TenantPolicy) (time.Duration, error) {
+	if mw.QuotaStore == nil || policy == nil || policy.Quota == nil {
+		return 0, nil
+	}
+	key := quotaKey(tenant, principal)
+	requests, retryAfter, err := mw.QuotaStore.Take(key, policy.Quota.Window)
+	if err != nil {
+		logger.Warn("Failed to count the requests of the principal", "quota", key, "error", err)
+		mw.reportError(err, map[string]string{"operation": "quota"})
+		return 0, nil
+	}
+	if requests <= policy.Quota.MaxRequests {
+		return 0, nil
+	}
+	err = fmt.Errorf("%w: %d requests within %v", ErrQuotaExceeded, requests, policy.Quota.Window)
+	if mw.sampleFailure(failureReason(err)) {
+		logger.Warn("Throttled the requests of the principal", "quota", key, "error_class", failureReason(err), "error", err)
+	}
+	return retryAfter, err
+}
+
+// checkQuota answers the request with a 429 when its principal exceeded the Quota of the policy of its tenant
+func (mw *AuthMiddleware) checkQuota(c *gin.Context, logger Logger, principal Principal, tenant *Tenant, policy *TenantPolicy) bool {
+	retryAfter, err := mw.takeQuota(logger, principal, tenant, policy)
+	if err == nil {
+		return true
+	}
+	mw.audit(c, principal, AuditQuotaExceeded, failureReason(err))
+	c.Abort()
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	mw.respond(c, http.StatusTooManyRequests, err, mw.Forbidden)
+	return false
+}
+
+// MemoryQuotaStore an in memory QuotaStore, the requests are not shared between instances. The windows which ended
+// are forgotten as the store grows. It is safe for concurrent use.
+type MemoryQuotaStore struct {
+	windows *MemoryFailureStore
+}
+
+// NewMemoryQuotaStore creates an empty MemoryQuotaStore
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{windows: NewMemoryFailureStore()}
+}
+
+// Take counts the request in the window of key, starting a new one when it ended
+func (s *MemoryQuotaStore) Take(key string, length time.Duration) (int, time.Duration, error) {
+	requests, left := s.windows.add(key, length)
+	return requests, left, nil
+}
@@ -0,0 +1,84 @@
+package jwt
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// failingQuotaStore a QuotaStore whose backend is unavailable
+type failingQuotaStore struct{}
+
+func (failingQuotaStore) Take(key string, window time.Duration) (int, time.Duration, error) {
+	return 0, 0, errors.New("connection refused")
+}
+
+func Test_TenantQuotas(t *testing.T) {
+	t.Logf("Given tenants allowed 2 requests per minute and principal")
+	{
+		acme, globex := newTestMiddleware(), otherPool()
+		acme.Policy = &TenantPolicy{Quota: &Quota{MaxRequests: 2, Window: time.Minute}}
+		globex.Policy = &TenantPolicy{Quota: &Quota{MaxRequests: 2, Window: time.Minute}}
+		var events []AuditEvent
+		mw := &AuthMiddleware{
+			TenantResolver: TenantFromHeader(""),
+			Tenants:        map[string]*AuthMiddleware{"acme": acme, "globex": globex},
+			QuotaStore:     NewMemoryQuotaStore(),
+			AuditEvents:    AuditSinkFunc(func(e AuditEvent) { events = append(events, e) }),
+		}
+		assert.NoError(t, mw.Validate())
+		router := authzHandler(mw)
+		acmeToken, globexToken := signToken(testClaims()), otherPoolToken(testClaims())
+
+		t.Logf("Then the requests over the quota are answered with a 429")
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", acmeToken).Code)
+		}
+		w := tenantRequest(router, "/orders", "acme", acmeToken)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		assert.Equal(t, AuditQuotaExceeded, events[len(events)-1].Outcome)
+
+		t.Logf("And the other principals of the tenant and the other tenants are not throttled")
+		other := testClaims()
+		other["sub"] = "user-456"
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", signToken(other)).Code)
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "globex", globexToken).Code)
+
+		t.Logf("And the requests validated by the adapters are counted against the same quota")
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set(TenantHeader, "globex")
+		_, err := mw.ValidateRequest(req, globexToken)
+		assert.NoError(t, err)
+		_, err = mw.ValidateRequest(req, globexToken)
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.Equal(t, http.StatusTooManyRequests, StatusCode(err))
+	}
+
+	t.Logf("Given a quota store failing")
+	{
+		mw := &AuthMiddleware{
+			UserPools:  []*AuthMiddleware{newTestMiddleware()},
+			QuotaStore: failingQuotaStore{},
+		}
+		mw.UserPools[0].Policy = &TenantPolicy{Quota: &Quota{MaxRequests: 1, Window: time.Minute}}
+		router := authzHandler(mw)
+
+		t.Logf("Then the requests are allowed")
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		}
+	}
+
+	t.Logf("Given a quota without limits")
+	{
+		mw := newTestMiddleware()
+		mw.Policy = &TenantPolicy{Quota: &Quota{MaxRequests: 10}}
+
+		t.Logf("Then the configuration is rejected")
+		assert.ErrorContains(t, mw.Validate(), "the quota of 10 requests per 0s is not positive")
+	}
+}
@@ -0,0 +1,233 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FailureStore counts the failed authentications of the clients over fixed windows, see FailureLimiter
+type FailureStore interface {
+
+	// Failures the number of failures of key within its current window, and the time left until the window ends
+	Failures(key string) (int, time.Duration, error)
+
+	// AddFailure records a failure of key, starting a window of the given length on its first failure
+	AddFailure(key string, window time.Duration) error
+}
+
+// FailureLimiter limits the failed validations within a window. As the FailureLimiter of the middleware, it
+// throttles the clients presenting invalid tokens: once a client IP has failed MaxFailures validations within the
+// Window, its requests are answered with a 429 until the window ends. The missing tokens, the timeouts and the
+// internal errors are not counted. As the Lockout of the middleware, it locks out the principals, see
+// lockoutFailures. The requests are allowed when the Store fails.
+type FailureLimiter struct {
+
+	// Store counts the failures, a MemoryFailureStore for a single instance, see the redisjwt package to share them
+	Store FailureStore
+
+	// MaxFailures the failed validations allowed per client IP, or principal, within the Window
+	MaxFailures int
+
+	// Window the length of the window over which the failures are counted
+	Window time.Duration
+}
+
+// NewFailureLimiter creates a FailureLimiter allowing maxFailures failed validations within window
+func NewFailureLimiter(store FailureStore, maxFailures int, window time.Duration) *FailureLimiter {
+	return &FailureLimiter{Store: store, MaxFailures: maxFailures, Window: window}
+}
+
+// valid whether the limiter has a store and positive limits
+func (l *FailureLimiter) valid() bool {
+	return l.Store != nil && l.MaxFailures > 0 && l.Window > 0
+}
+
+// lockoutFailures the failures counted against the principal of the token by the Lockout: the tokens replayed once
+// revoked, whose signature verified. The forged tokens are not, anyone could claim the sub of a user in them, nor the
+// expired tokens, which clients legitimately present before refreshing them.
+var lockoutFailures = []error{
+	ErrTokenRevoked,
+}
+
+// failureKey the key of the failures of the client of the request, its IP as per the trusted proxies of gin
+func failureKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// lockoutKey the key of the failures of the principal of the token, empty when the token names none
+func lockoutKey(token *jwtgo.Token) string {
+	claims, ok := token.Claims.(jwtgo.MapClaims)
+	if !ok {
+		return ""
+	}
+	id := NewPrincipal(claims).ID()
+	if id == "" {
+		return ""
+	}
+	return "principal:" + id
+}
+
+// throttled answers the request with a 429 when its client exceeded the failures allowed by the FailureLimiter
+func (mw *AuthMiddleware) throttled(c *gin.Context, logger Logger) bool {
+	if mw.FailureLimiter == nil {
+		return false
+	}
+	failures, retryAfter, err := mw.FailureLimiter.Store.Failures(failureKey(c))
+	if err != nil {
+		logger.Warn("Failed to read the authentication failures of the client", "client_ip", c.ClientIP(), "error", err)
+		mw.reportError(err, map[string]string{"operation": "failure_limiter"})
+		return false
+	}
+	if failures < mw.FailureLimiter.MaxFailures {
+		return false
+	}
+	err = fmt.Errorf("%w: %d failed authentications from %s", ErrTooManyFailures, failures, c.ClientIP())
+	if mw.sampleFailure(failureReason(err)) {
+		logger.Warn("Throttled the jwt token validation", "client_ip", c.ClientIP(), "error_class", failureReason(err), "error", err)
+	}
+	mw.observe(failureReason(err), 0)
+	mw.audit(c, nil, AuditUnauthenticated, failureReason(err))
+	c.Abort()
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	mw.respond(c, http.StatusTooManyRequests, err, mw.Unauthorized)
+	return true
+}
+
+// recordFailure counts the failed validation of the token of the request against its client and, when the failure
+// is one of the lockoutFailures, against the principal of the token, token being nil when it could not be decoded
+func (mw *AuthMiddleware) recordFailure(c *gin.Context, logger Logger, token *jwtgo.Token, err error) {
+	if errors.Is(err, ErrMissingHeader) || errors.Is(err, ErrInvalidTokenLookup) ||
+		errors.Is(err, ErrValidationTimeout) || errors.Is(err, ErrInternal) {
+		return
+	}
+	if mw.FailureLimiter != nil {
+		if err := mw.FailureLimiter.Store.AddFailure(failureKey(c), mw.FailureLimiter.Window); err != nil {
+			logger.Warn("Failed to record the authentication failure of the client", "client_ip", c.ClientIP(), "error", err)
+			mw.reportError(err, map[string]string{"operation": "failure_limiter"})
+		}
+	}
+	if mw.Lockout == nil || token == nil || !isAny(err, lockoutFailures) {
+		return
+	}
+	key := lockoutKey(token)
+	if key == "" {
+		return
+	}
+	if err := mw.Lockout.Store.AddFailure(key, mw.Lockout.Window); err != nil {
+		logger.Warn("Failed to record the authentication failure of the principal", "principal", key, "error", err)
+		mw.reportError(err, map[string]string{"operation": "lockout"})
+		return
+	}
+	failures, _, storeErr := mw.Lockout.Store.Failures(key)
+	if storeErr == nil && failures == mw.Lockout.MaxFailures {
+		principal := NewPrincipal(token.Claims.(jwtgo.MapClaims))
+		logger.Warn("Locked out the principal", "principal", key, "failures", failures, "window", mw.Lockout.Window)
+		mw.audit(c, principal, AuditLockedOut, failureReason(err))
+	}
+}
+
+// checkLockout rejects the tokens of the principals locked out by the Lockout with an ErrPrincipalLockedOut
+func (mw *AuthMiddleware) checkLockout(logger Logger, token *jwtgo.Token) error {
+	if mw.Lockout == nil {
+		return nil
+	}
+	key := lockoutKey(token)
+	if key == "" {
+		return nil
+	}
+	failures, left, err := mw.Lockout.Store.Failures(key)
+	if err != nil {
+		logger.Warn("Failed to read the authentication failures of the principal", "principal", key, "error", err)
+		mw.reportError(err, map[string]string{"operation": "lockout"})
+		return nil
+	}
+	if failures < mw.Lockout.MaxFailures {
+		return nil
+	}
+	err = fmt.Errorf("%w: %d failed authentications, retry in %v", ErrPrincipalLockedOut, failures, left.Round(time.Second))
+	mw.observe(failureReason(err), 0)
+	if mw.sampleFailure(failureReason(err)) {
+		logger.Warn("Rejected the token of a locked out principal", "principal", key, "error_class", failureReason(err), "error", err)
+	}
+	return err
+}
+
+// isAny whether err is any of the given errors
+func isAny(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryFailureStore an in memory FailureStore, the failures are not shared between instances. The windows which
+// ended are forgotten as the store grows. It is safe for concurrent use.
+type MemoryFailureStore struct {
+	mu      sync.Mutex
+	windows map[string]failureWindow
+	sweepAt int
+}
+
+type failureWindow struct {
+	failures int
+	ends     time.Time
+}
+
+// minFailureSweep the number of windows from which the ended ones are forgotten
+const minFailureSweep = 1024
+
+// NewMemoryFailureStore creates an empty MemoryFailureStore
+func NewMemoryFailureStore() *MemoryFailureStore {
+	return &MemoryFailureStore{windows: map[string]failureWindow{}, sweepAt: minFailureSweep}
+}
+
+// Failures the failures of key within its window, none once the window ended
+func (s *MemoryFailureStore) Failures(key string) (int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	window, ok := s.windows[key]
+	left := time.Until(window.ends)
+	if !ok || left <= 0 {
+		return 0, 0, nil
+	}
+	return window.failures, left, nil
+}
+
+// AddFailure records the failure in the window of key, starting a new one when it ended
+func (s *MemoryFailureStore) AddFailure(key string, length time.Duration) error {
+	s.add(key, length)
+	return nil
+}
+
+// add counts an event in the window of key, starting a new one when it ended, and returns the events of the window
+// and the time left until it ends
+func (s *MemoryFailureStore) add(key string, length time.Duration) (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	window, ok := s.windows[key]
+	if !ok || !now.Before(window.ends) {
+		window = failureWindow{ends: now.Add(length)}
+	}
+	window.failures++
+	s.windows[key] = window
+
+	if len(s.windows) >= s.sweepAt {
+		for key, window := range s.windows {
+			if !now.Before(window.ends) {
+				delete(s.windows, key)
+			}
+		}
+		s.sweepAt = max(2*len(s.windows), minFailureSweep)
+	}
+	return window.failures, window.ends.Sub(now)
+}
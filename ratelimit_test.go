@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"errors"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requestFrom performs a request of the given client IP
+func requestFrom(r http.Handler, ip, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/orders", nil)
+	req.RemoteAddr = ip + ":4321"
+	if token != "" {
+		req.Header.Set(AuthorizationHeader, token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_FailureLimiter(t *testing.T) {
+	t.Logf("Given a middleware allowing two failed validations per client IP and minute")
+	{
+		mw := newTestMiddleware()
+		mw.FailureLimiter = NewFailureLimiter(NewMemoryFailureStore(), 2, time.Minute)
+		router := authzHandler(mw)
+
+		t.Logf("Then the missing tokens are not counted")
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusUnauthorized, requestFrom(router, "192.0.2.1", "").Code)
+		}
+		assert.Equal(t, http.StatusOK, requestFrom(router, "192.0.2.1", signToken(testClaims())).Code)
+
+		t.Logf("And the client is throttled once it presented two invalid tokens")
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusUnauthorized, requestFrom(router, "192.0.2.1", "Bearer garbage").Code)
+		}
+		w := requestFrom(router, "192.0.2.1", signToken(testClaims()))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "too many failed authentications")
+		assert.Equal(t, uint64(1), mw.Stats().Failures["rate_limited"])
+
+		t.Logf("And the other clients are not throttled")
+		assert.Equal(t, http.StatusOK, requestFrom(router, "192.0.2.2", signToken(testClaims())).Code)
+	}
+
+	t.Logf("Given a failure store which fails")
+	{
+		var reported []error
+		mw := newTestMiddleware()
+		mw.FailureLimiter = NewFailureLimiter(failingFailureStore{}, 1, time.Minute)
+		mw.ErrorReporter = ErrorReporterFunc(func(err error, tags map[string]string) {
+			assert.Equal(t, map[string]string{"operation": "failure_limiter"}, tags)
+			reported = append(reported, err)
+		})
+		router := authzHandler(mw)
+
+		t.Logf("Then the requests are allowed")
+		assert.Equal(t, http.StatusUnauthorized, requestFrom(router, "192.0.2.1", "Bearer garbage").Code)
+		assert.Equal(t, http.StatusOK, requestFrom(router, "192.0.2.1", signToken(testClaims())).Code)
+		assert.Len(t, reported, 3)
+	}
+}
+
+// forgeToken returns a token claiming the given claims, with the signature of another token
+func forgeToken(claims jwtgo.MapClaims) string {
+	forged := signToken(claims)
+	other := signToken(jwtgo.MapClaims{"sub": "someone-else"})
+	return forged[:strings.LastIndex(forged, ".")] + other[strings.LastIndex(other, "."):]
+}
+
+func Test_Lockout(t *testing.T) {
+	t.Logf("Given a middleware locking out the principals after two revoked tokens per minute")
+	{
+		var events []AuditEvent
+		mw := newTestMiddleware()
+		mw.Revocations = NewMemoryRevocationStore()
+		mw.Revocations.Revoke("origin-1", time.Now().Add(time.Hour))
+		mw.Lockout = NewFailureLimiter(NewMemoryFailureStore(), 2, time.Minute)
+		mw.AuditEvents = AuditSinkFunc(func(e AuditEvent) { events = append(events, e) })
+		router := authzHandler(mw)
+
+		t.Logf("Then the expired tokens are not counted")
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusUnauthorized, requestFrom(router, "192.0.2.1", signToken(expired)).Code)
+		}
+		assert.Equal(t, http.StatusOK, requestFrom(router, "192.0.2.1", signToken(testClaims())).Code)
+
+		t.Logf("And the tokens forged for the principal do not lock it out")
+		revoked := testClaims()
+		revoked[OriginJTIClaim] = "origin-1"
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusUnauthorized, requestFrom(router, "192.0.2.1", forgeToken(testClaims())).Code)
+			assert.Equal(t, http.StatusUnauthorized, requestFrom(router, "192.0.2.1", forgeToken(revoked)).Code)
+		}
+		assert.Equal(t, http.StatusOK, requestFrom(router, "192.0.2.1", signToken(testClaims())).Code)
+
+		t.Logf("And the principal is locked out once two revoked tokens were replayed, whatever their client IP")
+		assert.Equal(t, http.StatusUnauthorized, requestFrom(router, "192.0.2.1", signToken(revoked)).Code)
+		assert.Equal(t, http.StatusUnauthorized, requestFrom(router, "192.0.2.2", signToken(revoked)).Code)
+		w := requestFrom(router, "192.0.2.3", signToken(testClaims()))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="gin jwt", error="invalid_token", error_description="locked_out"`, w.Header().Get(AuthenticateHeader))
+		assert.Equal(t, uint64(1), mw.Stats().Failures["locked_out"])
+
+		var lockouts []AuditEvent
+		for _, event := range events {
+			if event.Outcome == AuditLockedOut {
+				lockouts = append(lockouts, event)
+			}
+		}
+		assert.Len(t, lockouts, 1)
+		assert.Equal(t, "user-123", lockouts[0].Subject)
+		assert.Equal(t, "192.0.2.2", lockouts[0].IP)
+		assert.Equal(t, "revoked", lockouts[0].Reason)
+
+		t.Logf("And the other principals are not locked out")
+		other := testClaims()
+		other["sub"] = "user-456"
+		assert.Equal(t, http.StatusOK, requestFrom(router, "192.0.2.1", signToken(other)).Code)
+	}
+}
+
+type failingFailureStore struct{}
+
+func (failingFailureStore) Failures(key string) (int, time.Duration, error) {
+	return 0, 0, errors.New("store unavailable")
+}
+
+func (failingFailureStore) AddFailure(key string, window time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+func Test_MemoryFailureStore(t *testing.T) {
+	t.Logf("Given failures recorded over a short window")
+	{
+		store := NewMemoryFailureStore()
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, store.AddFailure("ip:192.0.2.1", 50*time.Millisecond))
+		}
+		failures, left, err := store.Failures("ip:192.0.2.1")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, failures)
+		assert.True(t, left > 0 && left <= 50*time.Millisecond)
+
+		failures, _, _ = store.Failures("ip:192.0.2.2")
+		assert.Equal(t, 0, failures)
+
+		t.Logf("Then the failures are forgotten once the window ended")
+		time.Sleep(60 * time.Millisecond)
+		failures, left, _ = store.Failures("ip:192.0.2.1")
+		assert.Equal(t, 0, failures)
+		assert.Equal(t, time.Duration(0), left)
+
+		assert.NoError(t, store.AddFailure("ip:192.0.2.1", time.Minute))
+		failures, _, _ = store.Failures("ip:192.0.2.1")
+		assert.Equal(t, 1, failures)
+	}
+
+	t.Logf("Given more clients than the sweep threshold")
+	{
+		store := NewMemoryFailureStore()
+		for i := 0; i < minFailureSweep-1; i++ {
+			store.AddFailure(string(rune(i)), time.Nanosecond)
+		}
+		time.Sleep(time.Millisecond)
+		store.AddFailure("ip:192.0.2.1", time.Minute)
+		assert.Len(t, store.windows, 1)
+	}
+}
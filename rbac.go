@@ -0,0 +1,141 @@
+package jwt
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RBAC role based access control: cognito:groups are mapped to roles, roles grant permissions and
+// routes require permissions. Set it on AuthMiddleware.RBAC to have the middleware enforce the
+// route requirements. It is safe for concurrent use.
+type RBAC struct {
+	mu              sync.RWMutex
+	groupRoles      map[string][]string
+	rolePermissions map[string][]string
+	routes          map[string][]string
+}
+
+// NewRBAC creates an empty RBAC
+func NewRBAC() *RBAC {
+	return &RBAC{
+		groupRoles:      make(map[string][]string),
+		rolePermissions: make(map[string][]string),
+		routes:          make(map[string][]string),
+	}
+}
+
+// AddRole declares a role granting the given permissions
+func (r *RBAC) AddRole(role string, permissions ...string) *RBAC {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rolePermissions[role] = append(r.rolePermissions[role], permissions...)
+	return r
+}
+
+// MapGroup maps a cognito group to roles. A group which is not mapped is considered as the role of the same name.
+func (r *RBAC) MapGroup(group string, roles ...string) *RBAC {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.groupRoles[group] = append(r.groupRoles[group], roles...)
+	return r
+}
+
+// Require registers the permissions required by the route, the path being the gin route pattern (e.g. /orders/:id)
+func (r *RBAC) Require(method, path string, permissions ...string) *RBAC {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := routeKey(method, path)
+	r.routes[key] = append(r.routes[key], permissions...)
+	return r
+}
+
+// Permissions returns the permissions granted to the given groups
+func (r *RBAC) Permissions(groups []string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var permissions []string
+	for _, group := range groups {
+		roles, ok := r.groupRoles[group]
+		if !ok {
+			roles = []string{group}
+		}
+		for _, role := range roles {
+			permissions = append(permissions, r.rolePermissions[role]...)
+		}
+	}
+	return normalize(permissions)
+}
+
+// Required returns the permissions required by the route
+func (r *RBAC) Required(method, path string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.routes[routeKey(method, path)]
+}
+
+// Missing returns the permissions required by the route which are not granted to the given groups
+func (r *RBAC) Missing(method, path string, groups []string) []string {
+	required := r.Required(method, path)
+	if len(required) == 0 {
+		return nil
+	}
+	return missing(required, r.Permissions(groups))
+}
+
+// RequirePermissions returns a handler rejecting with 403 the callers which are not granted all
+// the given permissions through the RBAC. It must be chained after MiddlewareFunc.
+func (mw *AuthMiddleware) RequirePermissions(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			mw.unauthorized(c, ErrMissingHeader)
+			return
+		}
+
+		if mw.authorizePermissions(c, principal, permissions) {
+			c.Next()
+		}
+	}
+}
+
+// authorizeRBAC enforces the permissions registered in the RBAC for the current route
+func (mw *AuthMiddleware) authorizeRBAC(c *gin.Context, principal Principal) bool {
+	if mw.RBAC == nil {
+		return true
+	}
+	return mw.authorizePermissions(c, principal, mw.RBAC.Required(c.Request.Method, c.FullPath()))
+}
+
+// authorizePermissions aborts with 403 when the principal is not granted the required permissions
+func (mw *AuthMiddleware) authorizePermissions(c *gin.Context, principal Principal, permissions []string) bool {
+	if len(permissions) == 0 {
+		return true
+	}
+	start := time.Now()
+	groups := mw.effectiveGroups(principal)
+	var granted []string
+	if mw.RBAC != nil {
+		granted = mw.RBAC.Permissions(groups)
+	}
+	absent := missing(permissions, granted)
+	decision := Decision{RequiredPermissions: permissions, PresentedGroups: groups, Allowed: len(absent) == 0}
+	if decision.Allowed {
+		mw.recordDecision(c, principal, decision, start)
+		return true
+	}
+
+	mw.requestLog(c).Debug("Principal has not been granted the permissions", "sub", principal.ID(), "permissions", absent)
+	decision.Reason = "missing permissions"
+	mw.recordDecision(c, principal, decision, start)
+	c.Set(ErrorDetailKey, absent)
+	mw.forbidden(c, accessError(ErrMissingPermissions, fmt.Sprintf("requires permissions: %s", strings.Join(absent, ", "))))
+	return false
+}
+
+// routeKey the key identifying a route: the method and the gin route pattern
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
@@ -0,0 +1,59 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func testRBAC() *RBAC {
+	return NewRBAC().
+		AddRole("reader", "orders:read").
+		AddRole("writer", "orders:read", "orders:write").
+		MapGroup("ops", "writer").
+		Require("GET", "/orders/:id", "orders:read").
+		Require("DELETE", "/orders/:id", "orders:write")
+}
+
+func Test_RBACPermissions(t *testing.T) {
+	t.Logf("Given groups mapped to roles")
+	{
+		rbac := testRBAC()
+		assert.Equal(t, []string{"orders:read", "orders:write"}, rbac.Permissions([]string{"ops"}))
+		assert.Equal(t, []string{"orders:read"}, rbac.Permissions([]string{"reader"}))
+		assert.Equal(t, []string{}, rbac.Permissions([]string{"unknown"}))
+	}
+}
+
+func Test_RBACIsEnforcedByTheMiddleware(t *testing.T) {
+	t.Logf("Given a middleware configured with route permissions")
+	{
+		mw := newTestMiddleware()
+		mw.RBAC = testRBAC()
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(mw.MiddlewareFunc())
+		router.GET("/orders/:id", testHandler)
+		router.DELETE("/orders/:id", testHandler)
+		router.GET("/status", testHandler)
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders/1", tokenWithGroups("reader")).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "DELETE", "/orders/1", tokenWithGroups("reader")).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "DELETE", "/orders/1", tokenWithGroups("ops")).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/status", tokenWithGroups()).Code)
+	}
+}
+
+func Test_RequirePermissions(t *testing.T) {
+	t.Logf("Given a route requiring the orders:write permission")
+	{
+		mw := newTestMiddleware()
+		mw.RBAC = testRBAC()
+		router := authzHandler(mw, mw.RequirePermissions("orders:write"))
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", tokenWithGroups("ops")).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", tokenWithGroups("reader")).Code)
+	}
+}
@@ -0,0 +1,26 @@
+package jwt
+
+import (
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"runtime/debug"
+)
+
+// safeParse parses the token, a panic, e.g. on a malformed json web key or an unexpected claim type, rejecting
+// the token rather than crashing the server
+func (mw *AuthMiddleware) safeParse(tokenStr string, logger Logger) (token *jwtgo.Token, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			token, err = nil, mw.recovered(logger, "token_validation", r)
+		}
+	}()
+	return mw.parse(tokenStr)
+}
+
+// recovered logs and reports the recovered panic along with its stack, returning it as an ErrInternal
+func (mw *AuthMiddleware) recovered(logger Logger, operation string, r interface{}) error {
+	err := fmt.Errorf("%w: %v", ErrInternal, r)
+	logger.Error("Recovered from a panic", "operation", operation, "error", err, "stack", string(debug.Stack()))
+	mw.reportError(err, map[string]string{"operation": operation})
+	return err
+}
@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_PanicWhileValidatingTheToken(t *testing.T) {
+	t.Logf("Given a key provider which panics")
+	{
+		var reported []error
+		mw := newTestMiddleware()
+		mw.Keys = KeyProviderFunc(func(kid string) (*rsa.PublicKey, string, error) {
+			panic("key store failure")
+		})
+		mw.ErrorReporter = ErrorReporterFunc(func(err error, tags map[string]string) {
+			assert.Equal(t, map[string]string{"operation": "token_validation"}, tags)
+			reported = append(reported, err)
+		})
+
+		w := performRequest(authzHandler(mw), "GET", "/orders", signToken(testClaims()))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="gin jwt", error="invalid_token", error_description="internal_error"`, w.Header().Get(AuthenticateHeader))
+		assert.Len(t, reported, 1)
+		assert.True(t, errors.Is(reported[0], ErrInternal))
+		assert.Equal(t, uint64(1), mw.Stats().Failures["internal_error"])
+	}
+}
+
+func Test_PanicWhileAuthorizing(t *testing.T) {
+	t.Logf("Given a claims mapper which panics")
+	{
+		var reported []map[string]string
+		mw := newTestMiddleware()
+		mw.ClaimsMapper = func(claims jwtgo.MapClaims) (interface{}, error) {
+			return claims["tenant"].(string), nil
+		}
+		mw.ErrorReporter = ErrorReporterFunc(func(err error, tags map[string]string) {
+			reported = append(reported, tags)
+		})
+
+		w := performRequest(authzHandler(mw), "GET", "/orders", signToken(testClaims()))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, []map[string]string{{"operation": "authentication"}}, reported)
+	}
+}
+
+func Test_PanicOfTheHandlerIsNotRecovered(t *testing.T) {
+	t.Logf("Given a handler which panics behind the middleware")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw, func(c *gin.Context) {
+			panic("handler failure")
+		})
+
+		assert.PanicsWithValue(t, "handler failure", func() {
+			performRequest(router, "GET", "/orders", signToken(testClaims()))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+// tokenPattern the JWS and JWE compact serializations, whose first segment is the base64url of a JSON header
+var tokenPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*=*(\.[A-Za-z0-9_-]*=*){2,4}`)
+
+// RedactToken returns the form of a token fit for the logs, the audit events and the error messages: the first 12
+// hexadecimal digits of its SHA-256, enough to correlate the outputs of a token without disclosing it
+func RedactToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "[token sha256:" + hex.EncodeToString(sum[:])[:12] + "]"
+}
+
+// redactTokens replaces the tokens found in s with their RedactToken form
+func redactTokens(s string) string {
+	return tokenPattern.ReplaceAllStringFunc(s, RedactToken)
+}
+
+// redactedError an error whose message has its tokens redacted, errors.Is and errors.As still reaching the
+// underlying error
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return redactTokens(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns err with the tokens of its message redacted, nil when err is nil
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err}
+}
+
+// redactingLogger Logger redacting the tokens of the messages and of the string and error values, so
+// that no log of the middleware ever carries a token
+type redactingLogger struct {
+	logger Logger
+}
+
+func (l *redactingLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debug(redactTokens(msg), redactValues(keysAndValues)...)
+}
+
+func (l *redactingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Info(redactTokens(msg), redactValues(keysAndValues)...)
+}
+
+func (l *redactingLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Warn(redactTokens(msg), redactValues(keysAndValues)...)
+}
+
+func (l *redactingLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Error(redactTokens(msg), redactValues(keysAndValues)...)
+}
+
+// redactValues returns a copy of the key/value pairs with the tokens of their values redacted
+func redactValues(keysAndValues []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keysAndValues))
+	for i, value := range keysAndValues {
+		switch v := value.(type) {
+		case string:
+			redacted[i] = redactTokens(v)
+		case error:
+			redacted[i] = redactError(v)
+		case []interface{}:
+			redacted[i] = redactValues(v)
+		case map[string]interface{}:
+			values := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				values[key] = redactValues([]interface{}{item})[0]
+			}
+			redacted[i] = values
+		default:
+			redacted[i] = value
+		}
+	}
+	return redacted
+}
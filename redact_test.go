@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_RedactToken(t *testing.T) {
+	t.Logf("Given a token")
+	{
+		token := signToken(testClaims())
+
+		t.Logf("Then it is redacted to a short hash, the same for every occurrence")
+		redacted := RedactToken(token)
+		assert.Regexp(t, `^\[token sha256:[0-9a-f]{12}\]$`, redacted)
+		assert.Equal(t, "refreshing "+redacted+" and "+redacted, redactTokens("refreshing "+token+" and "+token))
+
+		t.Logf("And the padded tokens, the encrypted refresh tokens and the unsigned tokens are redacted too")
+		for _, candidate := range []string{padded(token), token + ".cGFydA.dGFn", strings.Join(strings.Split(token, ".")[:2], ".") + "."} {
+			assert.NotContains(t, redactTokens("token: "+candidate), candidate)
+		}
+
+		t.Logf("And the URLs and domains are left untouched")
+		for _, text := range []string{"https://cognito-idp.eu-west-2.amazonaws.com/eu-west-2_abc", "my-domain.auth.eu-west-2.amazoncognito.com"} {
+			assert.Equal(t, text, redactTokens(text))
+		}
+	}
+}
+
+func Test_NoTokenInOutputs(t *testing.T) {
+	t.Logf("Given a middleware logging, auditing and reporting failures quoting the token")
+	{
+		logger := &recordingLogger{}
+		var events []AuditEvent
+		var reported []error
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.Debug = true
+		mw.AuditEvents = AuditSinkFunc(func(e AuditEvent) { events = append(events, e) })
+		mw.ErrorReporter = ErrorReporterFunc(func(err error, tags map[string]string) { reported = append(reported, err) })
+		mw.Enrichers = []Enricher{EnricherFunc(func(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error) {
+			if claims["sub"] == "rejected" {
+				return nil, tokenError(ErrInvalidClaims, fmt.Errorf("the token %s is rejected", token))
+			}
+			return nil, fmt.Errorf("userInfo unavailable for %s", token)
+		})}
+		router := authzHandler(mw)
+
+		var tokens []string
+		var outputs []string
+		rejected := testClaims()
+		rejected["sub"] = "rejected"
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+		expired["original_token"] = signToken(testClaims())
+		tokens = append(tokens, signToken(testClaims()), signToken(rejected), signToken(expired), expired["original_token"].(string))
+		for _, token := range tokens[:3] {
+			w := performRequest(router, "GET", "/orders", token)
+			outputs = append(outputs, w.Body.String(), fmt.Sprint(w.Header()))
+		}
+
+		t.Logf("Then no log, audit event, reported error nor response carries a token")
+		for _, entry := range logger.entries {
+			outputs = append(outputs, entry.msg+fmt.Sprint(entry.keysAndValues...))
+		}
+		for _, event := range events {
+			outputs = append(outputs, fmt.Sprintf("%+v", event))
+		}
+		for _, err := range reported {
+			outputs = append(outputs, err.Error())
+		}
+		assert.NotEmpty(t, reported)
+		assert.NotEmpty(t, events)
+		for _, output := range outputs {
+			for _, token := range tokens {
+				signature := token[strings.LastIndexByte(token, '.')+1:]
+				assert.NotContains(t, output, signature)
+			}
+		}
+
+		t.Logf("And their redacted form is found instead, the errors still matching their kind")
+		assert.Contains(t, strings.Join(outputs, "\n"), RedactToken(tokens[1]))
+		assert.Contains(t, strings.Join(outputs, "\n"), RedactToken(tokens[3]))
+		err := redactError(tokenError(ErrInvalidClaims, fmt.Errorf("the token %s is rejected", tokens[1])))
+		assert.True(t, errors.Is(err, ErrInvalidClaims))
+		assert.Equal(t, "the token "+RedactToken(tokens[1])+" is rejected", err.Error())
+	}
+}
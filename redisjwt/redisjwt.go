@@ -0,0 +1,141 @@
+// Package redisjwt provides Redis backed stores for the gin-jwt-cognito middleware, shared between the
+// instances of a service.
+package redisjwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// DefaultPrefix the default prefix of the keys written to Redis
+const DefaultPrefix = "cognito-jwt:"
+
+// SessionStore a jwt.SessionStore keeping the sessions in Redis, expiring with their tokens
+type SessionStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewSessionStore creates a SessionStore writing its keys under DefaultPrefix
+func NewSessionStore(client redis.UniversalClient) *SessionStore {
+	return &SessionStore{client: client, prefix: DefaultPrefix + "session:"}
+}
+
+// Get returns the session of the given ID, nil when there is none
+func (s *SessionStore) Get(id string) (*jwt.Session, error) {
+	value, err := s.client.Get(context.Background(), s.prefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := &jwt.Session{}
+	if err := json.Unmarshal(value, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Save saves the session until it expires
+func (s *SessionStore) Save(id string, session *jwt.Session) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(context.Background(), s.prefix+id, value, time.Until(session.ExpiresAt)).Err()
+}
+
+// Delete deletes the session of the given ID
+func (s *SessionStore) Delete(id string) error {
+	return s.client.Del(context.Background(), s.prefix+id).Err()
+}
+
+// FailureStore a jwt.FailureStore counting the failures in Redis, shared between the instances of a service
+type FailureStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewFailureStore creates a FailureStore writing its keys under DefaultPrefix
+func NewFailureStore(client redis.UniversalClient) *FailureStore {
+	return &FailureStore{client: client, prefix: DefaultPrefix + "failures:"}
+}
+
+// Failures returns the failures of key within its window and the time left until the window ends
+func (s *FailureStore) Failures(key string) (int, time.Duration, error) {
+	ctx := context.Background()
+	pipe := s.client.Pipeline()
+	count := pipe.Get(ctx, s.prefix+key)
+	ttl := pipe.PTTL(ctx, s.prefix+key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, err
+	}
+	failures, err := count.Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return failures, max(ttl.Val(), 0), nil
+}
+
+// AddFailure increments the failures of key, which expire window after the first one
+func (s *FailureStore) AddFailure(key string, window time.Duration) error {
+	ctx := context.Background()
+	pipe := s.client.TxPipeline()
+	pipe.Incr(ctx, s.prefix+key)
+	pipe.ExpireNX(ctx, s.prefix+key, window)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// QuotaStore a jwt.QuotaStore counting the requests in Redis, shared between the instances of a service
+type QuotaStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewQuotaStore creates a QuotaStore writing its keys under DefaultPrefix
+func NewQuotaStore(client redis.UniversalClient) *QuotaStore {
+	return &QuotaStore{client: client, prefix: DefaultPrefix + "quota:"}
+}
+
+// Take increments the requests of key, which expire window after the first one, and returns them along with the
+// time left until the window ends
+func (s *QuotaStore) Take(key string, window time.Duration) (int, time.Duration, error) {
+	ctx := context.Background()
+	pipe := s.client.TxPipeline()
+	count := pipe.Incr(ctx, s.prefix+key)
+	pipe.ExpireNX(ctx, s.prefix+key, window)
+	ttl := pipe.PTTL(ctx, s.prefix+key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+	return int(count.Val()), max(ttl.Val(), 0), nil
+}
+
+// FingerprintStore a jwt.FingerprintStore keeping the fingerprints in Redis until the tokens expire
+type FingerprintStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewFingerprintStore creates a FingerprintStore writing its keys under DefaultPrefix
+func NewFingerprintStore(client redis.UniversalClient) *FingerprintStore {
+	return &FingerprintStore{client: client, prefix: DefaultPrefix + "fingerprint:"}
+}
+
+// Bind binds the token to the fingerprint unless it is bound already, atomically
+func (s *FingerprintStore) Bind(key, fingerprint string, until time.Time) (string, error) {
+	bound, err := s.client.SetArgs(context.Background(), s.prefix+key, fingerprint, redis.SetArgs{Mode: "NX", Get: true, ExpireAt: until}).Result()
+	if errors.Is(err, redis.Nil) {
+		return fingerprint, nil
+	}
+	return bound, err
+}
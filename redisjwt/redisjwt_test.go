@@ -0,0 +1,101 @@
+package redisjwt
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/alicebob/miniredis/v2"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_SessionStore(t *testing.T) {
+	t.Logf("Given sessions kept in Redis")
+	{
+		server := miniredis.RunT(t)
+		store := NewSessionStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+
+		session := &jwt.Session{Token: "token", Claims: jwtgo.MapClaims{"sub": "user-123"}, ExpiresAt: time.Now().Add(time.Hour).Round(0)}
+		assert.Nil(t, store.Save("id-1", session))
+		assert.True(t, server.TTL(DefaultPrefix+"session:id-1") > 59*time.Minute)
+
+		saved, err := store.Get("id-1")
+		assert.Nil(t, err)
+		assert.Equal(t, "user-123", saved.Claims["sub"])
+		assert.True(t, session.ExpiresAt.Equal(saved.ExpiresAt))
+
+		assert.Nil(t, store.Delete("id-1"))
+		saved, err = store.Get("id-1")
+		assert.Nil(t, err)
+		assert.Nil(t, saved)
+	}
+}
+
+func Test_FailureStore(t *testing.T) {
+	t.Logf("Given failures counted in Redis")
+	{
+		server := miniredis.RunT(t)
+		store := NewFailureStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+
+		failures, left, err := store.Failures("ip:192.0.2.1")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, failures)
+		assert.Equal(t, time.Duration(0), left)
+
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, store.AddFailure("ip:192.0.2.1", time.Minute))
+			server.FastForward(10 * time.Second)
+		}
+		failures, left, err = store.Failures("ip:192.0.2.1")
+		assert.Nil(t, err)
+		assert.Equal(t, 3, failures)
+		assert.Equal(t, 30*time.Second, left)
+
+		t.Logf("Then the failures are forgotten once the window ended")
+		server.FastForward(30 * time.Second)
+		failures, _, err = store.Failures("ip:192.0.2.1")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, failures)
+	}
+}
+
+func Test_QuotaStore(t *testing.T) {
+	t.Logf("Given requests counted in Redis")
+	{
+		server := miniredis.RunT(t)
+		store := NewQuotaStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+
+		for i := 1; i <= 3; i++ {
+			requests, left, err := store.Take("tenant:acme:user-123", time.Minute)
+			assert.Nil(t, err)
+			assert.Equal(t, i, requests)
+			assert.Equal(t, time.Minute-time.Duration(i-1)*10*time.Second, left)
+			server.FastForward(10 * time.Second)
+		}
+
+		t.Logf("Then the requests are counted afresh once the window ended")
+		server.FastForward(30 * time.Second)
+		requests, left, err := store.Take("tenant:acme:user-123", time.Minute)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, requests)
+		assert.Equal(t, time.Minute, left)
+	}
+}
+
+func Test_FingerprintStore(t *testing.T) {
+	t.Logf("Given fingerprints kept in Redis")
+	{
+		server := miniredis.RunT(t)
+		store := NewFingerprintStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+
+		bound, err := store.Bind("token", "ip=192.0.2.0/24", time.Now().Add(time.Hour))
+		assert.Nil(t, err)
+		assert.Equal(t, "ip=192.0.2.0/24", bound)
+		assert.True(t, server.TTL(DefaultPrefix+"fingerprint:token") > 59*time.Minute)
+
+		bound, err = store.Bind("token", "ip=198.51.100.0/24", time.Now().Add(time.Hour))
+		assert.Nil(t, err)
+		assert.Equal(t, "ip=192.0.2.0/24", bound)
+	}
+}
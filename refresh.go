@@ -0,0 +1,85 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// DefaultRefreshTokenCookie the default cookie holding the refresh token
+const DefaultRefreshTokenCookie = "refresh_token"
+
+// DefaultRefreshTokenValidity the default validity of the refresh tokens of the Cognito app clients
+const DefaultRefreshTokenValidity = 30 * 24 * time.Hour
+
+// refreshRequest the JSON body accepted by the RefreshHandler
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+
+	// Username required to compute the secret hash when the app client has a secret
+	Username string `json:"username"`
+}
+
+// RefreshTokens exchanges the refresh token for fresh ID and access tokens with the Cognito InitiateAuth
+// REFRESH_TOKEN_AUTH flow. The username is only required when the app client has a secret.
+func (mw *AuthMiddleware) RefreshTokens(ctx context.Context, refreshToken, username string) (*Tokens, error) {
+	params := map[string]string{"REFRESH_TOKEN": refreshToken}
+	if mw.ClientSecret != "" {
+		params["SECRET_HASH"] = mw.secretHash(username)
+	}
+	input := map[string]interface{}{
+		"AuthFlow":       "REFRESH_TOKEN_AUTH",
+		"ClientId":       mw.ClientID,
+		"AuthParameters": params,
+	}
+	var output struct {
+		AuthenticationResult authenticationResult
+	}
+	if err := mw.callCognito(ctx, "InitiateAuth", input, &output); err != nil {
+		return nil, err
+	}
+	result := output.AuthenticationResult
+	return &Tokens{
+		IDToken:      result.IDToken,
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		ExpiresIn:    result.ExpiresIn,
+		TokenType:    result.TokenType,
+	}, nil
+}
+
+// RefreshHandler renews the tokens of the caller. The refresh token is read from the RefreshTokenCookie, or
+// the refresh_token field of the JSON body, and the fresh ID and access tokens are returned as Tokens.
+// Refresh tokens rejected by Cognito are answered with a 401.
+func (mw *AuthMiddleware) RefreshHandler() gin.HandlerFunc {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return func(c *gin.Context) {
+		var request refreshRequest
+		if cookie, err := c.Cookie(mw.RefreshTokenCookie); err == nil && cookie != "" {
+			request.RefreshToken = cookie
+		} else if c.Request.Body != nil {
+			c.ShouldBindJSON(&request)
+		}
+		if request.RefreshToken == "" {
+			mw.respond(c, http.StatusBadRequest, ErrMissingRefreshToken, nil)
+			return
+		}
+
+		tokens, err := mw.RefreshTokens(c.Request.Context(), request.RefreshToken, request.Username)
+		var cognitoErr *CognitoError
+		switch {
+		case errors.As(err, &cognitoErr) && cognitoErr.StatusCode < http.StatusInternalServerError:
+			mw.requestLog(c).Warn("Failed to refresh the tokens", "error", err)
+			mw.respond(c, http.StatusUnauthorized, err, nil)
+		case err != nil:
+			mw.requestLog(c).Error("Failed to refresh the tokens", "error", err)
+			mw.reportError(err, map[string]string{"operation": "token_refresh"})
+			mw.respond(c, http.StatusBadGateway, err, nil)
+		default:
+			c.JSON(http.StatusOK, tokens)
+		}
+	}
+}
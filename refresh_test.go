@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// cognitoServer fakes the Cognito API, answering the calls with the given handler
+func cognitoServer(handler func(action string, input map[string]interface{}) (int, interface{})) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&input)
+		action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AWSCognitoIdentityProviderService.")
+		status, output := handler(action, input)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(output)
+	}))
+	format := cognitoURLFormat
+	cognitoURLFormat = server.URL + "/%v"
+	return func() {
+		cognitoURLFormat = format
+		server.Close()
+	}
+}
+
+func refreshRouter(mw *AuthMiddleware) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/refresh", mw.RefreshHandler())
+	return r
+}
+
+func Test_RefreshHandler(t *testing.T) {
+	t.Logf("Given a user pool issuing fresh tokens for valid refresh tokens")
+	{
+		defer cognitoServer(func(action string, input map[string]interface{}) (int, interface{}) {
+			assert.Equal(t, "InitiateAuth", action)
+			assert.Equal(t, "REFRESH_TOKEN_AUTH", input["AuthFlow"])
+			assert.Equal(t, "test-client", input["ClientId"])
+			params := input["AuthParameters"].(map[string]interface{})
+			assert.NotEmpty(t, params["SECRET_HASH"])
+			if params["REFRESH_TOKEN"] != "valid" {
+				return http.StatusBadRequest, map[string]string{"__type": "NotAuthorizedException", "message": "Invalid Refresh Token"}
+			}
+			return http.StatusOK, map[string]interface{}{
+				"AuthenticationResult": map[string]interface{}{"IdToken": "id", "AccessToken": "access", "ExpiresIn": 3600, "TokenType": "Bearer"},
+			}
+		})()
+		mw := newTestMiddleware()
+		mw.ClientID = "test-client"
+		mw.ClientSecret = "secret"
+		router := refreshRouter(mw)
+
+		req, _ := http.NewRequest("POST", "/refresh", strings.NewReader(`{"refresh_token":"valid","username":"jdoe"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"id_token":"id","access_token":"access","expires_in":3600,"token_type":"Bearer"}`, w.Body.String())
+
+		req, _ = http.NewRequest("POST", "/refresh", nil)
+		req.AddCookie(&http.Cookie{Name: DefaultRefreshTokenCookie, Value: "revoked"})
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid Refresh Token")
+
+		req, _ = http.NewRequest("POST", "/refresh", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
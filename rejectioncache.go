@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheableRejections the failures a token cannot recover from. The unknown kids, which a refresh of the json
+// web key set may resolve, the tokens not valid yet and the internal errors are never cached, so that a
+// transient failure cannot poison the cache with the rejection of a valid token.
+var cacheableRejections = []error{
+	ErrMalformedToken,
+	ErrUnexpectedSigningMethod,
+	ErrInvalidSignature,
+	ErrTokenExpired,
+	ErrMissingIssuer,
+	ErrBadIssuer,
+	ErrWrongTokenUse,
+}
+
+// RejectionCache a bounded cache of the rejected tokens keyed by a hash of the token, rejecting cheaply the
+// garbage or expired tokens presented again and again. Only the failures a token cannot recover from are
+// cached, for a short time to live. It is safe for concurrent use.
+type RejectionCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]rejectionEntry
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type rejectionEntry struct {
+	err     error
+	expires time.Time
+}
+
+// NewRejectionCache creates a RejectionCache holding at most maxEntries rejections for ttl
+func NewRejectionCache(ttl time.Duration, maxEntries int) *RejectionCache {
+	return &RejectionCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[[sha256.Size]byte]rejectionEntry),
+	}
+}
+
+// get returns the cached rejection of the token, nil when it is not cached or expired
+func (c *RejectionCache) get(tokenStr string) error {
+	key := sha256.Sum256([]byte(tokenStr))
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || !time.Now().Before(entry.expires) {
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return entry.err
+}
+
+// add caches the rejection of the token when the failure is one it cannot recover from
+func (c *RejectionCache) add(tokenStr string, err error) {
+	if c.maxEntries <= 0 || !cacheableRejection(err) {
+		return
+	}
+	key := sha256.Sum256([]byte(tokenStr))
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = rejectionEntry{err: err, expires: now.Add(c.ttl)}
+}
+
+// evict drops the expired entries, or an arbitrary one when none has expired. Callers must hold the lock.
+func (c *RejectionCache) evict(now time.Time) {
+	evicted := 0
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+			evicted++
+		}
+	}
+	if evicted == 0 {
+		for key := range c.entries {
+			delete(c.entries, key)
+			evicted++
+			break
+		}
+	}
+	atomic.AddUint64(&c.evictions, uint64(evicted))
+}
+
+// Purge drops all the cached rejections, e.g. once the configuration of the middleware changed
+func (c *RejectionCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[[sha256.Size]byte]rejectionEntry)
+}
+
+// Metrics returns the cache counters
+func (c *RejectionCache) Metrics() CacheMetrics {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return CacheMetrics{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Entries:   entries,
+	}
+}
+
+// cacheableRejection whether the failure is one the token cannot recover from
+func cacheableRejection(err error) bool {
+	if errors.Is(err, ErrInternal) || errors.Is(err, ErrUnknownKeyID) || errors.Is(err, ErrTokenNotValidYet) {
+		return false
+	}
+	for _, rejection := range cacheableRejections {
+		if errors.Is(err, rejection) {
+			return true
+		}
+	}
+	return false
+}
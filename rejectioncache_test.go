@@ -0,0 +1,47 @@
+package jwt
+
+import (
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_RejectionCache(t *testing.T) {
+	t.Logf("Given a middleware caching the rejected tokens")
+	{
+		mw := newTestMiddleware()
+		mw.RejectionCache = NewRejectionCache(time.Minute, 10)
+		router := authzHandler(mw)
+
+		expired := testClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		tampered := signToken(testClaims())
+		tampered = tampered[:len(tampered)-8] + "AAAAAAAA"
+		for _, token := range []string{signToken(expired), tampered} {
+			for i := 0; i < 2; i++ {
+				assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", token).Code)
+			}
+		}
+		assert.Equal(t, CacheMetrics{Hits: 2, Misses: 2, Entries: 2}, *mw.Stats().RejectionCache)
+		assert.Equal(t, uint64(2), mw.Stats().Failures["expired"])
+		assert.Equal(t, uint64(2), mw.Stats().Failures["invalid_signature"])
+
+		t.Logf("Then the failures a token may recover from are not cached")
+		unknownKid := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+		unknownKid.Header["kid"] = "rotated-kid"
+		unknownKidStr, _ := unknownKid.SignedString(testKey)
+		notValidYet := testClaims()
+		notValidYet["nbf"] = time.Now().Add(time.Minute).Unix()
+		for _, token := range []string{unknownKidStr, signToken(notValidYet)} {
+			assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", token).Code)
+		}
+		assert.Equal(t, 2, mw.RejectionCache.Metrics().Entries)
+		assert.False(t, cacheableRejection(fmt.Errorf("%w: checking the revocations", ErrInternal)))
+
+		mw.RejectionCache.Purge()
+		assert.Equal(t, 0, mw.RejectionCache.Metrics().Entries)
+	}
+}
@@ -0,0 +1,114 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader serves the middleware of a Config which can be reloaded at runtime, e.g. to add a scope to a route or
+// rotate the app client. Every reload builds and validates a new middleware which is then swapped atomically: the
+// requests in flight complete with the middleware they started with, and an invalid configuration leaves the
+// current one in place.
+//
+// The options which are not part of the Config, such as the Logger, the Metrics or the caches, are applied to every
+// middleware by the configure func given to NewReloader.
+type Reloader struct {
+	configure func(*AuthMiddleware)
+	current   atomic.Value
+	mu        sync.Mutex
+	config    *Config
+}
+
+// NewReloader builds the middleware of the configuration, applying configure, which may be nil, to it and to every
+// reloaded middleware
+func NewReloader(config *Config, configure func(*AuthMiddleware)) (*Reloader, error) {
+	r := &Reloader{configure: configure, config: config}
+	mw, err := r.build(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.current.Store(mw)
+	return r, nil
+}
+
+// Middleware the current middleware. The handlers registered from it, e.g. the RefreshHandler, are not reloaded.
+func (r *Reloader) Middleware() *AuthMiddleware {
+	return r.current.Load().(*AuthMiddleware)
+}
+
+// MiddlewareFunc the gin middleware validating the requests with the current middleware
+func (r *Reloader) MiddlewareFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r.Middleware().middlewareImpl(c)
+	}
+}
+
+// Reload validates the configuration and swaps the middleware for the one it describes. The json web key set is
+// downloaded when the user pool changes, the keys of the current middleware are kept otherwise.
+func (r *Reloader) Reload(config *Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mw, err := r.build(config, r.Middleware())
+	if err != nil {
+		return err
+	}
+	r.config = config
+	r.current.Store(mw)
+	mw.log().Info("configuration reloaded", "user_pool_id", mw.UserPoolID)
+	return nil
+}
+
+// build builds the middleware of the configuration, reusing the keys of the current middleware of the same user pool
+func (r *Reloader) build(config *Config, current *AuthMiddleware) (*AuthMiddleware, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid middleware configuration: %w", err)
+	}
+	builder, _ := config.Builder()
+	if r.configure != nil {
+		builder.Configure(r.configure)
+	}
+	if current != nil && current.Region == config.Region && current.UserPoolID == config.UserPoolID {
+		current.keysMu.RLock()
+		jwk, loadedAt := current.JWK, current.jwkLoadedAt
+		current.keysMu.RUnlock()
+		builder.Configure(func(mw *AuthMiddleware) {
+			mw.setJWK(jwk)
+			mw.jwkLoadedAt = loadedAt
+		})
+	}
+	return builder.Build()
+}
+
+// Watch reloads the configuration file whenever its modification time or size changes, checking every interval
+// in the background until the context is done. The configurations failing to load or validate are logged and ignored.
+func (r *Reloader) Watch(ctx context.Context, path string, interval time.Duration) {
+	info, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			latest, err := os.Stat(path)
+			if err != nil || (info != nil && latest.ModTime().Equal(info.ModTime()) && latest.Size() == info.Size()) {
+				continue
+			}
+			info = latest
+			config, err := LoadConfig(path)
+			if err == nil {
+				err = r.Reload(config)
+			}
+			if err != nil {
+				r.Middleware().log().Error("failed to reload the configuration", "path", path, "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer serves the test key set, counting the downloads
+func jwksServer(t *testing.T, downloads *int32) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(downloads, 1)
+		keys := []JWKKey{}
+		for _, key := range testJWK() {
+			keys = append(keys, key)
+		}
+		json.NewEncoder(w).Encode(JWK{Keys: keys})
+	}))
+	format := jwkURLFormat
+	jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+	t.Cleanup(func() {
+		jwkURLFormat = format
+		server.Close()
+	})
+}
+
+func Test_Reload(t *testing.T) {
+	t.Logf("Given a middleware reloaded with a new required scope")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+
+		var configured int
+		reloader, err := NewReloader(&Config{Region: TestRegion, UserPoolID: TestUserPoolID},
+			func(mw *AuthMiddleware) { configured++ })
+		assert.NoError(t, err)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/orders", reloader.MiddlewareFunc(), testHandler)
+		token := signToken(testClaims())
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", token).Code)
+
+		err = reloader.Reload(&Config{Region: TestRegion, UserPoolID: TestUserPoolID,
+			Routes: map[string]RouteConfig{"GET /orders": {Scopes: []string{"orders/write"}}}})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", token).Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&downloads), "the keys of the same user pool are kept")
+		assert.Equal(t, 2, configured)
+		assert.Equal(t, 1, reloader.Middleware().KeyCount())
+
+		err = reloader.Reload(&Config{Region: TestRegion, UserPoolID: TestRegion + "_otherpool"})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&downloads), "the keys of a new user pool are downloaded")
+	}
+
+	t.Logf("Given a middleware reloaded with an invalid configuration")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+
+		reloader, err := NewReloader(&Config{Region: TestRegion, UserPoolID: TestUserPoolID, ClientID: "web"}, nil)
+		assert.NoError(t, err)
+		current := reloader.Middleware()
+
+		err = reloader.Reload(&Config{Region: TestRegion, UserPoolID: "eu-west-1_x"})
+		assert.ErrorContains(t, err, "does not belong to the region")
+		assert.Same(t, current, reloader.Middleware())
+	}
+
+	t.Logf("Given requests validated while the configuration is reloaded")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+
+		reloader, err := NewReloader(&Config{Region: TestRegion, UserPoolID: TestUserPoolID}, nil)
+		assert.NoError(t, err)
+		router := gin.New()
+		router.GET("/orders", reloader.MiddlewareFunc(), testHandler)
+		token := signToken(testClaims())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					code := performRequest(router, "GET", "/orders", token).Code
+					assert.Contains(t, []int{http.StatusOK, http.StatusForbidden}, code)
+				}
+			}()
+		}
+		for i := 0; i < 20; i++ {
+			scopes := []string{"orders/read"}
+			if i%2 == 0 {
+				scopes = []string{"orders/write"}
+			}
+			assert.NoError(t, reloader.Reload(&Config{Region: TestRegion, UserPoolID: TestUserPoolID,
+				Required: RouteConfig{Scopes: scopes}}))
+		}
+		wg.Wait()
+	}
+}
+
+func Test_Watch(t *testing.T) {
+	t.Logf("Given a configuration file rewritten while watched")
+	{
+		var downloads int32
+		jwksServer(t, &downloads)
+
+		path := filepath.Join(t.TempDir(), "auth.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte("region: eu-west-2\nuser_pool_id: eu-west-2_testpool\n"), 0600))
+		config, err := LoadConfig(path)
+		assert.NoError(t, err)
+		reloader, err := NewReloader(config, nil)
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reloader.Watch(ctx, path, 10*time.Millisecond)
+
+		assert.NoError(t, os.WriteFile(path, []byte("region: eu-west-2\nuser_pool_id: eu-west-2_testpool\nclient_id: web\n"), 0600))
+		assert.Eventually(t, func() bool { return reloader.Middleware().ClientID == "web" }, time.Second, 10*time.Millisecond)
+
+		assert.NoError(t, os.WriteFile(path, []byte("region: eu-west-2\nuser_pool: eu-west-2_testpool\n"), 0600))
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, "web", reloader.Middleware().ClientID, "the invalid configurations are ignored")
+	}
+}
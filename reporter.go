@@ -0,0 +1,26 @@
+package jwt
+
+// ErrorReporter receives the unexpected internal errors of the middleware, e.g. failed downloads of the
+// json web key set, failed external authorizations or recovered panics. The tags describe the failure
+// (operation, url, route...) and never carry the token nor its claims, the tokens found in the error messages
+// are redacted.
+type ErrorReporter interface {
+	ReportError(err error, tags map[string]string)
+}
+
+// ErrorReporterFunc adapter to use an ordinary function as an ErrorReporter
+type ErrorReporterFunc func(err error, tags map[string]string)
+
+// ReportError calls f(err, tags)
+func (f ErrorReporterFunc) ReportError(err error, tags map[string]string) {
+	f(err, tags)
+}
+
+// reportError hands over the error to the ErrorReporter, if any
+func (mw *AuthMiddleware) reportError(err error, tags map[string]string) {
+	if mw.ErrorReporter == nil {
+		return
+	}
+	err = redactError(err)
+	mw.hook(func() { mw.ErrorReporter.ReportError(err, tags) })
+}
@@ -0,0 +1,26 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_ErrorReporter(t *testing.T) {
+	t.Logf("Given a middleware reporting its internal errors")
+	{
+		var reported []map[string]string
+		mw := newTestMiddleware()
+		mw.ErrorReporter = ErrorReporterFunc(func(err error, tags map[string]string) {
+			reported = append(reported, tags)
+		})
+		mw.Authorizer = AuthorizerFunc(func(ctx context.Context, request AuthorizationRequest) (bool, error) {
+			return false, errors.New("opa unavailable")
+		})
+
+		assert.Equal(t, http.StatusForbidden, performRequest(authzHandler(mw), "GET", "/orders", signToken(testClaims())).Code)
+		assert.Equal(t, []map[string]string{{"operation": "external_authorization", "route": "GET /orders"}}, reported)
+	}
+}
@@ -0,0 +1,68 @@
+package jwt
+
+import (
+	"errors"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+)
+
+// ValidateRequest validates the given token of the request as the gin middleware does: its signature and claims, as
+// ValidateTokenContext within the context of the request, then the bindings of the token to the request, see
+// checkBindings, and the tenancy of the request, see authorizeTenant. The adapters of the other web frameworks use it,
+// so that the bound tokens are accepted along with their proof alone and the tokens of a tenant never authorize the
+// requests of another. See StatusCode for the status of the failures.
+func (mw *AuthMiddleware) ValidateRequest(r *http.Request, tokenStr string) (*jwtgo.Token, error) {
+	logger := mw.log()
+	ctx, cancel := mw.validationContext(r.Context())
+	defer cancel()
+	token, err := mw.validateTokenContext(ctx, tokenStr, logger)
+	if err != nil {
+		return token, err
+	}
+	clientIP := ""
+	if mw.Fingerprint != nil {
+		clientIP = mw.Fingerprint.clientIP(r)
+	}
+	if err := mw.checkBindings(r, clientIP, token, logger); err != nil {
+		return token, err
+	}
+	if _, err := mw.authorizeTenant(r, token, logger); err != nil {
+		return token, err
+	}
+	return token, nil
+}
+
+// checkBindings checks the bindings of the token to the request of the client of the given IP: the DPoP proof of the
+// tokens bound to a key, the client certificate of the ones bound to a certificate and the client fingerprint
+func (mw *AuthMiddleware) checkBindings(r *http.Request, clientIP string, token *jwtgo.Token, logger Logger) error {
+	if err := mw.checkDPoP(r, token, logger); err != nil {
+		return err
+	}
+	if err := mw.checkCertificate(r, token, logger); err != nil {
+		return err
+	}
+	return mw.checkFingerprint(r, clientIP, token, logger)
+}
+
+// checkRequestless rejects the tokens validated outside of a request whose checks need the request, as the request
+// without proof would: the tokens bound to a DPoP key, and all of them when DPoP is Required, the tokens bound to a
+// client certificate, and all of them when the Fingerprint binds them to their client
+func (mw *AuthMiddleware) checkRequestless(token *jwtgo.Token, logger Logger) error {
+	claims := token.Claims.(jwtgo.MapClaims)
+	var err error
+	switch {
+	case mw.DPoP != nil && (mw.DPoP.Required || boundKey(claims) != ""):
+		err = tokenError(ErrInvalidDPoPProof, errors.New("the dpop proof cannot be checked without the request, see ValidateRequest"))
+	case boundCertificate(claims) != "":
+		err = tokenError(ErrCertificateMismatch, errors.New("the client certificate cannot be checked without the request, see ValidateRequest"))
+	case mw.Fingerprint != nil:
+		err = tokenError(ErrFingerprintMismatch, errors.New("the client fingerprint cannot be checked without the request, see ValidateRequest"))
+	}
+	if err != nil {
+		mw.observe(failureReason(err), 0)
+		if mw.sampleFailure(failureReason(err)) {
+			logger.Warn("Rejected the jwt token validated without its request", "error_class", failureReason(err), "error", err)
+		}
+	}
+	return err
+}
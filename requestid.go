@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDFromHeader returns a RequestID function reading the correlation ID from the given request header,
+// e.g. X-Request-Id or X-Amzn-Trace-Id
+func RequestIDFromHeader(header string) func(*gin.Context) string {
+	return func(c *gin.Context) string {
+		return c.GetHeader(header)
+	}
+}
+
+// correlate stores the correlation ID of the request in the context
+func (mw *AuthMiddleware) correlate(c *gin.Context) {
+	if mw.RequestID == nil {
+		return
+	}
+	if requestID := mw.RequestID(c); requestID != "" {
+		c.Set(RequestIDKey, requestID)
+	}
+}
+
+// errorID returns the ID of the failure of the request, generated on first use. It is included in the
+// error response, the audit event and the warning and error logs of the request, so that a failure
+// reported by a customer can be matched with the server side logs.
+func (mw *AuthMiddleware) errorID(c *gin.Context) string {
+	if id := c.GetString(ErrorIDKey); id != "" {
+		return id
+	}
+	id := newErrorID()
+	c.Set(ErrorIDKey, id)
+	return id
+}
+
+// newErrorID generates the ID of a failure, see errorID
+func newErrorID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestLog returns the logger of the middleware, adding the correlation ID of the request to every log
+// and the error ID to the warning and error logs
+func (mw *AuthMiddleware) requestLog(c *gin.Context) Logger {
+	if mw.Logger == nil {
+		return NopLogger{}
+	}
+	var fields []interface{}
+	if requestID := c.GetString(RequestIDKey); requestID != "" {
+		fields = []interface{}{"request_id", requestID}
+	}
+	return &requestLogger{logger: mw.log(), fields: fields, errorID: func() string { return mw.errorID(c) }}
+}
+
+// requestLogger Logger adding the fields of the request to every log
+type requestLogger struct {
+	logger  Logger
+	fields  []interface{}
+	errorID func() string
+}
+
+func (l *requestLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debug(msg, l.with(keysAndValues)...)
+}
+
+func (l *requestLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Info(msg, l.with(keysAndValues)...)
+}
+
+func (l *requestLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Warn(msg, l.with(append([]interface{}{"error_id", l.errorID()}, keysAndValues...))...)
+}
+
+func (l *requestLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Error(msg, l.with(append([]interface{}{"error_id", l.errorID()}, keysAndValues...))...)
+}
+
+func (l *requestLogger) with(keysAndValues []interface{}) []interface{} {
+	if len(l.fields) == 0 {
+		return keysAndValues
+	}
+	return append(append(make([]interface{}, 0, len(l.fields)+len(keysAndValues)), l.fields...), keysAndValues...)
+}
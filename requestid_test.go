@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_RequestIDCorrelation(t *testing.T) {
+	t.Logf("Given a middleware reading the correlation ID from the X-Request-Id header")
+	{
+		logger := &recordingLogger{}
+		events := make(chan AuditEvent, 1)
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.AuditEvents = AuditChannel(events)
+		mw.RequestID = RequestIDFromHeader("X-Request-Id")
+		router := authzHandler(mw)
+
+		req, _ := http.NewRequest("GET", "/orders", nil)
+		req.Header.Set(AuthorizationHeader, "not-a-token")
+		req.Header.Set("X-Request-Id", "req-42")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		var authErr AuthError
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &authErr))
+		assert.Equal(t, "req-42", authErr.RequestID)
+		assert.Equal(t, "req-42", (<-events).RequestID)
+		assert.Equal(t, []interface{}{"request_id", "req-42"}, logger.entries[0].keysAndValues[:2])
+	}
+}
+
+// withoutErrorID removes the random error_id from the given JSON error body
+func withoutErrorID(t *testing.T, body string) string {
+	var document map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &document); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, document["error_id"])
+	delete(document, "error_id")
+	stripped, _ := json.Marshal(document)
+	return string(stripped)
+}
+
+func Test_ErrorIDMatchesLogsAndAuditEvents(t *testing.T) {
+	t.Logf("Given a request rejected by the middleware")
+	{
+		logger := &recordingLogger{}
+		events := make(chan AuditEvent, 1)
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.AuditEvents = AuditChannel(events)
+
+		w := performRequest(authzHandler(mw), "GET", "/orders", "not-a-token")
+		var authErr AuthError
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &authErr))
+		assert.Len(t, authErr.ErrorID, 16)
+		assert.Equal(t, authErr.ErrorID, (<-events).ErrorID)
+		assert.Equal(t, []interface{}{"error_id", authErr.ErrorID}, logger.entries[0].keysAndValues[:2])
+	}
+}
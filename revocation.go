@@ -0,0 +1,92 @@
+package jwt
+
+import (
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"sync"
+	"time"
+)
+
+// OriginJTIClaim the claim shared by all the tokens issued by the same authentication, refreshed ones included
+const OriginJTIClaim = "origin_jti"
+
+// RevocationStore keeps the origin_jti of the revoked tokens until they expire
+type RevocationStore interface {
+	RevocationChecker
+
+	// Revoke revokes the tokens of the given origin_jti, which can be forgotten once until has passed
+	Revoke(originJTI string, until time.Time) error
+}
+
+// RevocationChecker checks whether the tokens were revoked, the part of a RevocationStore the validation needs
+type RevocationChecker interface {
+
+	// IsRevoked whether the tokens of the given origin_jti were revoked
+	IsRevoked(originJTI string) (bool, error)
+}
+
+// RevocationCheckerFunc adapter to use an ordinary function as a RevocationChecker
+type RevocationCheckerFunc func(originJTI string) (bool, error)
+
+// IsRevoked calls f(originJTI)
+func (f RevocationCheckerFunc) IsRevoked(originJTI string) (bool, error) {
+	return f(originJTI)
+}
+
+// MemoryRevocationStore an in memory RevocationStore, the revocations are not shared between instances
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevocationStore creates an empty MemoryRevocationStore
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: map[string]time.Time{}}
+}
+
+// Revoke records the revocation, forgetting the revocations which expired
+func (s *MemoryRevocationStore) Revoke(originJTI string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for jti, expiry := range s.revoked {
+		if expiry.Before(now) {
+			delete(s.revoked, jti)
+		}
+	}
+	s.revoked[originJTI] = until
+	return nil
+}
+
+// IsRevoked whether the origin_jti was revoked and its tokens are yet to expire
+func (s *MemoryRevocationStore) IsRevoked(originJTI string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.revoked[originJTI]
+	return ok && until.After(time.Now()), nil
+}
+
+// checkRevoked rejects the tokens revoked as per the RevocationChecker or the Revocations store. The tokens without
+// origin_jti, issued before Cognito introduced the claim, cannot be revoked.
+func (mw *AuthMiddleware) checkRevoked(claims jwtgo.MapClaims) error {
+	var checker RevocationChecker = mw.RevocationChecker
+	if checker == nil && mw.Revocations != nil {
+		checker = mw.Revocations
+	}
+	if checker == nil {
+		return nil
+	}
+	originJTI, _ := claims[OriginJTIClaim].(string)
+	if originJTI == "" {
+		return nil
+	}
+	revoked, err := checker.IsRevoked(originJTI)
+	if err != nil {
+		mw.reportError(err, map[string]string{"operation": "revocation_check"})
+		return fmt.Errorf("%w: checking the revocations: %w", ErrInternal, err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
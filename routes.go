@@ -0,0 +1,136 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RouteRequirement the authorization requirements of a route
+type RouteRequirement struct {
+
+	// Groups the cognito:groups the caller must be member of
+	Groups []string
+
+	// GroupsMatch whether any (default) or all of the Groups are needed
+	GroupsMatch MatchMode
+
+	// Scopes the scopes the access token must have been granted
+	Scopes []string
+
+	// TokenUse the expected token_use claim: "id" or "access". Any when empty.
+	TokenUse string
+}
+
+// RouteTable the authorization requirements keyed by route, the upper case method and the gin
+// route pattern separated by a space, e.g. "GET /orders/:id"
+type RouteTable map[string]RouteRequirement
+
+// authorizeRoute enforces the Required requirement, then the one registered in the route table for the current
+// route
+func (mw *AuthMiddleware) authorizeRoute(c *gin.Context, principal Principal) bool {
+	if !mw.authorizeRequirement(c, principal, mw.Required) {
+		return false
+	}
+	if len(mw.Routes) == 0 {
+		return true
+	}
+	requirement, ok := mw.Routes[routeKey(c.Request.Method, c.FullPath())]
+	return !ok || mw.authorizeRequirement(c, principal, requirement)
+}
+
+// authorizeRequirement aborts with 403 when the principal does not meet the requirement
+func (mw *AuthMiddleware) authorizeRequirement(c *gin.Context, principal Principal, requirement RouteRequirement) bool {
+	if requirement.TokenUse != "" {
+		if tokenUse, _ := principal.Claims()["token_use"].(string); tokenUse != requirement.TokenUse {
+			mw.requestLog(c).Debug("Principal presented a token of the wrong type", "sub", principal.ID(), "token_use", tokenUse, "required", requirement.TokenUse)
+			mw.recordDecision(c, principal, Decision{Reason: "wrong token_use"}, time.Now())
+			mw.forbidden(c, accessError(ErrWrongTokenType, fmt.Sprintf("requires an %s token", requirement.TokenUse)))
+			return false
+		}
+	}
+	return mw.authorizeGroups(c, principal, requirement.GroupsMatch, requirement.Groups) &&
+		mw.authorizeScopes(c, principal, requirement.Scopes)
+}
+
+// DenyByDefault installs the middleware on every route of the engine: each route then requires a valid
+// token unless it is listed in PublicRoutes. It must be called before any route is registered, as gin
+// only applies global middlewares to the routes registered afterwards.
+func (mw *AuthMiddleware) DenyByDefault(engine *gin.Engine) error {
+	if routes := engine.Routes(); len(routes) > 0 {
+		return fmt.Errorf("deny by default must be installed before the routes are registered, found %s %s",
+			routes[0].Method, routes[0].Path)
+	}
+	for _, route := range mw.PublicRoutes {
+		if !strings.HasPrefix(route, ForwardSlash) && !strings.Contains(route, " "+ForwardSlash) {
+			return errors.New("invalid public route " + route + ", expecting \"/path\" or \"METHOD /path\"")
+		}
+	}
+	engine.Use(mw.MiddlewareFunc())
+	return nil
+}
+
+// isPublic whether the current route is listed in PublicRoutes
+func (mw *AuthMiddleware) isPublic(c *gin.Context) bool {
+	if len(mw.PublicRoutes) == 0 {
+		return false
+	}
+	path := c.FullPath()
+	return path != "" && mw.IsPublic(c.Request.Method, path)
+}
+
+// IsPublic whether the route is listed in PublicRoutes, either as "METHOD /path" or "/path" for any method
+func (mw *AuthMiddleware) IsPublic(method, route string) bool {
+	key := routeKey(method, route)
+	for _, public := range mw.PublicRoutes {
+		if public == route || public == key {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRoute checks the principal against the Required requirement and the one registered in Routes for the route. It lets the
+// adapters of other frameworks share the route table: a nil result means allowed, otherwise the returned
+// error describes the 403 response to send.
+func (mw *AuthMiddleware) CheckRoute(principal Principal, method, route string) *AuthError {
+	if authErr := mw.checkRequirement(principal, mw.Required); authErr != nil {
+		return authErr
+	}
+	requirement, ok := mw.Routes[routeKey(method, route)]
+	if !ok {
+		return nil
+	}
+	return mw.checkRequirement(principal, requirement)
+}
+
+// checkRequirement checks the principal against the requirement, see CheckRoute
+func (mw *AuthMiddleware) checkRequirement(principal Principal, requirement RouteRequirement) *AuthError {
+	err := mw.requirementError(principal, requirement)
+	if err == nil {
+		return nil
+	}
+	authErr := &AuthError{Code: http.StatusForbidden, Message: err.Error(), Cause: err}
+	if errors.Is(err, ErrInsufficientScope) {
+		authErr.Error = InsufficientScope
+		authErr.Detail = MissingScopes(principal, requirement.Scopes...)
+	}
+	return authErr
+}
+
+// requirementError checks the principal against the requirement, failing with an AccessError
+func (mw *AuthMiddleware) requirementError(principal Principal, requirement RouteRequirement) error {
+	if tokenUse, _ := principal.Claims()["token_use"].(string); requirement.TokenUse != "" && tokenUse != requirement.TokenUse {
+		return accessError(ErrWrongTokenType, fmt.Sprintf("requires an %s token", requirement.TokenUse))
+	}
+	if !mw.HasGroups(principal, requirement.GroupsMatch, requirement.Groups...) {
+		return accessError(ErrMissingGroups, "requires membership of groups: "+strings.Join(requirement.Groups, ", "))
+	}
+	if absent := MissingScopes(principal, requirement.Scopes...); len(absent) > 0 {
+		return accessError(ErrInsufficientScope, "requires scopes: "+strings.Join(absent, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_RouteTableIsEnforcedByTheMiddleware(t *testing.T) {
+	t.Logf("Given a central table of route requirements")
+	{
+		mw := newTestMiddleware()
+		mw.Routes = RouteTable{
+			"GET /orders/:id":    {Scopes: []string{"orders/read"}},
+			"DELETE /orders/:id": {Groups: []string{"admins"}, TokenUse: "access"},
+			"GET /profile":       {TokenUse: "id"},
+		}
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(mw.MiddlewareFunc())
+		router.GET("/orders/:id", testHandler)
+		router.DELETE("/orders/:id", testHandler)
+		router.GET("/profile", testHandler)
+
+		reader := testClaims()
+		reader[ScopeClaim] = "orders/read"
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders/1", signToken(reader)).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders/1", signToken(testClaims())).Code)
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "DELETE", "/orders/1", tokenWithGroups("admins")).Code)
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "DELETE", "/orders/1", tokenWithGroups("users")).Code)
+
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/profile", signToken(testClaims())).Code)
+	}
+}
+
+func Test_DenyByDefault(t *testing.T) {
+	t.Logf("Given an engine where every route but the public ones requires a token")
+	{
+		mw := newTestMiddleware()
+		mw.PublicRoutes = []string{"/health", "GET /docs/*file"}
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		assert.Nil(t, mw.DenyByDefault(router))
+		router.GET("/health", testHandler)
+		router.GET("/docs/*file", testHandler)
+		router.POST("/docs/*file", testHandler)
+		router.GET("/orders", testHandler)
+
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/health", "").Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/docs/index.html", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "POST", "/docs/index.html", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", "").Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+	}
+}
+
+func Test_DenyByDefaultAfterRoutesRegistration(t *testing.T) {
+	t.Logf("Given an engine with routes already registered")
+	{
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/orders", testHandler)
+		assert.NotNil(t, newTestMiddleware().DenyByDefault(router))
+	}
+}
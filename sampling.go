@@ -0,0 +1,60 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// FailureSampling samples the logs of the rejected tokens, so that a credential stuffing attack does not
+// flood the logging pipeline. The zero value logs every failure.
+type FailureSampling struct {
+
+	// Every logs the first failure and then 1 in Every failures of each reason
+	Every uint64
+
+	// Interval logs a summary of the suppressed failures of each reason at most once per Interval, no
+	// summaries when zero
+	Interval time.Duration
+}
+
+// failureSampler the state of the FailureSampling
+type failureSampler struct {
+	mu          sync.Mutex
+	seen        map[string]uint64
+	suppressed  map[string]uint64
+	lastSummary time.Time
+}
+
+// sampleFailure whether the failure of the given reason is to be logged. The summary of the suppressed
+// failures is logged when due.
+func (mw *AuthMiddleware) sampleFailure(reason string) bool {
+	sampling := mw.FailureLogSampling
+	if sampling.Every <= 1 {
+		return true
+	}
+
+	s := &mw.shared().sampler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen == nil {
+		s.seen = make(map[string]uint64)
+		s.suppressed = make(map[string]uint64)
+		s.lastSummary = time.Now()
+	}
+
+	if sampling.Interval > 0 && time.Since(s.lastSummary) >= sampling.Interval {
+		for suppressedReason, count := range s.suppressed {
+			mw.log().Warn("Suppressed jwt token failure logs", "error_class", suppressedReason, "count", count, "interval", sampling.Interval)
+		}
+		s.suppressed = make(map[string]uint64)
+		s.lastSummary = time.Now()
+	}
+
+	seen := s.seen[reason]
+	s.seen[reason]++
+	if seen%sampling.Every == 0 {
+		return true
+	}
+	s.suppressed[reason]++
+	return false
+}
@@ -0,0 +1,44 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_FailureLogSampling(t *testing.T) {
+	t.Logf("Given a middleware logging 1 in 3 failures of each reason")
+	{
+		logger := &recordingLogger{}
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.FailureLogSampling = FailureSampling{Every: 3}
+		router := authzHandler(mw)
+
+		for i := 0; i < 7; i++ {
+			performRequest(router, "GET", "/orders", "not-a-token")
+		}
+		performRequest(router, "GET", "/orders", "")
+		assert.Len(t, logger.messages("warn"), 4)
+		assert.Equal(t, uint64(7), mw.Stats().Failures["malformed"])
+	}
+
+	t.Logf("Given a middleware summarising the suppressed failures")
+	{
+		logger := &recordingLogger{}
+		mw := newTestMiddleware()
+		mw.Logger = logger
+		mw.FailureLogSampling = FailureSampling{Every: 10, Interval: 10 * time.Millisecond}
+		router := authzHandler(mw)
+
+		for i := 0; i < 5; i++ {
+			performRequest(router, "GET", "/orders", "not-a-token")
+		}
+		time.Sleep(20 * time.Millisecond)
+		performRequest(router, "GET", "/orders", "not-a-token")
+
+		assert.Equal(t, []string{"Failed to validate the jwt token", "Suppressed jwt token failure logs"}, logger.messages("warn"))
+		summary := logger.entries[1]
+		assert.Equal(t, []interface{}{"error_class", "malformed", "count", uint64(4), "interval", 10 * time.Millisecond}, summary.keysAndValues)
+	}
+}
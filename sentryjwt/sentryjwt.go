@@ -0,0 +1,28 @@
+// Package sentryjwt reports the unexpected internal errors of the middleware to Sentry.
+//
+//	mw.ErrorReporter = sentryjwt.New(sentry.CurrentHub())
+package sentryjwt
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/getsentry/sentry-go"
+)
+
+type reporter struct {
+	hub *sentry.Hub
+}
+
+// New returns a jwt.ErrorReporter capturing the errors with the given hub, the tags of the error becoming
+// Sentry tags. The reports carry neither the request nor the token.
+func New(hub *sentry.Hub) jwt.ErrorReporter {
+	return &reporter{hub: hub}
+}
+
+// ReportError implements jwt.ErrorReporter
+func (r *reporter) ReportError(err error, tags map[string]string) {
+	r.hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("component", "gin-jwt-cognito")
+		scope.SetTags(tags)
+		r.hub.CaptureException(err)
+	})
+}
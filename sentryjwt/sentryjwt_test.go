@@ -0,0 +1,45 @@
+package sentryjwt
+
+import (
+	"errors"
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// transport sentry.Transport keeping the events in memory
+type transport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *transport) Flush(timeout time.Duration) bool       { return true }
+func (t *transport) Configure(options sentry.ClientOptions) {}
+func (t *transport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func Test_ReportError(t *testing.T) {
+	t.Logf("Given an error reporter backed by a sentry hub")
+	{
+		recorder := &transport{}
+		client, err := sentry.NewClient(sentry.ClientOptions{Dsn: "https://key@sentry.example.com/1", Transport: recorder})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reporter := New(sentry.NewHub(client, sentry.NewScope()))
+
+		reporter.ReportError(errors.New("jwks unavailable"), map[string]string{"operation": "jwk_refresh"})
+
+		assert.Len(t, recorder.events, 1)
+		event := recorder.events[0]
+		assert.Equal(t, "jwks unavailable", event.Exception[0].Value)
+		assert.Equal(t, "jwk_refresh", event.Tags["operation"])
+		assert.Equal(t, "gin-jwt-cognito", event.Tags["component"])
+		assert.Nil(t, event.Request)
+	}
+}
@@ -0,0 +1,154 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionCookie the cookie holding the ID of the server side session
+const SessionCookie = "jwt_session"
+
+// Session a server side session, started once the token of the caller was validated
+type Session struct {
+	Token     string          `json:"token"`
+	Claims    jwtgo.MapClaims `json:"claims"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// SessionStore keeps the server side sessions, see the redisjwt package for a store shared between instances
+type SessionStore interface {
+
+	// Get returns the session of the given ID, nil when there is none
+	Get(id string) (*Session, error)
+
+	// Save saves the session under the given ID until it expires
+	Save(id string, session *Session) error
+
+	// Delete deletes the session of the given ID
+	Delete(id string) error
+}
+
+// MemorySessionStore an in memory SessionStore, the sessions are not shared between instances. The sessions which
+// expired are forgotten as the store grows, the middleware rejecting them as per its own clock until then.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	sweepAt  int
+}
+
+// minSessionSweep the number of sessions from which the expired ones are forgotten
+const minSessionSweep = 1024
+
+// NewMemorySessionStore creates an empty MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]*Session{}, sweepAt: minSessionSweep}
+}
+
+// Get returns the session of the given ID, nil when there is none
+func (s *MemorySessionStore) Get(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id], nil
+}
+
+// Save saves the session, forgetting the sessions which expired once the store doubled since the last sweep
+func (s *MemorySessionStore) Save(id string, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = session
+
+	if len(s.sessions) >= s.sweepAt {
+		now := time.Now()
+		for sessionID, existing := range s.sessions {
+			if existing.ExpiresAt.Before(now) {
+				delete(s.sessions, sessionID)
+			}
+		}
+		s.sweepAt = max(2*len(s.sessions), minSessionSweep)
+	}
+	return nil
+}
+
+// Delete deletes the session
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// sessionToken the token of the session of the request, nil when the sessions are off or the request carries
+// no live session. The revocations are still enforced on the sessions.
+func (mw *AuthMiddleware) sessionToken(c *gin.Context, logger Logger) *jwtgo.Token {
+	if mw.Sessions == nil {
+		return nil
+	}
+	id, err := c.Cookie(SessionCookie)
+	if err != nil || id == "" {
+		return nil
+	}
+	session, err := mw.Sessions.Get(id)
+	if err != nil {
+		logger.Error("Failed to get the session", "error", err)
+		mw.reportError(err, map[string]string{"operation": "session_lookup"})
+		return nil
+	}
+	if session == nil || !session.ExpiresAt.After(mw.now()) || mw.checkRevoked(session.Claims) != nil ||
+		mw.checkTrusted(session.Claims) != nil {
+		return nil
+	}
+	claims := make(jwtgo.MapClaims, len(session.Claims))
+	for name, value := range session.Claims {
+		claims[name] = value
+	}
+	return &jwtgo.Token{Raw: session.Token, Claims: claims, Valid: true}
+}
+
+// startSession saves a session for the validated token of a browser client and sets the SessionCookie. The tokens
+// bound to a key or a certificate of the client by their cnf claim get no session, the session cookie would be a
+// bearer credential.
+func (mw *AuthMiddleware) startSession(c *gin.Context, token *jwtgo.Token, logger Logger) {
+	if mw.Sessions == nil || !mw.browserClient(c) {
+		return
+	}
+	claims := token.Claims.(jwtgo.MapClaims)
+	if _, bound := claims["cnf"]; bound {
+		return
+	}
+	expiresAt, ok := ExpiresAt(claims)
+	if !ok {
+		return
+	}
+	id := randomString()
+	if err := mw.Sessions.Save(id, &Session{Token: token.Raw, Claims: claims, ExpiresAt: expiresAt}); err != nil {
+		logger.Error("Failed to save the session", "error", err)
+		mw.reportError(err, map[string]string{"operation": "session_save"})
+		return
+	}
+	setCookie(c, SessionCookie, id, int(expiresAt.Sub(mw.now()).Seconds()))
+}
+
+// browserClient whether the client of the request is a browser, which keeps the session cookie: its token is read
+// from a cookie, or it navigates to a page. The other clients present their token on every request anyway.
+func (mw *AuthMiddleware) browserClient(c *gin.Context) bool {
+	if mw.Extractor == nil && strings.HasPrefix(mw.TokenLookup, COOKIE+":") {
+		return true
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/html")
+}
+
+// endSession deletes the session of the request, if any, and clears the SessionCookie
+func (mw *AuthMiddleware) endSession(c *gin.Context) error {
+	if mw.Sessions == nil {
+		return nil
+	}
+	setCookie(c, SessionCookie, "", -1)
+	id, err := c.Cookie(SessionCookie)
+	if err != nil || id == "" {
+		return nil
+	}
+	return mw.Sessions.Delete(id)
+}
@@ -0,0 +1,78 @@
+package jwt
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_ServerSideSessions(t *testing.T) {
+	t.Logf("Given a middleware issuing server side sessions to the browsers")
+	{
+		metrics := &recordingMetrics{}
+		mw := newTestMiddleware()
+		mw.TokenLookup = COOKIE + ":" + AccessTokenCookie
+		mw.CSRF = CSRFDisabled
+		mw.Sessions = NewMemorySessionStore()
+		mw.Metrics = metrics
+		router := authzHandler(mw)
+		router.POST("/logout", mw.LogoutHandler())
+
+		w := cookieRequest(router, "GET", "", map[string]string{AccessTokenCookie: signToken(testClaims())})
+		assert.Equal(t, http.StatusOK, w.Code)
+		cookies := w.Result().Cookies()
+		assert.Len(t, cookies, 1)
+		assert.Equal(t, SessionCookie, cookies[0].Name)
+		assert.Len(t, metrics.reasons, 1)
+
+		sessionRequest := func(method, path string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(method, path, nil)
+			req.AddCookie(cookies[0])
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		assert.Equal(t, http.StatusOK, sessionRequest("GET", "/orders").Code)
+		assert.Len(t, metrics.reasons, 1, "the token of a session is not validated again")
+
+		assert.Equal(t, http.StatusNoContent, sessionRequest("POST", "/logout").Code)
+		assert.Equal(t, http.StatusUnauthorized, sessionRequest("GET", "/orders").Code)
+	}
+
+	t.Logf("Given the clients presenting their token in a header")
+	{
+		mw := newTestMiddleware()
+		mw.Sessions = NewMemorySessionStore()
+		router := authzHandler(mw)
+
+		t.Logf("Then they get no session, unless they navigate to a page")
+		w := performRequest(router, "GET", "/orders", signToken(testClaims()))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set("Accept", "text/html")
+		req.Header.Set(AuthorizationHeader, signToken(testClaims()))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, w.Result().Cookies(), 1)
+	}
+
+	t.Logf("Given a memory store of sessions growing")
+	{
+		store := NewMemorySessionStore()
+		for i := 0; i < minSessionSweep-1; i++ {
+			assert.NoError(t, store.Save(fmt.Sprint("expired-", i), &Session{ExpiresAt: time.Now().Add(-time.Minute)}))
+		}
+		assert.Len(t, store.sessions, minSessionSweep-1, "the expired sessions are not swept on every save")
+
+		t.Logf("Then the expired sessions are forgotten once it reaches the sweep threshold")
+		assert.NoError(t, store.Save("live", &Session{ExpiresAt: time.Now().Add(time.Minute)}))
+		assert.Len(t, store.sessions, 1)
+		assert.Equal(t, minSessionSweep, store.sweepAt)
+	}
+}
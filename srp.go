@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// srpN the 3072 bits prime of RFC 5054 used by the Cognito SRP flow
+const srpN = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD" +
+	"3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B" +
+	"1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096" +
+	"966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF69558" +
+	"17183995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D06" +
+	"0C7DB3970F85A6E1E4C7ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864D87602733EC86A64521F2B18177B" +
+	"200CBBE117577A615D6C770988C0BAD946E208E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+
+var (
+	srpPrime, _ = new(big.Int).SetString(srpN, 16)
+	srpG        = big.NewInt(2)
+	srpK        = hexToInt(hexHash("00" + srpN + "0" + srpG.Text(16)))
+)
+
+// AuthenticateSRP authenticates the user with the USER_SRP_AUTH flow, the password never leaving the client.
+// It lets the integration tests and the CLI tools obtain real tokens of the user pool of the middleware, which
+// must have its ClientID set. Challenges other than the password verifier, such as MFA, are not supported.
+func (mw *AuthMiddleware) AuthenticateSRP(ctx context.Context, username, password string) (*Tokens, error) {
+	a, err := rand.Int(rand.Reader, srpPrime)
+	if err != nil {
+		return nil, err
+	}
+	bigA := new(big.Int).Exp(srpG, a, srpPrime)
+
+	params := map[string]string{"USERNAME": username, "SRP_A": bigA.Text(16)}
+	if mw.ClientSecret != "" {
+		params["SECRET_HASH"] = mw.secretHash(username)
+	}
+	var challenge struct {
+		ChallengeName       string
+		ChallengeParameters map[string]string
+		Session             string
+	}
+	input := map[string]interface{}{"AuthFlow": "USER_SRP_AUTH", "ClientId": mw.ClientID, "AuthParameters": params}
+	if err := mw.callCognito(ctx, "InitiateAuth", input, &challenge); err != nil {
+		return nil, err
+	}
+	if challenge.ChallengeName != "PASSWORD_VERIFIER" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedChallenge, challenge.ChallengeName)
+	}
+
+	userID := challenge.ChallengeParameters["USER_ID_FOR_SRP"]
+	secretBlock := challenge.ChallengeParameters["SECRET_BLOCK"]
+	timestamp := time.Now().UTC().Format("Mon Jan 2 15:04:05 MST 2006")
+	key, err := mw.srpKey(a, bigA, userID, password, challenge.ChallengeParameters["SRP_B"], challenge.ChallengeParameters["SALT"])
+	if err != nil {
+		return nil, err
+	}
+	signature, err := srpSignature(key, mw.poolName(), userID, secretBlock, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := map[string]string{
+		"USERNAME":                    userID,
+		"TIMESTAMP":                   timestamp,
+		"PASSWORD_CLAIM_SECRET_BLOCK": secretBlock,
+		"PASSWORD_CLAIM_SIGNATURE":    signature,
+	}
+	if mw.ClientSecret != "" {
+		responses["SECRET_HASH"] = mw.secretHash(username)
+	}
+	var output struct {
+		ChallengeName        string
+		AuthenticationResult authenticationResult
+	}
+	input = map[string]interface{}{
+		"ChallengeName":      "PASSWORD_VERIFIER",
+		"ClientId":           mw.ClientID,
+		"ChallengeResponses": responses,
+		"Session":            challenge.Session,
+	}
+	if err := mw.callCognito(ctx, "RespondToAuthChallenge", input, &output); err != nil {
+		return nil, err
+	}
+	if output.ChallengeName != "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedChallenge, output.ChallengeName)
+	}
+	result := output.AuthenticationResult
+	return &Tokens{
+		IDToken:        result.IDToken,
+		AccessToken:    result.AccessToken,
+		RefreshToken:   result.RefreshToken,
+		ExpiresIn:      result.ExpiresIn,
+		TokenType:      result.TokenType,
+		DeviceKey:      result.NewDeviceMetadata.DeviceKey,
+		DeviceGroupKey: result.NewDeviceMetadata.DeviceGroupKey,
+	}, nil
+}
+
+// srpKey derives the password authentication key from the SRP exchange. The SRP_B and SALT parameters which are
+// missing or not hexadecimal numbers, and the B which are multiples of N, are rejected with an ErrInvalidChallenge:
+// the latter would make the key independent of the password.
+func (mw *AuthMiddleware) srpKey(a, bigA *big.Int, userID, password, hexB, hexSalt string) ([]byte, error) {
+	if !isHex(hexB) {
+		return nil, fmt.Errorf("%w: the SRP_B %q is not a hexadecimal number", ErrInvalidChallenge, hexB)
+	}
+	if !isHex(hexSalt) {
+		return nil, fmt.Errorf("%w: the SALT %q is not a hexadecimal number", ErrInvalidChallenge, hexSalt)
+	}
+	bigB := hexToInt(hexB)
+	if new(big.Int).Mod(bigB, srpPrime).Sign() == 0 {
+		return nil, fmt.Errorf("%w: the SRP_B is a multiple of N", ErrInvalidChallenge)
+	}
+	u := hexToInt(hexHash(padHex(bigA.Text(16)) + padHex(bigB.Text(16))))
+	if u.Sign() == 0 {
+		return nil, fmt.Errorf("%w: the scrambler u is zero", ErrInvalidChallenge)
+	}
+	x := srpX(mw.poolName(), userID, password, hexSalt)
+
+	// S = (B - k * g^x) ^ (a + u * x) mod N
+	base := new(big.Int).Sub(bigB, new(big.Int).Mul(srpK, new(big.Int).Exp(srpG, x, srpPrime)))
+	base.Mod(base, srpPrime)
+	exponent := new(big.Int).Add(a, new(big.Int).Mul(u, x))
+	return srpHKDF(new(big.Int).Exp(base, exponent, srpPrime), u), nil
+}
+
+// srpHKDF derives the 16 bytes key from the shared secret S and the scrambler u with HKDF
+func srpHKDF(s, u *big.Int) []byte {
+	ikm, _ := hex.DecodeString(padHex(s.Text(16)))
+	salt, _ := hex.DecodeString(padHex(u.Text(16)))
+	prk := hmacSHA256(salt, ikm)
+	return hmacSHA256(prk, []byte("Caldera Derived Key\x01"))[:16]
+}
+
+// poolName the user pool ID without its region prefix
+func (mw *AuthMiddleware) poolName() string {
+	parts := strings.SplitN(mw.UserPoolID, "_", 2)
+	return parts[len(parts)-1]
+}
+
+// srpX the private key derived from the password
+func srpX(poolName, userID, password, hexSalt string) *big.Int {
+	sum := sha256.Sum256([]byte(poolName + userID + ":" + password))
+	return hexToInt(hexHash(padHex(hexSalt) + hex.EncodeToString(sum[:])))
+}
+
+// srpSignature the PASSWORD_CLAIM_SIGNATURE of the challenge
+func srpSignature(key []byte, poolName, userID, secretBlock, timestamp string) (string, error) {
+	block, err := base64.StdEncoding.DecodeString(secretBlock)
+	if err != nil {
+		return "", fmt.Errorf("decoding the secret block: %w", err)
+	}
+	message := append(append([]byte(poolName+userID), block...), timestamp...)
+	return base64.StdEncoding.EncodeToString(hmacSHA256(key, message)), nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// hexHash the hex encoded SHA-256 of the hex encoded bytes
+func hexHash(hexData string) string {
+	data, _ := hex.DecodeString(hexData)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// isHex whether the string is a non empty sequence of hexadecimal digits
+func isHex(hexData string) bool {
+	return hexData != "" && strings.Trim(hexData, "0123456789abcdefABCDEF") == ""
+}
+
+func hexToInt(hexData string) *big.Int {
+	n, _ := new(big.Int).SetString(hexData, 16)
+	return n
+}
+
+// padHex pads the hex encoded positive integer to whole bytes, prefixing a zero byte when its high bit is set
+func padHex(hexData string) string {
+	if len(hexData)%2 == 1 {
+		return "0" + hexData
+	}
+	if strings.ContainsRune("89abcdefABCDEF", rune(hexData[0])) {
+		return "00" + hexData
+	}
+	return hexData
+}
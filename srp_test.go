@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_AuthenticateSRP(t *testing.T) {
+	t.Logf("Given a user pool verifying the passwords with SRP")
+	{
+		poolName := strings.SplitN(TestUserPoolID, "_", 2)[1]
+		salt := "a1b2c3d4e5f6"
+		secretBlock := base64.StdEncoding.EncodeToString([]byte("secret block"))
+		verifier := new(big.Int).Exp(srpG, srpX(poolName, "user-123", "correct horse", salt), srpPrime)
+		b, _ := rand.Int(rand.Reader, srpPrime)
+		bigB := new(big.Int).Add(new(big.Int).Mul(srpK, verifier), new(big.Int).Exp(srpG, b, srpPrime))
+		bigB.Mod(bigB, srpPrime)
+		var bigA *big.Int
+
+		defer cognitoServer(func(action string, input map[string]interface{}) (int, interface{}) {
+			switch action {
+			case "InitiateAuth":
+				params := input["AuthParameters"].(map[string]interface{})
+				assert.Equal(t, "USER_SRP_AUTH", input["AuthFlow"])
+				assert.Equal(t, "jdoe", params["USERNAME"])
+				bigA = hexToInt(params["SRP_A"].(string))
+				return http.StatusOK, map[string]interface{}{
+					"ChallengeName": "PASSWORD_VERIFIER",
+					"Session":       "session",
+					"ChallengeParameters": map[string]string{
+						"USER_ID_FOR_SRP": "user-123",
+						"SRP_B":           bigB.Text(16),
+						"SALT":            salt,
+						"SECRET_BLOCK":    secretBlock,
+					},
+				}
+			case "RespondToAuthChallenge":
+				responses := input["ChallengeResponses"].(map[string]interface{})
+				assert.Equal(t, "session", input["Session"])
+
+				// S = (A * v^u) ^ b mod N
+				u := hexToInt(hexHash(padHex(bigA.Text(16)) + padHex(bigB.Text(16))))
+				base := new(big.Int).Mul(bigA, new(big.Int).Exp(verifier, u, srpPrime))
+				s := new(big.Int).Exp(base.Mod(base, srpPrime), b, srpPrime)
+				expected, _ := srpSignature(srpHKDF(s, u), poolName, "user-123", secretBlock, responses["TIMESTAMP"].(string))
+				if responses["PASSWORD_CLAIM_SIGNATURE"] != expected {
+					return http.StatusBadRequest, map[string]string{"__type": "NotAuthorizedException", "message": "Incorrect username or password."}
+				}
+				return http.StatusOK, map[string]interface{}{
+					"AuthenticationResult": map[string]interface{}{"IdToken": "id", "AccessToken": "access", "RefreshToken": "refresh", "ExpiresIn": 3600},
+				}
+			}
+			return http.StatusBadRequest, nil
+		})()
+		mw := newTestMiddleware()
+		mw.ClientID = "cli"
+
+		tokens, err := mw.AuthenticateSRP(context.Background(), "jdoe", "correct horse")
+		assert.Nil(t, err)
+		assert.Equal(t, "access", tokens.AccessToken)
+
+		_, err = mw.AuthenticateSRP(context.Background(), "jdoe", "wrong password")
+		var cognitoErr *CognitoError
+		assert.True(t, errors.As(err, &cognitoErr))
+		assert.Equal(t, "NotAuthorizedException", cognitoErr.Type)
+	}
+}
+
+func Test_SRPChallengeParameters(t *testing.T) {
+	t.Logf("Given challenges whose SRP_B or SALT is missing, not hexadecimal, or B a multiple of N")
+	{
+		mw := newTestMiddleware()
+		a := big.NewInt(12345)
+		bigA := new(big.Int).Exp(srpG, a, srpPrime)
+		for name, params := range map[string][2]string{
+			"missing B":    {"", "a1b2"},
+			"non hex B":    {"xyz", "a1b2"},
+			"signed B":     {"-ff", "a1b2"},
+			"zero B":       {"0", "a1b2"},
+			"B equal to N": {srpN, "a1b2"},
+			"missing salt": {"ff", ""},
+			"non hex salt": {"ff", "salt"},
+		} {
+			_, err := mw.srpKey(a, bigA, "user-123", "correct horse", params[0], params[1])
+			assert.ErrorIs(t, err, ErrInvalidChallenge, name)
+		}
+
+		t.Logf("Then the valid parameters derive a key")
+		key, err := mw.srpKey(a, bigA, "user-123", "correct horse", "ff", "a1b2")
+		assert.NoError(t, err)
+		assert.Len(t, key, 16)
+	}
+}
+
+func Test_PadHex(t *testing.T) {
+	t.Logf("Given hex encoded integers")
+	{
+		assert.Equal(t, "0abc", padHex("abc"))
+		assert.Equal(t, "00ff", padHex("ff"))
+		assert.Equal(t, "7f", padHex("7f"))
+	}
+}
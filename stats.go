@@ -0,0 +1,118 @@
+package jwt
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Stats a snapshot of the runtime statistics of the middleware
+type Stats struct {
+
+	// Validated the number of tokens validated successfully
+	Validated uint64
+
+	// Failures the number of rejected tokens by reason, see Metrics for the reasons
+	Failures map[string]uint64
+
+	// Keys the number of json web keys known to the middleware
+	Keys int
+
+	// JWKLoadedAt when the json web key set has been downloaded, zero when the keys have been set by hand
+	JWKLoadedAt time.Time
+
+	// JWKAge the age of the json web key set, zero when the keys have been set by hand
+	JWKAge time.Duration
+
+	// LastRefreshError the error of the last refresh of the json web key set, empty when it succeeded
+	LastRefreshError string `json:",omitempty"`
+
+	// UnknownKeyRefreshes the number of refreshes of the json web key set on unknown kids, see RefreshOnUnknownKey
+	UnknownKeyRefreshes uint64 `json:",omitempty"`
+
+	// Authorizer the cache metrics of the Authorizer when it is a CachedAuthorizer
+	Authorizer *CacheMetrics `json:",omitempty"`
+
+	// TokenCache the metrics of the TokenCache, if any
+	TokenCache *CacheMetrics `json:",omitempty"`
+
+	// DroppedHooks the number of hooks dropped by the Hooks dispatcher, its buffer being full
+	DroppedHooks uint64 `json:",omitempty"`
+
+	// RejectionCache the metrics of the RejectionCache, if any
+	RejectionCache *CacheMetrics `json:",omitempty"`
+}
+
+// stats the counters backing Stats
+type stats struct {
+	mu        sync.Mutex
+	validated uint64
+	failures  map[string]uint64
+}
+
+// observe records the outcome of a validation, reason is empty for valid tokens
+func (mw *AuthMiddleware) observe(reason string, latency time.Duration) {
+	stats := &mw.shared().stats
+	stats.mu.Lock()
+	if reason == "" {
+		stats.validated++
+	} else {
+		if stats.failures == nil {
+			stats.failures = make(map[string]uint64)
+		}
+		stats.failures[reason]++
+	}
+	stats.mu.Unlock()
+
+	if mw.Metrics != nil {
+		mw.hook(func() { mw.Metrics.ObserveValidation(reason, latency) })
+	}
+}
+
+// Stats returns a snapshot of the runtime statistics of the middleware
+func (mw *AuthMiddleware) Stats() Stats {
+	shared := mw.shared()
+	shared.stats.mu.Lock()
+	snapshot := Stats{Validated: shared.stats.validated, Failures: make(map[string]uint64, len(shared.stats.failures))}
+	for reason, count := range shared.stats.failures {
+		snapshot.Failures[reason] = count
+	}
+	shared.stats.mu.Unlock()
+
+	shared.keysMu.RLock()
+	snapshot.Keys = len(shared.JWK)
+	snapshot.JWKLoadedAt = shared.jwkLoadedAt
+	snapshot.UnknownKeyRefreshes = shared.unknownKeyCount
+	if shared.lastRefreshErr != nil {
+		snapshot.LastRefreshError = shared.lastRefreshErr.Error()
+	}
+	shared.keysMu.RUnlock()
+	if !snapshot.JWKLoadedAt.IsZero() {
+		snapshot.JWKAge = time.Since(snapshot.JWKLoadedAt)
+	}
+
+	if cached, ok := mw.Authorizer.(*CachedAuthorizer); ok {
+		metrics := cached.Metrics()
+		snapshot.Authorizer = &metrics
+	}
+	if mw.TokenCache != nil {
+		metrics := mw.TokenCache.Metrics()
+		snapshot.TokenCache = &metrics
+	}
+	if mw.Hooks != nil {
+		snapshot.DroppedHooks = mw.Hooks.Dropped()
+	}
+	if mw.RejectionCache != nil {
+		metrics := mw.RejectionCache.Metrics()
+		snapshot.RejectionCache = &metrics
+	}
+	return snapshot
+}
+
+// PublishExpvar publishes the Stats of the middleware under the given expvar name, served by the
+// /debug/vars handler. Like expvar.Publish, it panics when the name is already in use.
+func (mw *AuthMiddleware) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return mw.Stats()
+	}))
+}
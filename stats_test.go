@@ -0,0 +1,177 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Stats(t *testing.T) {
+	t.Logf("Given a middleware which validated and rejected tokens")
+	{
+		mw := newTestMiddleware()
+		mw.Authorizer = NewCachedAuthorizer(AuthorizerFunc(func(ctx context.Context, req AuthorizationRequest) (bool, error) { return true, nil }), time.Minute, 10)
+		now := time.Now().Truncate(time.Second)
+		mw.TimeFunc = func() time.Time { return now }
+		router := authzHandler(mw)
+
+		claims := testClaims()
+		claims["iat"] = now.Unix()
+		claims["exp"] = now.Add(time.Hour).Unix()
+		performRequest(router, "GET", "/orders", signToken(claims))
+		performRequest(router, "GET", "/orders", signToken(claims))
+		performRequest(router, "GET", "/orders", "not-a-token")
+		performRequest(router, "GET", "/orders", "")
+
+		stats := mw.Stats()
+		assert.Equal(t, uint64(2), stats.Validated)
+		assert.Equal(t, map[string]uint64{"malformed": 1, "missing_token": 1}, stats.Failures)
+		assert.Equal(t, 1, stats.Keys)
+		assert.True(t, stats.JWKLoadedAt.IsZero())
+		assert.Equal(t, uint64(1), stats.Authorizer.Hits)
+		assert.Equal(t, uint64(1), stats.Authorizer.Misses)
+
+		mw.PublishExpvar("cognito_jwt_stats_test")
+		var published Stats
+		assert.Nil(t, json.Unmarshal([]byte(expvar.Get("cognito_jwt_stats_test").String()), &published))
+		assert.Equal(t, uint64(2), published.Validated)
+	}
+}
+
+func Test_RefreshJWK(t *testing.T) {
+	t.Logf("Given a user pool serving its json web key set")
+	{
+		available := true
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !available {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			keys := []JWKKey{}
+			for _, key := range testJWK() {
+				keys = append(keys, key)
+			}
+			json.NewEncoder(w).Encode(JWK{Keys: keys})
+		}))
+		defer server.Close()
+		defer func(format string) { jwkURLFormat = format }(jwkURLFormat)
+		jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+
+		mw := &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID}
+		assert.Nil(t, mw.RefreshJWK())
+		stats := mw.Stats()
+		assert.Equal(t, 1, stats.Keys)
+		assert.False(t, stats.JWKLoadedAt.IsZero())
+		assert.Empty(t, stats.LastRefreshError)
+
+		available = false
+		assert.NotNil(t, mw.RefreshJWK())
+		stats = mw.Stats()
+		assert.Equal(t, 1, stats.Keys)
+		assert.NotEmpty(t, stats.LastRefreshError)
+	}
+}
+
+func Test_ConcurrentRefreshesShareADownload(t *testing.T) {
+	t.Logf("Given many requests refreshing the json web key set at once")
+	{
+		var downloads int64
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&downloads, 1)
+			<-release
+			keys := []JWKKey{}
+			for _, key := range testJWK() {
+				keys = append(keys, key)
+			}
+			json.NewEncoder(w).Encode(JWK{Keys: keys})
+		}))
+		defer server.Close()
+		defer func(format string) { jwkURLFormat = format }(jwkURLFormat)
+		jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+
+		mw := &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, mw.RefreshJWK())
+			}()
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int64(1), atomic.LoadInt64(&downloads))
+		assert.Equal(t, 1, mw.KeyCount())
+	}
+}
+
+func Test_RefreshJWKReusesConnections(t *testing.T) {
+	t.Logf("Given a user pool serving its json web key set")
+	{
+		var connections int64
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []JWKKey{}
+			for _, key := range testJWK() {
+				keys = append(keys, key)
+			}
+			json.NewEncoder(w).Encode(JWK{Keys: keys})
+		}))
+		server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt64(&connections, 1)
+			}
+		}
+		server.Start()
+		defer server.Close()
+		defer func(format string) { jwkURLFormat = format }(jwkURLFormat)
+		jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+
+		mw := &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID}
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, mw.RefreshJWK())
+		}
+		assert.Equal(t, int64(1), atomic.LoadInt64(&connections))
+	}
+}
+
+func Test_RefreshJWKContext(t *testing.T) {
+	t.Logf("Given a refresh given up while the download is in progress")
+	{
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			keys := []JWKKey{}
+			for _, key := range testJWK() {
+				keys = append(keys, key)
+			}
+			json.NewEncoder(w).Encode(JWK{Keys: keys})
+		}))
+		defer server.Close()
+		defer func(format string) { jwkURLFormat = format }(jwkURLFormat)
+		jwkURLFormat = server.URL + "/%v/%v/.well-known/jwks.json"
+
+		mw := &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID}
+		refreshed := make(chan error)
+		go func() { refreshed <- mw.RefreshJWK() }()
+		time.Sleep(20 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, mw.RefreshJWKContext(ctx), context.DeadlineExceeded)
+
+		close(release)
+		assert.NoError(t, <-refreshed, "the shared download is not cancelled")
+		assert.Equal(t, 1, mw.KeyCount())
+	}
+}
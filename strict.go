@@ -0,0 +1,50 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"strings"
+)
+
+// minStrictKeyBits the minimum size of the RSA keys verifying the tokens in StrictMode
+const minStrictKeyBits = 2048
+
+// strictHeader rejects in StrictMode the tokens whose segments are padded and the ones carrying a crit header, none
+// of the critical extensions being understood by the middleware
+func strictHeader(token *jwtgo.Token) error {
+	if strings.ContainsRune(token.Raw, '=') {
+		return tokenError(ErrMalformedToken, errors.New("the segments of the token are padded"))
+	}
+	if crit, ok := token.Header["crit"]; ok {
+		return tokenError(ErrMalformedToken, fmt.Errorf("the critical header extensions %v are not understood", crit))
+	}
+	return nil
+}
+
+// strictKey rejects in StrictMode the keys naming no algorithm and the RSA keys shorter than minStrictKeyBits
+func strictKey(token *jwtgo.Token, publicKey interface{}, alg string) error {
+	if alg == "" {
+		return fmt.Errorf("%w: the key %v names no algorithm", ErrUnexpectedSigningMethod, token.Header["kid"])
+	}
+	if key, ok := publicKey.(*rsa.PublicKey); ok && key.N.BitLen() < minStrictKeyBits {
+		return tokenError(ErrWeakKey, fmt.Errorf("the key %v is shorter than %d bits", token.Header["kid"], minStrictKeyBits))
+	}
+	return nil
+}
+
+// strictClaims rejects in StrictMode the tokens of another issuer than the one of the middleware, whether issued by
+// Cognito or not, and the ones lacking their exp or iat
+func (mw *AuthMiddleware) strictClaims(claims jwtgo.MapClaims) error {
+	iss := mw.issuer()
+	if claims["iss"] != iss {
+		return tokenError(ErrBadIssuer, fmt.Errorf("the token is issued by %v, expecting %s", claims["iss"], iss))
+	}
+	for _, name := range []string{"exp", "iat"} {
+		if _, ok := claims[name].(float64); !ok {
+			return tokenError(ErrInvalidClaims, fmt.Errorf("the token has no %s claim", name))
+		}
+	}
+	return nil
+}
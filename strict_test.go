@@ -0,0 +1,103 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_StrictMode(t *testing.T) {
+	t.Logf("Given a middleware in strict mode")
+	{
+		mw := newTestMiddleware()
+		mw.StrictMode = true
+		router := authzHandler(mw)
+
+		t.Logf("Then a valid token is accepted")
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+
+		t.Logf("And the tokens the lenient parsing accepts are rejected")
+		withCrit := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+		withCrit.Header["kid"] = TestKid
+		withCrit.Header["crit"] = []string{"exp"}
+		critToken, _ := withCrit.SignedString(testKey)
+
+		foreign := testClaims()
+		foreign["iss"] = "https://idp.example.com"
+		noExp := testClaims()
+		delete(noExp, "exp")
+		noIat := testClaims()
+		delete(noIat, "iat")
+
+		for name, test := range map[string]struct {
+			token string
+			err   error
+		}{
+			"crit header":    {critToken, ErrMalformedToken},
+			"padded":         {padded(signToken(testClaims())), ErrMalformedToken},
+			"foreign issuer": {signToken(foreign), ErrBadIssuer},
+			"no exp":         {signToken(noExp), ErrInvalidClaims},
+			"no iat":         {signToken(noIat), ErrInvalidClaims},
+		} {
+			_, err := mw.validateToken(test.token, mw.log())
+			assert.True(t, errors.Is(err, test.err), "%s: %v", name, err)
+			assert.Equal(t, http.StatusUnauthorized, performRequest(router, "GET", "/orders", test.token).Code, name)
+		}
+
+		t.Logf("And they are accepted outside of the strict mode")
+		lenient := newTestMiddleware()
+		delete(foreign, "exp")
+		for name, token := range map[string]string{"crit header": critToken, "foreign issuer without exp": signToken(foreign)} {
+			_, err := lenient.validateToken(token, lenient.log())
+			assert.NoError(t, err, name)
+		}
+	}
+
+	t.Logf("Given a strict middleware whose keys are weak or name no algorithm")
+	{
+		weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+		assert.NoError(t, err)
+		mw := newTestMiddleware()
+		mw.StrictMode = true
+		mw.JWK = map[string]JWKKey{
+			TestKid: {Alg: "RS256", Kid: TestKid, Kty: "RSA", Use: "sig",
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(weakKey.E)).Bytes()),
+				N: base64.RawURLEncoding.EncodeToString(weakKey.N.Bytes())},
+		}
+		token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, testClaims())
+		token.Header["kid"] = TestKid
+		weakToken, _ := token.SignedString(weakKey)
+
+		t.Logf("Then the tokens signed with a key shorter than 2048 bits are rejected")
+		_, err = mw.validateToken(weakToken, mw.log())
+		assert.True(t, errors.Is(err, ErrWeakKey), err)
+		assert.Equal(t, "weak_key", failureReason(err))
+
+		t.Logf("And the keys naming no algorithm are rejected")
+		noAlg := testJWK()
+		key := noAlg[TestKid]
+		key.Alg = ""
+		noAlg[TestKid] = key
+		mw = newTestMiddleware()
+		mw.StrictMode = true
+		mw.JWK = noAlg
+		_, err = mw.validateToken(signToken(testClaims()), mw.log())
+		assert.True(t, errors.Is(err, ErrUnexpectedSigningMethod), err)
+	}
+}
+
+// padded pads the segments of the token with the base64 padding
+func padded(token string) string {
+	segments := strings.Split(token, ".")
+	for i, segment := range segments {
+		segments[i] = segment + strings.Repeat("=", (4-len(segment)%4)%4)
+	}
+	return strings.Join(segments, ".")
+}
@@ -0,0 +1,353 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TenantHeader the header naming the tenant of the requests, see TenantFromHeader
+const TenantHeader = "X-Tenant"
+
+// TenantResolver resolves the tenant of the requests, routing them to the user pool of the tenant, see
+// AuthMiddleware.Tenants
+type TenantResolver interface {
+
+	// ResolveTenant the ID of the tenant of the request, empty when the request names none
+	ResolveTenant(r *http.Request) (string, error)
+}
+
+// TenantResolverFunc adapter to use an ordinary function as a TenantResolver
+type TenantResolverFunc func(r *http.Request) (string, error)
+
+// ResolveTenant calls f(r)
+func (f TenantResolverFunc) ResolveTenant(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// TenantFromHeader resolves the tenant from the given header, TenantHeader when empty
+func TenantFromHeader(name string) TenantResolver {
+	if name == "" {
+		name = TenantHeader
+	}
+	return TenantResolverFunc(func(r *http.Request) (string, error) {
+		return r.Header.Get(name), nil
+	})
+}
+
+// TenantFromSubdomain resolves the tenant from the subdomain of the Host of the requests to the given domain, e.g.
+// acme for acme.api.example.com with the api.example.com domain. The requests to the domain itself name no tenant.
+func TenantFromSubdomain(domain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return TenantResolverFunc(func(r *http.Request) (string, error) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		host = strings.ToLower(host)
+		if host == suffix[1:] {
+			return "", nil
+		}
+		subdomain, ok := strings.CutSuffix(host, suffix)
+		if !ok || subdomain == "" || strings.Contains(subdomain, ".") {
+			return "", fmt.Errorf("the host %s is not a subdomain of %s", r.Host, suffix[1:])
+		}
+		return subdomain, nil
+	})
+}
+
+// TenantFromPathPrefix resolves the tenant from the first segment of the path of the requests, e.g. acme for
+// /acme/orders
+func TenantFromPathPrefix() TenantResolver {
+	return TenantResolverFunc(func(r *http.Request) (string, error) {
+		tenant, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, ForwardSlash), ForwardSlash)
+		return tenant, nil
+	})
+}
+
+// tenantPool returns the middleware of the user pool of the tenant of the request, nil when the request names no
+// tenant. The unknown tenants are rejected with an ErrUnknownTenant.
+func (mw *AuthMiddleware) tenantPool(r *http.Request) (string, *AuthMiddleware, error) {
+	tenant, err := mw.TenantResolver.ResolveTenant(r)
+	if err != nil {
+		return "", nil, tokenError(ErrUnknownTenant, fmt.Errorf("resolving the tenant: %w", err))
+	}
+	if tenant == "" {
+		return "", nil, nil
+	}
+	pools := mw.poolIndex()
+	pools.mu.RLock()
+	pool, ok := pools.byTenant[tenant]
+	pools.mu.RUnlock()
+	if !ok {
+		return tenant, nil, tokenError(ErrUnknownTenant, fmt.Errorf("the tenant %q is unknown", tenant))
+	}
+	return tenant, pool, nil
+}
+
+// TenantSource where the tenant of a request was resolved from, see Tenant
+type TenantSource string
+
+const (
+
+	// TenantFromRequest the tenant was resolved from the request by the TenantResolver
+	TenantFromRequest TenantSource = "request"
+
+	// TenantFromIssuer the request named no tenant, the tenant was resolved from the user pool of the issuer of the
+	// token, its ID being empty when the user pool serves several tenants
+	TenantFromIssuer TenantSource = "issuer"
+)
+
+// Tenant the tenant of an authenticated request, stored in the gin context by the middleware when a TenantResolver
+// is set, so that the handlers and the data access layers enforce the tenancy without deriving it again
+type Tenant struct {
+
+	// ID the ID of the tenant, as per the TenantResolver or the Tenants
+	ID string
+
+	// UserPoolID the ID of the user pool of the tenant
+	UserPoolID string
+
+	// Region the AWS region of the user pool of the tenant
+	Region string
+
+	// Issuer the issuer of the tokens of the user pool of the tenant
+	Issuer string
+
+	// ResolvedFrom where the tenant was resolved from
+	ResolvedFrom TenantSource
+}
+
+// GetTenant returns the Tenant stored in the context by the middleware
+func GetTenant(c *gin.Context) (*Tenant, bool) {
+	value, ok := c.Get(TenantKey)
+	if !ok {
+		return nil, false
+	}
+	tenant, ok := value.(*Tenant)
+	return tenant, ok
+}
+
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a copy of ctx holding the tenant. The middleware stores the tenant this way in the
+// context of the request too, so that the data access layers find it from a context.Context.
+func ContextWithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored in ctx by ContextWithTenant
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant, ok
+}
+
+// TenantPolicy the validation policy of the tokens of a user pool or a tenant, on top of the options of the
+// middleware, so that the stricter tenants, e.g. the regulated customers, get tighter policies from the same
+// middleware. See AuthMiddleware.Policy and AuthMiddleware.TenantPolicies.
+type TenantPolicy struct {
+
+	// Required the groups, scopes and token_use required on every route, the requests failing them are answered
+	// with a 403
+	Required RouteRequirement
+
+	// Audiences the app clients the tokens must be issued to, as per their client_id or aud, any when empty
+	Audiences []string
+
+	// MaxTokenAge the maximum age of the tokens as per their iat, e.g. to have the callers sign in again more
+	// often, no limit when 0. The older tokens are rejected with an ErrTokenExpired.
+	MaxTokenAge time.Duration
+
+	// Quota the requests allowed per principal, counted by the QuotaStore of the middleware, no limit when nil
+	Quota *Quota
+}
+
+// validate checks the token_use, the max token age and the quota of the policy
+func (p *TenantPolicy) validate() error {
+	var errs []error
+	if err := validateTokenUse(p.Required.TokenUse); err != nil {
+		errs = append(errs, err)
+	}
+	if p.MaxTokenAge < 0 {
+		errs = append(errs, fmt.Errorf("the max token age %v is negative", p.MaxTokenAge))
+	}
+	if p.Quota != nil {
+		if err := p.Quota.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkToken rejects the tokens issued to another app client than the Audiences or older than the MaxTokenAge
+func (p *TenantPolicy) checkToken(claims jwtgo.MapClaims, now time.Time) error {
+	if clientID := NewClaims(claims).ClientID; len(p.Audiences) > 0 && !slices.Contains(p.Audiences, clientID) {
+		return tokenError(ErrInvalidClaims, fmt.Errorf("the token is issued to the app client %q, not one of %v", clientID, p.Audiences))
+	}
+	if p.MaxTokenAge > 0 {
+		iat, ok := claims["iat"].(float64)
+		if !ok {
+			return tokenError(ErrInvalidClaims, errors.New("the token has no iat, its age is unknown"))
+		}
+		if age := now.Sub(time.Unix(int64(iat), 0)); age > p.MaxTokenAge {
+			return tokenError(ErrTokenExpired, fmt.Errorf("the token is %v old, older than %v", age.Round(time.Second), p.MaxTokenAge))
+		}
+	}
+	return nil
+}
+
+// checkTenant rejects with an ErrTenantMismatch the tokens which are not issued by the user pool of the tenant the
+// request is routed to by the TenantResolver, then checks the token against the policy of the tenant, or of the
+// user pool of the token when the request names no tenant. It returns the tenant of the request, nil without a
+// TenantResolver, and its policy, nil when there is none. The tokens validated without a request, r being nil, are
+// rejected with an ErrUnknownTenant when a TenantResolver is set.
+func (mw *AuthMiddleware) checkTenant(r *http.Request, token *jwtgo.Token, logger Logger) (*Tenant, *TenantPolicy, error) {
+	claims := token.Claims.(jwtgo.MapClaims)
+	iss, _ := claims["iss"].(string)
+	var tenant string
+	var pool *AuthMiddleware
+	var err error
+	switch {
+	case mw.TenantResolver != nil && r == nil:
+		err = tokenError(ErrUnknownTenant, errors.New("the tenant cannot be resolved without the request, see ValidateRequest"))
+	case mw.TenantResolver != nil:
+		tenant, pool, err = mw.tenantPool(r)
+		if err == nil {
+			err = mw.checkTenancy(tenant, pool, claims)
+		}
+	}
+	var resolved *Tenant
+	var policy *TenantPolicy
+	if err == nil {
+		resolved, policy = mw.resolvedTenant(tenant, pool, iss)
+		if policy != nil {
+			err = policy.checkToken(claims, mw.now())
+		}
+	}
+	if err != nil {
+		mw.observe(failureReason(err), 0)
+		if mw.sampleFailure(failureReason(err)) {
+			logger.Warn("Rejected the jwt token as per its tenant", "tenant", tenant, "error_class", failureReason(err), "error", err)
+		}
+		return nil, nil, err
+	}
+	return resolved, policy, nil
+}
+
+// authorizeTenant enforces the tenancy of the request, r being nil outside of a request, on its validated token, then
+// the policy of its tenant: the Required groups, scopes and token_use, failing with an AccessError, and the Quota,
+// failing with an ErrQuotaExceeded. It returns the tenant of the request, nil without a TenantResolver.
+func (mw *AuthMiddleware) authorizeTenant(r *http.Request, token *jwtgo.Token, logger Logger) (*Tenant, error) {
+	tenant, policy, err := mw.checkTenant(r, token, logger)
+	if err != nil || policy == nil {
+		return tenant, err
+	}
+	principal := NewPrincipal(token.Claims.(jwtgo.MapClaims))
+	if err := mw.requirementError(principal, policy.Required); err != nil {
+		return tenant, err
+	}
+	if _, err := mw.takeQuota(logger, principal, tenant, policy); err != nil {
+		return tenant, err
+	}
+	return tenant, nil
+}
+
+// checkTenancy rejects with an ErrTenantMismatch the tokens which are not issued by the user pool of the tenant,
+// nil when the request names no tenant, or which name another tenant in their TenantClaim. In StrictTenancy the
+// requests naming no tenant are rejected with an ErrUnknownTenant.
+func (mw *AuthMiddleware) checkTenancy(tenant string, pool *AuthMiddleware, claims jwtgo.MapClaims) error {
+	if pool == nil {
+		if mw.StrictTenancy {
+			return tokenError(ErrUnknownTenant, errors.New("the request names no tenant"))
+		}
+		return nil
+	}
+	if iss, _ := claims["iss"].(string); iss != pool.issuer() {
+		return tokenError(ErrTenantMismatch, fmt.Errorf("the token is issued by %s, not by the user pool of the tenant %s", iss, tenant))
+	}
+	if mw.TenantClaim == "" {
+		return nil
+	}
+	if claimed, _ := claims[mw.TenantClaim].(string); claimed != tenant {
+		return tokenError(ErrTenantMismatch, fmt.Errorf("the token is issued to the tenant %q, not to %s", claimed, tenant))
+	}
+	return nil
+}
+
+// validateTenancy checks that StrictTenancy routes the requests to their tenant, and that the tenants sharing a user
+// pool are told apart by a TenantClaim
+func (mw *AuthMiddleware) validateTenancy() error {
+	if !mw.StrictTenancy {
+		return nil
+	}
+	var errs []error
+	if mw.TenantResolver == nil {
+		errs = append(errs, errors.New("the strict tenancy requires a tenant resolver"))
+	}
+	if err := mw.checkSharedPools(mw.Tenants); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkSharedPools rejects in StrictTenancy the tenants sharing a user pool without a TenantClaim
+func (mw *AuthMiddleware) checkSharedPools(tenants map[string]*AuthMiddleware) error {
+	if !mw.StrictTenancy || mw.TenantClaim != "" {
+		return nil
+	}
+	byPool := make(map[*AuthMiddleware][]string, len(tenants))
+	for tenant, pool := range tenants {
+		byPool[pool] = append(byPool[pool], tenant)
+	}
+	var errs []error
+	for pool, shared := range byPool {
+		if len(shared) > 1 && pool != nil {
+			sort.Strings(shared)
+			errs = append(errs, fmt.Errorf("the tenants %s share the user pool %s without a tenant claim", strings.Join(shared, ", "), pool.issuer()))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// resolvedTenant returns the tenant of the request, nil without a TenantResolver, and its policy: the one of the
+// tenant in the TenantPolicies, the Policy of its user pool otherwise. The requests naming no tenant get the user
+// pool of the issuer, and its tenant when it is the only one of the pool.
+func (mw *AuthMiddleware) resolvedTenant(tenant string, pool *AuthMiddleware, iss string) (*Tenant, *TenantPolicy) {
+	source := TenantFromRequest
+	if pool == nil && !mw.StrictTenancy {
+		source = TenantFromIssuer
+		pools := mw.poolIndex()
+		pools.mu.RLock()
+		pool = pools.byIssuer[iss]
+		var tenants []string
+		for id, tenantPool := range pools.byTenant {
+			if pool != nil && tenantPool == pool {
+				tenants = append(tenants, id)
+			}
+		}
+		pools.mu.RUnlock()
+		if len(tenants) == 1 {
+			tenant = tenants[0]
+		}
+	}
+	var policy *TenantPolicy
+	if pool != nil {
+		policy = pool.Policy
+	}
+	if tenantPolicy, ok := mw.TenantPolicies[tenant]; ok && tenant != "" {
+		policy = tenantPolicy
+	}
+	if mw.TenantResolver == nil || pool == nil {
+		return nil, policy
+	}
+	return &Tenant{ID: tenant, UserPoolID: pool.UserPoolID, Region: pool.Region, Issuer: pool.issuer(), ResolvedFrom: source}, policy
+}
@@ -0,0 +1,295 @@
+package jwt
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// tenantRequest performs a request to the given url with the token
+func tenantRequest(r http.Handler, url, tenant, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", url, nil)
+	req.Header.Set(AuthorizationHeader, token)
+	if tenant != "" {
+		req.Header.Set(TenantHeader, tenant)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_TenantRouting(t *testing.T) {
+	t.Logf("Given a middleware routing the requests to the user pool of their tenant by header")
+	{
+		acme, globex := newTestMiddleware(), otherPool()
+		mw := &AuthMiddleware{
+			TenantResolver: TenantFromHeader(""),
+			Tenants:        map[string]*AuthMiddleware{"acme": acme, "initech": acme, "globex": globex},
+		}
+		assert.NoError(t, mw.Validate())
+		router := authzHandler(mw)
+		acmeToken, globexToken := signToken(testClaims()), otherPoolToken(testClaims())
+
+		t.Logf("Then the tokens of the user pool of the tenant are accepted, the pools being shared by tenants")
+		for tenant, token := range map[string]string{"acme": acmeToken, "initech": acmeToken, "globex": globexToken} {
+			assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", tenant, token).Code, tenant)
+		}
+
+		t.Logf("And the tokens of the user pool of another tenant are rejected")
+		w := tenantRequest(router, "/orders", "globex", acmeToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "tenant_mismatch")
+
+		t.Logf("And the unknown tenants are rejected, while the requests naming no tenant are served by the issuer")
+		w = tenantRequest(router, "/orders", "hooli", acmeToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "unknown_tenant")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "", globexToken).Code)
+	}
+}
+
+func Test_TenantPolicies(t *testing.T) {
+	t.Logf("Given tenants with their own validation policies")
+	{
+		acme, globex := newTestMiddleware(), otherPool()
+		acme.Policy = &TenantPolicy{Audiences: []string{"test-client", "mobile-client"}}
+		globex.Policy = &TenantPolicy{MaxTokenAge: time.Hour, Required: RouteRequirement{Groups: []string{"staff"}}}
+		mw := &AuthMiddleware{
+			TenantResolver: TenantFromHeader(""),
+			Tenants:        map[string]*AuthMiddleware{"acme": acme, "initech": acme, "globex": globex},
+			TenantPolicies: map[string]*TenantPolicy{"initech": {Required: RouteRequirement{Scopes: []string{"orders/read"}}}},
+		}
+		assert.NoError(t, mw.Validate())
+		router := authzHandler(mw)
+
+		t.Logf("Then the tokens issued to the audiences of the policy are accepted, the others rejected")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", signToken(testClaims())).Code)
+		other := testClaims()
+		other["client_id"] = "other-client"
+		w := tenantRequest(router, "/orders", "acme", signToken(other))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "invalid_claims")
+
+		t.Logf("And the policy of the tenant overrides the one of its user pool")
+		assert.Equal(t, http.StatusForbidden, tenantRequest(router, "/orders", "initech", signToken(testClaims())).Code)
+		scoped := testClaims()
+		scoped["client_id"] = "other-client"
+		scoped["scope"] = "orders/read"
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "initech", signToken(scoped)).Code)
+
+		t.Logf("And the tokens older than the max token age or lacking the required groups are rejected")
+		staff := testClaims()
+		staff["cognito:groups"] = []string{"staff"}
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "globex", otherPoolToken(staff)).Code)
+		assert.Equal(t, http.StatusForbidden, tenantRequest(router, "/orders", "globex", otherPoolToken(testClaims())).Code)
+		staff["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+		w = tenantRequest(router, "/orders", "globex", otherPoolToken(staff))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "expired")
+
+		t.Logf("And the policy of the user pool of the issuer applies to the requests naming no tenant")
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/orders", "", signToken(other)).Code)
+	}
+
+	t.Logf("Given invalid policies")
+	{
+		mw := newTestMiddleware()
+		mw.Policy = &TenantPolicy{MaxTokenAge: -time.Minute}
+		mw.TenantPolicies = map[string]*TenantPolicy{"acme": {Required: RouteRequirement{TokenUse: "refresh"}}, "globex": nil}
+
+		t.Logf("Then the configuration is rejected")
+		err := mw.Validate()
+		assert.ErrorContains(t, err, "the max token age -1m0s is negative")
+		assert.ErrorContains(t, err, "policy of the tenant acme")
+		assert.ErrorContains(t, err, "the policy of the tenant globex is nil")
+	}
+}
+
+func Test_TenantContext(t *testing.T) {
+	t.Logf("Given a middleware routing the requests to the user pool of their tenant")
+	{
+		acme, globex := newTestMiddleware(), otherPool()
+		mw := &AuthMiddleware{
+			TenantResolver: TenantFromHeader(""),
+			Tenants:        map[string]*AuthMiddleware{"acme": acme, "initech": acme, "globex": globex},
+		}
+		var tenants, fromContext []*Tenant
+		router := authzHandler(mw, func(c *gin.Context) {
+			tenant, _ := GetTenant(c)
+			tenants = append(tenants, tenant)
+			tenant, _ = TenantFromContext(c.Request.Context())
+			fromContext = append(fromContext, tenant)
+		})
+
+		t.Logf("Then the tenant of the request is stored in the gin context and in the context of the request")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", signToken(testClaims())).Code)
+		assert.Equal(t, &Tenant{ID: "acme", UserPoolID: TestUserPoolID, Region: TestRegion, Issuer: acme.issuer(), ResolvedFrom: TenantFromRequest}, tenants[0])
+		assert.Same(t, tenants[0], fromContext[0])
+
+		t.Logf("And the requests naming no tenant get the one of the user pool of the token, if it serves a single tenant")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "", otherPoolToken(testClaims())).Code)
+		assert.Equal(t, &Tenant{ID: "globex", UserPoolID: otherUserPoolID, Region: TestRegion, Issuer: globex.issuer(), ResolvedFrom: TenantFromIssuer}, tenants[1])
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "", signToken(testClaims())).Code)
+		assert.Equal(t, "", tenants[2].ID)
+		assert.Equal(t, TestUserPoolID, tenants[2].UserPoolID)
+	}
+
+	t.Logf("Given a middleware without tenant resolver")
+	{
+		var found bool
+		router := authzHandler(newTestMiddleware(), func(c *gin.Context) {
+			_, found = GetTenant(c)
+		})
+
+		t.Logf("Then no tenant is stored in the context")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", signToken(testClaims())).Code)
+		assert.False(t, found)
+	}
+}
+
+func Test_CrossTenantRejection(t *testing.T) {
+	t.Logf("Given a middleware failing closed on the tenancy, caching the tokens and the rejections")
+	{
+		acme, globex := newTestMiddleware(), otherPool()
+		mw := &AuthMiddleware{
+			TenantResolver: TenantFromHeader(""),
+			Tenants:        map[string]*AuthMiddleware{"acme": acme, "initech": acme, "globex": globex},
+			StrictTenancy:  true,
+			TenantClaim:    "custom:tenant_id",
+			TokenCache:     NewTokenCache(10),
+			RejectionCache: NewRejectionCache(time.Minute, 10),
+		}
+		assert.NoError(t, mw.Validate())
+		router := authzHandler(mw)
+		claims := testClaims()
+		claims["custom:tenant_id"] = "acme"
+		acmeToken := signToken(claims)
+		claims = testClaims()
+		claims["custom:tenant_id"] = "globex"
+		globexToken := otherPoolToken(claims)
+
+		t.Logf("Then the tokens of a tenant authorize its requests only, before and after they are cached")
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "acme", acmeToken).Code)
+			assert.Equal(t, http.StatusOK, tenantRequest(router, "/orders", "globex", globexToken).Code)
+			for tenant, token := range map[string]string{"globex": acmeToken, "initech": acmeToken, "acme": globexToken} {
+				w := tenantRequest(router, "/orders", tenant, token)
+				assert.Equal(t, http.StatusUnauthorized, w.Code, tenant)
+				assert.Contains(t, w.Header().Get(AuthenticateHeader), "tenant_mismatch", tenant)
+			}
+		}
+
+		t.Logf("And the tokens naming no tenant are rejected by the tenants sharing their user pool")
+		w := tenantRequest(router, "/orders", "initech", signToken(testClaims()))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "tenant_mismatch")
+
+		t.Logf("And the requests resolving to no tenant are rejected rather than served by the issuer")
+		w = tenantRequest(router, "/orders", "", acmeToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "unknown_tenant")
+
+		t.Logf("And the tenants onboarded at runtime are held to the same guarantees")
+		assert.NoError(t, mw.AddUserPool(context.Background(), &AuthMiddleware{Region: TestRegion, UserPoolID: "eu-west-2_hooli", JWK: testJWK()}, "hooli"))
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/orders", "hooli", acmeToken).Code)
+	}
+
+	t.Logf("Given a forward auth endpoint failing closed on the tenancy")
+	{
+		acme, globex := newTestMiddleware(), otherPool()
+		globex.Policy = &TenantPolicy{Required: RouteRequirement{Groups: []string{"staff"}}}
+		mw := &AuthMiddleware{
+			TenantResolver: TenantFromHeader(""),
+			Tenants:        map[string]*AuthMiddleware{"acme": acme, "globex": globex},
+			StrictTenancy:  true,
+		}
+		assert.NoError(t, mw.Validate())
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/auth", mw.ForwardAuthHandler())
+		acmeToken := signToken(testClaims())
+
+		t.Logf("Then the tokens of a tenant authorize its requests only")
+		assert.Equal(t, http.StatusOK, tenantRequest(router, "/auth", "acme", acmeToken).Code)
+		w := tenantRequest(router, "/auth", "globex", acmeToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "tenant_mismatch")
+		assert.Empty(t, w.Header().Get(ForwardAuthUserHeader))
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(router, "/auth", "", acmeToken).Code)
+
+		t.Logf("And the policy of the tenant is enforced")
+		assert.Equal(t, http.StatusForbidden, tenantRequest(router, "/auth", "globex", otherPoolToken(testClaims())).Code)
+
+		t.Logf("And the adapters are held to the same guarantees, the tokens validated without request being rejected")
+		_, err := mw.ValidateToken(acmeToken)
+		assert.ErrorIs(t, err, ErrUnknownTenant)
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set(TenantHeader, "globex")
+		_, err = mw.ValidateRequest(req, acmeToken)
+		assert.ErrorIs(t, err, ErrTenantMismatch)
+		assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+		_, err = mw.ValidateRequest(req, otherPoolToken(testClaims()))
+		assert.ErrorIs(t, err, ErrMissingGroups)
+		assert.Equal(t, http.StatusForbidden, StatusCode(err))
+		req.Header.Set(TenantHeader, "acme")
+		_, err = mw.ValidateRequest(req, acmeToken)
+		assert.NoError(t, err)
+	}
+
+	t.Logf("Given strict tenancies misconfigured")
+	{
+		acme := newTestMiddleware()
+		mw := &AuthMiddleware{
+			Tenants:       map[string]*AuthMiddleware{"acme": acme, "initech": acme},
+			StrictTenancy: true,
+		}
+
+		t.Logf("Then they are rejected, as are the tenants sharing a user pool without a tenant claim")
+		err := mw.Validate()
+		assert.ErrorContains(t, err, "the strict tenancy requires a tenant resolver")
+		assert.ErrorContains(t, err, "the tenants acme, initech share the user pool "+acme.issuer()+" without a tenant claim")
+
+		mw = &AuthMiddleware{TenantResolver: TenantFromHeader(""), Tenants: map[string]*AuthMiddleware{"globex": otherPool()}, StrictTenancy: true}
+		assert.NoError(t, mw.Validate())
+		assert.ErrorContains(t, mw.AddUserPool(context.Background(), otherPool(), "hooli"), "the tenants globex, hooli share the user pool")
+		assert.ErrorContains(t, mw.AddUserPool(context.Background(), newTestMiddleware(), "acme", "initech"), "the tenants acme, initech share the user pool")
+		assert.Equal(t, http.StatusUnauthorized, tenantRequest(authzHandler(mw), "/orders", "acme", signToken(testClaims())).Code)
+	}
+}
+
+func Test_TenantResolvers(t *testing.T) {
+	t.Logf("Given the requests of tenants")
+	{
+		request := func(url string) *http.Request {
+			req := httptest.NewRequest("GET", url, nil)
+			req.Header.Set("X-Customer", "acme")
+			return req
+		}
+
+		t.Logf("Then the tenant is resolved from the subdomain of the domain")
+		subdomain := TenantFromSubdomain("api.example.com")
+		for url, expected := range map[string]string{
+			"https://acme.api.example.com/orders":      "acme",
+			"https://ACME.api.example.com:8443/orders": "acme",
+			"https://api.example.com/orders":           "",
+		} {
+			tenant, err := subdomain.ResolveTenant(request(url))
+			assert.NoError(t, err, url)
+			assert.Equal(t, expected, tenant, url)
+		}
+		for _, url := range []string{"https://acme.eu.api.example.com/orders", "https://acme.example.org/orders"} {
+			_, err := subdomain.ResolveTenant(request(url))
+			assert.Error(t, err, url)
+		}
+
+		t.Logf("And from a header or the prefix of the path")
+		tenant, _ := TenantFromHeader("X-Customer").ResolveTenant(request("/orders"))
+		assert.Equal(t, "acme", tenant)
+		tenant, _ = TenantFromPathPrefix().ResolveTenant(request("/acme/orders"))
+		assert.Equal(t, "acme", tenant)
+	}
+}
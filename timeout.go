@@ -0,0 +1,34 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+)
+
+// validationContext the context of the validation of a request, bounded by the Timeout
+func (mw *AuthMiddleware) validationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if mw.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, mw.Timeout)
+}
+
+// deadline returns an ErrValidationTimeout once the deadline of ctx is exceeded. The requests cancelled by the
+// client are left to the checks to give up on.
+func (mw *AuthMiddleware) deadline(ctx context.Context) error {
+	if err := ctx.Err(); errors.Is(err, context.DeadlineExceeded) {
+		return tokenError(ErrValidationTimeout, fmt.Errorf("the validation exceeded its deadline: %w", err))
+	}
+	return nil
+}
+
+// validateTokenContext validates the token within the deadline of ctx
+func (mw *AuthMiddleware) validateTokenContext(ctx context.Context, tokenStr string, logger Logger) (*jwtgo.Token, error) {
+	if err := mw.deadline(ctx); err != nil {
+		mw.observe(failureReason(err), 0)
+		return nil, err
+	}
+	return mw.parseToken(ctx, tokenStr, logger)
+}
@@ -0,0 +1,68 @@
+package jwt
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_ValidationTimeout(t *testing.T) {
+	t.Logf("Given an enricher slower than the validation deadline")
+	{
+		mw := newTestMiddleware()
+		mw.Timeout = 20 * time.Millisecond
+		mw.Enrichers = []Enricher{EnricherFunc(func(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})}
+		router := authzHandler(mw)
+		w := performRequest(router, "GET", "/orders", signToken(testClaims()))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Empty(t, w.Header().Get(AuthenticateHeader))
+	}
+
+	t.Logf("Given an external authorizer failing open but slower than the validation deadline")
+	{
+		mw := newTestMiddleware()
+		mw.Timeout = 20 * time.Millisecond
+		mw.AuthorizerFailOpen = true
+		mw.Authorizer = AuthorizerFunc(func(ctx context.Context, request AuthorizationRequest) (bool, error) {
+			<-ctx.Done()
+			return false, ctx.Err()
+		})
+		var errorKey interface{}
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Next()
+			errorKey, _ = c.Get(ErrorKey)
+		})
+		router.GET("/orders", mw.MiddlewareFunc(), testHandler)
+		assert.Equal(t, http.StatusServiceUnavailable, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		assert.ErrorIs(t, errorKey.(error), ErrValidationTimeout)
+	}
+
+	t.Logf("Given a token validated once the deadline is exceeded")
+	{
+		mw := newTestMiddleware()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		_, err := mw.ValidateTokenContext(ctx, signToken(testClaims()))
+		assert.ErrorIs(t, err, ErrValidationTimeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, uint64(1), mw.Stats().Failures["timeout"])
+	}
+
+	t.Logf("Given validations completing within the default deadline")
+	{
+		mw := newTestMiddleware()
+		router := authzHandler(mw)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+		assert.Equal(t, time.Hour, mw.Timeout)
+	}
+}
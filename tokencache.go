@@ -0,0 +1,131 @@
+package jwt
+
+import (
+	"container/list"
+	"crypto/sha256"
+	jwtgo "github.com/golang-jwt/jwt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TokenCache a bounded LRU cache of the validated tokens keyed by a hash of the token, sparing the signature
+// verification of the tokens presented again before they expire. The tokens are cached until their exp, the
+// revocations are still checked on every hit. It is safe for concurrent use.
+type TokenCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type tokenEntry struct {
+	key     [sha256.Size]byte
+	token   *jwtgo.Token
+	expires time.Time
+}
+
+// NewTokenCache creates a TokenCache holding at most maxEntries tokens
+func NewTokenCache(maxEntries int) *TokenCache {
+	return &TokenCache{
+		maxEntries: maxEntries,
+		entries:    make(map[[sha256.Size]byte]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// get returns a copy of the cached token unless it expired
+func (c *TokenCache) get(tokenStr string) (*jwtgo.Token, bool) {
+	key := sha256.Sum256([]byte(tokenStr))
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if ok && !now.Before(element.Value.(*tokenEntry).expires) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.lru.MoveToFront(element)
+	return cloneToken(element.Value.(*tokenEntry).token), true
+}
+
+// add caches a copy of the validated token until its exp, the tokens without exp are not cached
+func (c *TokenCache) add(token *jwtgo.Token) {
+	expires, ok := ExpiresAt(token.Claims.(jwtgo.MapClaims))
+	if !ok || c.maxEntries <= 0 {
+		return
+	}
+	key := sha256.Sum256([]byte(token.Raw))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(element)
+		return
+	}
+	for c.lru.Len() >= c.maxEntries {
+		c.remove(c.lru.Back())
+		atomic.AddUint64(&c.evictions, 1)
+	}
+	c.entries[key] = c.lru.PushFront(&tokenEntry{key: key, token: cloneToken(token), expires: expires})
+}
+
+// Remove drops the given token from the cache, e.g. once it is revoked
+func (c *TokenCache) Remove(tokenStr string) {
+	key := sha256.Sum256([]byte(tokenStr))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// remove drops the entry of the element. Callers must hold the lock.
+func (c *TokenCache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*tokenEntry).key)
+}
+
+// Purge drops all the cached tokens
+func (c *TokenCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[[sha256.Size]byte]*list.Element)
+	c.lru.Init()
+}
+
+// Metrics returns the cache counters
+func (c *TokenCache) Metrics() CacheMetrics {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+	return CacheMetrics{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Entries:   entries,
+	}
+}
+
+// cloneToken copies the token and its claims, which the enrichers add to
+func cloneToken(token *jwtgo.Token) *jwtgo.Token {
+	claims := token.Claims.(jwtgo.MapClaims)
+	clone := *token
+	copied := make(jwtgo.MapClaims, len(claims))
+	for name, value := range claims {
+		copied[name] = value
+	}
+	clone.Claims = copied
+	return &clone
+}
@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_TokenCache(t *testing.T) {
+	t.Logf("Given a middleware caching the validated tokens")
+	{
+		mw := newTestMiddleware()
+		mw.TokenCache = NewTokenCache(2)
+		mw.Revocations = NewMemoryRevocationStore()
+		router := authzHandler(mw)
+
+		claims := testClaims()
+		claims[OriginJTIClaim] = "origin-1"
+		token := signToken(claims)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", token).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", token).Code)
+		assert.Equal(t, CacheMetrics{Hits: 1, Misses: 1, Entries: 1}, *mw.Stats().TokenCache)
+		assert.Equal(t, uint64(2), mw.Stats().Validated)
+
+		t.Logf("Then the cached tokens are copies")
+		cached, err := mw.ValidateToken(token)
+		assert.NoError(t, err)
+		cached.Claims.(jwtgo.MapClaims)["enriched"] = true
+		cached, _ = mw.ValidateToken(token)
+		assert.NotContains(t, cached.Claims, "enriched")
+
+		t.Logf("When the token is revoked")
+		mw.Revocations.Revoke("origin-1", time.Now().Add(time.Hour))
+		w := performRequest(router, "GET", "/orders", token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get(AuthenticateHeader), "revoked")
+		assert.Equal(t, 0, mw.Stats().TokenCache.Entries)
+	}
+}
+
+func Test_TokenCacheEviction(t *testing.T) {
+	t.Logf("Given a cache of two tokens")
+	{
+		mw := newTestMiddleware()
+		mw.TokenCache = NewTokenCache(2)
+		tokens := make([]string, 3)
+		for i := range tokens {
+			claims := testClaims()
+			claims["jti"] = i
+			tokens[i] = signToken(claims)
+		}
+		mw.ValidateToken(tokens[0])
+		mw.ValidateToken(tokens[1])
+		mw.ValidateToken(tokens[0])
+		mw.ValidateToken(tokens[2])
+
+		t.Logf("Then the least recently used token is evicted")
+		metrics := mw.TokenCache.Metrics()
+		assert.Equal(t, uint64(1), metrics.Evictions)
+		assert.Equal(t, 2, metrics.Entries)
+		_, ok := mw.TokenCache.get(tokens[0])
+		assert.True(t, ok)
+		_, ok = mw.TokenCache.get(tokens[1])
+		assert.False(t, ok)
+
+		mw.TokenCache.Purge()
+		assert.Equal(t, 0, mw.TokenCache.Metrics().Entries)
+	}
+}
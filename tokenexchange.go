@@ -0,0 +1,171 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+
+	// TokenExchangeGrant the RFC 8693 token exchange grant type
+	TokenExchangeGrant = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// AccessTokenType the RFC 8693 token type of the access tokens
+	AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+
+	// exchangeExpiryMargin how long before their expiry the exchanged tokens are exchanged again
+	exchangeExpiryMargin = 30 * time.Second
+)
+
+// ExchangedToken a downstream token issued by the token exchange endpoint
+type ExchangedToken struct {
+	AccessToken     string    `json:"access_token"`
+	IssuedTokenType string    `json:"issued_token_type"`
+	TokenType       string    `json:"token_type"`
+	ExpiresIn       int       `json:"expires_in"`
+	Scope           string    `json:"scope,omitempty"`
+	ExpiresAt       time.Time `json:"-"`
+}
+
+// TokenExchanger exchanges the validated tokens of the callers for downstream tokens, issued for another audience
+// or scopes, with the RFC 8693 token exchange grant. The exchanged tokens are cached per subject token, audience and
+// scopes until they, or the subject token, expire.
+type TokenExchanger struct {
+
+	// Endpoint the token exchange endpoint of the security token service
+	Endpoint string
+
+	// ClientID and ClientSecret the credentials of the service at the token exchange endpoint, if any
+	ClientID     string
+	ClientSecret string
+
+	mu    sync.Mutex
+	cache map[string]exchangedEntry
+}
+
+// exchangedEntry a cached exchanged token, reused until the earliest of its expiry and the one of its subject token
+type exchangedEntry struct {
+	token     *ExchangedToken
+	expiresAt time.Time
+}
+
+// NewTokenExchanger creates a TokenExchanger calling the given endpoint with the given client credentials
+func NewTokenExchanger(endpoint, clientID, clientSecret string) *TokenExchanger {
+	return &TokenExchanger{Endpoint: endpoint, ClientID: clientID, ClientSecret: clientSecret}
+}
+
+// Exchange exchanges the subject token of the given subject for a token for the audience and scopes, returning
+// the cached token when it is still valid. The cached tokens are keyed by the hash of the subject token, so that a
+// token exchanged for a revoked or expired subject token, or for the one of another session of the subject, is not
+// reused.
+func (e *TokenExchanger) Exchange(ctx context.Context, subjectToken, subject, audience string, scopes ...string) (*ExchangedToken, error) {
+	sorted := append([]string{}, scopes...)
+	sort.Strings(sorted)
+	digest := sha256.Sum256([]byte(subjectToken))
+	key := strings.Join([]string{subject, hex.EncodeToString(digest[:]), audience, strings.Join(sorted, " ")}, "\x00")
+
+	e.mu.Lock()
+	cached, ok := e.cache[key]
+	e.mu.Unlock()
+	if ok && time.Until(cached.expiresAt) > exchangeExpiryMargin {
+		return cached.token, nil
+	}
+
+	token, err := e.exchange(ctx, subjectToken, audience, sorted)
+	if err != nil {
+		return nil, err
+	}
+	entry := exchangedEntry{token: token, expiresAt: token.ExpiresAt}
+	if expiresAt, ok := unverifiedExpiry(subjectToken); ok && expiresAt.Before(entry.expiresAt) {
+		entry.expiresAt = expiresAt
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cache == nil {
+		e.cache = map[string]exchangedEntry{}
+	}
+	now := time.Now()
+	for cachedKey, cachedEntry := range e.cache {
+		if cachedEntry.expiresAt.Before(now) {
+			delete(e.cache, cachedKey)
+		}
+	}
+	e.cache[key] = entry
+	return token, nil
+}
+
+// unverifiedExpiry the exp claim of the token, validated beforehand by the middleware
+func unverifiedExpiry(tokenStr string) (time.Time, bool) {
+	segments := strings.Split(tokenStr, ".")
+	if len(segments) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segments[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims jwtgo.MapClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, false
+	}
+	return ExpiresAt(claims)
+}
+
+// ExchangeFor exchanges the token of the request, validated by the middleware, for a token for the audience
+// and scopes
+func (e *TokenExchanger) ExchangeFor(c *gin.Context, audience string, scopes ...string) (*ExchangedToken, error) {
+	principal, ok := GetPrincipal(c)
+	if !ok {
+		return nil, errors.New("the request carries no validated token")
+	}
+	return e.Exchange(c.Request.Context(), c.GetString(TokenStringKey), principal.ID(), audience, scopes...)
+}
+
+// exchange posts the token exchange grant to the endpoint
+func (e *TokenExchanger) exchange(ctx context.Context, subjectToken, audience string, scopes []string) (*ExchangedToken, error) {
+	form := url.Values{
+		"grant_type":         {TokenExchangeGrant},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {AccessTokenType},
+		"audience":           {audience},
+	}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if e.ClientID != "" {
+		req.SetBasicAuth(e.ClientID, e.ClientSecret)
+	}
+
+	r, err := cognitoHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling the token exchange endpoint: %w", err)
+	}
+	defer closeBody(r)
+	if r.StatusCode != http.StatusOK {
+		return nil, oauthError(r)
+	}
+	token := &ExchangedToken{}
+	if err := json.NewDecoder(r.Body).Decode(token); err != nil {
+		return nil, fmt.Errorf("decoding the token exchange response: %w", err)
+	}
+	token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return token, nil
+}
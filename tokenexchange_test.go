@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_TokenExchanger(t *testing.T) {
+	t.Logf("Given a security token service issuing downstream tokens")
+	{
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			r.ParseForm()
+			assert.Equal(t, TokenExchangeGrant, r.PostForm.Get("grant_type"))
+			assert.Equal(t, AccessTokenType, r.PostForm.Get("subject_token_type"))
+			assert.Equal(t, "orders/read orders/write", r.PostForm.Get("scope"))
+			if r.PostForm.Get("subject_token") != "user-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":      "downstream-" + r.PostForm.Get("audience"),
+				"issued_token_type": AccessTokenType,
+				"token_type":        "Bearer",
+				"expires_in":        300,
+			})
+		}))
+		defer server.Close()
+		exchanger := NewTokenExchanger(server.URL, "orders-api", "secret")
+
+		token, err := exchanger.Exchange(context.Background(), "user-token", "user-123", "billing", "orders/write", "orders/read")
+		assert.Nil(t, err)
+		assert.Equal(t, "downstream-billing", token.AccessToken)
+
+		token, err = exchanger.Exchange(context.Background(), "user-token", "user-123", "billing", "orders/read", "orders/write")
+		assert.Nil(t, err)
+		assert.Equal(t, "downstream-billing", token.AccessToken)
+		assert.Equal(t, 1, calls, "the exchanged token is cached")
+
+		token, _ = exchanger.Exchange(context.Background(), "user-token", "user-123", "shipping", "orders/read", "orders/write")
+		assert.Equal(t, "downstream-shipping", token.AccessToken)
+		assert.Equal(t, 2, calls)
+
+		_, err = exchanger.Exchange(context.Background(), "forged-token", "user-456", "billing", "orders/read", "orders/write")
+		var oauthErr *OAuthError
+		assert.True(t, errors.As(err, &oauthErr))
+		assert.Equal(t, "invalid_grant", oauthErr.Code)
+	}
+
+	t.Logf("Given subject tokens of the same subject, one of them about to expire")
+	{
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			r.ParseForm()
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":      "downstream-" + r.PostForm.Get("subject_token"),
+				"issued_token_type": AccessTokenType,
+				"token_type":        "Bearer",
+				"expires_in":        3600,
+			})
+		}))
+		defer server.Close()
+		exchanger := NewTokenExchanger(server.URL, "orders-api", "secret")
+
+		t.Logf("Then the token exchanged for one subject token is not reused for another")
+		second := signToken(testClaims())
+		token, _ := exchanger.Exchange(context.Background(), "first-token", "user-123", "billing")
+		assert.Equal(t, "downstream-first-token", token.AccessToken)
+		token, _ = exchanger.Exchange(context.Background(), second, "user-123", "billing")
+		assert.Equal(t, "downstream-"+second, token.AccessToken)
+		assert.Equal(t, 2, calls)
+
+		t.Logf("And the token exchanged for a subject token about to expire is not cached beyond it")
+		expiring := testClaims()
+		expiring["exp"] = time.Now().Add(10 * time.Second).Unix()
+		subjectToken := signToken(expiring)
+		exchanger.Exchange(context.Background(), subjectToken, "user-123", "billing")
+		exchanger.Exchange(context.Background(), subjectToken, "user-123", "billing")
+		assert.Equal(t, 4, calls)
+	}
+}
@@ -0,0 +1,55 @@
+// Package twirpjwt validates the Cognito tokens of Twirp RPC calls through a jwt.AuthMiddleware, so RPC
+// and REST services share one auth layer. Twirp does not expose the request headers to its interceptors,
+// hence the validation happens in an HTTP handler wrapping the Twirp server. The public routes and the
+// route requirements table are keyed by the Twirp path, e.g. "POST /twirp/acme.orders.OrderService/DeleteOrder".
+package twirpjwt
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/twitchtv/twirp"
+	"net/http"
+)
+
+// Handler wraps the Twirp server: the calls with an invalid token are answered with an unauthenticated
+// Twirp error, the others are served with the jwt.Principal stored in the context
+func Handler(mw *jwt.AuthMiddleware, server http.Handler) http.Handler {
+	mw.MiddlewareInit()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mw.IsPublic(r.Method, r.URL.Path) {
+			server.ServeHTTP(w, r)
+			return
+		}
+
+		tokenStr, err := mw.ExtractToken(r.Header.Get)
+		if err != nil {
+			twirp.WriteError(w, twirp.NewError(twirp.Unauthenticated, err.Error()))
+			return
+		}
+		token, err := mw.ValidateRequest(r, tokenStr)
+		if err != nil {
+			twirp.WriteError(w, twirp.NewError(errorCode(err), err.Error()))
+			return
+		}
+
+		principal := jwt.NewPrincipal(token.Claims.(jwtgo.MapClaims))
+		if authErr := mw.CheckRoute(principal, r.Method, r.URL.Path); authErr != nil {
+			twirp.WriteError(w, twirp.NewError(twirp.PermissionDenied, authErr.Message))
+			return
+		}
+		server.ServeHTTP(w, r.WithContext(jwt.ContextWithPrincipal(r.Context(), principal)))
+	})
+}
+
+// errorCode the Twirp error code of the failed validation, as per its jwt.StatusCode
+func errorCode(err error) twirp.ErrorCode {
+	switch jwt.StatusCode(err) {
+	case http.StatusForbidden:
+		return twirp.PermissionDenied
+	case http.StatusTooManyRequests:
+		return twirp.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return twirp.Unavailable
+	}
+	return twirp.Unauthenticated
+}
@@ -0,0 +1,45 @@
+package twirpjwt
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Logf("Given a twirp server wrapped by the handler")
+	{
+		issuer, _ := jwttest.NewIssuer()
+		mw := issuer.Middleware()
+		mw.Routes = jwt.RouteTable{"POST /twirp/orders.OrderService/DeleteOrder": {Groups: []string{"admins"}}}
+
+		var subject string
+		handler := Handler(mw, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := jwt.PrincipalFromContext(r.Context())
+			subject = principal.ID()
+		}))
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+
+		call := func(method, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/twirp/orders.OrderService/"+method, nil)
+			if token != "" {
+				req.Header.Set(jwt.AuthorizationHeader, token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusOK, call("GetOrder", token).Code)
+		assert.Equal(t, "user-123", subject)
+
+		w := call("GetOrder", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"unauthenticated"`)
+
+		assert.Equal(t, http.StatusForbidden, call("DeleteOrder", token).Code)
+	}
+}
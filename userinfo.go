@@ -0,0 +1,54 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+	"time"
+)
+
+// UserInfoEnricher an Enricher merging the attributes returned by the userInfo endpoint of the Hosted UI, e.g.
+// email and name, cached per sub for the given time to live. Only the access tokens granted the openid scope
+// are enriched.
+func (mw *AuthMiddleware) UserInfoEnricher(ttl time.Duration) Enricher {
+	cache := &ttlCache{}
+	return EnricherFunc(func(ctx context.Context, token string, claims jwtgo.MapClaims) (jwtgo.MapClaims, error) {
+		if tokenUse, _ := claims["token_use"].(string); tokenUse != "access" || IsMachine(claims) {
+			return nil, nil
+		}
+		sub, _ := claims["sub"].(string)
+		if cached, ok := cache.get(sub); ok {
+			return cached.(jwtgo.MapClaims), nil
+		}
+		userInfo, err := mw.userInfo(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		cache.set(sub, userInfo, time.Now().Add(ttl))
+		return userInfo, nil
+	})
+}
+
+// userInfo calls the userInfo endpoint of the Hosted UI with the access token
+func (mw *AuthMiddleware) userInfo(ctx context.Context, token string) (jwtgo.MapClaims, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mw.domainURL()+"/oauth2/userInfo", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	r, err := cognitoHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling the userInfo endpoint: %w", err)
+	}
+	defer closeBody(r)
+	if r.StatusCode != http.StatusOK {
+		return nil, oauthError(r)
+	}
+	userInfo := jwtgo.MapClaims{}
+	if err := json.NewDecoder(r.Body).Decode(&userInfo); err != nil {
+		return nil, fmt.Errorf("decoding the userInfo response: %w", err)
+	}
+	return userInfo, nil
+}
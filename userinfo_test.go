@@ -0,0 +1,41 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_UserInfoEnricher(t *testing.T) {
+	t.Logf("Given a Hosted UI serving the profile of the users")
+	{
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			assert.Equal(t, "/oauth2/userInfo", r.URL.Path)
+			json.NewEncoder(w).Encode(map[string]string{"sub": "forged", "email": "jdoe@example.com", "name": "John Doe"})
+		}))
+		defer server.Close()
+
+		mw := newTestMiddleware()
+		mw.Domain = server.URL
+		mw.Enrichers = []Enricher{mw.UserInfoEnricher(time.Minute)}
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/me", mw.MiddlewareFunc(), func(c *gin.Context) {
+			principal, _ := GetPrincipal(c)
+			c.JSON(http.StatusOK, gin.H{"sub": principal.ID(), "email": principal.Claims()["email"]})
+		})
+
+		for i := 0; i < 2; i++ {
+			w := performRequest(router, "GET", "/me", signToken(testClaims()))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"sub":"user-123","email":"jdoe@example.com"}`, w.Body.String())
+		}
+		assert.Equal(t, 1, calls, "the profile is cached per sub")
+	}
+}
@@ -0,0 +1,19 @@
+// Package jwt is the v2 API of the Gin middleware validating the JSON web tokens issued by the AWS Cognito user pools.
+//
+// New is its single constructor. The Middleware is configured by the options given to it, then immutable: its
+// configuration is not exported, so that no change made once the handlers are created goes unnoticed.
+//
+//	mw, err := jwt.New(ctx, "eu-west-2", "eu-west-2_x",
+//		jwt.WithRequiredGroups("staff"),
+//		jwt.WithPublicRoutes("GET /health"))
+//	router.Use(mw.Handler())
+//
+// The tokens are validated as by the v1 package, github.com/akhettar/gin-jwt-cognito: the failures are its sentinel
+// errors, re-exported here, and the responses, failure reasons and metrics are the same.
+//
+// # Compatibility
+//
+// The module follows semantic versioning: within v2 the exported identifiers are neither removed nor changed in a
+// backward incompatible way, the options are only added, and the sentinel errors, the failure reasons and the JSON
+// bodies of the responses are kept.
+package jwt
@@ -0,0 +1,139 @@
+package jwt
+
+import (
+	"context"
+	cognito "github.com/akhettar/gin-jwt-cognito"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"net/http"
+)
+
+// Principal the authenticated caller of a request
+type Principal = cognito.Principal
+
+// RouteRequirement the authorization requirements of a route
+type RouteRequirement = cognito.RouteRequirement
+
+// RouteTable the authorization requirements keyed by route, e.g. "GET /orders/:id"
+type RouteTable = cognito.RouteTable
+
+// KeyProvider provides the public keys verifying the signature of the tokens
+type KeyProvider = cognito.KeyProvider
+
+// ClaimsValidator checks the claims of the tokens once their signature and standard claims are verified
+type ClaimsValidator = cognito.ClaimsValidator
+
+// ClaimsValidatorFunc adapter to use an ordinary function as a ClaimsValidator
+type ClaimsValidatorFunc = cognito.ClaimsValidatorFunc
+
+// RevocationChecker checks whether the tokens were revoked
+type RevocationChecker = cognito.RevocationChecker
+
+// Logger the structured logger of the middleware
+type Logger = cognito.Logger
+
+// Metrics the sink of the outcome of the validations
+type Metrics = cognito.Metrics
+
+// TokenError a token validation failure, see the Err* variables
+type TokenError = cognito.TokenError
+
+// AccessError an authorization failure of a valid token
+type AccessError = cognito.AccessError
+
+// The failures of the validations, matched with errors.Is
+var (
+	ErrMissingHeader     = cognito.ErrMissingHeader
+	ErrMalformedToken    = cognito.ErrMalformedToken
+	ErrUnknownKeyID      = cognito.ErrUnknownKeyID
+	ErrTokenExpired      = cognito.ErrTokenExpired
+	ErrBadIssuer         = cognito.ErrBadIssuer
+	ErrWrongTokenUse     = cognito.ErrWrongTokenUse
+	ErrInvalidClaims     = cognito.ErrInvalidClaims
+	ErrTokenRevoked      = cognito.ErrTokenRevoked
+	ErrMissingGroups     = cognito.ErrMissingGroups
+	ErrInsufficientScope = cognito.ErrInsufficientScope
+	ErrPolicyDenied      = cognito.ErrPolicyDenied
+	ErrValidationTimeout = cognito.ErrValidationTimeout
+)
+
+// Middleware the Gin middleware validating the tokens of a Cognito user pool, created by New. It is safe for
+// concurrent use.
+type Middleware struct {
+	mw *cognito.AuthMiddleware
+}
+
+// New creates the middleware of the tokens of the given user pool, configured by the options, and downloads the
+// json web key set of the user pool within the deadline of ctx unless WithKeyProvider is given. The configuration
+// is validated first.
+func New(ctx context.Context, region, userPoolID string, opts ...Option) (*Middleware, error) {
+	mw, err := cognito.NewWithContext(ctx, region, userPoolID, func(mw *cognito.AuthMiddleware) {
+		c := &config{mw: mw}
+		for _, opt := range opts {
+			opt(c)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Middleware{mw: mw}, nil
+}
+
+// Handler the gin handler validating the token of the requests and enforcing the requirements of their route. The
+// principal of the request is then found with GetPrincipal.
+func (m *Middleware) Handler() gin.HandlerFunc {
+	return m.mw.MiddlewareFunc()
+}
+
+// RequireGroups returns a handler rejecting with a 403 the callers which are members of none of the groups. It must
+// be chained after Handler.
+func (m *Middleware) RequireGroups(groups ...string) gin.HandlerFunc {
+	return m.mw.RequireGroups(groups...)
+}
+
+// RequireScopes returns a handler rejecting with a 403 the tokens lacking any of the scopes. It must be chained after
+// Handler.
+func (m *Middleware) RequireScopes(scopes ...string) gin.HandlerFunc {
+	return m.mw.RequireScopes(scopes...)
+}
+
+// RequirePolicy returns a handler rejecting with a 403 the requests for which the expression does not hold, see the
+// Policy of the v1 package. It panics when the expression does not compile. It must be chained after Handler.
+func (m *Middleware) RequirePolicy(expression string) gin.HandlerFunc {
+	return m.mw.RequirePolicy(expression)
+}
+
+// ValidateRequest validates the token of the request as Handler does, for the other web frameworks. See StatusCode
+// for the status of the failures.
+func (m *Middleware) ValidateRequest(r *http.Request, tokenStr string) (Principal, error) {
+	token, err := m.mw.ValidateRequest(r, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	return cognito.NewPrincipal(token.Claims.(jwtgo.MapClaims)), nil
+}
+
+// ExtractToken extracts the token of the request as per WithTokenLookup, the header of the given name by default
+func (m *Middleware) ExtractToken(r *http.Request) (string, error) {
+	return m.mw.ExtractToken(r.Header.Get)
+}
+
+// Challenge the WWW-Authenticate challenge of the 401 responses to the requests failing with err
+func (m *Middleware) Challenge(err error) string {
+	return m.mw.Challenge(err)
+}
+
+// StatusCode the status of the response to a request failing with err, as Handler answers it
+func StatusCode(err error) int {
+	return cognito.StatusCode(err)
+}
+
+// GetPrincipal returns the principal of the request authenticated by Handler
+func GetPrincipal(c *gin.Context) (Principal, bool) {
+	return cognito.GetPrincipal(c)
+}
+
+// PrincipalFromContext returns the principal stored in ctx by the adapters of the other web frameworks
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	return cognito.PrincipalFromContext(ctx)
+}
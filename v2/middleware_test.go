@@ -0,0 +1,106 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"github.com/akhettar/gin-jwt-cognito/jwttest"
+	"github.com/gin-gonic/gin"
+	jwtgo "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newIssuer(t *testing.T) *jwttest.Issuer {
+	issuer, err := jwttest.NewIssuer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+func performRequest(r http.Handler, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if token != "" {
+		req.Header.Set("Authentication", token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_New(t *testing.T) {
+	t.Logf("Given a middleware configured by its options")
+	{
+		issuer := newIssuer(t)
+		mw, err := New(context.Background(), jwttest.Region, jwttest.UserPoolID,
+			WithKeyProvider(issuer.Middleware()),
+			WithPublicRoutes("GET /health"),
+			WithRoutes(RouteTable{"GET /admin": {Groups: []string{"admins"}}}))
+		assert.NoError(t, err)
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(mw.Handler())
+		handler := func(c *gin.Context) {
+			principal, _ := GetPrincipal(c)
+			if principal == nil {
+				c.String(http.StatusOK, "public")
+				return
+			}
+			c.String(http.StatusOK, principal.ID())
+		}
+		router.GET("/orders", handler)
+		router.GET("/admin", handler)
+		router.GET("/health", handler)
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+
+		t.Logf("Then the valid tokens are accepted and the principal is found")
+		w := performRequest(router, "/orders", token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "user-123", w.Body.String())
+
+		t.Logf("And the requirements of the routes are enforced")
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "/admin", token).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "/health", "").Code)
+
+		w = performRequest(router, "/orders", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="gin jwt"`, w.Header().Get("WWW-Authenticate"))
+	}
+
+	t.Logf("Given a request validated outside of gin")
+	{
+		issuer := newIssuer(t)
+		mw, err := New(context.Background(), jwttest.Region, jwttest.UserPoolID,
+			WithKeyProvider(issuer.Middleware()),
+			WithClaimsValidators(ClaimsValidatorFunc(func(claims jwtgo.MapClaims) error {
+				if claims["sub"] == "blocked" {
+					return errors.New("blocked user")
+				}
+				return nil
+			})))
+		assert.NoError(t, err)
+
+		token, _ := issuer.Sign(issuer.Claims("user-123"))
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.Header.Set("Authentication", token)
+		extracted, err := mw.ExtractToken(req)
+		assert.NoError(t, err)
+		principal, err := mw.ValidateRequest(req, extracted)
+		assert.NoError(t, err)
+		assert.Equal(t, "user-123", principal.ID())
+
+		blocked, _ := issuer.Sign(issuer.Claims("blocked"))
+		_, err = mw.ValidateRequest(req, blocked)
+		assert.ErrorIs(t, err, ErrInvalidClaims)
+		assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+	}
+
+	t.Logf("Given an invalid configuration")
+	{
+		_, err := New(context.Background(), "", "eu-west-2_x", WithKeyProvider(newIssuer(t).Middleware()))
+		assert.ErrorContains(t, err, "the region is required")
+	}
+}
@@ -0,0 +1,104 @@
+package jwt
+
+import (
+	cognito "github.com/akhettar/gin-jwt-cognito"
+	"time"
+)
+
+// Option configures the Middleware created by New
+type Option func(*config)
+
+// config the configuration of the Middleware under construction
+type config struct {
+	mw *cognito.AuthMiddleware
+}
+
+// WithIssuer sets the issuer of the tokens, the one of the user pool by default, e.g. the one of a local emulator
+func WithIssuer(iss string) Option {
+	return func(c *config) { c.mw.Iss = iss }
+}
+
+// WithClientID sets the app client of the tokens, along with its secret, if any
+func WithClientID(clientID, clientSecret string) Option {
+	return func(c *config) {
+		c.mw.ClientID = clientID
+		c.mw.ClientSecret = clientSecret
+	}
+}
+
+// WithTokenLookup sets where the token is read from, "header:<name>" or "cookie:<name>", the Authentication header
+// by default
+func WithTokenLookup(lookup string) Option {
+	return func(c *config) { c.mw.TokenLookup = lookup }
+}
+
+// WithRequiredGroups requires the callers of every route to be members of any of the groups
+func WithRequiredGroups(groups ...string) Option {
+	return func(c *config) { c.mw.Required.Groups = append(c.mw.Required.Groups, groups...) }
+}
+
+// WithRequiredScopes requires the tokens of every route to be granted all of the scopes
+func WithRequiredScopes(scopes ...string) Option {
+	return func(c *config) { c.mw.Required.Scopes = append(c.mw.Required.Scopes, scopes...) }
+}
+
+// WithRoutes sets the requirements of the routes, keyed by the upper case method and the gin route pattern separated
+// by a space, e.g. "GET /orders/:id"
+func WithRoutes(routes RouteTable) Option {
+	return func(c *config) { c.mw.Routes = routes }
+}
+
+// WithPublicRoutes adds routes which do not require a token, either "/path" or "METHOD /path"
+func WithPublicRoutes(routes ...string) Option {
+	return func(c *config) { c.mw.PublicRoutes = append(c.mw.PublicRoutes, routes...) }
+}
+
+// WithTimeout bounds the validation of a token, the download of the keys included
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) { c.mw.Timeout = timeout }
+}
+
+// WithStrictMode turns the StrictMode of the v1 package on, for the security sensitive deployments
+func WithStrictMode() Option {
+	return func(c *config) { c.mw.StrictMode = true }
+}
+
+// WithMachineToMachine accepts only the machine to machine access tokens
+func WithMachineToMachine() Option {
+	return func(c *config) { c.mw.MachineToMachine = true }
+}
+
+// WithProductionErrors answers the failures with the generic status text rather than the concrete failure
+func WithProductionErrors() Option {
+	return func(c *config) { c.mw.ErrorMode = cognito.ProductionErrors }
+}
+
+// WithRealm sets the realm of the WWW-Authenticate challenges
+func WithRealm(realm string) Option {
+	return func(c *config) { c.mw.Realm = realm }
+}
+
+// WithKeyProvider sets the provider of the public keys, the json web key set of the user pool is then not downloaded
+func WithKeyProvider(keys KeyProvider) Option {
+	return func(c *config) { c.mw.Keys = keys }
+}
+
+// WithClaimsValidators adds checks of the claims of the tokens
+func WithClaimsValidators(validators ...ClaimsValidator) Option {
+	return func(c *config) { c.mw.ClaimsValidators = append(c.mw.ClaimsValidators, validators...) }
+}
+
+// WithRevocationChecker sets the check of the revoked tokens
+func WithRevocationChecker(checker RevocationChecker) Option {
+	return func(c *config) { c.mw.RevocationChecker = checker }
+}
+
+// WithLogger sets the logger of the middleware
+func WithLogger(logger Logger) Option {
+	return func(c *config) { c.mw.Logger = logger }
+}
+
+// WithMetrics sets the sink of the outcome of the validations
+func WithMetrics(metrics Metrics) Option {
+	return func(c *config) { c.mw.Metrics = metrics }
+}
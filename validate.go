@@ -0,0 +1,206 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// regionPattern the format of the AWS regions, e.g. eu-west-2 or us-gov-west-1
+	regionPattern = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-[0-9]+$`)
+
+	// userPoolIDPattern the format of the user pool IDs, the region and an identifier separated by an underscore
+	userPoolIDPattern = regexp.MustCompile(`^([a-z]{2}(-gov)?-[a-z]+-[0-9]+)_[0-9A-Za-z]+$`)
+
+	// cognitoIssuerHost the host of the issuers of the Cognito user pools
+	cognitoIssuerHost = regexp.MustCompile(`^cognito-idp\.[a-z0-9-]+\.amazonaws\.com$`)
+)
+
+// Validate checks the configuration of the middleware: the format of the region, the user pool ID, the issuer, the
+// json web key set url and the token lookup, the routes, the UserPools, and the options conflicting with each other.
+// All the problems are returned at once, joined, rather than surfacing one by one at request time. The Builder,
+// NewFromEnv and the Config validate the configuration this way.
+func (mw *AuthMiddleware) Validate() error {
+	var errs []error
+	switch {
+	case mw.Region == "":
+		if !mw.multiPool() {
+			errs = append(errs, errors.New("the region is required"))
+		}
+	case !regionPattern.MatchString(mw.Region):
+		errs = append(errs, fmt.Errorf("the region %q is not an AWS region, e.g. eu-west-2", mw.Region))
+	}
+	if match := userPoolIDPattern.FindStringSubmatch(mw.UserPoolID); mw.UserPoolID == "" {
+		if !mw.multiPool() {
+			errs = append(errs, errors.New("the user pool ID is required"))
+		}
+	} else if match == nil {
+		errs = append(errs, fmt.Errorf("the user pool ID %q is not of the form <region>_<id>", mw.UserPoolID))
+	} else if mw.Region != "" && match[1] != mw.Region {
+		errs = append(errs, fmt.Errorf("the user pool %s does not belong to the region %s", mw.UserPoolID, mw.Region))
+	}
+	if mw.Iss != "" {
+		errs = append(errs, mw.validateIssuer())
+	}
+	if mw.JWKURL != "" {
+		if jwkURL, err := url.Parse(mw.JWKURL); err != nil || jwkURL.Host == "" ||
+			(jwkURL.Scheme != "https" && !(jwkURL.Scheme == "http" && mw.allowsHTTP(jwkURL.Hostname()))) {
+			errs = append(errs, fmt.Errorf("the json web key set url %q is not an absolute https URL", mw.JWKURL))
+		}
+	}
+	if lookup := mw.TokenLookup; lookup != "" {
+		kind, name, ok := strings.Cut(lookup, ":")
+		if !ok || name == "" || (kind != HEADER && kind != COOKIE) {
+			errs = append(errs, fmt.Errorf("the token lookup %q is not of the form header:<name> or cookie:<name>", lookup))
+		}
+	}
+	errs = append(errs, mw.validateRoutes(), mw.validateOptions(), mw.validatePools(), mw.validateTenancy())
+	return errors.Join(errs...)
+}
+
+// validateIssuer checks the issuer is an absolute https URL, the one of the user pool when issued by Cognito
+func (mw *AuthMiddleware) validateIssuer() error {
+	iss, err := url.Parse(mw.Iss)
+	if err != nil || iss.Host == "" || iss.RawQuery != "" || iss.Fragment != "" || strings.HasSuffix(iss.Path, ForwardSlash) {
+		return fmt.Errorf("the issuer %q is not an absolute URL without trailing slash, query or fragment", mw.Iss)
+	}
+	if iss.Scheme != "https" && !(iss.Scheme == "http" && mw.allowsHTTP(iss.Hostname())) {
+		return fmt.Errorf("the issuer %s is not an https URL", mw.Iss)
+	}
+	if cognitoIssuerHost.MatchString(iss.Host) && mw.UserPoolID != "" &&
+		mw.Iss != (UserPool{Region: mw.Region, UserPoolID: mw.UserPoolID}).Iss() {
+		return fmt.Errorf("the issuer %s is not the one of the user pool %s", mw.Iss, mw.UserPoolID)
+	}
+	return nil
+}
+
+// allowsHTTP whether the issuer and the json web key set may be served over http by the host, the loopback ones
+// outside of the StrictMode
+func (mw *AuthMiddleware) allowsHTTP(host string) bool {
+	return !mw.StrictMode && isLoopback(host)
+}
+
+// isLoopback whether the host is the local machine, where the emulators of Cognito are served over http
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validateRoutes checks the form of the routes and their token_use, and that no public route has requirements
+func (mw *AuthMiddleware) validateRoutes() error {
+	var errs []error
+	if err := validateTokenUse(mw.Required.TokenUse); err != nil {
+		errs = append(errs, fmt.Errorf("required: %w", err))
+	}
+	routes := make([]string, 0, len(mw.Routes))
+	for route := range mw.Routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method != strings.ToUpper(method) || !strings.HasPrefix(path, ForwardSlash) {
+			errs = append(errs, fmt.Errorf("the route %q is not of the form \"METHOD /path\"", route))
+			continue
+		}
+		if err := validateTokenUse(mw.Routes[route].TokenUse); err != nil {
+			errs = append(errs, fmt.Errorf("route %s: %w", route, err))
+		}
+		if mw.IsPublic(method, path) {
+			errs = append(errs, fmt.Errorf("the route %s is public, its requirements would never be enforced", route))
+		}
+	}
+	for _, public := range mw.PublicRoutes {
+		path := public
+		if method, rest, ok := strings.Cut(public, " "); ok {
+			if method == "" || method != strings.ToUpper(method) {
+				path = ""
+			} else {
+				path = rest
+			}
+		}
+		if !strings.HasPrefix(path, ForwardSlash) {
+			errs = append(errs, fmt.Errorf("the public route %q is neither of the form \"/path\" nor \"METHOD /path\"", public))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateTokenUse checks the required token_use is either id or access
+func validateTokenUse(tokenUse string) error {
+	if tokenUse != "" && tokenUse != "id" && tokenUse != "access" {
+		return fmt.Errorf("the required token_use %q is neither id nor access", tokenUse)
+	}
+	return nil
+}
+
+// validateOptions checks the options do not conflict with each other
+func (mw *AuthMiddleware) validateOptions() error {
+	var errs []error
+	if mw.ClientSecret != "" && mw.ClientID == "" {
+		errs = append(errs, errors.New("the client secret is set without the client ID"))
+	}
+	if mw.MachineToMachine {
+		if mw.Required.TokenUse == "id" {
+			errs = append(errs, errors.New("the machine to machine tokens are access tokens, the id token_use can not be required"))
+		}
+		if mw.BrowserLogin {
+			errs = append(errs, errors.New("the browser login is not available to the machine to machine tokens"))
+		}
+	}
+	if mw.BrowserLogin && (mw.Domain == "" || mw.ClientID == "" || mw.RedirectURL == "") {
+		errs = append(errs, errors.New("the browser login requires the domain, the client ID and the redirect URL"))
+	}
+	if mw.Policy != nil {
+		if err := mw.Policy.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("policy: %w", err))
+		}
+	}
+	tenants := make([]string, 0, len(mw.TenantPolicies))
+	for tenant := range mw.TenantPolicies {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		if policy := mw.TenantPolicies[tenant]; policy == nil {
+			errs = append(errs, fmt.Errorf("the policy of the tenant %s is nil", tenant))
+		} else if err := policy.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("policy of the tenant %s: %w", tenant, err))
+		}
+	}
+	if mw.CSRF < CSRFDoubleSubmit || mw.CSRF > CSRFDisabled {
+		errs = append(errs, fmt.Errorf("the csrf mode %d is unknown", mw.CSRF))
+	}
+	if mw.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("the timeout %v is negative", mw.Timeout))
+	}
+	if mw.FailureLimiter != nil && !mw.FailureLimiter.valid() {
+		errs = append(errs, errors.New("the failure limiter requires a store, a positive number of failures and window"))
+	}
+	if mw.Fingerprint != nil && !mw.Fingerprint.valid() {
+		errs = append(errs, errors.New("the fingerprint binding requires a store, prefixes of valid lengths and a part of the fingerprint"))
+	}
+	if mw.Lockout != nil && !mw.Lockout.valid() {
+		errs = append(errs, errors.New("the lockout requires a store, a positive number of failures and window"))
+	}
+	if mw.UnknownKeyRefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("the unknown key refresh interval %v is negative", mw.UnknownKeyRefreshInterval))
+	}
+	switch {
+	case mw.RefreshBefore < 0:
+		errs = append(errs, fmt.Errorf("the refresh before %v is negative", mw.RefreshBefore))
+	case mw.RefreshBefore > 0 && strings.HasPrefix(mw.TokenLookup, HEADER+":"):
+		errs = append(errs, errors.New("the automatic refresh is available in cookie mode only, not with a header token lookup"))
+	case mw.RefreshBefore > 0 && mw.ClientID == "":
+		errs = append(errs, errors.New("the automatic refresh requires the client ID"))
+	}
+	return errors.Join(errs...)
+}
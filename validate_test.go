@@ -0,0 +1,106 @@
+package jwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_Validate(t *testing.T) {
+	t.Logf("Given a valid configuration")
+	{
+		mw := &AuthMiddleware{
+			Region:        TestRegion,
+			UserPoolID:    TestUserPoolID,
+			Iss:           UserPool{Region: TestRegion, UserPoolID: TestUserPoolID}.Iss(),
+			TokenLookup:   "cookie:access_token",
+			ClientID:      "web",
+			RefreshBefore: time.Minute,
+			Routes:        RouteTable{"GET /orders/:id": {Scopes: []string{"orders/read"}}},
+			PublicRoutes:  []string{"GET /health", "/metrics"},
+		}
+		assert.NoError(t, mw.Validate())
+
+		mw.Iss = "http://localhost:9229/" + TestUserPoolID
+		assert.NoError(t, mw.Validate(), "the local emulators are served over http")
+
+		mw.StrictMode = true
+		mw.JWKURL = "http://127.0.0.1:9229/jwks.json"
+		err := mw.Validate()
+		assert.ErrorContains(t, err, "the issuer http://localhost:9229/"+TestUserPoolID+" is not an https URL", "not in strict mode")
+		assert.ErrorContains(t, err, `the json web key set url "http://127.0.0.1:9229/jwks.json" is not an absolute https URL`)
+	}
+
+	t.Logf("Given a configuration with many problems")
+	{
+		mw := &AuthMiddleware{
+			Region:           "europe",
+			UserPoolID:       "eu-west-1_testpool",
+			Iss:              "https://cognito-idp.eu-west-2.amazonaws.com/eu-west-2_other",
+			TokenLookup:      "query:token",
+			ClientSecret:     "secret",
+			MachineToMachine: true,
+			Required:         RouteRequirement{TokenUse: "id"},
+			Routes: RouteTable{
+				"GET /health": {Groups: []string{"ops"}},
+				"/orders":     {},
+				"GET /users":  {TokenUse: "refresh"},
+			},
+			PublicRoutes:              []string{"GET /health", "health"},
+			Timeout:                   -time.Second,
+			RefreshBefore:             -time.Second,
+			UnknownKeyRefreshInterval: -time.Second,
+			FailureLimiter:            NewFailureLimiter(nil, 5, time.Minute),
+			Lockout:                   NewFailureLimiter(NewMemoryFailureStore(), 0, time.Minute),
+			Fingerprint:               &FingerprintBinding{Store: NewMemoryFingerprintStore(), IPv4Prefix: 33},
+			CSRF:                      CSRFMode(7),
+		}
+		err := mw.Validate()
+		for _, problem := range []string{
+			`the region "europe" is not an AWS region`,
+			"the issuer https://cognito-idp.eu-west-2.amazonaws.com/eu-west-2_other is not the one of the user pool eu-west-1_testpool",
+			`the token lookup "query:token" is not of the form header:<name> or cookie:<name>`,
+			"the route GET /health is public, its requirements would never be enforced",
+			`the route "/orders" is not of the form "METHOD /path"`,
+			`route GET /users: the required token_use "refresh" is neither id nor access`,
+			`the public route "health" is neither of the form "/path" nor "METHOD /path"`,
+			"the client secret is set without the client ID",
+			"the id token_use can not be required",
+			"the timeout -1s is negative",
+			"the refresh before -1s is negative",
+			"the unknown key refresh interval -1s is negative",
+			"the failure limiter requires a store, a positive number of failures and window",
+			"the lockout requires a store, a positive number of failures and window",
+			"the fingerprint binding requires a store, prefixes of valid lengths and a part of the fingerprint",
+			"the csrf mode 7 is unknown",
+		} {
+			assert.ErrorContains(t, err, problem)
+		}
+	}
+
+	t.Logf("Given an issuer which is not an https URL")
+	{
+		for _, iss := range []string{"cognito-idp.eu-west-2.amazonaws.com", "http://issuer.example.com", "https://issuer.example.com/", "https://issuer.example.com?pool=x"} {
+			mw := &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID, Iss: iss}
+			assert.Error(t, mw.Validate(), iss)
+		}
+	}
+
+	t.Logf("Given conflicting login and refresh options")
+	{
+		mw := &AuthMiddleware{Region: TestRegion, UserPoolID: TestUserPoolID, BrowserLogin: true, MachineToMachine: true,
+			TokenLookup: "header:Authorization", RefreshBefore: time.Minute}
+		err := mw.Validate()
+		assert.ErrorContains(t, err, "the browser login is not available to the machine to machine tokens")
+		assert.ErrorContains(t, err, "the browser login requires the domain, the client ID and the redirect URL")
+		assert.ErrorContains(t, err, "the automatic refresh is available in cookie mode only")
+	}
+
+	t.Logf("Given an invalid configuration loaded from a file")
+	{
+		config := &Config{Region: TestRegion, UserPoolID: TestUserPoolID, Issuer: "issuer", ErrorMode: "quiet"}
+		err := config.Validate()
+		assert.ErrorContains(t, err, `the error mode "quiet" is neither verbose nor production`)
+		assert.ErrorContains(t, err, `the issuer "issuer" is not an absolute URL`)
+	}
+}
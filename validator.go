@@ -0,0 +1,37 @@
+package jwt
+
+import (
+	"context"
+	jwtgo "github.com/golang-jwt/jwt"
+)
+
+// Validator validates tokens outside of any HTTP request, e.g. tokens carried by SQS or Kafka messages
+// consumed by background workers. It shares the configuration and keys of the middleware it comes from.
+type Validator struct {
+	mw *AuthMiddleware
+}
+
+// Validator returns a Validator sharing the configuration and keys of the middleware
+func (mw *AuthMiddleware) Validator() *Validator {
+	mw.MiddlewareInit()
+	mw = mw.Freeze()
+	return &Validator{mw: mw}
+}
+
+// NewValidator creates a Validator for the given user pool, downloading its public json web keys
+func NewValidator(iss, userPoolID, region string) (*Validator, error) {
+	mw, err := AuthJWTMiddleware(iss, userPoolID, region)
+	if err != nil {
+		return nil, err
+	}
+	return mw.Validator(), nil
+}
+
+// Validate validates the signature and claims of the given token
+func (v *Validator) Validate(ctx context.Context, tokenStr string) (*Claims, error) {
+	token, err := v.mw.ValidateTokenContext(ctx, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClaims(token.Claims.(jwtgo.MapClaims)), nil
+}
@@ -0,0 +1,37 @@
+package jwt
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_ValidatorValidatesTokensOutsideHttpRequests(t *testing.T) {
+	t.Logf("Given a validator derived from the middleware")
+	{
+		validator := newTestMiddleware().Validator()
+
+		raw := testClaims()
+		raw[GroupsClaim] = []string{"workers"}
+		raw[ScopeClaim] = "jobs/run"
+		claims, err := validator.Validate(context.Background(), signToken(raw))
+		assert.Nil(t, err)
+		assert.Equal(t, "user-123", claims.Subject)
+		assert.Equal(t, "access", claims.TokenUse)
+		assert.Equal(t, "test-client", claims.ClientID)
+		assert.Equal(t, "jdoe", claims.Username)
+		assert.Equal(t, []string{"workers"}, claims.Groups)
+		assert.Equal(t, []string{"jobs/run"}, claims.Scopes)
+		assert.Equal(t, time.Unix(raw["exp"].(int64), 0), claims.ExpiresAt)
+		assert.Equal(t, "user-123", claims.Principal().ID())
+
+		_, err = validator.Validate(context.Background(), ExpiredCognitoToken)
+		assert.NotNil(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = validator.Validate(ctx, signToken(raw))
+		assert.Equal(t, context.Canceled, err)
+	}
+}
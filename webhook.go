@@ -0,0 +1,73 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookAuthorizer an Authorizer posting the AuthorizationRequest, the claims and the request metadata, to a
+// centralized entitlement service. The service answers with a 200 and a {"allowed": true|false} body, or a 403
+// to deny the request; any other answer is a failure, see AuthorizerFailOpen. Wrap it with NewCachedAuthorizer
+// to cache the decisions.
+type WebhookAuthorizer struct {
+
+	// URL the URL of the webhook
+	URL string
+
+	// Timeout the maximum duration of a call, 2 seconds by default
+	Timeout time.Duration
+
+	// Header additional headers of the calls, e.g. the credentials of the service
+	Header http.Header
+}
+
+// NewWebhookAuthorizer creates a WebhookAuthorizer calling the given URL
+func NewWebhookAuthorizer(url string, timeout time.Duration) *WebhookAuthorizer {
+	return &WebhookAuthorizer{URL: url, Timeout: timeout}
+}
+
+// Authorize posts the request to the webhook
+func (w *WebhookAuthorizer) Authorize(ctx context.Context, request AuthorizationRequest) (bool, error) {
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, values := range w.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	r, err := cognitoHTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("calling the authorization webhook: %w", err)
+	}
+	defer closeBody(r)
+	switch r.StatusCode {
+	case http.StatusOK:
+		var decision struct {
+			Allowed bool `json:"allowed"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+			return false, fmt.Errorf("decoding the authorization webhook response: %w", err)
+		}
+		return decision.Allowed, nil
+	case http.StatusForbidden:
+		return false, nil
+	}
+	return false, fmt.Errorf("the authorization webhook answered %s", r.Status)
+}
@@ -0,0 +1,51 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_WebhookAuthorizer(t *testing.T) {
+	t.Logf("Given an entitlement service allowing the admins")
+	{
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			assert.Equal(t, "Bearer service-key", r.Header.Get("Authorization"))
+			var request AuthorizationRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			assert.Equal(t, "/orders", request.Route)
+			assert.Equal(t, "user-123", request.Subject)
+			if request.Claims["username"] == "slow" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			json.NewEncoder(w).Encode(map[string]bool{"allowed": request.Claims["username"] == "admin"})
+		}))
+		defer server.Close()
+
+		webhook := NewWebhookAuthorizer(server.URL, 50*time.Millisecond)
+		webhook.Header = http.Header{"Authorization": {"Bearer service-key"}}
+		mw := newTestMiddleware()
+		mw.Authorizer = NewCachedAuthorizer(webhook, time.Minute, 100)
+		router := authzHandler(mw)
+
+		admin := testClaims()
+		admin["username"] = "admin"
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(admin)).Code)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(admin)).Code)
+		assert.Equal(t, 1, calls, "the decisions are cached")
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", signToken(testClaims())).Code)
+
+		slow := testClaims()
+		slow["username"] = "slow"
+		assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/orders", signToken(slow)).Code)
+
+		mw.AuthorizerFailOpen = true
+		router = authzHandler(mw)
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/orders", signToken(slow)).Code)
+	}
+}
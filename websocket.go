@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IsWebSocketUpgrade whether the request asks to upgrade the connection to a WebSocket
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") && headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// canWriteBody whether an error body can be written: WebSocket clients never read the body of a failed
+// handshake, and nothing can be written once the handler has hijacked the connection
+func canWriteBody(c *gin.Context) bool {
+	return !c.Writer.Written() && !IsWebSocketUpgrade(c.Request)
+}
+
+// WatchExpiry returns a channel closed once the token of the request expires, checked every interval
+// against TimeFunc. Long lived connections such as WebSockets use it to close the connection when the
+// token they were opened with is no longer valid. The watch stops when ctx is done.
+func (mw *AuthMiddleware) WatchExpiry(ctx context.Context, c *gin.Context, interval time.Duration) <-chan struct{} {
+	expired := make(chan struct{})
+	expiry, ok := c.Get(TokenExpiryKey)
+	if !ok {
+		close(expired)
+		return expired
+	}
+	exp := expiry.(time.Time)
+	now := mw.now
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if !now().Before(exp) {
+				close(expired)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return expired
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
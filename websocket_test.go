@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_WebSocketHandshakeRejectionHasNoBody(t *testing.T) {
+	t.Logf("Given a WebSocket upgrade request without token")
+	{
+		router := ginHandler(newTestMiddleware())
+		req, _ := http.NewRequest("GET", "/auth/list", nil)
+		req.Header.Set("Connection", "keep-alive, Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.NotEmpty(t, w.Header().Get(AuthenticateHeader))
+	}
+}
+
+func Test_WatchExpiry(t *testing.T) {
+	t.Logf("Given a connection opened with a token expiring in the future")
+	{
+		now := time.Now()
+		var elapsed int64
+		mw := newTestMiddleware()
+		mw.TimeFunc = func() time.Time { return now.Add(time.Duration(atomic.LoadInt64(&elapsed))) }
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(TokenExpiryKey, now.Add(time.Minute))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		expired := mw.WatchExpiry(ctx, c, time.Millisecond)
+
+		select {
+		case <-expired:
+			t.Fatalf("\t\t The token should not have expired yet. %v", BallotX)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		atomic.StoreInt64(&elapsed, int64(2*time.Minute))
+		select {
+		case <-expired:
+			t.Logf("\t\t The token should have expired. %v", CheckMark)
+		case <-time.After(time.Second):
+			t.Errorf("\t\t The token should have expired. %v", BallotX)
+		}
+	}
+}
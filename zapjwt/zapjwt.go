@@ -0,0 +1,33 @@
+// Package zapjwt adapts a zap logger to the jwt.Logger interface, the key/value pairs of the middleware
+// logs becoming zap fields.
+package zapjwt
+
+import (
+	jwt "github.com/akhettar/gin-jwt-cognito"
+	"go.uber.org/zap"
+)
+
+type logger struct {
+	sugar *zap.SugaredLogger
+}
+
+// New returns a jwt.Logger writing to the given zap logger
+func New(l *zap.Logger) jwt.Logger {
+	return &logger{sugar: l.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+func (l *logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.sugar.Debugw(msg, keysAndValues...)
+}
+
+func (l *logger) Info(msg string, keysAndValues ...interface{}) {
+	l.sugar.Infow(msg, keysAndValues...)
+}
+
+func (l *logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.sugar.Warnw(msg, keysAndValues...)
+}
+
+func (l *logger) Error(msg string, keysAndValues ...interface{}) {
+	l.sugar.Errorw(msg, keysAndValues...)
+}
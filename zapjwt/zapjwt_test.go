@@ -0,0 +1,28 @@
+package zapjwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+)
+
+func Test_ZapLogger(t *testing.T) {
+	t.Logf("Given a jwt.Logger backed by a zap logger")
+	{
+		core, logs := observer.New(zapcore.DebugLevel)
+		logger := New(zap.New(core))
+
+		logger.Debug("Validated the jwt token", "sub", "user-123")
+		logger.Warn("Failed to validate the jwt token", "error_class", "expired")
+
+		entries := logs.AllUntimed()
+		assert.Len(t, entries, 2)
+		assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+		assert.Equal(t, map[string]interface{}{"sub": "user-123"}, entries[0].ContextMap())
+		assert.Equal(t, zapcore.WarnLevel, entries[1].Level)
+		assert.Equal(t, "Failed to validate the jwt token", entries[1].Message)
+		assert.Equal(t, map[string]interface{}{"error_class": "expired"}, entries[1].ContextMap())
+	}
+}